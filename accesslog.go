@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ACCESS_LOG_GIN    = "gin"
+	ACCESS_LOG_JSON   = "json"
+	ACCESS_LOG_COMMON = "common" // the Apache Common Log Format
+)
+
+// ACCESS_LOG_SKIP_PATHS are the paths not logged by default (the probes and the scrapes)
+const ACCESS_LOG_SKIP_PATHS = "/health,/ready,/metrics"
+
+// ACCESS_LOG_SECRET_PARAMETERS are the query parameters redacted in the access logs (the token of the input adapters)
+var ACCESS_LOG_SECRET_PARAMETERS = []string{"token"}
+
+// redactQuery redacts the secret query parameters of a logged path (ex: /inputs/grafana?token=...)
+func redactQuery(path string) string {
+	i := strings.Index(path, "?")
+	if i < 0 {
+		return path
+	}
+	parameters := strings.Split(path[i+1:], "&")
+	for j, parameter := range parameters {
+		name := strings.SplitN(parameter, "=", 2)[0]
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		for _, secret := range ACCESS_LOG_SECRET_PARAMETERS {
+			if name == secret {
+				parameters[j] = strings.SplitN(parameter, "=", 2)[0] + "=" + REDACTED
+			}
+		}
+	}
+	return path[:i+1] + strings.Join(parameters, "&")
+}
+
+// redactAccessLog wraps an access log formatter, redacting the secret query parameters
+func redactAccessLog(formatter gin.LogFormatter) gin.LogFormatter {
+	return func(params gin.LogFormatterParams) string {
+		params.Path = redactQuery(params.Path)
+		return formatter(params)
+	}
+}
+
+// ginAccessLog is the default gin format (gin.LoggerWithConfig does not export it)
+func ginAccessLog(params gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if params.IsOutputColor() {
+		statusColor = params.StatusCodeColor()
+		methodColor = params.MethodColor()
+		resetColor = params.ResetColor()
+	}
+
+	if params.Latency > time.Minute {
+		params.Latency = params.Latency - params.Latency%time.Second
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %s\n%s",
+		params.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, params.StatusCode, resetColor,
+		params.Latency,
+		params.ClientIP,
+		methodColor, params.Method, resetColor,
+		params.Path,
+		params.ErrorMessage,
+	)
+}
+
+// accessLogEntry is an access log line, in the json format
+type accessLogEntry struct {
+	Time      string  `json:"time"`
+	Status    int     `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	ClientIP  string  `json:"client_ip"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Size      int     `json:"size"`
+	UserAgent string  `json:"user_agent,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func jsonAccessLog(params gin.LogFormatterParams) string {
+	line, _ := json.Marshal(&accessLogEntry{
+		Time:      params.TimeStamp.Format(time.RFC3339),
+		Status:    params.StatusCode,
+		LatencyMs: float64(params.Latency) / float64(time.Millisecond),
+		ClientIP:  params.ClientIP,
+		Method:    params.Method,
+		Path:      params.Path,
+		Size:      params.BodySize,
+		UserAgent: params.Request.UserAgent(),
+		Error:     strings.TrimSpace(params.ErrorMessage),
+	})
+	return string(line) + "\n"
+}
+
+func commonAccessLog(params gin.LogFormatterParams) string {
+	return fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %d\n",
+		params.ClientIP,
+		params.TimeStamp.Format("02/Jan/2006:15:04:05 -0700"),
+		params.Method,
+		params.Path,
+		params.Request.Proto,
+		params.StatusCode,
+		params.BodySize,
+	)
+}
+
+// NewAccessLogger creates the access logger of the http endpoints, in the given format ([gin|json|common]),
+// written to output and skipping the skipPaths (the token query parameters being redacted)
+func NewAccessLogger(format string, output io.Writer, skipPaths []string) (gin.HandlerFunc, error) {
+	config := gin.LoggerConfig{Output: output, SkipPaths: skipPaths}
+	switch format {
+	case ACCESS_LOG_GIN:
+		config.Formatter = ginAccessLog
+	case ACCESS_LOG_JSON:
+		config.Formatter = jsonAccessLog
+	case ACCESS_LOG_COMMON:
+		config.Formatter = commonAccessLog
+	default:
+		return nil, fmt.Errorf("unknown access log format '%s' (gin, json or common)", format)
+	}
+	config.Formatter = redactAccessLog(config.Formatter)
+	return gin.LoggerWithConfig(config), nil
+}
+
+// splitList parses a comma separated list (ignoring the empty items)
+func splitList(value string) []string {
+	paths := make([]string, 0)
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLogger(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := NewAccessLogger(ACCESS_LOG_JSON, &output, splitList(" /health, ,/ready"))
+	assert.Nil(t, err)
+
+	router := PrepareGinRouter(&PrometheusCachetConfig{AccessLogger: logger})
+	for _, path := range []string{"/health", "/ready", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	var entry accessLogEntry
+	assert.Nil(t, json.Unmarshal(output.Bytes(), &entry))
+	assert.Equal(t, "/nowhere", entry.Path)
+	assert.Equal(t, http.StatusNotFound, entry.Status)
+
+	output.Reset()
+	logger, err = NewAccessLogger(ACCESS_LOG_COMMON, &output, nil)
+	assert.Nil(t, err)
+	router = PrepareGinRouter(&PrometheusCachetConfig{AccessLogger: logger})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	assert.True(t, strings.HasSuffix(output.String(), `"GET /health HTTP/1.1" 200 16`+"\n"), output.String())
+
+	// the token of the input adapters is not logged
+	for _, format := range []string{ACCESS_LOG_GIN, ACCESS_LOG_JSON, ACCESS_LOG_COMMON} {
+		output.Reset()
+		logger, err = NewAccessLogger(format, &output, nil)
+		assert.Nil(t, err)
+		router = PrepareGinRouter(&PrometheusCachetConfig{AccessLogger: logger})
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nowhere?a=1&token=s3cr3t", nil))
+		assert.NotContains(t, output.String(), "s3cr3t", format)
+		assert.Contains(t, output.String(), "a=1", format)
+	}
+	assert.Equal(t, "/inputs/grafana?token=<redacted>&x=token", redactQuery("/inputs/grafana?token=abc&x=token"))
+	assert.Equal(t, "/health", redactQuery("/health"))
+
+	_, err = NewAccessLogger("xml", &output, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,467 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: alerts.proto
+
+// The gRPC alert submission of the bridge: the same payloads (and the same processing) as the
+// Alertmanager webhook (POST /alert), for the internal producers preferring gRPC.
+// Generate alerts.pb.go with protoc-gen-go 1.3.2: protoc --go_out=plugins=grpc:. alerts.proto
+
+package alertpb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Alert struct {
+	Labels      map[string]string `protobuf:"bytes,1,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Annotations map[string]string `protobuf:"bytes,2,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// RFC 3339 dates
+	StartsAt string `protobuf:"bytes,3,opt,name=starts_at,json=startsAt,proto3" json:"starts_at,omitempty"`
+	EndsAt   string `protobuf:"bytes,4,opt,name=ends_at,json=endsAt,proto3" json:"ends_at,omitempty"`
+	// optional: a hash of the labels if empty
+	Fingerprint          string   `protobuf:"bytes,5,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Alert) Reset()         { *m = Alert{} }
+func (m *Alert) String() string { return proto.CompactTextString(m) }
+func (*Alert) ProtoMessage()    {}
+func (*Alert) Descriptor() ([]byte, []int) {
+	return fileDescriptor_20493709c38b81dc, []int{0}
+}
+
+func (m *Alert) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Alert.Unmarshal(m, b)
+}
+func (m *Alert) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Alert.Marshal(b, m, deterministic)
+}
+func (m *Alert) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Alert.Merge(m, src)
+}
+func (m *Alert) XXX_Size() int {
+	return xxx_messageInfo_Alert.Size(m)
+}
+func (m *Alert) XXX_DiscardUnknown() {
+	xxx_messageInfo_Alert.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Alert proto.InternalMessageInfo
+
+func (m *Alert) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *Alert) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+func (m *Alert) GetStartsAt() string {
+	if m != nil {
+		return m.StartsAt
+	}
+	return ""
+}
+
+func (m *Alert) GetEndsAt() string {
+	if m != nil {
+		return m.EndsAt
+	}
+	return ""
+}
+
+func (m *Alert) GetFingerprint() string {
+	if m != nil {
+		return m.Fingerprint
+	}
+	return ""
+}
+
+// SubmitAlertsRequest mirrors the Alertmanager webhook payload (version 4)
+type SubmitAlertsRequest struct {
+	Version  string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	GroupKey string `protobuf:"bytes,2,opt,name=group_key,json=groupKey,proto3" json:"group_key,omitempty"`
+	// firing or resolved
+	Status               string            `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Receiver             string            `protobuf:"bytes,4,opt,name=receiver,proto3" json:"receiver,omitempty"`
+	GroupLabels          map[string]string `protobuf:"bytes,5,rep,name=group_labels,json=groupLabels,proto3" json:"group_labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	CommonLabels         map[string]string `protobuf:"bytes,6,rep,name=common_labels,json=commonLabels,proto3" json:"common_labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	CommonAnnotations    map[string]string `protobuf:"bytes,7,rep,name=common_annotations,json=commonAnnotations,proto3" json:"common_annotations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	ExternalUrl          string            `protobuf:"bytes,8,opt,name=external_url,json=externalUrl,proto3" json:"external_url,omitempty"`
+	Alerts               []*Alert          `protobuf:"bytes,9,rep,name=alerts,proto3" json:"alerts,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *SubmitAlertsRequest) Reset()         { *m = SubmitAlertsRequest{} }
+func (m *SubmitAlertsRequest) String() string { return proto.CompactTextString(m) }
+func (*SubmitAlertsRequest) ProtoMessage()    {}
+func (*SubmitAlertsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_20493709c38b81dc, []int{1}
+}
+
+func (m *SubmitAlertsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubmitAlertsRequest.Unmarshal(m, b)
+}
+func (m *SubmitAlertsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubmitAlertsRequest.Marshal(b, m, deterministic)
+}
+func (m *SubmitAlertsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubmitAlertsRequest.Merge(m, src)
+}
+func (m *SubmitAlertsRequest) XXX_Size() int {
+	return xxx_messageInfo_SubmitAlertsRequest.Size(m)
+}
+func (m *SubmitAlertsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubmitAlertsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubmitAlertsRequest proto.InternalMessageInfo
+
+func (m *SubmitAlertsRequest) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *SubmitAlertsRequest) GetGroupKey() string {
+	if m != nil {
+		return m.GroupKey
+	}
+	return ""
+}
+
+func (m *SubmitAlertsRequest) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *SubmitAlertsRequest) GetReceiver() string {
+	if m != nil {
+		return m.Receiver
+	}
+	return ""
+}
+
+func (m *SubmitAlertsRequest) GetGroupLabels() map[string]string {
+	if m != nil {
+		return m.GroupLabels
+	}
+	return nil
+}
+
+func (m *SubmitAlertsRequest) GetCommonLabels() map[string]string {
+	if m != nil {
+		return m.CommonLabels
+	}
+	return nil
+}
+
+func (m *SubmitAlertsRequest) GetCommonAnnotations() map[string]string {
+	if m != nil {
+		return m.CommonAnnotations
+	}
+	return nil
+}
+
+func (m *SubmitAlertsRequest) GetExternalUrl() string {
+	if m != nil {
+		return m.ExternalUrl
+	}
+	return ""
+}
+
+func (m *SubmitAlertsRequest) GetAlerts() []*Alert {
+	if m != nil {
+		return m.Alerts
+	}
+	return nil
+}
+
+// AlertReport is what the bridge did with an alert
+type AlertReport struct {
+	Label                string   `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`
+	Components           []string `protobuf:"bytes,2,rep,name=components,proto3" json:"components,omitempty"`
+	Error                string   `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Skipped              bool     `protobuf:"varint,4,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	Silenced             []string `protobuf:"bytes,5,rep,name=silenced,proto3" json:"silenced,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AlertReport) Reset()         { *m = AlertReport{} }
+func (m *AlertReport) String() string { return proto.CompactTextString(m) }
+func (*AlertReport) ProtoMessage()    {}
+func (*AlertReport) Descriptor() ([]byte, []int) {
+	return fileDescriptor_20493709c38b81dc, []int{2}
+}
+
+func (m *AlertReport) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AlertReport.Unmarshal(m, b)
+}
+func (m *AlertReport) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AlertReport.Marshal(b, m, deterministic)
+}
+func (m *AlertReport) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AlertReport.Merge(m, src)
+}
+func (m *AlertReport) XXX_Size() int {
+	return xxx_messageInfo_AlertReport.Size(m)
+}
+func (m *AlertReport) XXX_DiscardUnknown() {
+	xxx_messageInfo_AlertReport.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AlertReport proto.InternalMessageInfo
+
+func (m *AlertReport) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func (m *AlertReport) GetComponents() []string {
+	if m != nil {
+		return m.Components
+	}
+	return nil
+}
+
+func (m *AlertReport) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *AlertReport) GetSkipped() bool {
+	if m != nil {
+		return m.Skipped
+	}
+	return false
+}
+
+func (m *AlertReport) GetSilenced() []string {
+	if m != nil {
+		return m.Silenced
+	}
+	return nil
+}
+
+type SubmitAlertsResponse struct {
+	// OK, or paused (the payload is queued until the forwarding is resumed)
+	Status               string         `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Alerts               []*AlertReport `protobuf:"bytes,2,rep,name=alerts,proto3" json:"alerts,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *SubmitAlertsResponse) Reset()         { *m = SubmitAlertsResponse{} }
+func (m *SubmitAlertsResponse) String() string { return proto.CompactTextString(m) }
+func (*SubmitAlertsResponse) ProtoMessage()    {}
+func (*SubmitAlertsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_20493709c38b81dc, []int{3}
+}
+
+func (m *SubmitAlertsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubmitAlertsResponse.Unmarshal(m, b)
+}
+func (m *SubmitAlertsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubmitAlertsResponse.Marshal(b, m, deterministic)
+}
+func (m *SubmitAlertsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubmitAlertsResponse.Merge(m, src)
+}
+func (m *SubmitAlertsResponse) XXX_Size() int {
+	return xxx_messageInfo_SubmitAlertsResponse.Size(m)
+}
+func (m *SubmitAlertsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubmitAlertsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubmitAlertsResponse proto.InternalMessageInfo
+
+func (m *SubmitAlertsResponse) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *SubmitAlertsResponse) GetAlerts() []*AlertReport {
+	if m != nil {
+		return m.Alerts
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Alert)(nil), "prometheus_cachethq.Alert")
+	proto.RegisterMapType((map[string]string)(nil), "prometheus_cachethq.Alert.AnnotationsEntry")
+	proto.RegisterMapType((map[string]string)(nil), "prometheus_cachethq.Alert.LabelsEntry")
+	proto.RegisterType((*SubmitAlertsRequest)(nil), "prometheus_cachethq.SubmitAlertsRequest")
+	proto.RegisterMapType((map[string]string)(nil), "prometheus_cachethq.SubmitAlertsRequest.CommonAnnotationsEntry")
+	proto.RegisterMapType((map[string]string)(nil), "prometheus_cachethq.SubmitAlertsRequest.CommonLabelsEntry")
+	proto.RegisterMapType((map[string]string)(nil), "prometheus_cachethq.SubmitAlertsRequest.GroupLabelsEntry")
+	proto.RegisterType((*AlertReport)(nil), "prometheus_cachethq.AlertReport")
+	proto.RegisterType((*SubmitAlertsResponse)(nil), "prometheus_cachethq.SubmitAlertsResponse")
+}
+
+func init() { proto.RegisterFile("alerts.proto", fileDescriptor_20493709c38b81dc) }
+
+var fileDescriptor_20493709c38b81dc = []byte{
+	// 572 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xcd, 0x6e, 0xd3, 0x40,
+	0x10, 0x96, 0x53, 0xe2, 0xc4, 0x63, 0x23, 0xb5, 0xdb, 0xaa, 0x58, 0x41, 0x42, 0x21, 0x07, 0x14,
+	0x84, 0x94, 0x43, 0xb8, 0xb4, 0x3d, 0xb4, 0x0a, 0x3f, 0xe2, 0x00, 0x5c, 0x5c, 0x71, 0x41, 0x48,
+	0x91, 0xe3, 0x0c, 0x89, 0x55, 0x67, 0xd7, 0xdd, 0x5d, 0x47, 0xe4, 0x29, 0x78, 0x32, 0x1e, 0x84,
+	0xb7, 0x40, 0x3b, 0xeb, 0xa4, 0x6e, 0x1a, 0xaa, 0xf8, 0xe6, 0xf9, 0x3c, 0xf3, 0xcd, 0xee, 0x7c,
+	0xdf, 0x2c, 0x04, 0x71, 0x86, 0x52, 0xab, 0x41, 0x2e, 0x85, 0x16, 0xec, 0x38, 0x97, 0x62, 0x81,
+	0x7a, 0x8e, 0x85, 0x1a, 0x27, 0x71, 0x32, 0x47, 0x3d, 0xbf, 0xed, 0xfd, 0x6d, 0x40, 0x73, 0x64,
+	0xb2, 0xd8, 0x25, 0xb8, 0x59, 0x3c, 0xc1, 0x4c, 0x85, 0x4e, 0xf7, 0xa0, 0xef, 0x0f, 0x5f, 0x0d,
+	0x76, 0xe4, 0x0f, 0x28, 0x77, 0xf0, 0x85, 0x12, 0x3f, 0x72, 0x2d, 0x57, 0x51, 0x59, 0xc5, 0xbe,
+	0x82, 0x1f, 0x73, 0x2e, 0x74, 0xac, 0x53, 0xc1, 0x55, 0xd8, 0x20, 0x92, 0x37, 0x8f, 0x90, 0x8c,
+	0xee, 0xb2, 0x2d, 0x53, 0xb5, 0x9e, 0x3d, 0x07, 0x4f, 0xe9, 0x58, 0x6a, 0x35, 0x8e, 0x75, 0x78,
+	0xd0, 0x75, 0xfa, 0x5e, 0xd4, 0xb6, 0xc0, 0x48, 0xb3, 0x67, 0xd0, 0x42, 0x3e, 0xa5, 0x5f, 0x4f,
+	0xe8, 0x97, 0x6b, 0xc2, 0x91, 0x66, 0x5d, 0xf0, 0x7f, 0xa6, 0x7c, 0x86, 0x32, 0x97, 0x29, 0xd7,
+	0x61, 0x93, 0x7e, 0x56, 0xa1, 0xce, 0x39, 0xf8, 0x95, 0xd3, 0xb3, 0x43, 0x38, 0xb8, 0xc1, 0x55,
+	0xe8, 0x50, 0xa2, 0xf9, 0x64, 0x27, 0xd0, 0x5c, 0xc6, 0x59, 0x81, 0x61, 0x83, 0x30, 0x1b, 0x5c,
+	0x34, 0xce, 0x9c, 0xce, 0x25, 0x1c, 0x6e, 0x9f, 0xb9, 0x4e, 0x7d, 0xef, 0x4f, 0x13, 0x8e, 0xaf,
+	0x8b, 0xc9, 0x22, 0xd5, 0x34, 0x00, 0x15, 0xe1, 0x6d, 0x81, 0x4a, 0xb3, 0x10, 0x5a, 0x4b, 0x94,
+	0x2a, 0x15, 0xbc, 0xe4, 0x59, 0x87, 0x66, 0x08, 0x33, 0x29, 0x8a, 0x7c, 0x6c, 0x7a, 0x58, 0xbe,
+	0x36, 0x01, 0x9f, 0x71, 0xc5, 0x4e, 0xc1, 0x55, 0x3a, 0xd6, 0x85, 0x2a, 0xc7, 0x53, 0x46, 0xac,
+	0x03, 0x6d, 0x89, 0x09, 0xa6, 0x4b, 0x94, 0xe5, 0x74, 0x36, 0x31, 0xfb, 0x01, 0x81, 0x25, 0x2c,
+	0xa5, 0x6e, 0x92, 0x4a, 0xe7, 0x3b, 0x55, 0xda, 0x71, 0xd4, 0xc1, 0x27, 0x53, 0x5c, 0x55, 0xdf,
+	0x9f, 0xdd, 0x21, 0x6c, 0x0c, 0x4f, 0x13, 0xb1, 0x58, 0x08, 0xbe, 0xa6, 0x77, 0x89, 0xfe, 0x62,
+	0x6f, 0xfa, 0xf7, 0x54, 0x5d, 0xe5, 0x0f, 0x92, 0x0a, 0xc4, 0x38, 0xb0, 0xb2, 0x41, 0xd5, 0x6a,
+	0x2d, 0xea, 0x72, 0x55, 0xb3, 0xcb, 0x03, 0xfb, 0x1d, 0x25, 0xdb, 0x38, 0x7b, 0x09, 0x01, 0xfe,
+	0xd2, 0x28, 0x79, 0x9c, 0x8d, 0x0b, 0x99, 0x85, 0x6d, 0xeb, 0xa7, 0x35, 0xf6, 0x4d, 0x66, 0x6c,
+	0x08, 0xae, 0xdd, 0xb2, 0xd0, 0xa3, 0x63, 0x74, 0xfe, 0xef, 0xf8, 0xa8, 0xcc, 0x34, 0x46, 0xda,
+	0x1e, 0x64, 0x2d, 0x23, 0x5e, 0xc1, 0xd1, 0x83, 0x49, 0xd5, 0x22, 0xf8, 0x00, 0xa7, 0xbb, 0x87,
+	0x50, 0xcb, 0xcf, 0xbf, 0x1d, 0xf0, 0xed, 0xc5, 0x30, 0x17, 0x52, 0x9b, 0x4c, 0xd2, 0xbd, 0xac,
+	0xb6, 0x01, 0x7b, 0x01, 0x90, 0x88, 0x45, 0x2e, 0x38, 0x72, 0x6d, 0x9f, 0x05, 0x2f, 0xaa, 0x20,
+	0xa6, 0x0a, 0xa5, 0x14, 0xb2, 0x74, 0xb1, 0x0d, 0xcc, 0x4e, 0xa8, 0x9b, 0x34, 0xcf, 0x71, 0x4a,
+	0x1e, 0x6e, 0x47, 0xeb, 0xd0, 0xd8, 0x5b, 0xa5, 0x19, 0xf2, 0x04, 0xa7, 0x64, 0x5f, 0x2f, 0xda,
+	0xc4, 0xbd, 0x39, 0x9c, 0xdc, 0x17, 0x5c, 0xe5, 0x82, 0x2b, 0xac, 0xac, 0x8a, 0x73, 0x6f, 0x55,
+	0xce, 0x36, 0xe2, 0xd9, 0xe7, 0xaa, 0xfb, 0x88, 0x78, 0x74, 0xc7, 0xb5, 0x84, 0x43, 0x05, 0x01,
+	0xc1, 0xd7, 0x28, 0x97, 0x69, 0x82, 0x2c, 0x81, 0xa0, 0xda, 0x99, 0xf5, 0xf7, 0x75, 0x63, 0xe7,
+	0xf5, 0x1e, 0x99, 0xf6, 0x1a, 0xef, 0xbc, 0xef, 0x2d, 0x6a, 0x9f, 0x4f, 0x26, 0x2e, 0xbd, 0xe9,
+	0x6f, 0xff, 0x0d, 0x00, 0x14, 0x6b, 0x09, 0x5b, 0xe3, 0x05, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// AlertServiceClient is the client API for AlertService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type AlertServiceClient interface {
+	// SubmitAlerts forwards a payload of alerts to CachetHQ. The prometheus_token is sent in the
+	// authorization metadata ("Bearer <token>"). The errors are INVALID_ARGUMENT (invalid payload),
+	// UNAUTHENTICATED (wrong token), UNAVAILABLE (transient CachetHQ error, to retry) or INTERNAL
+	SubmitAlerts(ctx context.Context, in *SubmitAlertsRequest, opts ...grpc.CallOption) (*SubmitAlertsResponse, error)
+}
+
+type alertServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAlertServiceClient(cc *grpc.ClientConn) AlertServiceClient {
+	return &alertServiceClient{cc}
+}
+
+func (c *alertServiceClient) SubmitAlerts(ctx context.Context, in *SubmitAlertsRequest, opts ...grpc.CallOption) (*SubmitAlertsResponse, error) {
+	out := new(SubmitAlertsResponse)
+	err := c.cc.Invoke(ctx, "/prometheus_cachethq.AlertService/SubmitAlerts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AlertServiceServer is the server API for AlertService service.
+type AlertServiceServer interface {
+	// SubmitAlerts forwards a payload of alerts to CachetHQ. The prometheus_token is sent in the
+	// authorization metadata ("Bearer <token>"). The errors are INVALID_ARGUMENT (invalid payload),
+	// UNAUTHENTICATED (wrong token), UNAVAILABLE (transient CachetHQ error, to retry) or INTERNAL
+	SubmitAlerts(context.Context, *SubmitAlertsRequest) (*SubmitAlertsResponse, error)
+}
+
+// UnimplementedAlertServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedAlertServiceServer struct {
+}
+
+func (*UnimplementedAlertServiceServer) SubmitAlerts(ctx context.Context, req *SubmitAlertsRequest) (*SubmitAlertsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitAlerts not implemented")
+}
+
+func RegisterAlertServiceServer(s *grpc.Server, srv AlertServiceServer) {
+	s.RegisterService(&_AlertService_serviceDesc, srv)
+}
+
+func _AlertService_SubmitAlerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitAlertsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AlertServiceServer).SubmitAlerts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/prometheus_cachethq.AlertService/SubmitAlerts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AlertServiceServer).SubmitAlerts(ctx, req.(*SubmitAlertsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _AlertService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "prometheus_cachethq.AlertService",
+	HandlerType: (*AlertServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitAlerts",
+			Handler:    _AlertService_SubmitAlerts_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "alerts.proto",
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// AlertVolume pushes the number of incidents created by the bridge to a CachetHQ metric (with the sum calculation),
+// for the "incidents per day" graph of the status page: a point for each incident, or a point per interval
+type AlertVolume struct {
+	cachet   Cachet
+	metricID int
+	interval time.Duration // 0: a point (of 1) for each incident
+
+	mutex sync.Mutex
+	count int
+}
+
+// NewAlertVolume creates a new AlertVolume pushing to the CachetHQ metric metricID, every interval (if not 0)
+func NewAlertVolume(cachet Cachet, metricID int, interval time.Duration) *AlertVolume {
+	return &AlertVolume{cachet: cachet, metricID: metricID, interval: interval}
+}
+
+// Created counts an incident created
+func (v *AlertVolume) Created() {
+	if v == nil {
+		return
+	}
+	if v.interval == 0 {
+		v.add(1, time.Now())
+		return
+	}
+	v.mutex.Lock()
+	v.count++
+	v.mutex.Unlock()
+}
+
+func (v *AlertVolume) add(count int, timestamp time.Time) {
+	if err := v.cachet.AddMetricPoint(v.metricID, float64(count), timestamp); err != nil {
+		log.Println("not able to add a point to the CachetHQ metric", v.metricID, ":", err)
+	}
+}
+
+// flush pushes the incidents counted since the previous flush (if any)
+func (v *AlertVolume) flush(now time.Time) {
+	v.mutex.Lock()
+	count := v.count
+	v.count = 0
+	v.mutex.Unlock()
+
+	if count > 0 {
+		v.add(count, now)
+	}
+}
+
+// Run pushes the count every interval, until stop is closed (and then pushes the last count)
+func (v *AlertVolume) Run(stop <-chan struct{}) {
+	if v.interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			v.flush(time.Now())
+			return
+		case now := <-ticker.C:
+			v.flush(now)
+		}
+	}
+}
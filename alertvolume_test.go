@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertVolume(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	cachet := NewCachetImpl(ts.URL, "undefined", ts.Client())
+
+	// a point for each incident
+	config := &PrometheusCachetConfig{LabelName: "alertname", Cachet: cachet, AlertVolume: NewAlertVolume(cachet, 1, 0)}
+	incidentAction(config, []string{"API"}, 1, "", METRIC_INCIDENT_CREATED)
+	incidentAction(config, []string{"API"}, 1, "", METRIC_INCIDENT_RESOLVED)
+	incidentAction(config, []string{"API"}, 2, "", METRIC_INCIDENT_CREATED)
+	points := fake.MetricPoints(1)
+	assert.Equal(t, 2, len(points))
+	assert.Equal(t, float64(1), points[0].Value)
+
+	// a point per interval
+	config.AlertVolume = NewAlertVolume(cachet, 2, time.Hour)
+	incidentAction(config, []string{"API"}, 3, "", METRIC_INCIDENT_CREATED)
+	incidentAction(config, []string{"API"}, 4, "", METRIC_INCIDENT_CREATED)
+	assert.Equal(t, 0, len(fake.MetricPoints(2)))
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	config.AlertVolume.flush(now)
+	config.AlertVolume.flush(now.Add(time.Hour))
+	assert.Equal(t, []cachetHqMetricPoint{{Value: 2, Timestamp: now.Unix()}}, fake.MetricPoints(2))
+
+	// disabled
+	config.AlertVolume = nil
+	incidentAction(config, []string{"API"}, 5, "", METRIC_INCIDENT_CREATED)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AZURE_COMMON_SCHEMA is the schemaId of the Azure Monitor common alert schema
+const AZURE_COMMON_SCHEMA = "azureMonitorCommonAlertSchema"
+
+// cf https://learn.microsoft.com/en-us/azure/azure-monitor/alerts/alerts-common-schema
+type azureAlert struct {
+	SchemaID string `json:"schemaId"`
+	Data     struct {
+		Essentials struct {
+			AlertID            string   `json:"alertId"`
+			AlertRule          string   `json:"alertRule"`
+			Severity           string   `json:"severity"`         // Sev0 to Sev4
+			MonitorCondition   string   `json:"monitorCondition"` // Fired or Resolved
+			ConfigurationItems []string `json:"configurationItems"`
+			Description        string   `json:"description"`
+			FiredDateTime      string   `json:"firedDateTime"`
+		} `json:"essentials"`
+		CustomProperties map[string]string `json:"customProperties"`
+	} `json:"data"`
+}
+
+// azureSeverities are the severity labels of the Azure severities
+var azureSeverities = map[string]string{
+	"Sev0": "critical",
+	"Sev1": "error",
+	"Sev2": "warning",
+	"Sev3": "informational",
+	"Sev4": "verbose",
+}
+
+// AzureAdapter translates the Azure Monitor alerts (with the common alert schema)
+type AzureAdapter struct{}
+
+// Parse translates a fired (or resolved) alert: the impacted component is the cachet_component custom property,
+// else the first configuration item (the resource), or the alert rule
+func (a *AzureAdapter) Parse(config *PrometheusCachetConfig, body []byte) (*PrometheusAlert, error) {
+	var alert azureAlert
+	if err := json.Unmarshal(body, &alert); err != nil {
+		return nil, err
+	}
+	if alert.SchemaID != AZURE_COMMON_SCHEMA {
+		return nil, fmt.Errorf("schemaId '%s' is not supported: enable the common alert schema on the action group", alert.SchemaID)
+	}
+	essentials := &alert.Data.Essentials
+	if essentials.AlertID == "" {
+		return nil, fmt.Errorf("alertId is required")
+	}
+	var firing bool
+	switch essentials.MonitorCondition {
+	case "Fired":
+		firing = true
+	case "Resolved":
+	default:
+		return nil, fmt.Errorf("unknown monitorCondition '%s' (Fired or Resolved)", essentials.MonitorCondition)
+	}
+
+	component := essentials.AlertRule
+	if len(essentials.ConfigurationItems) > 0 && essentials.ConfigurationItems[0] != "" {
+		component = essentials.ConfigurationItems[0]
+	}
+	if value := alert.Data.CustomProperties[INPUT_COMPONENT_TAG]; value != "" {
+		component = value
+	}
+	if component == "" {
+		return nil, fmt.Errorf("no alertRule, configurationItems nor %s custom property", INPUT_COMPONENT_TAG)
+	}
+
+	// the resolution of an alert has its alertId
+	alerts := newInputAlert(config, "azure", firing, component, essentials.AlertID)
+	detail := &alerts.Alerts[0]
+	if severity, ok := azureSeverities[essentials.Severity]; ok {
+		detail.Labels[LABEL_SEVERITY] = severity
+	}
+	if essentials.Description != "" {
+		detail.Annotations[ANNOTATION_SUMMARY] = essentials.Description
+	} else if essentials.AlertRule != "" {
+		detail.Annotations[ANNOTATION_SUMMARY] = essentials.AlertRule
+	}
+	if fired, err := time.Parse(time.RFC3339, essentials.FiredDateTime); err == nil {
+		detail.StartAt = fired.UTC().Format(time.RFC3339)
+	}
+	return alerts, nil
+}
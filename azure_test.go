@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAzureAdapter(t *testing.T) {
+	config := &PrometheusCachetConfig{LabelName: "alertname"}
+	adapter := &AzureAdapter{}
+
+	alerts, err := adapter.Parse(config, []byte(`{"schemaId":"azureMonitorCommonAlertSchema","data":{"essentials":{"alertId":"/subscriptions/1/providers/Microsoft.AlertsManagement/alerts/b9569717","alertRule":"High CPU","severity":"Sev1","monitorCondition":"Fired","configurationItems":["vm-web01"],"firedDateTime":"2020-01-01T10:00:00.3713213Z"}}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "firing", alerts.Status)
+	assert.Equal(t, "vm-web01", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "error", alerts.Alerts[0].Labels[LABEL_SEVERITY])
+	assert.Equal(t, "High CPU", alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY])
+	assert.Equal(t, "2020-01-01T10:00:00Z", alerts.Alerts[0].StartAt)
+	assert.Equal(t, "azure:/subscriptions/1/providers/Microsoft.AlertsManagement/alerts/b9569717", alerts.Alerts[0].fingerprint())
+
+	alerts, err = adapter.Parse(config, []byte(`{"schemaId":"azureMonitorCommonAlertSchema","data":{"essentials":{"alertId":"/subscriptions/1/providers/Microsoft.AlertsManagement/alerts/b9569717","alertRule":"High CPU","monitorCondition":"Resolved","configurationItems":["vm-web01"]},"customProperties":{"cachet_component":"Website"}}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "resolved", alerts.Status)
+	assert.Equal(t, "Website", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "azure:/subscriptions/1/providers/Microsoft.AlertsManagement/alerts/b9569717", alerts.Alerts[0].fingerprint())
+
+	// the legacy schemas
+	_, err = adapter.Parse(config, []byte(`{"schemaId":"AzureMonitorMetricAlert","data":{}}`))
+	assert.NotNil(t, err)
+	_, err = adapter.Parse(config, []byte(`{"schemaId":"azureMonitorCommonAlertSchema","data":{"essentials":{"alertId":"1","alertRule":"High CPU","monitorCondition":"Acknowledged"}}}`))
+	assert.NotNil(t, err)
+}
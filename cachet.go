@@ -2,47 +2,257 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
+const (
+	CACHETHQ_AUTH_TOKEN  = "token"
+	CACHETHQ_AUTH_BEARER = "bearer"
+	CACHETHQ_AUTH_BASIC  = "basic"
+
+	// a hung CachetHQ must not block the watchdog
+	CACHETHQ_PING_TIMEOUT = 10 * time.Second
+	// how long the writes are paused when CachetHQ rate limits the API without Retry-After, and at most
+	CACHETHQ_RATE_LIMIT_PAUSE     = 30 * time.Second
+	CACHETHQ_RATE_LIMIT_MAX_PAUSE = time.Hour
+)
+
+// CachetHTTPError is returned when CachetHQ answers with an unexpected http status code
+type CachetHTTPError struct {
+	StatusCode int
+	Body       string
+	// the Retry-After of a 429 (0 if none)
+	RetryAfter time.Duration
+}
+
+func (e *CachetHTTPError) Error() string {
+	return fmt.Sprintf("CachetHQ returned %d: %s", e.StatusCode, e.Body)
+}
+
+// RateLimited returns true (with how long to wait) if CachetHQ is rate limiting the API: its Retry-After, or
+// CACHETHQ_RATE_LIMIT_PAUSE without
+func RateLimited(err error) (time.Duration, bool) {
+	httpError, ok := err.(*CachetHTTPError)
+	if !ok || httpError.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if httpError.RetryAfter <= 0 {
+		return CACHETHQ_RATE_LIMIT_PAUSE, true
+	}
+	if httpError.RetryAfter > CACHETHQ_RATE_LIMIT_MAX_PAUSE {
+		return CACHETHQ_RATE_LIMIT_MAX_PAUSE, true
+	}
+	return httpError.RetryAfter, true
+}
+
+// parseRetryAfter parses a Retry-After header: a number of seconds, or an http date (0 if invalid, or past)
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// IsTransientError returns true if the error is worth a retry: CachetHQ is not reachable (timeout, connection
+// refused or reset), is rate limiting, or answers with a 5xx. A TLS (i.e. certificate) error is not transient
+func IsTransientError(err error) bool {
+	if httpError, ok := err.(*CachetHTTPError); ok {
+		return httpError.StatusCode == http.StatusTooManyRequests || httpError.StatusCode >= 500
+	}
+	if urlError, ok := err.(*url.Error); ok {
+		err = urlError.Err
+	}
+	if netError, ok := err.(net.Error); ok && netError.Timeout() {
+		return true
+	}
+	switch e := err.(type) {
+	case *net.OpError:
+		// a TLS alert sent by CachetHQ
+		return e.Op != "remote error"
+	case *net.DNSError:
+		return true
+	}
+	// the connection was closed by CachetHQ
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+// IncidentOptions are the optional attributes of a new incident
+type IncidentOptions struct {
+	// Stickied incidents are pinned at the top of the status page
+	Stickied bool
+	// Fingerprint of the alert, embedded in the incident message (cf IncidentMarker)
+	Fingerprint string
+	// Investigating incidents start as "Investigating" instead of "Identified" (i.e. the route escalates them)
+	Investigating bool
+	// Private incidents are not visible on the status page, and do not change the status of the component while firing
+	Private bool
+	// Name and Message replace the default texts of the incident (cf IncidentTemplates)
+	Name    string
+	Message string
+	// Footnote is appended to the message of the incident (ex: the occurrence counter of the hidden incidents)
+	Footnote string
+	// Notify emails the CachetHQ subscribers of the component (never for the private incidents)
+	Notify bool
+}
+
 type CachetIncident struct {
 	Id          int    `json:"id"`
 	ComponentId int    `json:"component_id"`
+	Name        string `json:"name"`
+	Message     string `json:"message"`
 	Status      int    `json:"status"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
 }
 
+// IncidentFilter are the criteria of an incident search
+type IncidentFilter struct {
+	ComponentID int
+	// Status (if not 0) keeps only the incidents with this status
+	Status int
+	// Open keeps only the incidents not fixed yet (investigating, identified or watching)
+	Open bool
+	// Since (if not zero) keeps only the incidents created after this date
+	Since time.Time
+}
+
+// OPEN_INCIDENT_STATUSES are the statuses of an incident not fixed yet
+var OPEN_INCIDENT_STATUSES = []int{1, 2, 3}
+
+// match checks the filter on the client side, in case CachetHQ ignored some query parameters (loc being the CachetHQ timezone)
+func (f IncidentFilter) match(incident *CachetIncident, loc *time.Location) bool {
+	if f.ComponentID != 0 && incident.ComponentId != f.ComponentID {
+		return false
+	}
+	if f.Status != 0 && incident.Status != f.Status {
+		return false
+	}
+	if f.Open && incident.Status == 4 {
+		return false
+	}
+	return f.Since.IsZero() || !f.before(incident, loc)
+}
+
+// before returns true if the incident was created before f.Since
+func (f IncidentFilter) before(incident *CachetIncident, loc *time.Location) bool {
+	createdAt, err := ParseCachetTime(incident.CreatedAt, loc)
+	return err == nil && createdAt.Before(f.Since)
+}
+
+type CachetComponent struct {
+	Id          int          `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Link        string       `json:"link"`
+	Status      int          `json:"status"`
+	GroupId     int          `json:"group_id"`
+	Tags        cachetHqTags `json:"tags"`
+	UpdatedAt   string       `json:"updated_at"`
+}
+
 // Cachet is a facade to CachetHQ client calls
 type Cachet interface {
+	// Ping checks that CachetHQ is reachable via a GET /api/v1/ping (within CACHETHQ_PING_TIMEOUT)
+	Ping() error
+
 	// List will fetch the different CachetHQ components (id/name) via a GET /api/v1/components
 	// it will return a map[componentname]componentid
 	ListComponents() (map[string]int, error)
 
+	// ListComponentsDetails is like ListComponents, but returns the whole components (with their tags)
+	ListComponentsDetails() ([]*CachetComponent, error)
+
+	// ListComponentGroups will fetch the different CachetHQ component groups via a GET /api/v1/components/groups
+	// it will return a map[groupname]groupid
+	ListComponentGroups() (map[string]int, error)
+
 	SearchComponent(name string) (int, error)
 
+	// SetComponentEnabled shows (or hides) a component via a PUT /api/v1/components/<componentid>
+	SetComponentEnabled(componentID int, enabled bool) error
+
+	// SetComponentStatus changes the status of a component via a PUT /api/v1/components/<componentid>
+	// component status: https://docs.cachethq.io/docs/component-statuses
+	SetComponentStatus(componentID, componentStatus int) error
+
+	// SetComponentDetails changes the description and/or the link (if not empty) of a component via a PUT /api/v1/components/<componentid>
+	SetComponentDetails(componentID int, description, link string) error
+
+	// CreateComponent creates a new (operational) component, in the group groupID (if not 0), via a POST /api/v1/components
+	// it returns the id of the new component
+	CreateComponent(name, description string, groupID int) (int, error)
+
+	// CreateComponentGroup creates a new component group via a POST /api/v1/components/groups
+	// it returns the id of the new group
+	CreateComponentGroup(name string) (int, error)
+
+	// SetComponentGroup moves a component to the group groupID (0 for none) via a PUT /api/v1/components/<componentid>
+	SetComponentGroup(componentID, groupID int) error
+
+	// RestoreComponentDetails sets back the description and the link (even empty) of a component via a PUT /api/v1/components/<componentid>
+	RestoreComponentDetails(componentID int, description, link string) error
+
 	// Return an incident
 	ReadIncident(incidentId int) (*CachetIncident, error)
 
-	// Returns all incidents for a given component, ASC sorted (i.e. the last incident, is the first in the list)
-	SearchIncidents(componentId int) ([]*CachetIncident, error)
+	// Returns all incidents matching the filter (following the pagination), DESC sorted (i.e. the last incident, is the first in the list)
+	SearchIncidents(filter IncidentFilter) ([]*CachetIncident, error)
 
 	// CreateIncident will create a new incident for the choosen CachetHQ components (id/name) via a POST /api/v1/incidents
 	// component status: component status: https://docs.cachethq.io/docs/component-statuses
 	// - status = 1 for alert resolved
 	// - status = 4 for alert fatal
-	CreateIncident(componentName string, componentID, status int, componentStatus int) error
+	// it returns the id of the new incident
+	CreateIncident(componentName string, componentID, status int, componentStatus int, options IncidentOptions) (int, error)
 
-	// UpdateIncident will create a new incident update for the choosen CachetHQ components (id/name) via a PUT /api/v1/incidents/<incidentid>
+	// UpdateIncident changes the status of the incident (and of its component) via a PUT /api/v1/incidents/<incidentid>,
+	// the message being posted as an incident update (cf CreateIncidentUpdate). The incident is renamed name, or
+	// "<component> down" / "<component> up" if empty
 	// component status: component status: https://docs.cachethq.io/docs/component-statuses
 	// - status = 1 for alert resolved
 	// - status = 4 for alert fatal
-	UpdateIncident(componentName string, componentID, incidentId, status int, message string) error
+	UpdateIncident(componentName string, componentID, incidentId, status int, name, message string) error
+
+	// SetIncidentStatus changes only the incident status and message via a PUT /api/v1/incidents/<incidentid>
+	// incident status: https://docs.cachethq.io/docs/incident-statuses
+	SetIncidentStatus(incidentId, incidentStatus int, message string) error
+
+	// CreateIncidentUpdate adds an entry to the timeline of the incident, and changes its status, via a POST
+	// /api/v1/incidents/<incidentid>/updates. The incident message (and the updates written by hand) are left alone.
+	// Before CachetHQ 2.4 (without incident updates), the message is appended to the incident message instead
+	// incident status: https://docs.cachethq.io/docs/incident-statuses
+	CreateIncidentUpdate(incidentId, incidentStatus int, message string) error
+
+	// DeleteIncident deletes an incident via a DELETE /api/v1/incidents/<incidentid>
+	DeleteIncident(incidentId int) error
+
+	// PublishIncident makes a hidden incident visible, and changes the status of its component (and the incident status, if not 0)
+	// via a PUT /api/v1/incidents/<incidentid>
+	PublishIncident(incidentId, componentID, componentStatus, incidentStatus int) error
+
+	// ListSchedules fetches the scheduled maintenances (CachetHQ 2.4+) via a GET /api/v1/schedules
+	ListSchedules() ([]*CachetSchedule, error)
+
+	// AddMetricPoint adds a point to a metric via a POST /api/v1/metrics/<metricid>/points
+	AddMetricPoint(metricID int, value float64, timestamp time.Time) error
 }
 
 // cf https://docs.cachethq.io/reference#update-a-component
@@ -68,6 +278,45 @@ type cachetHqMessage struct {
 	Status int `json:"status"`
 }
 
+type cachetHqComponentEnabled struct {
+	Enabled bool `json:"enabled"`
+}
+
+type cachetHqComponentDetails struct {
+	Description string `json:"description,omitempty"`
+	Link        string `json:"link,omitempty"`
+}
+
+type cachetHqComponentDetailsRestore struct {
+	Description string `json:"description"`
+	Link        string `json:"link"`
+}
+
+// cf https://docs.cachethq.io/reference#create-component
+type cachetHqComponentCreate struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Status      int    `json:"status"`
+	GroupId     int    `json:"group_id,omitempty"`
+	Enabled     bool   `json:"enabled"`
+}
+
+type cachetHqComponentGroup struct {
+	GroupId int `json:"group_id"`
+}
+
+// cf https://docs.cachethq.io/reference#post-componentgroups
+type cachetHqComponentGroupCreate struct {
+	Name string `json:"name"`
+}
+
+// the id of a created component (or group)
+type cachetHqCreated struct {
+	Data struct {
+		Id int `json:"id"`
+	} `json:"data"`
+}
+
 // cf https://docs.cachethq.io/reference#get-components
 // {
 //    "meta": {
@@ -103,6 +352,92 @@ type cachetHqMessage struct {
 //    ]
 //}
 type cachetHqComponentList struct {
+	Meta struct {
+		Pagination struct {
+			CurrentPage int `json:"current_page"`
+			TotalPages  int `json:"total_pages"`
+		} `json:"pagination"`
+	} `json:"meta"`
+	Data []CachetComponent `json:"data"`
+}
+
+// cachetHqTags can be either a {"slug": "Tag Name"} object, or
+// a [{"slug": "Tag Name"}] list (depending on the CachetHQ version)
+type cachetHqTags map[string]string
+
+func (t *cachetHqTags) UnmarshalJSON(data []byte) error {
+	tags := make(map[string]string)
+
+	var object map[string]string
+	if err := json.Unmarshal(data, &object); err == nil {
+		*t = object
+		return nil
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, item := range list {
+		var name string
+		if err := json.Unmarshal(item, &name); err == nil {
+			tags[name] = name
+			continue
+		}
+		if err := json.Unmarshal(item, &object); err != nil {
+			return err
+		}
+		for slug, name := range object {
+			tags[slug] = name
+		}
+	}
+	*t = tags
+	return nil
+}
+
+// SCHEDULE_COMPLETE is the status of a (past) scheduled maintenance (0 is upcoming, 1 in progress)
+const SCHEDULE_COMPLETE = 2
+
+// CachetSchedule is a scheduled maintenance (CompletedAt being zero if its end is unknown)
+type CachetSchedule struct {
+	Id           int
+	Name         string
+	Status       int
+	ScheduledAt  time.Time
+	CompletedAt  time.Time
+	ComponentIDs []int
+}
+
+// cf https://docs.cachethq.io/reference#schedules
+type cachetHqScheduleList struct {
+	Meta struct {
+		Pagination struct {
+			CurrentPage int `json:"current_page"`
+			TotalPages  int `json:"total_pages"`
+		} `json:"pagination"`
+	} `json:"meta"`
+	Data []struct {
+		Id          int    `json:"id"`
+		Name        string `json:"name"`
+		Status      int    `json:"status"`
+		ScheduledAt string `json:"scheduled_at"`
+		CompletedAt string `json:"completed_at"`
+		// the schedule components (with a component_id), or the components themselves
+		Components []struct {
+			Id          int `json:"id"`
+			ComponentId int `json:"component_id"`
+		} `json:"components"`
+	} `json:"data"`
+}
+
+// cf https://docs.cachethq.io/reference#post-metric-points
+type cachetHqMetricPoint struct {
+	Value     float64 `json:"value"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// cf https://docs.cachethq.io/reference#get-componentgroups
+type cachetHqComponentGroupList struct {
 	Meta struct {
 		Pagination struct {
 			CurrentPage int `json:"current_page"`
@@ -132,17 +467,44 @@ type cachetHqIncidentRead struct {
 // cf https://docs.cachethq.io/reference#incidents
 type cachetHqIncident struct {
 	Name            string `json:"name"`
-	Message         string `json:"message"`
+	Message         string `json:"message,omitempty"` // not changed by the updates (cf CreateIncidentUpdate)
 	Status          int    `json:"status"`
-	Visible         int    `json:"visible"`
+	Visible         *int   `json:"visible,omitempty"` // not changed by the updates
 	ComponentID     int    `json:"component_id"`
 	ComponentStatus int    `json:"component_status"`
+	Stickied        *bool  `json:"stickied,omitempty"`
+	Notify          *bool  `json:"notify,omitempty"`
+}
+
+type cachetHqIncidentStatus struct {
+	Status  int    `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type cachetHqIncidentUpdate struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+type cachetHqIncidentVisible struct {
+	Visible         int `json:"visible"`
+	ComponentID     int `json:"component_id"`
+	ComponentStatus int `json:"component_status"`
+	Status          int `json:"status,omitempty"`
 }
 
 type CachetImpl struct {
 	apiURL string
 	apiKey string
 	client *http.Client
+	// version of the CachetHQ server (nil if unknown)
+	version *CachetVersion
+	// timezone of the CachetHQ dates
+	location *time.Location
+	// how apiKey is sent to CachetHQ: [token|bearer|basic]
+	authScheme string
+	userAgent  string
+	headers    http.Header
 }
 
 // NewCachetImpl creates a new Cachet interface implementation
@@ -151,87 +513,249 @@ func NewCachetImpl(apiURL, apiKey string, client *http.Client) *CachetImpl {
 	apiURL = strings.TrimRight(apiURL, "/")
 
 	return &CachetImpl{
-		apiURL: apiURL,
-		apiKey: apiKey,
-		client: client,
+		apiURL:     apiURL,
+		apiKey:     apiKey,
+		client:     client,
+		location:   time.UTC,
+		authScheme: CACHETHQ_AUTH_TOKEN,
+		userAgent:  "prometheus-cachethq",
+		headers:    http.Header{},
+	}
+}
+
+// SetLocation changes the timezone of the CachetHQ dates (the APP_TIMEZONE of CachetHQ)
+func (c *CachetImpl) SetLocation(location *time.Location) {
+	c.location = location
+}
+
+// SetUserAgent changes the User-Agent sent to CachetHQ
+func (c *CachetImpl) SetUserAgent(userAgent string) {
+	c.userAgent = userAgent
+}
+
+// SetHeaders adds static headers to all the requests sent to CachetHQ (i.e. for an access proxy)
+func (c *CachetImpl) SetHeaders(headers http.Header) {
+	c.headers = headers
+}
+
+// ParseHeaders parses a comma separated list of "Name: value" headers
+func ParseHeaders(list string) (http.Header, error) {
+	headers := http.Header{}
+	for _, header := range strings.Split(list, ",") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+		i := strings.Index(header, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("wrong header '%s', expecting 'Name: value'", strings.TrimSpace(header))
+		}
+		headers.Add(strings.TrimSpace(header[:i]), strings.TrimSpace(header[i+1:]))
+	}
+	return headers, nil
+}
+
+// SetAuthScheme changes how the api key is sent to CachetHQ:
+// - token (default): X-Cachet-Token header
+// - bearer: Authorization: Bearer header
+// - basic: http basic authentication, the api key being "user:password"
+func (c *CachetImpl) SetAuthScheme(scheme string) error {
+	switch scheme {
+	case CACHETHQ_AUTH_TOKEN, CACHETHQ_AUTH_BEARER:
+	case CACHETHQ_AUTH_BASIC:
+		if !strings.Contains(c.apiKey, ":") {
+			return fmt.Errorf("the CachetHQ token must be 'user:password' with the basic authentication")
+		}
+	default:
+		return fmt.Errorf("unknown CachetHQ authentication scheme '%s'", scheme)
+	}
+	c.authScheme = scheme
+	return nil
+}
+
+// prepare adds the static headers, the User-Agent, and the authentication to a request
+func (c *CachetImpl) prepare(req *http.Request) {
+	for name, values := range c.headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	switch c.authScheme {
+	case CACHETHQ_AUTH_BEARER:
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	case CACHETHQ_AUTH_BASIC:
+		i := strings.Index(c.apiKey, ":")
+		req.SetBasicAuth(c.apiKey[:i], c.apiKey[i+1:])
+	default:
+		req.Header.Set("X-Cachet-Token", c.apiKey)
 	}
 }
 
 func (c *CachetImpl) ListComponents() (map[string]int, error) {
+	components, err := c.ListComponentsDetails()
+	if err != nil {
+		return nil, err
+	}
+
 	componentsID := make(map[string]int)
-	var message cachetHqComponentList
+	for _, component := range components {
+		componentsID[component.Name] = component.Id
+	}
+	return componentsID, nil
+}
 
-	// we loop "only" on the max first 100 pages
-	for page := 1; page < 100; page++ {
-		nextPage := fmt.Sprintf("%s/api/v1/components?page=%d", c.apiURL, page)
+// do sends a request to the CachetHQ API (path being i.e. "/api/v1/components"): body (if not nil) is sent as json,
+// and the json answer is decoded into out (if not nil)
+func (c *CachetImpl) do(method, path string, body, out interface{}) error {
+	return c.doContext(context.Background(), method, path, body, out)
+}
 
-		req, err := http.NewRequest(http.MethodGet, nextPage, nil)
-		if err != nil {
-			return nil, err
+func (c *CachetImpl) doContext(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
 		}
+		reader = &buf
+	}
 
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Cachet-Token", c.apiKey)
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	c.prepare(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-		resp, err := c.client.Do(req)
+	b, err := ioutil.ReadAll(resp.Body)
+	// (the deletes answer a 204)
+	if resp.StatusCode != 200 && resp.StatusCode != http.StatusNoContent {
 		if err != nil {
-			return nil, err
+			return err
 		}
-		defer resp.Body.Close()
+		log.Println(string(b))
+		return &CachetHTTPError{StatusCode: resp.StatusCode, Body: string(b), RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	}
+	if err != nil {
+		return err
+	}
 
-		body, err := ioutil.ReadAll(resp.Body)
-		if resp.StatusCode != 200 {
-			if err != nil {
-				return nil, err
-			}
-			log.Println(string(body))
-		}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
 
-		if err := json.Unmarshal(body, &message); err != nil {
+func (c *CachetImpl) ListComponentsDetails() ([]*CachetComponent, error) {
+	components := make([]*CachetComponent, 0)
+
+	// we loop "only" on the max first 100 pages
+	for page := 1; page <= 100; page++ {
+		var message cachetHqComponentList
+		if err := c.do(http.MethodGet, fmt.Sprintf("/api/v1/components?page=%d", page), nil, &message); err != nil {
 			return nil, err
 		}
 
 		for _, data := range message.Data {
-			componentsID[data.Name] = data.Id
+			copydata := data
+			components = append(components, &copydata)
 		}
 
 		// is there a next page?
 		if message.Meta.Pagination.CurrentPage >= message.Meta.Pagination.TotalPages {
 			// nope
-			return componentsID, nil
+			return components, nil
 		}
 	}
-	return componentsID, nil
+	return components, nil
 }
 
-func (c *CachetImpl) SearchComponent(name string) (int, error) {
-	var message cachetHqComponentList
+func (c *CachetImpl) ListComponentGroups() (map[string]int, error) {
+	groupsID := make(map[string]int)
 
-	page := fmt.Sprintf("%s/api/v1/components?name=%s&page=1", c.apiURL, name)
+	// we loop "only" on the max first 100 pages
+	for page := 1; page <= 100; page++ {
+		var message cachetHqComponentGroupList
+		if err := c.do(http.MethodGet, fmt.Sprintf("/api/v1/components/groups?page=%d", page), nil, &message); err != nil {
+			return nil, err
+		}
 
-	req, err := http.NewRequest(http.MethodGet, page, nil)
-	if err != nil {
-		return -1, err
+		for _, data := range message.Data {
+			groupsID[data.Name] = data.Id
+		}
+
+		// is there a next page?
+		if message.Meta.Pagination.CurrentPage >= message.Meta.Pagination.TotalPages {
+			// nope
+			return groupsID, nil
+		}
 	}
+	return groupsID, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Cachet-Token", c.apiKey)
+func (c *CachetImpl) ListSchedules() ([]*CachetSchedule, error) {
+	schedules := make([]*CachetSchedule, 0)
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return -1, err
-	}
-	defer resp.Body.Close()
+	// we loop "only" on the max first 100 pages
+	for page := 1; page <= 100; page++ {
+		var message cachetHqScheduleList
+		if err := c.do(http.MethodGet, fmt.Sprintf("/api/v1/schedules?page=%d", page), nil, &message); err != nil {
+			return nil, err
+		}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode != 200 {
-		if err != nil {
-			return -1, err
+		for _, data := range message.Data {
+			scheduledAt, err := ParseCachetTime(data.ScheduledAt, c.location)
+			if err != nil {
+				return nil, fmt.Errorf("schedule %d: %v", data.Id, err)
+			}
+			schedule := &CachetSchedule{
+				Id:           data.Id,
+				Name:         data.Name,
+				Status:       data.Status,
+				ScheduledAt:  scheduledAt,
+				ComponentIDs: make([]int, 0, len(data.Components)),
+			}
+			if data.CompletedAt != "" {
+				if schedule.CompletedAt, err = ParseCachetTime(data.CompletedAt, c.location); err != nil {
+					return nil, fmt.Errorf("schedule %d: %v", data.Id, err)
+				}
+			}
+			for _, component := range data.Components {
+				if component.ComponentId != 0 {
+					schedule.ComponentIDs = append(schedule.ComponentIDs, component.ComponentId)
+				} else {
+					schedule.ComponentIDs = append(schedule.ComponentIDs, component.Id)
+				}
+			}
+			schedules = append(schedules, schedule)
+		}
+
+		// is there a next page?
+		if message.Meta.Pagination.CurrentPage >= message.Meta.Pagination.TotalPages {
+			// nope
+			return schedules, nil
 		}
-		log.Println(string(body))
 	}
+	return schedules, nil
+}
+
+func (c *CachetImpl) AddMetricPoint(metricID int, value float64, timestamp time.Time) error {
+	return c.do(http.MethodPost, fmt.Sprintf("/api/v1/metrics/%d/points", metricID), &cachetHqMetricPoint{Value: value, Timestamp: timestamp.Unix()}, nil)
+}
 
-	if err := json.Unmarshal(body, &message); err != nil {
+func (c *CachetImpl) SearchComponent(name string) (int, error) {
+	var message cachetHqComponentList
+	if err := c.do(http.MethodGet, fmt.Sprintf("/api/v1/components?name=%s&page=1", name), nil, &message); err != nil {
 		return -1, err
 	}
 
@@ -242,10 +766,49 @@ func (c *CachetImpl) SearchComponent(name string) (int, error) {
 	return -1, fmt.Errorf("no component found")
 }
 
-func (c *CachetImpl) CreateIncident(componentName string, componentID, status int, componentStatus int) error {
+func (c *CachetImpl) SetComponentEnabled(componentID int, enabled bool) error {
+	return c.do(http.MethodPut, fmt.Sprintf("/api/v1/components/%d", componentID), &cachetHqComponentEnabled{Enabled: enabled}, nil)
+}
+
+func (c *CachetImpl) SetComponentStatus(componentID, componentStatus int) error {
+	return c.do(http.MethodPut, fmt.Sprintf("/api/v1/components/%d", componentID), &cachetHqMessage{Status: componentStatus}, nil)
+}
+
+func (c *CachetImpl) SetComponentDetails(componentID int, description, link string) error {
+	return c.do(http.MethodPut, fmt.Sprintf("/api/v1/components/%d", componentID), &cachetHqComponentDetails{Description: description, Link: link}, nil)
+}
+
+func (c *CachetImpl) CreateComponent(name, description string, groupID int) (int, error) {
+	var created cachetHqCreated
+	if err := c.do(http.MethodPost, "/api/v1/components", &cachetHqComponentCreate{Name: name, Description: description, Status: 1, GroupId: groupID, Enabled: true}, &created); err != nil {
+		return -1, err
+	}
+	return created.Data.Id, nil
+}
+
+func (c *CachetImpl) CreateComponentGroup(name string) (int, error) {
+	var created cachetHqCreated
+	if err := c.do(http.MethodPost, "/api/v1/components/groups", &cachetHqComponentGroupCreate{Name: name}, &created); err != nil {
+		return -1, err
+	}
+	return created.Data.Id, nil
+}
+
+func (c *CachetImpl) SetComponentGroup(componentID, groupID int) error {
+	return c.do(http.MethodPut, fmt.Sprintf("/api/v1/components/%d", componentID), &cachetHqComponentGroup{GroupId: groupID}, nil)
+}
+
+func (c *CachetImpl) RestoreComponentDetails(componentID int, description, link string) error {
+	return c.do(http.MethodPut, fmt.Sprintf("/api/v1/components/%d", componentID), &cachetHqComponentDetailsRestore{Description: description, Link: link}, nil)
+}
+
+func (c *CachetImpl) CreateIncident(componentName string, componentID, status int, componentStatus int, options IncidentOptions) (int, error) {
 	incidentName := fmt.Sprintf("%s down", componentName)
 	incidentMessage := fmt.Sprintf("Prometheus flagged service %s as down", componentName)
 	incidentStatus := 2 // "Identified"
+	if options.Investigating {
+		incidentStatus = 1 // "Investigating"
+	}
 
 	// if we are in status = 1 (alert resolved)
 	if status == 1 {
@@ -253,170 +816,186 @@ func (c *CachetImpl) CreateIncident(componentName string, componentID, status in
 		incidentMessage = fmt.Sprintf("Prometheus flagged service %s as recovered", componentName)
 		incidentStatus = 4 // "Fixed"
 	}
+	if options.Name != "" {
+		incidentName = options.Name
+	}
+	if options.Message != "" {
+		incidentMessage = options.Message
+	}
+	if options.Footnote != "" {
+		incidentMessage += "\n\n" + options.Footnote
+	}
+	incidentMessage += IncidentMarker(options.Fingerprint)
+
+	visible := 1
+	if options.Private {
+		visible = 0
+		if status != 1 {
+			componentStatus = 0 // unchanged
+		}
+	}
 
 	incident := &cachetHqIncident{
 		Name:            incidentName,
 		Message:         incidentMessage,
 		Status:          incidentStatus,
 		ComponentID:     componentID,
-		Visible:         1,
+		Visible:         &visible,
 		ComponentStatus: componentStatus,
 	}
-
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(incident); err != nil {
-		return err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/v1/incidents", c.apiURL), &buf)
-	if err != nil {
-		return err
+	// only the firing incidents are pinned (stickied incidents appeared with CachetHQ 2.4)
+	if status != 1 && options.Stickied && !options.Private && c.version.AtLeast(2, 4) {
+		stickied := true
+		incident.Stickied = &stickied
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Cachet-Token", c.apiKey)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return err
+	if options.Notify && !options.Private {
+		notify := true
+		incident.Notify = &notify
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		b, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		log.Println(string(b))
+	var created cachetHqIncidentRead
+	if err := c.do(http.MethodPost, "/api/v1/incidents", incident, &created); err != nil {
+		return -1, err
 	}
-	//body, _ := ioutil.ReadAll(resp.Body)
-	//log.Println("response from CachetHQ when sending alert: ", string(body))
-
-	return nil
+	return created.Data.Id, nil
 }
 
-func (c *CachetImpl) UpdateIncident(componentName string, componentID, incidentId, status int, message string) error {
+func (c *CachetImpl) UpdateIncident(componentName string, componentID, incidentId, status int, name, message string) error {
 	incidentName := fmt.Sprintf("%s down", componentName)
-	incidentMessage := message
 	incidentStatus := 2  // "Identified"
 	componentStatus := 4 // "Major Outage"
 
 	// if we are in status = 1 (alert resolved)
 	if status == 1 {
 		incidentName = fmt.Sprintf("%s up", componentName)
-		incidentMessage = message
 		incidentStatus = 4  // "Fixed"
 		componentStatus = 1 // "Operational"
 	}
+	if name != "" {
+		incidentName = name
+	}
+	if err := c.CreateIncidentUpdate(incidentId, incidentStatus, message); err != nil {
+		return err
+	}
 
 	incident := &cachetHqIncident{
 		Name:            incidentName,
-		Message:         incidentMessage,
 		Status:          incidentStatus,
 		ComponentID:     componentID,
-		Visible:         1,
 		ComponentStatus: componentStatus,
 	}
-
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(incident); err != nil {
-		return err
+	// a resolved incident is not pinned anymore
+	if status == 1 && c.version.AtLeast(2, 4) {
+		stickied := false
+		incident.Stickied = &stickied
 	}
 
-	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/api/v1/incidents/%d", c.apiURL, incidentId), &buf)
-	if err != nil {
-		return err
+	return c.do(http.MethodPut, fmt.Sprintf("/api/v1/incidents/%d", incidentId), incident, nil)
+}
+
+func (c *CachetImpl) SetIncidentStatus(incidentId, incidentStatus int, message string) error {
+	return c.do(http.MethodPut, fmt.Sprintf("/api/v1/incidents/%d", incidentId), &cachetHqIncidentStatus{Status: incidentStatus, Message: message}, nil)
+}
+
+func (c *CachetImpl) CreateIncidentUpdate(incidentId, incidentStatus int, message string) error {
+	if !c.version.AtLeast(2, 4) {
+		incident, err := c.ReadIncident(incidentId)
+		if err != nil {
+			return err
+		}
+		return c.SetIncidentStatus(incidentId, incidentStatus, incident.Message+"\n\n"+message)
 	}
+	return c.do(http.MethodPost, fmt.Sprintf("/api/v1/incidents/%d/updates", incidentId), &cachetHqIncidentUpdate{Status: incidentStatus, Message: message}, nil)
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Cachet-Token", c.apiKey)
+func (c *CachetImpl) PublishIncident(incidentId, componentID, componentStatus, incidentStatus int) error {
+	return c.do(http.MethodPut, fmt.Sprintf("/api/v1/incidents/%d", incidentId), &cachetHqIncidentVisible{Visible: 1, ComponentID: componentID, ComponentStatus: componentStatus, Status: incidentStatus}, nil)
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return err
+func (c *CachetImpl) SearchIncidents(filter IncidentFilter) ([]*CachetIncident, error) {
+	if !filter.Open || filter.Status != 0 {
+		return c.searchIncidents(filter)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		b, err := ioutil.ReadAll(resp.Body)
+	// CachetHQ filters only on one status: one search per open status, merged latest first
+	incidents := make([]*CachetIncident, 0)
+	for _, status := range OPEN_INCIDENT_STATUSES {
+		statusFilter := filter
+		statusFilter.Status = status
+		found, err := c.searchIncidents(statusFilter)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		log.Println(string(b))
+		incidents = append(incidents, found...)
 	}
-	return nil
+	sort.SliceStable(incidents, func(i, j int) bool { return incidents[i].Id > incidents[j].Id })
+	return incidents, nil
 }
 
-func (c *CachetImpl) SearchIncidents(componentId int) ([]*CachetIncident, error) {
+// searchIncidents searches the incidents, filtered by CachetHQ on the component, the status,
+// and the creation date (from CachetHQ 3.x: the 2.x API ignores it, the pages are then read until an older incident)
+func (c *CachetImpl) searchIncidents(filter IncidentFilter) ([]*CachetIncident, error) {
 	incidents := make([]*CachetIncident, 0)
-	var message cachetHqIncidemntsList
 
-	// pagination doesn't work
-	nextPage := fmt.Sprintf("%s/api/v1/incidents?component_id=%d&sort=id&order=desc&per_page=1000", c.apiURL, componentId)
-	req, err := http.NewRequest(http.MethodGet, nextPage, nil)
-	if err != nil {
-		return nil, err
+	query := url.Values{}
+	query.Set("sort", "id")
+	query.Set("order", "desc")
+	query.Set("per_page", "1000")
+	if filter.ComponentID != 0 {
+		query.Set("component_id", strconv.Itoa(filter.ComponentID))
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Cachet-Token", c.apiKey)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
+	if filter.Status != 0 {
+		query.Set("status", strconv.Itoa(filter.Status))
+	}
+	if !filter.Since.IsZero() {
+		loc := c.location
+		if loc == nil {
+			loc = time.UTC
+		}
+		query.Set("since", filter.Since.In(loc).Format(CACHET_TIME_LAYOUT))
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode != 200 {
-		if err != nil {
+	// we loop "only" on the max first 100 pages
+	for page := 1; page <= 100; page++ {
+		var message cachetHqIncidemntsList
+		query.Set("page", strconv.Itoa(page))
+		if err := c.do(http.MethodGet, "/api/v1/incidents?"+query.Encode(), nil, &message); err != nil {
 			return nil, err
 		}
-		log.Println(string(body))
-	}
 
-	if err := json.Unmarshal(body, &message); err != nil {
-		return nil, err
-	}
+		older := false
+		for _, data := range message.Data {
+			copydata := data
+			if filter.match(&copydata, c.location) {
+				incidents = append(incidents, &copydata)
+			}
+			older = older || (!filter.Since.IsZero() && filter.before(&copydata, c.location))
+		}
 
-	for _, data := range message.Data {
-		copydata := data
-		incidents = append(incidents, &copydata)
+		// is there a next page? (and the incidents are not already older than the filter)
+		if message.Meta.Pagination.CurrentPage >= message.Meta.Pagination.TotalPages || older {
+			// nope
+			return incidents, nil
+		}
 	}
-
 	return incidents, nil
 }
 
 func (c *CachetImpl) ReadIncident(incidentId int) (*CachetIncident, error) {
 	var incident cachetHqIncidentRead
-
-	request := fmt.Sprintf("%s/api/v1/incidents/%d", c.apiURL, incidentId)
-
-	req, err := http.NewRequest(http.MethodGet, request, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Cachet-Token", c.apiKey)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
+	if err := c.do(http.MethodGet, fmt.Sprintf("/api/v1/incidents/%d", incidentId), nil, &incident); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return &incident.Data, nil
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode != 200 {
-		if err != nil {
-			return nil, err
-		}
-		log.Println(string(body))
-	}
+func (c *CachetImpl) DeleteIncident(incidentId int) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/api/v1/incidents/%d", incidentId), nil, nil)
+}
 
-	if err := json.Unmarshal(body, &incident); err != nil {
-		return nil, err
-	}
+func (c *CachetImpl) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), CACHETHQ_PING_TIMEOUT)
+	defer cancel()
 
-	return &incident.Data, nil
+	return c.doContext(ctx, http.MethodGet, "/api/v1/ping", nil, nil)
 }
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -100,6 +102,18 @@ func TestCachetListComponents(t *testing.T) {
 					"human_status": "Fixed"
 				}
 			}`)
+		} else if r.Method == "POST" && r.URL.Path == "/api/v1/incidents/4/updates" {
+			w.Header().Set("Content-Type", "aplication/json")
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, `{
+					"data": {
+						"id": 1,
+						"incident_id": 4,
+						"status": 2,
+						"message": "message",
+						"created_at": "2015-08-01 12:00:00"
+					}
+				}`)
 		} else if r.Method == "PUT" && r.URL.Path == "/api/v1/incidents/4" {
 			w.Header().Set("Content-Type", "aplication/json")
 			w.WriteHeader(http.StatusOK)
@@ -133,21 +147,159 @@ func TestCachetListComponents(t *testing.T) {
 	assert.Equal(t, 1, len(listComponents))
 	assert.Equal(t, 1, listComponents["API"])
 
+	// test list components with their tags
+	components, err := cachet.ListComponentsDetails()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(components))
+	assert.Equal(t, "Tag Name", components[0].Tags["slug-of-tag"])
+
 	// test search component
 	componentID, err := cachet.SearchComponent("API")
 	assert.Nil(t, err)
 	assert.Equal(t, 1, componentID)
 
 	// test list incidents
-	listIncidents, err := cachet.SearchIncidents(1)
+	listIncidents, err := cachet.SearchIncidents(IncidentFilter{ComponentID: 1})
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(listIncidents))
 	assert.Equal(t, 2, listIncidents[0].Id)
 	assert.Equal(t, 1, listIncidents[0].Status)
 
-	err = cachet.CreateIncident("API", 1, 1, 4)
+	incidentID, err := cachet.CreateIncident("API", 1, 1, 4, IncidentOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, incidentID)
+
+	err = cachet.UpdateIncident("API", 1, 4, 4, "", "message")
+	assert.Nil(t, err)
+}
+
+func TestIsTransientError(t *testing.T) {
+	assert.True(t, IsTransientError(&CachetHTTPError{StatusCode: 502}))
+	assert.True(t, IsTransientError(&CachetHTTPError{StatusCode: 429}))
+	assert.False(t, IsTransientError(&CachetHTTPError{StatusCode: 401}))
+	assert.False(t, IsTransientError(fmt.Errorf("no component found")))
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	cachet := NewCachetImpl(down.URL, "undefined", &http.Client{})
+	_, err := cachet.ListComponents()
+	assert.True(t, IsTransientError(err))
+
+	// a certificate not trusted is not going to fix itself
+	untrusted := httptest.NewTLSServer(http.NotFoundHandler())
+	defer untrusted.Close()
+	cachet = NewCachetImpl(untrusted.URL, "undefined", &http.Client{})
+	_, err = cachet.ListComponents()
+	assert.NotNil(t, err)
+	assert.False(t, IsTransientError(err))
+
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer hung.Close()
+	cachet = NewCachetImpl(hung.URL, "undefined", &http.Client{Timeout: 10 * time.Millisecond})
+	_, err = cachet.ListComponents()
+	assert.True(t, IsTransientError(err))
+}
+
+func TestCachetSearchIncidentsPagination(t *testing.T) {
+	queries := make([]string, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/incidents", r.URL.Path)
+		queries = append(queries, r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/json")
+
+		// 2 pages, the open incident being on the second one
+		switch r.FormValue("page") {
+		case "1":
+			io.WriteString(w, `{"meta":{"pagination":{"current_page":1,"total_pages":2}},"data":[
+				{"id":4,"component_id":1,"status":4,"created_at":"2020-01-04 00:00:00"},
+				{"id":3,"component_id":1,"status":4,"created_at":"2020-01-03 00:00:00"}]}`)
+		case "2":
+			io.WriteString(w, `{"meta":{"pagination":{"current_page":2,"total_pages":2}},"data":[
+				{"id":2,"component_id":1,"status":2,"created_at":"2020-01-02 00:00:00"},
+				{"id":1,"component_id":1,"status":4,"created_at":"2020-01-01 00:00:00"}]}`)
+		default:
+			t.Error("unexpected page", r.FormValue("page"))
+		}
+	}))
+	defer ts.Close()
+
+	cachet := NewCachetImpl(ts.URL, "token", ts.Client())
+
+	incidents, err := cachet.SearchIncidents(IncidentFilter{ComponentID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(incidents))
+	assert.Equal(t, 2, len(queries))
+	assert.Contains(t, queries[0], "component_id=1")
+
+	// filtered on the client side too
+	queries = queries[:0]
+	incidents, err = cachet.SearchIncidents(IncidentFilter{ComponentID: 1, Status: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(incidents))
+	assert.Equal(t, 2, incidents[0].Id)
+	assert.Contains(t, queries[0], "status=2")
+
+	// no need to read the second page
+	queries = queries[:0]
+	incidents, err = cachet.SearchIncidents(IncidentFilter{ComponentID: 1, Since: time.Date(2020, 1, 3, 12, 0, 0, 0, time.UTC)})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(incidents))
+	assert.Equal(t, 4, incidents[0].Id)
+	assert.Equal(t, 1, len(queries))
+	assert.Contains(t, queries[0], "since=2020-01-03+12%3A00%3A00")
+}
+
+func TestCachetAuthScheme(t *testing.T) {
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer ts.Close()
+
+	cachet := NewCachetImpl(ts.URL, "secret", ts.Client())
+	assert.Nil(t, cachet.Ping())
+	assert.Equal(t, "secret", header.Get("X-Cachet-Token"))
+
+	assert.Nil(t, cachet.SetAuthScheme(CACHETHQ_AUTH_BEARER))
+	assert.Nil(t, cachet.Ping())
+	assert.Equal(t, "Bearer secret", header.Get("Authorization"))
+	assert.Equal(t, "", header.Get("X-Cachet-Token"))
+
+	assert.NotNil(t, cachet.SetAuthScheme(CACHETHQ_AUTH_BASIC))
+	assert.NotNil(t, cachet.SetAuthScheme("kerberos"))
+
+	cachet = NewCachetImpl(ts.URL, "user:pass:word", ts.Client())
+	assert.Nil(t, cachet.SetAuthScheme(CACHETHQ_AUTH_BASIC))
+	assert.Nil(t, cachet.Ping())
+	assert.Equal(t, "Basic dXNlcjpwYXNzOndvcmQ=", header.Get("Authorization"))
+}
+
+func TestCachetExtraHeaders(t *testing.T) {
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer ts.Close()
+
+	cachet := NewCachetImpl(ts.URL, "secret", ts.Client())
+	assert.Nil(t, cachet.Ping())
+	assert.Equal(t, "prometheus-cachethq", header.Get("User-Agent"))
+
+	headers, err := ParseHeaders("CF-Access-Client-Id: id.access, CF-Access-Client-Secret: s3cr3t:x")
 	assert.Nil(t, err)
+	cachet.SetHeaders(headers)
+	cachet.SetUserAgent("status-bridge/1.0")
+	assert.Nil(t, cachet.Ping())
+	assert.Equal(t, "status-bridge/1.0", header.Get("User-Agent"))
+	assert.Equal(t, "id.access", header.Get("CF-Access-Client-Id"))
+	assert.Equal(t, "s3cr3t:x", header.Get("CF-Access-Client-Secret"))
+	assert.Equal(t, "secret", header.Get("X-Cachet-Token"))
 
-	err = cachet.UpdateIncident("API", 1, 4, 4, "message")
+	_, err = ParseHeaders("no value")
+	assert.NotNil(t, err)
+	headers, err = ParseHeaders("")
 	assert.Nil(t, err)
+	assert.Equal(t, 0, len(headers))
 }
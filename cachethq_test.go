@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -21,6 +22,12 @@ var (
 
 	// status updated by cachetHQ bridge
 	finalStatus int
+
+	// component visibility updated by cachetHQ bridge
+	finalEnabled *bool
+
+	// component description updated by cachetHQ bridge
+	finalDescription string
 )
 
 // setup sets up a test HTTP server. Tests should register handlers on
@@ -31,6 +38,8 @@ func setupMockCachetHQ(t *testing.T) {
 	mockServer = httptest.NewServer(mux)
 
 	finalStatus = 0
+	finalEnabled = nil
+	finalDescription = ""
 
 	mux.HandleFunc("/api/v1/components",
 		func(w http.ResponseWriter, r *http.Request) {
@@ -85,6 +94,24 @@ func setupMockCachetHQ(t *testing.T) {
 			if err == nil {
 				finalStatus = incident.Status
 			}
+			fmt.Fprint(w, `{"data": {"id": 1, "component_id": 1, "status": 2}}`)
+		})
+
+	mux.HandleFunc("/api/v1/components/1",
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "PUT", r.Method)
+			var component struct {
+				Enabled     *bool  `json:"enabled"`
+				Description string `json:"description"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&component); err == nil {
+				if component.Enabled != nil {
+					finalEnabled = component.Enabled
+				}
+				if component.Description != "" {
+					finalDescription = component.Description
+				}
+			}
 		})
 }
 
@@ -157,7 +184,9 @@ func TestCachetHqComponent22(t *testing.T) {
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	go server.ListenAndServe()
+	listener, err := net.Listen("tcp", server.Addr)
+	assert.Nil(t, err, "Not able to listen on :9998")
+	go server.Serve(listener)
 	defer server.Close()
 
 	// send an alert
@@ -176,3 +205,172 @@ func TestCachetHqComponent22(t *testing.T) {
 	// the status has NOT been updated because "component22" does not exist
 	assert.Equal(t, 0, finalStatus)
 }
+
+func TestCachetHqDisableComponent(t *testing.T) {
+	setupMockCachetHQ(t)
+	defer teardown()
+
+	config := PrometheusCachetConfig{
+		LabelName: "alertname",
+		LogLevel:  LOG_DEBUG,
+		Cachet:    NewCachetImpl(mockServer.URL, "1234567890abcdef", &http.Client{}),
+	}
+
+	router := PrepareGinRouter(&config)
+
+	for _, status := range []string{"firing", "resolved"} {
+		var jsonStr = []byte(`{"receiver":"cachethq-receiver","status":"` + status + `","alerts":[{"status":"` + status + `","labels":{"alertname":"component21"},"annotations":{"cachet_action":"disable"}}],"version":"4"}`)
+		req, _ := http.NewRequest("POST", "/alert", bytes.NewBuffer(jsonStr))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotNil(t, finalEnabled)
+		assert.Equal(t, status == "resolved", *finalEnabled)
+		// no incident created
+		assert.Equal(t, 0, finalStatus)
+	}
+}
+
+func TestCachetHqComponentDescription(t *testing.T) {
+	setupMockCachetHQ(t)
+	defer teardown()
+
+	config := PrometheusCachetConfig{
+		LabelName:             "alertname",
+		LogLevel:              LOG_DEBUG,
+		Cachet:                NewCachetImpl(mockServer.URL, "1234567890abcdef", &http.Client{}),
+		DescriptionAnnotation: "summary",
+	}
+
+	router := PrepareGinRouter(&config)
+
+	var jsonStr = []byte(`{"receiver":"cachethq-receiver","status":"firing","alerts":[{"status":"firing","labels":{"alertname":"component21"},"annotations":{"summary":"eu-west-1 impacted"}}],"version":"4"}`)
+	req, _ := http.NewRequest("POST", "/alert", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "eu-west-1 impacted", finalDescription)
+	assert.Equal(t, 2, finalStatus)
+}
+
+func TestCachetHqTestEndpoint(t *testing.T) {
+	setupMockCachetHQ(t)
+	defer teardown()
+
+	config := PrometheusCachetConfig{
+		LabelName:       "alertname",
+		PrometheusToken: "promToken",
+		LogLevel:        LOG_DEBUG,
+		Cachet:          NewCachetImpl(mockServer.URL, "1234567890abcdef", &http.Client{}),
+	}
+
+	router := PrepareGinRouter(&config)
+
+	// dry run: nothing sent to CachetHQ
+	req, _ := http.NewRequest("POST", "/test", bytes.NewBufferString(`{"component":"component21","status":"firing","dry_run":true}`))
+	req.Header.Set("Authorization", "Bearer "+config.PrometheusToken)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report TestReport
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []string{"component21"}, report.Components)
+	assert.Equal(t, 1, len(report.Actions))
+	assert.Equal(t, 0, finalStatus)
+
+	// real run
+	req, _ = http.NewRequest("POST", "/test", bytes.NewBufferString(`{"component":"component21","status":"firing"}`))
+	req.Header.Set("Authorization", "Bearer "+config.PrometheusToken)
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, finalStatus)
+
+	// unknown component
+	req, _ = http.NewRequest("POST", "/test", bytes.NewBufferString(`{"component":"component22","status":"firing","dry_run":true}`))
+	req.Header.Set("Authorization", "Bearer "+config.PrometheusToken)
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 0, len(report.Components))
+	assert.Equal(t, 0, len(report.Actions))
+
+	// wrong token
+	req, _ = http.NewRequest("POST", "/test", bytes.NewBufferString(`{"component":"component21","status":"firing"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestCachetHqErrorStatus(t *testing.T) {
+	// CachetHQ is down
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	config := PrometheusCachetConfig{
+		LabelName: "alertname",
+		LogLevel:  LOG_DEBUG,
+		Cachet:    NewCachetImpl(down.URL, "1234567890abcdef", &http.Client{}),
+	}
+
+	router := PrepareGinRouter(&config)
+
+	var jsonStr = `{"receiver":"cachethq-receiver","status":"firing","alerts":[{"status":"firing","labels":{"alertname":"component21"}}],"version":"4"}`
+
+	req, _ := http.NewRequest("POST", "/alert", bytes.NewBufferString(jsonStr))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	config.CachetErrorStatus = http.StatusServiceUnavailable
+	req, _ = http.NewRequest("POST", "/alert", bytes.NewBufferString(jsonStr))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// malformed payload
+	req, _ = http.NewRequest("POST", "/alert", bytes.NewBufferString(`{"alerts": 1}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdminListener(t *testing.T) {
+	config := PrometheusCachetConfig{LabelName: "alertname", PrometheusToken: "secret", Metrics: NewMetrics(), AdminListen: "127.0.0.1:9091"}
+	public, admin := PrepareGinRouter(&config), PrepareAdminRouter(&config)
+	status := func(router http.Handler, method, path string) int {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(`{}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// the public listener only receives the alerts
+	assert.Equal(t, http.StatusBadRequest, status(public, "POST", "/alert"))
+	assert.Equal(t, http.StatusNotFound, status(public, "GET", "/health"))
+	assert.Equal(t, http.StatusNotFound, status(public, "GET", "/metrics"))
+	assert.Equal(t, http.StatusNotFound, status(public, "GET", "/admin/history"))
+	assert.Equal(t, http.StatusNotFound, status(public, "GET", "/debug/pprof/"))
+
+	assert.Equal(t, http.StatusNotFound, status(admin, "POST", "/alert"))
+	assert.Equal(t, http.StatusOK, status(admin, "GET", "/health"))
+	assert.Equal(t, http.StatusOK, status(admin, "GET", "/metrics"))
+	assert.Equal(t, http.StatusOK, status(admin, "GET", "/debug/pprof/"))
+	assert.Equal(t, http.StatusOK, status(admin, "GET", "/debug/pprof/goroutine"))
+
+	// pprof is an admin endpoint
+	config.PrometheusToken = ""
+	assert.Equal(t, http.StatusForbidden, status(admin, "GET", "/debug/pprof/heap"))
+}
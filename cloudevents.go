@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CLOUDEVENTS_CONTENT_TYPE is the content type of the CloudEvents of the structured HTTP binding
+const CLOUDEVENTS_CONTENT_TYPE = "application/cloudevents+json"
+
+// CLOUDEVENTS_SPEC_VERSION is the version of the CloudEvents specification implemented by the bridge
+const CLOUDEVENTS_SPEC_VERSION = "1.0"
+
+// CloudEvent is a CloudEvent of the structured HTTP binding (cf https://github.com/cloudevents/spec), wrapping an
+// Alertmanager webhook payload in its data
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+	DataBase64      string          `json:"data_base64"`
+}
+
+// webhookBody returns the webhook payload of a request: its body, or the data of the CloudEvent wrapping it
+// (structured binding: the event is the body, binary binding: the event attributes are the ce-* headers)
+func webhookBody(c *gin.Context, config *PrometheusCachetConfig) (io.Reader, error) {
+	contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if contentType == CLOUDEVENTS_CONTENT_TYPE {
+		var event CloudEvent
+		if err := json.NewDecoder(c.Request.Body).Decode(&event); err != nil {
+			return nil, decodingError(err)
+		}
+		if err := event.validate(); err != nil {
+			return nil, err
+		}
+		event.log(config)
+		if event.DataBase64 != "" {
+			data, err := base64.StdEncoding.DecodeString(event.DataBase64)
+			if err != nil {
+				var fields ValidationError
+				fields.add("data_base64", "is not base64 encoded")
+				return nil, fields
+			}
+			return bytes.NewReader(data), nil
+		}
+		return bytes.NewReader(event.Data), nil
+	}
+
+	if specVersion := c.GetHeader("Ce-Specversion"); specVersion != "" {
+		event := CloudEvent{
+			SpecVersion:     specVersion,
+			ID:              c.GetHeader("Ce-Id"),
+			Source:          c.GetHeader("Ce-Source"),
+			Type:            c.GetHeader("Ce-Type"),
+			DataContentType: contentType,
+		}
+		if err := event.validate(); err != nil {
+			return nil, err
+		}
+		event.log(config)
+	}
+	return c.Request.Body, nil
+}
+
+// validate checks the required attributes of the event, and that its data is json (i.e. an Alertmanager payload)
+func (e *CloudEvent) validate() error {
+	var fields ValidationError
+	if e.SpecVersion != CLOUDEVENTS_SPEC_VERSION {
+		fields.add("specversion", "'%s' is not supported (the bridge implements the version %s)", e.SpecVersion, CLOUDEVENTS_SPEC_VERSION)
+	}
+	if e.ID == "" {
+		fields.add("id", "is required")
+	}
+	if e.Source == "" {
+		fields.add("source", "is required")
+	}
+	if e.Type == "" {
+		fields.add("type", "is required")
+	}
+	if e.DataContentType != "" && e.DataContentType != "application/json" && !strings.HasSuffix(e.DataContentType, "+json") {
+		fields.add("datacontenttype", "'%s' is not json", e.DataContentType)
+	}
+	return fields.orNil()
+}
+
+func (e *CloudEvent) log(config *PrometheusCachetConfig) {
+	if config.debug() {
+		log.Println("received the CloudEvent", e.ID, "of", e.Source, "("+e.Type+")")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudEvents(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "token", ts.Client()),
+	}
+	router := PrepareGinRouter(&config)
+	send := func(body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/alert", bytes.NewBufferString(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	payload := func(component string) string {
+		return `{"version":"4","status":"firing","receiver":"cachethq","alerts":[{"labels":{"alertname":"` + component + `"}}]}`
+	}
+
+	// binary binding: the payload is the body
+	w := send(payload("API"), map[string]string{
+		"Content-Type":   "application/json",
+		"Ce-Specversion": "1.0",
+		"Ce-Id":          "1",
+		"Ce-Source":      "/alertmanager",
+		"Ce-Type":        "io.prometheus.alertmanager.webhook",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 4, fake.components[0].Status)
+
+	// structured binding: the payload is the data of the event
+	w = send(`{"specversion":"1.0","id":"2","source":"/alertmanager","type":"io.prometheus.alertmanager.webhook","datacontenttype":"application/json","data":`+payload("WEB")+`}`,
+		map[string]string{"Content-Type": CLOUDEVENTS_CONTENT_TYPE + "; charset=utf-8"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 4, fake.components[1].Status)
+
+	// or its base64 data
+	w = send(`{"specversion":"1.0","id":"3","source":"/alertmanager","type":"io.prometheus.alertmanager.webhook","data_base64":"`+base64.StdEncoding.EncodeToString([]byte(payload("API")))+`"}`,
+		map[string]string{"Content-Type": CLOUDEVENTS_CONTENT_TYPE})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// an invalid event
+	w = send(`{"specversion":"0.3","source":"/alertmanager","type":"io.prometheus.alertmanager.webhook","datacontenttype":"text/plain","data":"API"}`,
+		map[string]string{"Content-Type": CLOUDEVENTS_CONTENT_TYPE})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var problem Problem
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, []FieldError{
+		{Field: "specversion", Reason: "'0.3' is not supported (the bridge implements the version 1.0)"},
+		{Field: "id", Reason: "is required"},
+		{Field: "datacontenttype", Reason: "'text/plain' is not json"},
+	}, problem.InvalidParams)
+}
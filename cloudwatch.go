@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SNS_TIMEOUT bounds the download of a SNS signing certificate, and the confirmation of a subscription
+const SNS_TIMEOUT = 10 * time.Second
+
+// SNS_HOST is the host of the SNS signing certificates and subscription urls
+var SNS_HOST = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// cf https://docs.aws.amazon.com/sns/latest/dg/sns-message-and-json-formats.html
+type snsMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	SubscribeURL     string `json:"SubscribeURL"`
+}
+
+// signedString returns the string signed by SNS (the fields depending on the message type)
+func (m *snsMessage) signedString() string {
+	fields := [][2]string{{"Message", m.Message}, {"MessageId", m.MessageID}}
+	if m.Type == "Notification" {
+		if m.Subject != "" {
+			fields = append(fields, [2]string{"Subject", m.Subject})
+		}
+	} else {
+		fields = append(fields, [2]string{"SubscribeURL", m.SubscribeURL})
+	}
+	fields = append(fields, [2]string{"Timestamp", m.Timestamp})
+	if m.Type != "Notification" {
+		fields = append(fields, [2]string{"Token", m.Token})
+	}
+	fields = append(fields, [2]string{"TopicArn", m.TopicArn}, [2]string{"Type", m.Type})
+
+	var signed strings.Builder
+	for _, field := range fields {
+		signed.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return signed.String()
+}
+
+// cf https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/AlarmThatSendsEmail.html
+type cloudWatchAlarm struct {
+	AlarmName        string `json:"AlarmName"`
+	AlarmDescription string `json:"AlarmDescription"`
+	AlarmArn         string `json:"AlarmArn"`
+	NewStateValue    string `json:"NewStateValue"` // ALARM, OK or INSUFFICIENT_DATA
+	NewStateReason   string `json:"NewStateReason"`
+	StateChangeTime  string `json:"StateChangeTime"`
+}
+
+// CLOUDWATCH_TIME_LAYOUT is the layout of the CloudWatch alarm dates
+const CLOUDWATCH_TIME_LAYOUT = "2006-01-02T15:04:05.000-0700"
+
+// cloudWatchComponent matches the "cachet_component: <name>" line of an alarm description
+var cloudWatchComponent = regexp.MustCompile(`(?m)^\s*` + INPUT_COMPONENT_TAG + `\s*[:=]\s*(.+?)\s*$`)
+
+// CloudWatchAdapter translates the CloudWatch alarms notified by SNS, after the verification of their signature
+// (and confirms the SNS subscriptions)
+type CloudWatchAdapter struct {
+	client *http.Client
+	// the hosts allowed for the certificates and the subscription urls
+	hosts *regexp.Regexp
+
+	mutex sync.Mutex
+	certs map[string]*x509.Certificate // by url
+}
+
+// NewCloudWatchAdapter creates a new CloudWatchAdapter
+func NewCloudWatchAdapter() *CloudWatchAdapter {
+	return &CloudWatchAdapter{
+		client: &http.Client{Timeout: SNS_TIMEOUT},
+		hosts:  SNS_HOST,
+		certs:  make(map[string]*x509.Certificate),
+	}
+}
+
+// snsURL checks that rawurl is a https url of SNS
+func (a *CloudWatchAdapter) snsURL(rawurl string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || !a.hosts.MatchString(u.Hostname()) {
+		return fmt.Errorf("%s is not a SNS url", rawurl)
+	}
+	return nil
+}
+
+// certificate returns the SNS signing certificate (downloaded once)
+func (a *CloudWatchAdapter) certificate(certURL string) (*x509.Certificate, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if cert, ok := a.certs[certURL]; ok {
+		return cert, nil
+	}
+
+	if err := a.snsURL(certURL); err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("not able to download %s: http status %d", certURL, resp.StatusCode)
+	}
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM certificate", certURL)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	a.certs[certURL] = cert
+	return cert, nil
+}
+
+// verify checks the signature of a SNS message
+func (a *CloudWatchAdapter) verify(message *snsMessage) error {
+	var algorithm x509.SignatureAlgorithm
+	switch message.SignatureVersion {
+	case "1":
+		algorithm = x509.SHA1WithRSA
+	case "2":
+		algorithm = x509.SHA256WithRSA
+	default:
+		return fmt.Errorf("unknown SNS signature version '%s'", message.SignatureVersion)
+	}
+	signature, err := base64.StdEncoding.DecodeString(message.Signature)
+	if err != nil {
+		return fmt.Errorf("not a SNS signature: %v", err)
+	}
+	cert, err := a.certificate(message.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	if err := cert.CheckSignature(algorithm, []byte(message.signedString()), signature); err != nil {
+		return fmt.Errorf("wrong SNS signature: %v", err)
+	}
+	return nil
+}
+
+// confirm confirms a SNS subscription
+func (a *CloudWatchAdapter) confirm(message *snsMessage) error {
+	if err := a.snsURL(message.SubscribeURL); err != nil {
+		return err
+	}
+	resp, err := a.client.Get(message.SubscribeURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("not able to confirm the SNS subscription to %s: http status %d", message.TopicArn, resp.StatusCode)
+	}
+	return nil
+}
+
+// Parse translates a CloudWatch alarm: ALARM fires, OK resolves, INSUFFICIENT_DATA is ignored. The impacted component
+// is the "cachet_component: <name>" line of the alarm description, or the alarm name
+func (a *CloudWatchAdapter) Parse(config *PrometheusCachetConfig, body []byte) (*PrometheusAlert, error) {
+	var message snsMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, err
+	}
+	if message.Type == "" {
+		return nil, fmt.Errorf("not a SNS message")
+	}
+	if err := a.verify(&message); err != nil {
+		return nil, err
+	}
+
+	switch message.Type {
+	case "SubscriptionConfirmation":
+		return nil, a.confirm(&message)
+	case "Notification":
+	default:
+		// ex: UnsubscribeConfirmation
+		return nil, nil
+	}
+
+	var alarm cloudWatchAlarm
+	if err := json.Unmarshal([]byte(message.Message), &alarm); err != nil {
+		return nil, fmt.Errorf("not a CloudWatch alarm: %v", err)
+	}
+	if alarm.AlarmName == "" {
+		return nil, fmt.Errorf("not a CloudWatch alarm: AlarmName is required")
+	}
+	var firing bool
+	switch alarm.NewStateValue {
+	case "ALARM":
+		firing = true
+	case "OK":
+	default:
+		return nil, nil
+	}
+
+	component := alarm.AlarmName
+	if match := cloudWatchComponent.FindStringSubmatch(alarm.AlarmDescription); match != nil {
+		component = match[1]
+	}
+	key := alarm.AlarmArn
+	if key == "" {
+		key = alarm.AlarmName
+	}
+
+	alerts := newInputAlert(config, "cloudwatch", firing, component, key)
+	alert := &alerts.Alerts[0]
+	if alarm.NewStateReason != "" {
+		alert.Annotations[ANNOTATION_SUMMARY] = alarm.NewStateReason
+	}
+	if changed, err := time.Parse(CLOUDWATCH_TIME_LAYOUT, alarm.StateChangeTime); err == nil {
+		alert.StartAt = changed.Format(time.RFC3339)
+	}
+	return alerts, nil
+}
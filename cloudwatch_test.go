@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSNS serves a signing certificate, and the subscription confirmations
+type fakeSNS struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	confirmed int
+}
+
+func newFakeSNS(t *testing.T) *fakeSNS {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	sns := &fakeSNS{key: key}
+	sns.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cert.pem":
+			pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+		case "/confirm":
+			sns.confirmed++
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return sns
+}
+
+func (sns *fakeSNS) adapter() *CloudWatchAdapter {
+	adapter := NewCloudWatchAdapter()
+	adapter.client = sns.server.Client()
+	adapter.hosts = regexp.MustCompile(`^127\.0\.0\.1$`)
+	return adapter
+}
+
+// sign returns the message signed (with the SignatureVersion 2), as posted by SNS
+func (sns *fakeSNS) sign(t *testing.T, message *snsMessage) []byte {
+	message.SignatureVersion = "2"
+	message.SigningCertURL = sns.server.URL + "/cert.pem"
+	digest := sha256.Sum256([]byte(message.signedString()))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, sns.key, crypto.SHA256, digest[:])
+	assert.Nil(t, err)
+	message.Signature = base64.StdEncoding.EncodeToString(signature)
+
+	body, err := json.Marshal(message)
+	assert.Nil(t, err)
+	return body
+}
+
+func TestCloudWatchAdapter(t *testing.T) {
+	sns := newFakeSNS(t)
+	defer sns.server.Close()
+	adapter := sns.adapter()
+	config := &PrometheusCachetConfig{LabelName: "alertname"}
+
+	alarm := `{"AlarmName":"api-5xx","AlarmDescription":"too many errors\ncachet_component: Public API","AlarmArn":"arn:aws:cloudwatch:eu-west-1:123456789012:alarm:api-5xx","NewStateValue":"ALARM","NewStateReason":"Threshold Crossed","StateChangeTime":"2020-01-01T10:00:00.000+0000"}`
+	alerts, err := adapter.Parse(config, sns.sign(t, &snsMessage{Type: "Notification", MessageID: "1", TopicArn: "arn:aws:sns:eu-west-1:123456789012:alarms", Subject: "ALARM: api-5xx", Message: alarm, Timestamp: "2020-01-01T10:00:01.000Z"}))
+	assert.Nil(t, err)
+	assert.Equal(t, "firing", alerts.Status)
+	assert.Equal(t, "Public API", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "Threshold Crossed", alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY])
+	assert.Equal(t, "2020-01-01T10:00:00Z", alerts.Alerts[0].StartAt)
+	assert.Equal(t, "cloudwatch:arn:aws:cloudwatch:eu-west-1:123456789012:alarm:api-5xx", alerts.Alerts[0].fingerprint())
+
+	alarm = `{"AlarmName":"api-5xx","AlarmArn":"arn:aws:cloudwatch:eu-west-1:123456789012:alarm:api-5xx","NewStateValue":"OK"}`
+	alerts, err = adapter.Parse(config, sns.sign(t, &snsMessage{Type: "Notification", MessageID: "2", TopicArn: "arn:aws:sns:eu-west-1:123456789012:alarms", Message: alarm, Timestamp: "2020-01-01T10:05:01.000Z"}))
+	assert.Nil(t, err)
+	assert.Equal(t, "resolved", alerts.Status)
+	assert.Equal(t, "api-5xx", alerts.Alerts[0].Labels["alertname"])
+
+	alarm = `{"AlarmName":"api-5xx","NewStateValue":"INSUFFICIENT_DATA"}`
+	alerts, err = adapter.Parse(config, sns.sign(t, &snsMessage{Type: "Notification", MessageID: "3", Message: alarm}))
+	assert.Nil(t, err)
+	assert.Nil(t, alerts)
+
+	// tampered
+	body := sns.sign(t, &snsMessage{Type: "Notification", MessageID: "4", Message: `{"AlarmName":"api-5xx","NewStateValue":"OK"}`})
+	var message snsMessage
+	json.Unmarshal(body, &message)
+	message.Message = `{"AlarmName":"api-5xx","NewStateValue":"ALARM"}`
+	body, _ = json.Marshal(&message)
+	_, err = adapter.Parse(config, body)
+	assert.NotNil(t, err)
+}
+
+func TestCloudWatchAdapterSubscription(t *testing.T) {
+	sns := newFakeSNS(t)
+	defer sns.server.Close()
+	adapter := sns.adapter()
+	config := &PrometheusCachetConfig{LabelName: "alertname"}
+
+	alerts, err := adapter.Parse(config, sns.sign(t, &snsMessage{Type: "SubscriptionConfirmation", MessageID: "1", Token: "token", TopicArn: "arn:aws:sns:eu-west-1:123456789012:alarms", Message: "You have chosen to subscribe", SubscribeURL: sns.server.URL + "/confirm"}))
+	assert.Nil(t, err)
+	assert.Nil(t, alerts)
+	assert.Equal(t, 1, sns.confirmed)
+
+	// only the SNS urls are followed
+	_, err = NewCloudWatchAdapter().Parse(config, sns.sign(t, &snsMessage{Type: "SubscriptionConfirmation", MessageID: "2", SubscribeURL: sns.server.URL + "/confirm"}))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, sns.confirmed)
+}
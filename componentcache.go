@@ -0,0 +1,165 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// cf https://docs.cachethq.io/docs/component-statuses
+var componentStatusNames = map[int]string{
+	1: "Operational",
+	2: "Performance Issues",
+	3: "Partial Outage",
+	4: "Major Outage",
+}
+
+// CachedComponent is a component as last known by the bridge
+type CachedComponent struct {
+	ID         int               `json:"id"`
+	Name       string            `json:"name"`
+	Status     int               `json:"status"`
+	StatusName string            `json:"status_name"`
+	GroupID    int               `json:"group_id"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// ComponentCache is a Cachet decorator keeping the last known components (and their status), to serve them
+// without calling CachetHQ: it is refreshed by the component listings of the pipeline, by the status changes
+// sent by the bridge, and every interval
+type ComponentCache struct {
+	Cachet
+	interval time.Duration
+
+	mutex      sync.RWMutex
+	components map[int]*CachedComponent
+	list       []*CachetComponent
+	// when the components were last listed (zero if never)
+	refreshedAt time.Time
+}
+
+// NewComponentCache creates a new Cachet decorator, whose components are refreshed every interval (by Run)
+func NewComponentCache(cachet Cachet, interval time.Duration) *ComponentCache {
+	return &ComponentCache{
+		Cachet:     cachet,
+		interval:   interval,
+		components: make(map[int]*CachedComponent),
+	}
+}
+
+// Run refreshes the components every interval (and right away), until stop is closed
+func (c *ComponentCache) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.ListComponentsDetails(); err != nil {
+			log.Println("not able to refresh the components:", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Components returns the last known components (by id), and when they were listed
+func (c *ComponentCache) Components() ([]CachedComponent, time.Time) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	components := make([]CachedComponent, 0, len(c.components))
+	for _, component := range c.components {
+		components = append(components, *component)
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i].ID < components[j].ID })
+	return components, c.refreshedAt
+}
+
+// details returns the components as last listed (without the status changes sent since)
+func (c *ComponentCache) details() []*CachetComponent {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.list
+}
+
+func (c *ComponentCache) ListComponentsDetails() ([]*CachetComponent, error) {
+	list, err := c.Cachet.ListComponentsDetails()
+	if err != nil {
+		return list, err
+	}
+
+	components := make(map[int]*CachedComponent, len(list))
+	for _, component := range list {
+		components[component.Id] = &CachedComponent{
+			ID:         component.Id,
+			Name:       component.Name,
+			Status:     component.Status,
+			StatusName: componentStatusNames[component.Status],
+			GroupID:    component.GroupId,
+			Tags:       component.Tags,
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.components = components
+	c.list = list
+	c.refreshedAt = time.Now()
+	return list, nil
+}
+
+func (c *ComponentCache) SetComponentStatus(componentID, componentStatus int) error {
+	if err := c.Cachet.SetComponentStatus(componentID, componentStatus); err != nil {
+		return err
+	}
+	c.setStatus(componentID, componentStatus)
+	return nil
+}
+
+func (c *ComponentCache) CreateIncident(componentName string, componentID, status int, componentStatus int, options IncidentOptions) (int, error) {
+	id, err := c.Cachet.CreateIncident(componentName, componentID, status, componentStatus, options)
+	if err != nil {
+		return id, err
+	}
+	// a private firing incident leaves the component status unchanged
+	if !options.Private || status == 1 {
+		c.setStatus(componentID, componentStatus)
+	}
+	return id, nil
+}
+
+func (c *ComponentCache) UpdateIncident(componentName string, componentID, incidentId, status int, name, message string) error {
+	if err := c.Cachet.UpdateIncident(componentName, componentID, incidentId, status, name, message); err != nil {
+		return err
+	}
+	if status == 1 {
+		c.setStatus(componentID, 1)
+	} else {
+		c.setStatus(componentID, 4)
+	}
+	return nil
+}
+
+func (c *ComponentCache) PublishIncident(incidentId, componentID, componentStatus, incidentStatus int) error {
+	if err := c.Cachet.PublishIncident(incidentId, componentID, componentStatus, incidentStatus); err != nil {
+		return err
+	}
+	c.setStatus(componentID, componentStatus)
+	return nil
+}
+
+// setStatus records the status of a known component (0 meaning unchanged)
+func (c *ComponentCache) setStatus(componentID, componentStatus int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if component, ok := c.components[componentID]; ok && componentStatus > 0 {
+		component.Status = componentStatus
+		component.StatusName = componentStatusNames[componentStatus]
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComponentCache(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	cache := NewComponentCache(NewCachetImpl(ts.URL, "token", ts.Client()), 0)
+	config := PrometheusCachetConfig{
+		LabelName:  "alertname",
+		Cachet:     cache,
+		Components: cache,
+	}
+	router := PrepareGinRouter(&config)
+	components := func() []CachedComponent {
+		req, _ := http.NewRequest("GET", "/components", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var answer struct {
+			Components []CachedComponent `json:"components"`
+		}
+		assert.Nil(t, json.NewDecoder(w.Body).Decode(&answer))
+		return answer.Components
+	}
+
+	// nothing listed yet
+	assert.Equal(t, []CachedComponent{}, components())
+
+	// the status set by the pipeline is served without calling CachetHQ again
+	req, _ := http.NewRequest("POST", "/alert", bytes.NewBufferString(`{"version":"4","status":"firing","alerts":[{"labels":{"alertname":"API"}}]}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []CachedComponent{
+		{ID: 1, Name: "API", Status: 4, StatusName: "Major Outage"},
+		{ID: 2, Name: "WEB", Status: 1, StatusName: "Operational"},
+	}, components())
+
+	// disabled
+	config.Components = nil
+	req, _ = http.NewRequest("GET", "/components", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
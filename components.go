@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MATCH_BY_NAME = "name"
+	MATCH_BY_TAG  = "tag"
+)
+
+// ImpactedComponent is a CachetHQ component impacted by an alert
+type ImpactedComponent struct {
+	ID   int
+	Name string
+	// Others are the additional components sharing the same incident (whose status follows the incident)
+	Others []int
+}
+
+// IDs returns the id of the component, and of the others components sharing the incident
+func (c *ImpactedComponent) IDs() []int {
+	return append([]int{c.ID}, c.Others...)
+}
+
+// normalizeName folds the case, trims, and considers dashes, underscores and spaces as the same separator
+// i.e. " Public_API ", "public-api" and "public   api" are all normalized to "public api"
+func normalizeName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("-", " ", "_", " ").Replace(name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// MatchComponents returns the CachetHQ components impacted by an alert, looking at the config.LabelName label:
+// - by default, the label value is the component name
+// - with MATCH_BY_TAG, the label value is a tag (slug or name), and all the components with this tag are impacted
+// the config.Aliases are applied first, and if config.NormalizeNames is set, both sides are normalized before being compared
+//
+// If the alert has a config.ComponentsLabel label (i.e. cachet_components="api,web,cdn"), all these
+// components are impacted by one single incident
+//
+// If the alert has a config.GroupLabel label (i.e. cachet_group="EU Region"), all the components of
+// this CachetHQ group (groups is a map[groupname]groupid) are impacted by one single incident
+func MatchComponents(config *PrometheusCachetConfig, components []*CachetComponent, groups map[string]int, alert *PrometheusAlertDetail) []ImpactedComponent {
+	if config.GroupLabel != "" && alert.Labels[config.GroupLabel] != "" {
+		// the group name is matched like the component names (aliases, normalization)
+		value := resolveAlias(config, alert.Labels[config.GroupLabel])
+		groupName := value
+		groupID, found := groups[value]
+		if !found {
+			for name, id := range groups {
+				// the first one (by name) if several groups are equal once normalized
+				if namesEqual(config, name, value) && (!found || name < groupName) {
+					groupName, groupID, found = name, id, true
+				}
+			}
+		}
+		if !found {
+			return []ImpactedComponent{}
+		}
+
+		ids := make([]int, 0)
+		for _, component := range components {
+			if component.GroupId == groupID {
+				ids = append(ids, component.Id)
+			}
+		}
+		if len(ids) == 0 {
+			return []ImpactedComponent{}
+		}
+		return []ImpactedComponent{{ID: ids[0], Name: groupName, Others: ids[1:]}}
+	}
+
+	if config.ComponentsLabel != "" && alert.Labels[config.ComponentsLabel] != "" {
+		separator := config.ComponentsSeparator
+		if separator == "" {
+			separator = ","
+		}
+
+		names := make([]string, 0)
+		ids := make([]int, 0)
+		seen := make(map[int]bool)
+		for _, value := range strings.Split(alert.Labels[config.ComponentsLabel], separator) {
+			for _, component := range findComponents(config, components, strings.TrimSpace(value)) {
+				// i.e. "api,api", or an alias and its component name
+				if seen[component.ID] {
+					continue
+				}
+				seen[component.ID] = true
+				names = append(names, component.Name)
+				ids = append(ids, component.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return []ImpactedComponent{}
+		}
+		return []ImpactedComponent{{ID: ids[0], Name: componentsName(names, config.IncidentNameMaxLength), Others: ids[1:]}}
+	}
+
+	return findComponents(config, components, alert.Labels[config.LabelName])
+}
+
+// namesEqual compares two names, normalized if config.NormalizeNames is set
+func namesEqual(config *PrometheusCachetConfig, a, b string) bool {
+	if config.NormalizeNames {
+		return normalizeName(a) == normalizeName(b)
+	}
+	return a == b
+}
+
+// resolveAlias translates historical label values into the component (or group) name
+func resolveAlias(config *PrometheusCachetConfig, value string) string {
+	alias := value
+	if config.NormalizeNames {
+		alias = normalizeName(value)
+	}
+	if name, ok := config.Aliases[alias]; ok {
+		return name
+	}
+	return value
+}
+
+func findComponents(config *PrometheusCachetConfig, components []*CachetComponent, value string) []ImpactedComponent {
+	impacted := make([]ImpactedComponent, 0)
+	if value == "" {
+		return impacted
+	}
+
+	equals := func(a, b string) bool {
+		return namesEqual(config, a, b)
+	}
+	value = resolveAlias(config, value)
+
+	for _, component := range components {
+		if config.MatchBy == MATCH_BY_TAG {
+			for slug, name := range component.Tags {
+				if equals(slug, value) || equals(name, value) {
+					impacted = append(impacted, ImpactedComponent{ID: component.Id, Name: component.Name})
+					break
+				}
+			}
+		} else if equals(component.Name, value) {
+			impacted = append(impacted, ImpactedComponent{ID: component.Id, Name: component.Name})
+		}
+	}
+	return impacted
+}
+
+// INCIDENT_NAME_MAX_LENGTH is the default max length of the components named by an incident (the CachetHQ incident
+// names being limited to 255 characters, including their " down"/" up" suffix)
+const INCIDENT_NAME_MAX_LENGTH = 200
+
+// componentsName joins the names of the components impacted by an incident. Above max characters (if not 0),
+// the last names are replaced by " and N more"
+func componentsName(names []string, max int) string {
+	name := strings.Join(names, ", ")
+	if max <= 0 || utf8.RuneCountInString(name) <= max {
+		return name
+	}
+	for kept := len(names) - 1; kept > 0; kept-- {
+		name = fmt.Sprintf("%s and %d more", strings.Join(names[:kept], ", "), len(names)-kept)
+		if utf8.RuneCountInString(name) <= max {
+			return name
+		}
+	}
+	// even the first name is too long
+	suffix := ""
+	if len(names) > 1 {
+		suffix = fmt.Sprintf(" and %d more", len(names)-1)
+	}
+	first := []rune(names[0])
+	if keep := max - utf8.RuneCountInString(suffix); keep > 0 && keep < len(first) {
+		first = first[:keep]
+	}
+	return string(first) + suffix
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachetHqTags(t *testing.T) {
+	var tags cachetHqTags
+
+	assert.Nil(t, json.Unmarshal([]byte(`{"payments": "Payments"}`), &tags))
+	assert.Equal(t, "Payments", tags["payments"])
+
+	assert.Nil(t, json.Unmarshal([]byte(`[{"payments": "Payments"}, {"eu": "EU"}]`), &tags))
+	assert.Equal(t, 2, len(tags))
+	assert.Equal(t, "EU", tags["eu"])
+
+	assert.Nil(t, json.Unmarshal([]byte(`["payments"]`), &tags))
+	assert.Equal(t, "payments", tags["payments"])
+
+	assert.Nil(t, json.Unmarshal([]byte(`[]`), &tags))
+	assert.Equal(t, 0, len(tags))
+}
+
+func TestMatchComponents(t *testing.T) {
+	components := []*CachetComponent{
+		{Id: 1, Name: "Public API", Tags: cachetHqTags{"payments": "Payments"}},
+		{Id: 2, Name: "Checkout", Tags: cachetHqTags{"payments": "Payments", "web": "Web"}},
+		{Id: 3, Name: "Blog"},
+	}
+	config := &PrometheusCachetConfig{LabelName: "service"}
+
+	// by name
+	impacted := MatchComponents(config, components, nil, &PrometheusAlertDetail{Labels: map[string]string{"service": "Blog"}})
+	assert.Equal(t, []ImpactedComponent{{ID: 3, Name: "Blog"}}, impacted)
+
+	impacted = MatchComponents(config, components, nil, &PrometheusAlertDetail{Labels: map[string]string{"service": "payments"}})
+	assert.Equal(t, 0, len(impacted))
+
+	// by tag
+	config.MatchBy = MATCH_BY_TAG
+	impacted = MatchComponents(config, components, nil, &PrometheusAlertDetail{Labels: map[string]string{"service": "payments"}})
+	assert.Equal(t, []ImpactedComponent{{ID: 1, Name: "Public API"}, {ID: 2, Name: "Checkout"}}, impacted)
+
+	impacted = MatchComponents(config, components, nil, &PrometheusAlertDetail{Labels: map[string]string{"service": "Web"}})
+	assert.Equal(t, []ImpactedComponent{{ID: 2, Name: "Checkout"}}, impacted)
+}
+
+func TestMatchComponentsNormalized(t *testing.T) {
+	components := []*CachetComponent{
+		{Id: 1, Name: "Public API", Tags: cachetHqTags{"payments-eu": "Payments EU"}},
+	}
+	config := &PrometheusCachetConfig{LabelName: "service"}
+
+	alert := &PrometheusAlertDetail{Labels: map[string]string{"service": "public_api"}}
+	assert.Equal(t, 0, len(MatchComponents(config, components, nil, alert)))
+
+	config.NormalizeNames = true
+	assert.Equal(t, []ImpactedComponent{{ID: 1, Name: "Public API"}}, MatchComponents(config, components, nil, alert))
+
+	config.MatchBy = MATCH_BY_TAG
+	alert = &PrometheusAlertDetail{Labels: map[string]string{"service": " Payments_EU"}}
+	assert.Equal(t, []ImpactedComponent{{ID: 1, Name: "Public API"}}, MatchComponents(config, components, nil, alert))
+}
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "public api", normalizeName(" Public_API "))
+	assert.Equal(t, "public api", normalizeName("public-api"))
+	assert.Equal(t, "public api", normalizeName("public   api"))
+}
+
+func TestMatchComponentsAliases(t *testing.T) {
+	components := []*CachetComponent{
+		{Id: 1, Name: "Public API"},
+	}
+	config := &PrometheusCachetConfig{
+		LabelName: "service",
+		Aliases:   map[string]string{"api": "Public API", "apigw": "Public API"},
+	}
+
+	for _, value := range []string{"api", "apigw", "Public API"} {
+		alert := &PrometheusAlertDetail{Labels: map[string]string{"service": value}}
+		assert.Equal(t, []ImpactedComponent{{ID: 1, Name: "Public API"}}, MatchComponents(config, components, nil, alert))
+	}
+
+	alert := &PrometheusAlertDetail{Labels: map[string]string{"service": "API"}}
+	assert.Equal(t, 0, len(MatchComponents(config, components, nil, alert)))
+
+	config.NormalizeNames = true
+	assert.Equal(t, []ImpactedComponent{{ID: 1, Name: "Public API"}}, MatchComponents(config, components, nil, alert))
+}
+
+func TestMatchComponentsList(t *testing.T) {
+	components := []*CachetComponent{
+		{Id: 1, Name: "api"},
+		{Id: 2, Name: "web"},
+		{Id: 3, Name: "cdn"},
+	}
+	config := &PrometheusCachetConfig{
+		LabelName:       "alertname",
+		ComponentsLabel: "cachet_components",
+	}
+
+	alert := &PrometheusAlertDetail{Labels: map[string]string{"alertname": "api", "cachet_components": "api, cdn,unknown"}}
+	assert.Equal(t, []ImpactedComponent{{ID: 1, Name: "api, cdn", Others: []int{3}}}, MatchComponents(config, components, nil, alert))
+
+	config.ComponentsSeparator = "|"
+	alert = &PrometheusAlertDetail{Labels: map[string]string{"cachet_components": "web|api"}}
+	impacted := MatchComponents(config, components, nil, alert)
+	assert.Equal(t, []ImpactedComponent{{ID: 2, Name: "web, api", Others: []int{1}}}, impacted)
+	assert.Equal(t, []int{2, 1}, impacted[0].IDs())
+
+	alert = &PrometheusAlertDetail{Labels: map[string]string{"cachet_components": "unknown"}}
+	assert.Equal(t, 0, len(MatchComponents(config, components, nil, alert)))
+
+	// the same component twice
+	alert = &PrometheusAlertDetail{Labels: map[string]string{"cachet_components": "api|cdn|api"}}
+	assert.Equal(t, []ImpactedComponent{{ID: 1, Name: "api, cdn", Others: []int{3}}}, MatchComponents(config, components, nil, alert))
+}
+
+func TestComponentsName(t *testing.T) {
+	names := []string{"api", "web", "cdn", "search"}
+	assert.Equal(t, "api, web, cdn, search", componentsName(names, 0))
+	assert.Equal(t, "api, web, cdn, search", componentsName(names, 21))
+	assert.Equal(t, "api, web and 2 more", componentsName(names, 20))
+	assert.Equal(t, "api and 3 more", componentsName(names, 15))
+	// even the first name is too long
+	assert.Equal(t, "ap and 3 more", componentsName(names, 13))
+	assert.Equal(t, "api and 3 more", componentsName(names, 3))
+	// a single (too long) name is only truncated
+	assert.Equal(t, "search-fr", componentsName([]string{"search-fr"}, 9))
+	assert.Equal(t, "searc", componentsName([]string{"search-fr"}, 5))
+
+	components := []*CachetComponent{{Id: 1, Name: "api"}, {Id: 2, Name: "web"}, {Id: 3, Name: "cdn"}}
+	config := &PrometheusCachetConfig{ComponentsLabel: "cachet_components", IncidentNameMaxLength: 10}
+	alert := &PrometheusAlertDetail{Labels: map[string]string{"cachet_components": "api,web,cdn"}}
+	assert.Equal(t, []ImpactedComponent{{ID: 1, Name: "api and 2 more", Others: []int{2, 3}}}, MatchComponents(config, components, nil, alert))
+}
+
+func TestMatchComponentsGroup(t *testing.T) {
+	components := []*CachetComponent{
+		{Id: 1, Name: "api", GroupId: 1},
+		{Id: 2, Name: "web", GroupId: 2},
+		{Id: 3, Name: "cdn", GroupId: 1},
+	}
+	groups := map[string]int{"EU Region": 1, "US Region": 2, "Empty": 3}
+	config := &PrometheusCachetConfig{
+		LabelName:  "alertname",
+		GroupLabel: "cachet_group",
+	}
+
+	alert := &PrometheusAlertDetail{Labels: map[string]string{"alertname": "web", "cachet_group": "EU Region"}}
+	assert.Equal(t, []ImpactedComponent{{ID: 1, Name: "EU Region", Others: []int{3}}}, MatchComponents(config, components, groups, alert))
+
+	alert = &PrometheusAlertDetail{Labels: map[string]string{"cachet_group": "Empty"}}
+	assert.Equal(t, 0, len(MatchComponents(config, components, groups, alert)))
+
+	alert = &PrometheusAlertDetail{Labels: map[string]string{"cachet_group": "Unknown"}}
+	assert.Equal(t, 0, len(MatchComponents(config, components, groups, alert)))
+
+	// aliases and normalization apply to the group names too
+	alert = &PrometheusAlertDetail{Labels: map[string]string{"cachet_group": "eu-region"}}
+	assert.Equal(t, 0, len(MatchComponents(config, components, groups, alert)))
+	config.NormalizeNames = true
+	assert.Equal(t, []ImpactedComponent{{ID: 1, Name: "EU Region", Others: []int{3}}}, MatchComponents(config, components, groups, alert))
+
+	config.Aliases = map[string]string{"europe": "EU Region"}
+	alert = &PrometheusAlertDetail{Labels: map[string]string{"cachet_group": "Europe"}}
+	assert.Equal(t, []ImpactedComponent{{ID: 1, Name: "EU Region", Others: []int{3}}}, MatchComponents(config, components, groups, alert))
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// COMPONENT_SYNC_TIMEOUT bounds a request to the targets API of Prometheus
+const COMPONENT_SYNC_TIMEOUT = 10 * time.Second
+
+// cf https://prometheus.io/docs/prometheus/latest/querying/api/#targets
+type prometheusTargetsAnswer struct {
+	Status string `json:"status"`
+	Data   struct {
+		ActiveTargets []struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"activeTargets"`
+	} `json:"data"`
+	Error string `json:"error"`
+}
+
+// a target group of a file_sd file (json or yaml), cf https://prometheus.io/docs/guides/file-sd/
+type fileTargetGroup struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+const (
+	COMPONENT_RETIRE_REPORT  = "report"
+	COMPONENT_RETIRE_DISABLE = "disable"
+)
+
+// RetiredComponent is a synced component whose targets are gone for longer than the grace period
+type RetiredComponent struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name"`
+	LastSeen time.Time `json:"last_seen"`
+	Disabled bool      `json:"disabled"`
+}
+
+// discoveredComponent is a component of the discovered targets
+type discoveredComponent struct {
+	name  string
+	group string // empty if none
+}
+
+// ComponentSync creates the CachetHQ components of the services discovered by Prometheus (the targets of its API,
+// or of a file_sd file), so that the new services appear on the status page without a manual step: each value of the
+// label of the targets is a component. The existing components are never renamed nor removed, only moved to their
+// group (if any)
+type ComponentSync struct {
+	config   *PrometheusCachetConfig
+	url      string // the Prometheus server (if not empty)
+	file     string // else the targets file
+	label    string
+	group    string // the group of the synced components (created if missing)
+	client   *http.Client
+	interval time.Duration
+
+	// GroupLabel is the label of the targets naming the group of their component (created if missing), the
+	// components without it being in the group
+	GroupLabel string
+	// RetireAfter is the grace period after which a synced component without targets is retired (0 to never
+	// retire), RetireAction telling if it is only reported, or also disabled
+	RetireAfter  time.Duration
+	RetireAction string
+
+	mutex    sync.Mutex
+	lastSeen map[string]time.Time         // when the synced components were last discovered, by name
+	retired  map[string]*RetiredComponent // by name
+}
+
+// NewComponentSync creates a new ComponentSync of the targets of the Prometheus server at url (or of the file),
+// synced every interval
+func NewComponentSync(config *PrometheusCachetConfig, url, file, label, group string, interval time.Duration) *ComponentSync {
+	return &ComponentSync{
+		config:   config,
+		url:      strings.TrimSuffix(url, "/"),
+		file:     file,
+		label:    label,
+		group:    group,
+		client:   &http.Client{Timeout: COMPONENT_SYNC_TIMEOUT},
+		interval: interval,
+		lastSeen: make(map[string]time.Time),
+		retired:  make(map[string]*RetiredComponent),
+	}
+}
+
+// Run syncs the components every interval (and right away), until stop is closed
+func (s *ComponentSync) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sync(time.Now()); err != nil {
+			log.Println("not able to sync the components:", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// targets returns the labels of the discovered targets
+func (s *ComponentSync) targets() ([]map[string]string, error) {
+	targets := make([]map[string]string, 0)
+	if s.url == "" {
+		content, err := ioutil.ReadFile(s.file)
+		if err != nil {
+			return nil, err
+		}
+		// a json file is also a yaml one
+		var groups []fileTargetGroup
+		if err := yaml.Unmarshal(content, &groups); err != nil {
+			return nil, fmt.Errorf("not a targets file: %v", err)
+		}
+		for _, group := range groups {
+			targets = append(targets, group.Labels)
+		}
+		return targets, nil
+	}
+
+	resp, err := s.client.Get(s.url + "/api/v1/targets?state=active")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var answer prometheusTargetsAnswer
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return nil, fmt.Errorf("not a Prometheus targets answer (http status %d): %v", resp.StatusCode, err)
+	}
+	if answer.Status != "success" {
+		return nil, fmt.Errorf("Prometheus answered %s: %s", answer.Status, answer.Error)
+	}
+	for _, target := range answer.Data.ActiveTargets {
+		targets = append(targets, target.Labels)
+	}
+	return targets, nil
+}
+
+// discovered returns the components of the discovered targets (sorted by name), the group of a component being
+// the one of its first target with the group label
+func (s *ComponentSync) discovered() ([]*discoveredComponent, error) {
+	targets, err := s.targets()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]*discoveredComponent)
+	discovered := make([]*discoveredComponent, 0)
+	for _, labels := range targets {
+		name := strings.TrimSpace(labels[s.label])
+		if name == "" {
+			continue
+		}
+		component, ok := seen[name]
+		if !ok {
+			component = &discoveredComponent{name: name}
+			seen[name] = component
+			discovered = append(discovered, component)
+		}
+		if component.group == "" && s.GroupLabel != "" {
+			component.group = strings.TrimSpace(labels[s.GroupLabel])
+		}
+	}
+	for _, component := range discovered {
+		if component.group == "" {
+			component.group = s.group
+		}
+	}
+	sort.Slice(discovered, func(i, j int) bool { return discovered[i].name < discovered[j].name })
+	return discovered, nil
+}
+
+// Retired returns the retired components, sorted by name
+func (s *ComponentSync) Retired() []RetiredComponent {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	retired := make([]RetiredComponent, 0, len(s.retired))
+	for _, component := range s.retired {
+		retired = append(retired, *component)
+	}
+	sort.Slice(retired, func(i, j int) bool { return retired[i].Name < retired[j].Name })
+	return retired
+}
+
+// sync creates the missing components (and their group), moves the existing ones to their group, and retires the
+// synced components whose targets are gone
+func (s *ComponentSync) sync(now time.Time) error {
+	discovered, err := s.discovered()
+	if err != nil {
+		return err
+	}
+	cachet := s.config.Cachet
+	components, err := cachet.ListComponentsDetails()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*CachetComponent, len(components))
+	for _, component := range components {
+		existing[component.Name] = component
+	}
+
+	var groups map[string]int
+	if s.group != "" || s.GroupLabel != "" {
+		if groups, err = cachet.ListComponentGroups(); err != nil {
+			return err
+		}
+	}
+
+	for _, discovered := range discovered {
+		s.seen(discovered.name, now)
+		if err := s.revive(discovered.name); err != nil {
+			return err
+		}
+
+		groupID := 0
+		if discovered.group != "" {
+			if groupID = groups[discovered.group]; groupID == 0 {
+				if groupID, err = cachet.CreateComponentGroup(discovered.group); err != nil {
+					return err
+				}
+				groups[discovered.group] = groupID
+				log.Println("created the component group", discovered.group)
+			}
+		}
+
+		component, ok := existing[discovered.name]
+		if !ok {
+			id, err := cachet.CreateComponent(discovered.name, "", groupID)
+			if err != nil {
+				return err
+			}
+			existing[discovered.name] = &CachetComponent{Id: id, Name: discovered.name, GroupId: groupID}
+			log.Println("created the component", discovered.name, "of the discovered targets")
+			continue
+		}
+		if discovered.group != "" && component.GroupId != groupID {
+			if err := cachet.SetComponentGroup(component.Id, groupID); err != nil {
+				return err
+			}
+			log.Println("moved the component", discovered.name, "to the group", discovered.group)
+		}
+	}
+	return s.retire(existing, now)
+}
+
+func (s *ComponentSync) seen(name string, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastSeen[name] = now
+}
+
+// revive enables back a component disabled by the sync, its targets being back
+func (s *ComponentSync) revive(name string) error {
+	s.mutex.Lock()
+	retired := s.retired[name]
+	delete(s.retired, name)
+	s.mutex.Unlock()
+
+	if retired == nil || !retired.Disabled {
+		return nil
+	}
+	if err := s.config.Cachet.SetComponentEnabled(retired.ID, true); err != nil {
+		return err
+	}
+	log.Println("enabled back the component", name, "its targets being back")
+	return nil
+}
+
+// retire reports (and disables) the synced components not discovered since the grace period. Only the components
+// discovered since the start of the bridge are retired: the ones created by hand are left alone
+func (s *ComponentSync) retire(existing map[string]*CachetComponent, now time.Time) error {
+	if s.RetireAfter <= 0 {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for name, lastSeen := range s.lastSeen {
+		component, ok := existing[name]
+		if !ok {
+			// removed by hand
+			delete(s.lastSeen, name)
+			delete(s.retired, name)
+			continue
+		}
+		if now.Sub(lastSeen) < s.RetireAfter || s.retired[name] != nil {
+			continue
+		}
+
+		retired := &RetiredComponent{ID: component.Id, Name: name, LastSeen: lastSeen}
+		if s.RetireAction == COMPONENT_RETIRE_DISABLE {
+			if err := s.config.Cachet.SetComponentEnabled(component.Id, false); err != nil {
+				return err
+			}
+			retired.Disabled = true
+			log.Println("disabled the component", name, "without target since", lastSeen.Format(time.RFC3339))
+		} else {
+			log.Println("the component", name, "has no target since", lastSeen.Format(time.RFC3339), "and could be retired")
+		}
+		s.retired[name] = retired
+	}
+	return nil
+}
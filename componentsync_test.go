@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComponentSync(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/targets", r.URL.Path)
+		w.Write([]byte(`{"status":"success","data":{"activeTargets":[
+			{"labels":{"job":"API","instance":"api-1:80"}},
+			{"labels":{"job":"API","instance":"api-2:80"}},
+			{"labels":{"job":"DNS","instance":"dns:53"}},
+			{"labels":{"instance":"node:9100"}}
+		]}}`))
+	}))
+	defer prometheus.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "token", ts.Client()),
+	}
+
+	// the missing components are created, and the synced ones moved to the group
+	sync := NewComponentSync(config, prometheus.URL, "", "job", "Services", 0)
+	assert.Nil(t, sync.sync(time.Now()))
+	assert.Equal(t, 3, len(fake.components))
+	assert.Equal(t, 1, len(fake.groups))
+	assert.Equal(t, "Services", fake.groups[0].Name)
+	assert.Equal(t, 1, fake.components[0].GroupId)
+	assert.Equal(t, 0, fake.components[1].GroupId)
+	assert.Equal(t, "DNS", fake.components[2].Name)
+	assert.Equal(t, 1, fake.components[2].GroupId)
+	assert.Equal(t, 1, fake.components[2].Status)
+
+	// already in sync
+	assert.Nil(t, sync.sync(time.Now()))
+	assert.Equal(t, 3, len(fake.components))
+	assert.Equal(t, 1, len(fake.groups))
+
+	// a targets file
+	file := writeConfigFile(t, `
+- targets: ["cdn:443"]
+  labels:
+    job: CDN
+`)
+	defer os.Remove(file)
+	assert.Nil(t, NewComponentSync(config, "", file, "job", "", 0).sync(time.Now()))
+	assert.Equal(t, 4, len(fake.components))
+	assert.Equal(t, "CDN", fake.components[3].Name)
+	assert.Equal(t, 0, fake.components[3].GroupId)
+
+	// the groups of the group label (the others in the default group)
+	file = writeConfigFile(t, `
+- targets: ["cdn:443"]
+  labels:
+    job: CDN
+    team: Edge
+- targets: ["mail:25"]
+  labels:
+    job: MAIL
+- targets: ["dns:53"]
+  labels:
+    job: DNS
+    team: Edge
+`)
+	defer os.Remove(file)
+	sync = NewComponentSync(config, "", file, "job", "Services", 0)
+	sync.GroupLabel = "team"
+	assert.Nil(t, sync.sync(time.Now()))
+	assert.Equal(t, 5, len(fake.components))
+	assert.Equal(t, "Edge", fake.groups[1].Name)
+	assert.Equal(t, 2, fake.components[3].GroupId)
+	assert.Equal(t, 2, fake.components[2].GroupId)
+	assert.Equal(t, "MAIL", fake.components[4].Name)
+	assert.Equal(t, 1, fake.components[4].GroupId)
+}
+
+func TestComponentSyncRetire(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	jobs := `{"labels":{"job":"API"}},{"labels":{"job":"DNS"}}`
+	prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"activeTargets":[` + jobs + `]}}`))
+	}))
+	defer prometheus.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "token", ts.Client()),
+	}
+	config.ComponentSync = NewComponentSync(config, prometheus.URL, "", "job", "", 0)
+	config.ComponentSync.RetireAfter = time.Hour
+	config.ComponentSync.RetireAction = COMPONENT_RETIRE_DISABLE
+	now := time.Now()
+	assert.Nil(t, config.ComponentSync.sync(now))
+	assert.Equal(t, 3, len(fake.components))
+
+	// DNS is gone, but within the grace period
+	jobs = `{"labels":{"job":"API"}}`
+	assert.Nil(t, config.ComponentSync.sync(now.Add(30*time.Minute)))
+	assert.Equal(t, 0, len(config.ComponentSync.Retired()))
+	assert.True(t, fake.components[2].Enabled)
+
+	// retired (WEB, never discovered, is left alone)
+	assert.Nil(t, config.ComponentSync.sync(now.Add(time.Hour)))
+	assert.Equal(t, []RetiredComponent{{ID: 3, Name: "DNS", LastSeen: now, Disabled: true}}, config.ComponentSync.Retired())
+	assert.False(t, fake.components[2].Enabled)
+	assert.True(t, fake.components[1].Enabled)
+
+	router := PrepareGinRouter(config)
+	req, _ := http.NewRequest("GET", "/reports/retired", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"DNS"`)
+
+	// back
+	jobs = `{"labels":{"job":"API"}},{"labels":{"job":"DNS"}}`
+	assert.Nil(t, config.ComponentSync.sync(now.Add(2*time.Hour)))
+	assert.Equal(t, 0, len(config.ComponentSync.Retired()))
+	assert.True(t, fake.components[2].Enabled)
+
+	// only reported
+	config.ComponentSync.RetireAction = COMPONENT_RETIRE_REPORT
+	jobs = `{"labels":{"job":"API"}}`
+	assert.Nil(t, config.ComponentSync.sync(now.Add(4*time.Hour)))
+	assert.Equal(t, 1, len(config.ComponentSync.Retired()))
+	assert.True(t, fake.components[2].Enabled)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigFile is the (optional) yaml configuration file, used for everything
+// that doesn't fit into a command line parameter
+//
+//	routes:
+//	  - name: critical
+//	    match:
+//	      severity: critical
+//	    escalation:
+//	      - after: 15m
+//	        status: identified
+//	      - after: 1h
+//	        status: identified
+//	        message: "we are still working on it"
+//	aliases:
+//	  Public API: [api, api-gateway, apigw]
+//	inputs:
+//	  mytool:
+//	    status: $.state
+//	    component: $.service
+//	uptime_metrics:
+//	  Public API: 3
+//	features:
+//	  async_pipeline: true
+type ConfigFile struct {
+	Routes  []*Route            `yaml:"routes"`
+	Aliases map[string][]string `yaml:"aliases"`
+	// the generic input adapters, by name (cf GenericInput)
+	Inputs map[string]*GenericInput `yaml:"inputs"`
+	// the CachetHQ metric ids where the daily availability of the components is pushed, by component name
+	UptimeMetrics map[string]int `yaml:"uptime_metrics"`
+	// the incident texts, by transition (cf IncidentTemplates)
+	Templates *IncidentTemplates `yaml:"templates"`
+	// the incident texts selected by the alerts (cf ANNOTATION_TEMPLATE), by name. They are completed with the
+	// ones of the route of the alert (or the global ones)
+	NamedTemplates map[string]*IncidentTemplates `yaml:"named_templates"`
+	// the translated incident texts, by locale (completed with the global ones), and the locale of the status page
+	// (the global templates if empty), cf Route.Locale
+	Locales map[string]*IncidentTemplates `yaml:"locales"`
+	Locale  string                        `yaml:"locale"`
+	// the health urls checked before resolving the incidents, by component name (cf ResolveProbe)
+	Probes map[string]string `yaml:"probes"`
+	// the experimental behaviors enabled, by name (cf Features)
+	Features Features `yaml:"features"`
+	// the relabeling of the alerts received, before the filter and the component mapping (cf RelabelConfig)
+	RelabelConfigs Relabeling `yaml:"relabel_configs"`
+	// the alerts not matching this expression are ignored (cf AlertExpression)
+	Filter string `yaml:"filter"`
+
+	filter *AlertExpression
+}
+
+// localeTemplates returns the incident texts of the locale (already completed with the global ones), or the global
+// ones if locale is empty
+func (c *ConfigFile) localeTemplates(locale string) (*IncidentTemplates, error) {
+	if locale == "" {
+		return c.Templates, nil
+	}
+	templates, ok := c.Locales[locale]
+	if !ok {
+		return nil, fmt.Errorf("unknown locale '%s'", locale)
+	}
+	return templates, nil
+}
+
+// LoadConfigFile reads and validates the yaml configuration file (normalizeNames is the normalize_names parameter,
+// to detect the aliases colliding once normalized)
+func LoadConfigFile(filename string, normalizeNames bool) (*ConfigFile, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// the unknown (i.e. misspelled) keys are rejected, with their line (cf config.schema.json)
+	var configFile ConfigFile
+	if err := yaml.UnmarshalStrict(content, &configFile); err != nil {
+		return nil, fmt.Errorf("not able to parse %s: %v", filename, err)
+	}
+
+	if err := configFile.Features.validate(); err != nil {
+		return nil, fmt.Errorf("features: %v", err)
+	}
+	if err := configFile.Templates.validate(); err != nil {
+		return nil, fmt.Errorf("templates: %v", err)
+	}
+	for name, templates := range configFile.NamedTemplates {
+		if err := templates.validate(); err != nil {
+			return nil, fmt.Errorf("named_templates %s: %v", name, err)
+		}
+	}
+	if err := configFile.RelabelConfigs.validate(); err != nil {
+		return nil, fmt.Errorf("relabel_configs %v", err)
+	}
+	if configFile.filter, err = CompileExpression(configFile.Filter); err != nil {
+		return nil, fmt.Errorf("filter: %v", err)
+	}
+	for locale, templates := range configFile.Locales {
+		if err := templates.validate(); err != nil {
+			return nil, fmt.Errorf("locales %s: %v", locale, err)
+		}
+		configFile.Locales[locale] = templates.inherit(configFile.Templates)
+	}
+	if configFile.Templates, err = configFile.localeTemplates(configFile.Locale); err != nil {
+		return nil, fmt.Errorf("locale: %v", err)
+	}
+	for i, route := range configFile.Routes {
+		if err := route.validate(); err != nil {
+			return nil, fmt.Errorf("route %d (%s): %v", i, route.Name, err)
+		}
+		templates, err := configFile.localeTemplates(route.Locale)
+		if err != nil {
+			return nil, fmt.Errorf("route %d (%s): locale: %v", i, route.Name, err)
+		}
+		route.Templates = route.Templates.inherit(templates)
+	}
+
+	if _, err := configFile.AliasMap(normalizeNames); err != nil {
+		return nil, err
+	}
+
+	for name, input := range configFile.Inputs {
+		if err := input.validate(name); err != nil {
+			return nil, fmt.Errorf("input %s: %v", name, err)
+		}
+	}
+
+	return &configFile, nil
+}
+
+// AliasMap returns the aliases as a map[alias]componentname. If normalize is set, the aliases are normalized
+// (cf normalizeName), and they must still be unique
+func (c *ConfigFile) AliasMap(normalize bool) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for component, names := range c.Aliases {
+		for _, alias := range names {
+			if normalize {
+				alias = normalizeName(alias)
+			}
+			if previous, ok := aliases[alias]; ok && previous != component {
+				return nil, fmt.Errorf("alias '%s' is used for both '%s' and '%s'", alias, previous, component)
+			}
+			aliases[alias] = component
+		}
+	}
+	return aliases, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "prometheus-cachethq-*.yaml")
+	assert.Nil(t, err)
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	assert.Nil(t, err)
+	return file.Name()
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	filename := writeConfigFile(t, `
+routes:
+  - name: critical
+    match:
+      severity: critical
+    escalation:
+      - after: 15m
+        status: identified
+      - after: 1h
+        status: watching
+        message: still working on it
+  - name: default
+`)
+	defer os.Remove(filename)
+
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(configFile.Routes))
+	assert.Equal(t, "critical", configFile.Routes[0].Name)
+	assert.Equal(t, 15*time.Minute, configFile.Routes[0].Escalation[0].After)
+	assert.Equal(t, 2, configFile.Routes[0].Escalation[0].incidentStatus)
+	assert.Equal(t, 3, configFile.Routes[0].Escalation[1].incidentStatus)
+
+	critical := &PrometheusAlertDetail{Labels: map[string]string{"severity": "critical"}}
+	warning := &PrometheusAlertDetail{Labels: map[string]string{"severity": "warning"}}
+	assert.Equal(t, "critical", MatchRoute(configFile.Routes, "", critical).Name)
+	assert.Equal(t, "default", MatchRoute(configFile.Routes, "", warning).Name)
+}
+
+func TestLoadConfigFileWrongEscalation(t *testing.T) {
+	filename := writeConfigFile(t, `
+routes:
+  - name: critical
+    escalation:
+      - after: 15m
+        status: panicking
+`)
+	defer os.Remove(filename)
+
+	_, err := LoadConfigFile(filename, false)
+	assert.NotNil(t, err)
+}
+
+func TestLoadConfigFileAliases(t *testing.T) {
+	filename := writeConfigFile(t, `
+aliases:
+  Public API: [api, api-gateway]
+  Website: [web]
+`)
+	defer os.Remove(filename)
+
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+	aliases, err := configFile.AliasMap(false)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"api": "Public API", "api-gateway": "Public API", "web": "Website"}, aliases)
+}
+
+func TestLoadConfigFileDuplicatedAlias(t *testing.T) {
+	filename := writeConfigFile(t, `
+aliases:
+  Public API: [api]
+  Website: [api]
+`)
+	defer os.Remove(filename)
+
+	_, err := LoadConfigFile(filename, false)
+	assert.NotNil(t, err)
+}
+
+func TestLoadConfigFileNormalizedAliases(t *testing.T) {
+	filename := writeConfigFile(t, `
+aliases:
+  Public API: [Api-Gateway]
+  Website: [api_gateway]
+`)
+	defer os.Remove(filename)
+
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+	aliases, err := configFile.AliasMap(false)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(aliases))
+
+	// both aliases are "api gateway" once normalized
+	_, err = LoadConfigFile(filename, true)
+	assert.NotNil(t, err)
+}
+
+func TestLoadConfigFileStrict(t *testing.T) {
+	filename := writeConfigFile(t, `
+routes:
+  - name: critical
+    stickied: true
+    escalations:
+      - after: 15m
+        status: identified
+`)
+	defer os.Remove(filename)
+
+	_, err := LoadConfigFile(filename, false)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "line 5: field escalations not found")
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	// the published schema is the one of the ConfigFile (go run . -config_schema > config.schema.json)
+	published, err := ioutil.ReadFile(CONFIG_SCHEMA_FILE)
+	assert.Nil(t, err)
+	assert.Equal(t, string(ConfigSchema()), string(published))
+}
@@ -0,0 +1,22 @@
+#!/bin/sh
+# Nagios/Icinga2 notification command posting the host/service state changes to the prometheus-cachethq bridge.
+#
+# usage: notify-cachethq.sh <host|service> <host> <service> <state> <state type> <output> [component]
+# ex (Nagios):
+#   define command {
+#     command_name notify-service-by-cachethq
+#     command_line /usr/local/bin/notify-cachethq.sh service "$HOSTNAME$" "$SERVICEDESC$" "$SERVICESTATE$" "$SERVICESTATETYPE$" "$SERVICEOUTPUT$" "$_SERVICECACHET_COMPONENT$"
+#   }
+# CACHETHQ_BRIDGE_URL (default http://localhost:8080) and PROMETHEUS_TOKEN are read from the environment
+
+escape() {
+	printf '%s' "$1" | sed -e 's/\\/\\\\/g' -e 's/"/\\"/g' | tr '\n' ' '
+}
+
+payload=$(printf '{"type":"%s","host":"%s","service":"%s","state":"%s","state_type":"%s","output":"%s","component":"%s"}' \
+	"$(escape "$1")" "$(escape "$2")" "$(escape "$3")" "$(escape "$4")" "$(escape "$5")" "$(escape "$6")" "$(escape "$7")")
+
+exec curl -sS --fail -X POST "${CACHETHQ_BRIDGE_URL:-http://localhost:8080}/inputs/nagios" \
+	-H "Authorization: Bearer ${PROMETHEUS_TOKEN}" \
+	-H 'Content-Type: application/json' \
+	-d "$payload"
@@ -0,0 +1,26 @@
+// Zabbix webhook media type posting the problem (and recovery) events to the prometheus-cachethq bridge.
+// Media type parameters:
+//   url                 = http://prometheus_cachet_bridge:8080/inputs/zabbix
+//   token               = <prometheus token>
+//   event_id            = {EVENT.ID}
+//   event_value         = {EVENT.VALUE}
+//   event_update_status = {EVENT.UPDATE.STATUS}
+//   event_name          = {EVENT.NAME}
+//   event_severity      = {EVENT.SEVERITY}
+//   host                = {HOST.NAME}
+//   event_tags          = {EVENT.TAGSJSON}
+var params = JSON.parse(value);
+var url = params.url;
+var token = params.token;
+delete params.url;
+delete params.token;
+
+var request = new HttpRequest();
+request.addHeader('Content-Type: application/json');
+request.addHeader('Authorization: Bearer ' + token);
+
+var response = request.post(url, JSON.stringify(params));
+if (request.getStatus() < 200 || request.getStatus() >= 300) {
+    throw 'prometheus-cachethq answered ' + request.getStatus() + ': ' + response;
+}
+return 'OK';
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// NotifyCooldown holds back the subscriber emails of a component that just recovered: a new incident of the
+// component, within the cooldown after the resolve of its previous one, is still published but does not notify the
+// subscribers again (cf notify_severities), so that a flapping service does not produce a burst of emails
+type NotifyCooldown struct {
+	cooldown time.Duration
+
+	mutex    sync.Mutex
+	resolved map[int]time.Time // the last resolve, by component id
+}
+
+// NewNotifyCooldown creates a new NotifyCooldown
+func NewNotifyCooldown(cooldown time.Duration) *NotifyCooldown {
+	return &NotifyCooldown{
+		cooldown: cooldown,
+		resolved: make(map[int]time.Time),
+	}
+}
+
+// resolve records the resolve of an incident of the component
+func (c *NotifyCooldown) resolve(componentID int, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.resolved[componentID] = now
+	// forget the components out of their cooldown
+	for id, at := range c.resolved {
+		if now.Sub(at) >= c.cooldown {
+			delete(c.resolved, id)
+		}
+	}
+}
+
+// cooling returns true if an incident of the component was resolved within the cooldown
+func (c *NotifyCooldown) cooling(componentID int, now time.Time) bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	at, ok := c.resolved[componentID]
+	return ok && now.Sub(at) < c.cooldown
+}
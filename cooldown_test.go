@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyCooldown(t *testing.T) {
+	var disabled *NotifyCooldown
+	disabled.resolve(1, time.Now())
+	assert.False(t, disabled.cooling(1, time.Now()))
+
+	cooldown := NewNotifyCooldown(30 * time.Minute)
+	now := time.Now()
+	assert.False(t, cooldown.cooling(1, now))
+	cooldown.resolve(1, now)
+	assert.True(t, cooldown.cooling(1, now.Add(10*time.Minute)))
+	assert.False(t, cooldown.cooling(2, now.Add(10*time.Minute)))
+	assert.False(t, cooldown.cooling(1, now.Add(30*time.Minute)))
+
+	// forgotten once out of the cooldown
+	cooldown.resolve(2, now.Add(time.Hour))
+	assert.Equal(t, 1, len(cooldown.resolved))
+}
+
+func TestNotifyCooldownIncidents(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:        "alertname",
+		Cachet:           NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident:   true,
+		FiringAlerts:     NewFiringAlerts(),
+		NotifySeverities: []string{"critical"},
+		NotifyCooldown:   NewNotifyCooldown(time.Hour),
+	}
+	api := PrometheusAlertDetail{Labels: map[string]string{"alertname": "API", "severity": "critical"}}
+	web := PrometheusAlertDetail{Labels: map[string]string{"alertname": "WEB", "severity": "critical"}}
+	for _, status := range []string{"firing", "resolved", "firing"} {
+		_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: status, Alerts: []PrometheusAlertDetail{api}})
+		assert.Nil(t, err)
+	}
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{web}})
+	assert.Nil(t, err)
+
+	assert.Equal(t, 3, len(fake.incidents))
+	assert.True(t, fake.incidents[0].Notify)
+	// API flapped: still published, without emailing the subscribers again
+	assert.False(t, fake.incidents[1].Notify)
+	assert.Equal(t, 1, fake.incidents[1].Visible)
+	assert.True(t, fake.incidents[2].Notify)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CORS_ALLOWED_METHODS = "GET,POST,PUT,DELETE"
+	CORS_ALLOWED_HEADERS = "Authorization,Content-Type"
+	// how long (in seconds) the browsers cache a preflight answer
+	CORS_MAX_AGE = "600"
+)
+
+// CORS are the cross-origin requests allowed, for the dashboards hosted on another origin
+type CORS struct {
+	origins []string // "*" allows any origin
+	methods string
+	headers string
+}
+
+// NewCORS creates a new CORS policy, from comma separated lists
+func NewCORS(origins, methods, headers string) *CORS {
+	return &CORS{
+		origins: splitList(origins),
+		methods: strings.Join(splitList(methods), ", "),
+		headers: strings.Join(splitList(headers), ", "),
+	}
+}
+
+func (cors *CORS) allowed(origin string) bool {
+	for _, allowed := range cors.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSMiddleware adds the CORS headers to the answers to the allowed origins, and answers their preflight requests
+func CORSMiddleware(cors *CORS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if !cors.allowed(origin) {
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", cors.methods)
+			c.Header("Access-Control-Allow-Headers", cors.headers)
+			c.Header("Access-Control-Max-Age", CORS_MAX_AGE)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
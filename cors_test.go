@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	router := PrepareGinRouter(&PrometheusCachetConfig{
+		CORS: NewCORS("https://dashboard.example.com", CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS),
+	})
+
+	// preflight
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("OPTIONS", "/admin/history", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 204, w.Code)
+	assert.Equal(t, "https://dashboard.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST, PUT, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Authorization, Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "https://dashboard.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	// another origin
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	assert.True(t, NewCORS("*", "", "").allowed("https://any.example.com"))
+}
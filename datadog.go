@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// datadogEvent is the payload posted by a Datadog webhook, with the template documented in the README
+type datadogEvent struct {
+	AlertID    string `json:"alert_id"` // the monitor id
+	Transition string `json:"alert_transition"`
+	Scope      string `json:"alert_scope"` // the group of a multi alert monitor
+	Title      string `json:"title"`
+	Hostname   string `json:"hostname"`
+	Tags       string `json:"tags"` // comma separated key:value
+}
+
+// datadogTransitions are the severities of the transitions firing an incident (Recovered resolving it)
+var datadogTransitions = map[string]string{
+	"triggered":    "critical",
+	"re-triggered": "critical",
+	"warn":         "warning",
+	"re-warn":      "warning",
+}
+
+func (e *datadogEvent) tag(name string) string {
+	for _, tag := range splitList(e.Tags) {
+		if strings.HasPrefix(tag, name+":") {
+			return strings.TrimPrefix(tag, name+":")
+		}
+	}
+	return ""
+}
+
+// DatadogAdapter translates the Datadog monitor notifications
+type DatadogAdapter struct{}
+
+// Parse translates a monitor alert (or recovery): the impacted component is the cachet_component tag, or the host
+func (a *DatadogAdapter) Parse(config *PrometheusCachetConfig, body []byte) (*PrometheusAlert, error) {
+	var event datadogEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	if event.AlertID == "" || event.Transition == "" {
+		return nil, fmt.Errorf("alert_id and alert_transition are required")
+	}
+
+	transition := strings.ToLower(event.Transition)
+	severity, firing := datadogTransitions[transition]
+	if !firing && transition != "recovered" {
+		// ex: No Data, Renotify
+		return nil, nil
+	}
+
+	component := event.tag(INPUT_COMPONENT_TAG)
+	if component == "" {
+		component = event.Hostname
+	}
+	if component == "" {
+		return nil, fmt.Errorf("no hostname nor %s tag", INPUT_COMPONENT_TAG)
+	}
+
+	// a multi alert monitor has an alert by scope
+	key := event.AlertID
+	if event.Scope != "" && event.Scope != "*" {
+		key += "/" + event.Scope
+	}
+	alerts := newInputAlert(config, "datadog", firing, component, key)
+	alert := &alerts.Alerts[0]
+	if firing {
+		alert.Labels[LABEL_SEVERITY] = severity
+	}
+	if event.Title != "" {
+		alert.Annotations[ANNOTATION_SUMMARY] = event.Title
+	}
+	return alerts, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatadogAdapter(t *testing.T) {
+	config := &PrometheusCachetConfig{LabelName: "alertname"}
+	adapter := &DatadogAdapter{}
+
+	alerts, err := adapter.Parse(config, []byte(`{"alert_id":"1234","alert_transition":"Triggered","alert_scope":"host:web01","title":"[Triggered on {host:web01}] High latency","hostname":"web01","tags":"env:prod, cachet_component:API"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "firing", alerts.Status)
+	assert.Equal(t, "API", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "critical", alerts.Alerts[0].Labels[LABEL_SEVERITY])
+	assert.Equal(t, "[Triggered on {host:web01}] High latency", alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY])
+	assert.Equal(t, "datadog:1234/host:web01", alerts.Alerts[0].fingerprint())
+
+	alerts, err = adapter.Parse(config, []byte(`{"alert_id":"1234","alert_transition":"Recovered","alert_scope":"*","hostname":"web01","tags":"env:prod"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "resolved", alerts.Status)
+	assert.Equal(t, "web01", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "datadog:1234", alerts.Alerts[0].fingerprint())
+
+	alerts, err = adapter.Parse(config, []byte(`{"alert_id":"1234","alert_transition":"Warn","hostname":"web01"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "warning", alerts.Alerts[0].Labels[LABEL_SEVERITY])
+
+	alerts, err = adapter.Parse(config, []byte(`{"alert_id":"1234","alert_transition":"No Data","hostname":"web01"}`))
+	assert.Nil(t, err)
+	assert.Nil(t, alerts)
+
+	_, err = adapter.Parse(config, []byte(`{"alert_id":"1234","alert_transition":"Triggered"}`))
+	assert.NotNil(t, err)
+	_, err = adapter.Parse(config, []byte(`{"title":"test"}`))
+	assert.NotNil(t, err)
+}
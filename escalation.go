@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+type escalatedIncident struct {
+	incidentID    int
+	componentName string
+	// the name of each component of the incident (cf Metrics.IncidentAction)
+	componentNames []string
+	severity       string
+	alert          PrometheusAlertDetail
+	route          *Route
+	firingSince    time.Time
+	nextStep       int
+}
+
+// IncidentEscalator progresses the status of the open incidents over time,
+// while the alert keeps firing, following the escalation steps of their routes
+type IncidentEscalator struct {
+	cachet   Cachet
+	metrics  *Metrics
+	interval time.Duration
+
+	// the escalations are delayed while the forwarding is paused
+	forwarding *Forwarding
+	// the named incident texts, selected by the alerts (cf ANNOTATION_TEMPLATE)
+	named map[string]*IncidentTemplates
+	// the incidents edited by a human keep their status (cf Route.PreserveEdits)
+	revisions     *IncidentRevisions
+	preserveEdits bool
+
+	mutex     sync.Mutex
+	incidents map[int]*escalatedIncident // key is the component id
+}
+
+// NewIncidentEscalator creates a new IncidentEscalator, checking the open incidents every interval
+func NewIncidentEscalator(cachet Cachet, metrics *Metrics, interval time.Duration) *IncidentEscalator {
+	return &IncidentEscalator{
+		cachet:    cachet,
+		metrics:   metrics,
+		interval:  interval,
+		incidents: make(map[int]*escalatedIncident),
+	}
+}
+
+// SetNamedTemplates sets the named incident texts, selected by the annotation of the alerts
+func (e *IncidentEscalator) SetNamedTemplates(named map[string]*IncidentTemplates) {
+	e.named = named
+}
+
+// SetForwarding delays the escalations while the forwarding is paused
+func (e *IncidentEscalator) SetForwarding(forwarding *Forwarding) {
+	e.forwarding = forwarding
+}
+
+// SetRevisions keeps the status of the incidents edited by a human since the bridge wrote them (preserveEdits being
+// the default of the routes)
+func (e *IncidentEscalator) SetRevisions(revisions *IncidentRevisions, preserveEdits bool) {
+	e.revisions = revisions
+	e.preserveEdits = preserveEdits
+}
+
+// Track starts to follow the incident of an alert, if its route has escalation steps
+func (e *IncidentEscalator) Track(componentID, incidentID int, componentName string, componentNames []string, route *Route, alert *PrometheusAlertDetail) {
+	if e == nil || route == nil || len(route.Escalation) == 0 {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.incidents[componentID] = &escalatedIncident{
+		incidentID:     incidentID,
+		componentName:  componentName,
+		componentNames: componentNames,
+		severity:       alert.Labels[LABEL_SEVERITY],
+		alert:          *alert,
+		route:          route,
+		firingSince:    alert.firingSince(),
+	}
+}
+
+// Forget stops to follow the incident of a component (i.e. the alert is resolved)
+func (e *IncidentEscalator) Forget(componentID int) {
+	if e == nil {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	delete(e.incidents, componentID)
+}
+
+// Run checks the open incidents every interval, until stop is closed
+func (e *IncidentEscalator) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if !e.forwarding.Paused() {
+				e.check(now)
+			}
+		}
+	}
+}
+
+// escalationUpdate is an escalation step due for an incident
+type escalationUpdate struct {
+	componentID int
+	incident    escalatedIncident
+	step        int
+	message     string
+}
+
+func (e *IncidentEscalator) check(now time.Time) {
+	// the CachetHQ calls are done outside of the lock, to not block Track/Forget (i.e. the alert processing)
+	for _, update := range e.dueUpdates(now) {
+		if err := e.cachet.CreateIncidentUpdate(update.incident.incidentID, e.status(update), update.message); err != nil {
+			// we will retry on the next check
+			log.Println("not able to escalate incident", update.incident.incidentID, ":", err)
+			continue
+		}
+		e.metrics.IncidentAction(update.incident.componentNames, update.incident.severity, METRIC_INCIDENT_UPDATED)
+		e.stepDone(update)
+	}
+}
+
+// status returns the status of the escalation step, or the current one of the incident if a human edited it
+func (e *IncidentEscalator) status(update escalationUpdate) int {
+	status := update.incident.route.Escalation[update.step].incidentStatus
+	if e.revisions == nil || !update.incident.route.preserveEdits(e.preserveEdits) {
+		return status
+	}
+	incident, err := e.cachet.ReadIncident(update.incident.incidentID)
+	if err != nil {
+		log.Println("not able to read the incident", update.incident.incidentID, ", escalated anyway:", err)
+		return status
+	}
+	if e.revisions.edited(incident) {
+		log.Println("incident", incident.Id, "edited since the bridge wrote it, keeping its status")
+		return incident.Status
+	}
+	return status
+}
+
+// dueUpdates returns the latest escalation step due for each incident (the outdated ones are skipped)
+func (e *IncidentEscalator) dueUpdates(now time.Time) []escalationUpdate {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	updates := make([]escalationUpdate, 0)
+	for componentID, incident := range e.incidents {
+		steps := incident.route.Escalation
+		step := incident.nextStep
+		// skip the steps already outdated (i.e. a later one is due too)
+		for step+1 < len(steps) && now.Sub(incident.firingSince) >= steps[step+1].After {
+			step++
+		}
+		if step >= len(steps) || now.Sub(incident.firingSince) < steps[step].After {
+			continue
+		}
+
+		message := steps[step].Message
+		if message == "" {
+			message = fmt.Sprintf("Prometheus still flags service %s as down (for %d minutes)", incident.componentName, int(now.Sub(incident.firingSince).Minutes()))
+			if templates := selectTemplates(e.named, incident.route.Templates, &incident.alert); templates != nil {
+				_, message = templates.Updated.render(NewIncidentTemplateData(incident.componentName, &incident.alert, now.Sub(incident.firingSince)), "", message)
+			}
+		}
+
+		updates = append(updates, escalationUpdate{componentID: componentID, incident: *incident, step: step, message: message})
+	}
+	return updates
+}
+
+// stepDone records a sent escalation step, unless the incident was forgotten (or replaced) meanwhile
+func (e *IncidentEscalator) stepDone(update escalationUpdate) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	incident, ok := e.incidents[update.componentID]
+	if !ok || incident.incidentID != update.incident.incidentID {
+		return
+	}
+	incident.nextStep = update.step + 1
+	if incident.nextStep >= len(incident.route.Escalation) {
+		delete(e.incidents, update.componentID)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncidentEscalator(t *testing.T) {
+	updates := make([]cachetHqIncidentStatus, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api/v1/incidents/12/updates", r.URL.Path)
+		var update cachetHqIncidentStatus
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&update))
+		updates = append(updates, update)
+	}))
+	defer ts.Close()
+
+	route := &Route{
+		Name: "critical",
+		Escalation: []*EscalationStep{
+			{After: 10 * time.Minute, incidentStatus: 2},
+			{After: time.Hour, incidentStatus: 2, Message: "still on it"},
+		},
+	}
+
+	escalator := NewIncidentEscalator(NewCachetImpl(ts.URL, "token", ts.Client()), nil, time.Minute)
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	escalator.Track(1, 12, "API", []string{"API"}, route, &PrometheusAlertDetail{StartAt: start.Format(time.RFC3339), Fingerprint: "abc123"})
+
+	escalator.check(start.Add(5 * time.Minute))
+	assert.Equal(t, 0, len(updates))
+
+	escalator.check(start.Add(10 * time.Minute))
+	assert.Equal(t, 1, len(updates))
+	assert.Equal(t, 2, updates[0].Status)
+	assert.Equal(t, "Prometheus still flags service API as down (for 10 minutes)", updates[0].Message)
+
+	escalator.check(start.Add(61 * time.Minute))
+	assert.Equal(t, 2, len(updates))
+	assert.Equal(t, "still on it", updates[1].Message)
+
+	// all steps done
+	escalator.check(start.Add(120 * time.Minute))
+	assert.Equal(t, 2, len(updates))
+}
+
+func TestIncidentEscalatorSkipsOutdatedSteps(t *testing.T) {
+	updates := make([]cachetHqIncidentStatus, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var update cachetHqIncidentStatus
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&update))
+		updates = append(updates, update)
+	}))
+	defer ts.Close()
+
+	route := &Route{
+		Escalation: []*EscalationStep{
+			{After: 10 * time.Minute, incidentStatus: 2},
+			{After: time.Hour, incidentStatus: 3},
+		},
+	}
+
+	escalator := NewIncidentEscalator(NewCachetImpl(ts.URL, "token", ts.Client()), nil, time.Minute)
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	escalator.Track(1, 12, "API", []string{"API"}, route, &PrometheusAlertDetail{StartAt: start.Format(time.RFC3339)})
+
+	// both steps are due: only the latest one is sent
+	escalator.check(start.Add(2 * time.Hour))
+	assert.Equal(t, 1, len(updates))
+	assert.Equal(t, 3, updates[0].Status)
+
+	escalator.check(start.Add(3 * time.Hour))
+	assert.Equal(t, 1, len(updates))
+}
+
+func TestIncidentEscalatorForget(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no escalation expected")
+	}))
+	defer ts.Close()
+
+	route := &Route{Escalation: []*EscalationStep{{After: time.Minute, incidentStatus: 2}}}
+
+	escalator := NewIncidentEscalator(NewCachetImpl(ts.URL, "token", ts.Client()), nil, time.Minute)
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	escalator.Track(1, 12, "API", []string{"API"}, route, &PrometheusAlertDetail{StartAt: start.Format(time.RFC3339)})
+	escalator.Forget(1)
+
+	escalator.check(start.Add(time.Hour))
+}
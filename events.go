@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// EVENTS_KEEPALIVE is how often a comment is sent on an idle /events stream (to detect the gone clients)
+const EVENTS_KEEPALIVE = 30 * time.Second
+
+const (
+	EVENT_WEBHOOK  = "webhook"
+	EVENT_INCIDENT = "incident"
+	EVENT_ERROR    = "error"
+)
+
+// Event is something the bridge did, streamed on /events
+type Event struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	// EVENT_WEBHOOK
+	Receiver string `json:"receiver,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Alerts   int    `json:"alerts,omitempty"`
+	// EVENT_INCIDENT
+	Components []string `json:"components,omitempty"`
+	IncidentID int      `json:"incident_id,omitempty"`
+	Action     string   `json:"action,omitempty"`
+	// EVENT_ERROR
+	Message string `json:"message,omitempty"`
+}
+
+// EventBroker dispatches the events of the bridge to the /events subscribers
+type EventBroker struct {
+	mutex       sync.Mutex
+	subscribers map[chan *Event]struct{}
+}
+
+// NewEventBroker creates a new EventBroker
+func NewEventBroker() *EventBroker {
+	return &EventBroker{subscribers: make(map[chan *Event]struct{})}
+}
+
+// Subscribe returns a channel receiving the next events
+func (b *EventBroker) Subscribe() chan *Event {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	events := make(chan *Event, 16)
+	b.subscribers[events] = struct{}{}
+	return events
+}
+
+// Unsubscribe stops sending the events to the channel
+func (b *EventBroker) Unsubscribe(events chan *Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.subscribers, events)
+}
+
+// Publish sends an event to all the subscribers. It never blocks: slow subscribers miss events
+func (b *EventBroker) Publish(event *Event) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBroker(t *testing.T) {
+	broker := NewEventBroker()
+	events := broker.Subscribe()
+
+	broker.Publish(&Event{Type: EVENT_ERROR, Message: "boom"})
+	event := <-events
+	assert.Equal(t, "boom", event.Message)
+	assert.False(t, event.Time.IsZero())
+
+	// slow subscribers miss events, but never block the bridge
+	for i := 0; i < 100; i++ {
+		broker.Publish(&Event{Type: EVENT_ERROR})
+	}
+	assert.Equal(t, 16, len(events))
+
+	broker.Unsubscribe(events)
+	var none *EventBroker
+	none.Publish(&Event{Type: EVENT_ERROR})
+}
+
+func TestEventsEndpoint(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	cachet := httptest.NewServer(fake)
+	defer cachet.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(cachet.URL, "token", cachet.Client()),
+		Events:    NewEventBroker(),
+	}
+	ts := httptest.NewServer(WithWriteTimeout(PrepareGinRouter(config), time.Second))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/events")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// after the write timeout of the other requests
+	time.Sleep(1500 * time.Millisecond)
+
+	payload := `{"version":"4","status":"firing","receiver":"cachet","alerts":[{"labels":{"alertname":"API"}}]}`
+	alert, err := http.Post(ts.URL+"/alert", "application/json", bytes.NewBufferString(payload))
+	assert.Nil(t, err)
+	alert.Body.Close()
+	assert.Equal(t, 200, alert.StatusCode)
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && len(lines) < 4 {
+		if line := scanner.Text(); line != "" && !strings.HasPrefix(line, ":") {
+			lines = append(lines, line)
+		}
+	}
+	if !assert.Equal(t, 4, len(lines)) {
+		return
+	}
+	assert.Equal(t, "event:webhook", lines[0])
+	assert.True(t, strings.Contains(lines[1], `"receiver":"cachet"`))
+	assert.Equal(t, "event:incident", lines[2])
+	assert.True(t, strings.Contains(lines[3], `"action":"created"`))
+	assert.True(t, strings.Contains(lines[3], `"components":["API"]`))
+}
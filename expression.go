@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/vm"
+)
+
+// expressionEnv returns what the expressions are evaluated against, ex:
+//
+//	labels.severity == "critical" && labels.env in ["prod", "staging"]
+//	receiver == "cachet" and annotations.summary matches "(?i)database"
+func expressionEnv(receiver string, labels, annotations map[string]string) map[string]interface{} {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	return map[string]interface{}{"labels": labels, "annotations": annotations, "receiver": receiver}
+}
+
+// AlertExpression is a boolean expression over the labels and the annotations of an alert (and the Alertmanager
+// receiver), cf https://github.com/antonmedv/expr/blob/master/docs/Language-Definition.md
+type AlertExpression struct {
+	source  string
+	program *vm.Program
+}
+
+// CompileExpression compiles a boolean expression (nil if source is empty)
+func CompileExpression(source string) (*AlertExpression, error) {
+	if source == "" {
+		return nil, nil
+	}
+	program, err := expr.Compile(source, expr.Env(expressionEnv("", nil, nil)), expr.AsBool())
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression '%s': %v", source, err)
+	}
+	return &AlertExpression{source: source, program: program}, nil
+}
+
+// matches evaluates the expression on the alert (always true if nil). The missing labels are empty strings, and an
+// expression failing at runtime (ex: an invalid regexp) does not match
+func (e *AlertExpression) matches(receiver string, alert *PrometheusAlertDetail) bool {
+	if e == nil {
+		return true
+	}
+	result, err := expr.Run(e.program, expressionEnv(receiver, alert.Labels, alert.Annotations))
+	if err != nil {
+		log.Printf("expression '%s' failed: %v", e.source, err)
+		return false
+	}
+	return result.(bool)
+}
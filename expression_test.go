@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigFileExpressions(t *testing.T) {
+	filename := writeConfigFile(t, `
+filter: labels.env != "dev"
+routes:
+  - name: critical
+    when: labels.severity == "critical" && labels.env in ["prod", "staging"]
+  - name: database
+    receiver: cachet
+    when: annotations.summary matches "(?i)database"
+  - name: default
+`)
+	defer os.Remove(filename)
+
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+
+	critical := &PrometheusAlertDetail{Labels: map[string]string{"severity": "critical", "env": "prod"}}
+	database := &PrometheusAlertDetail{Labels: map[string]string{"severity": "critical", "env": "dev"}, Annotations: map[string]string{"summary": "Database down"}}
+	assert.Equal(t, "critical", MatchRoute(configFile.Routes, "cachet", critical).Name)
+	assert.Equal(t, "database", MatchRoute(configFile.Routes, "cachet", database).Name)
+	assert.Equal(t, "default", MatchRoute(configFile.Routes, "other", database).Name)
+	assert.Equal(t, "default", MatchRoute(configFile.Routes, "", &PrometheusAlertDetail{}).Name)
+
+	assert.True(t, configFile.filter.matches("", critical))
+	assert.False(t, configFile.filter.matches("", database))
+
+	// the expressions are checked on load
+	for _, expression := range []string{`labels.severity`, `severity == "critical"`, `labels.env ==`} {
+		filename := writeConfigFile(t, "routes:\n  - when: '"+expression+"'\n")
+		defer os.Remove(filename)
+		_, err := LoadConfigFile(filename, false)
+		assert.NotNil(t, err, expression)
+	}
+}
+
+func TestFilterExpression(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "token", ts.Client()),
+	}
+	config.Filter, _ = CompileExpression(`labels.env != "dev"`)
+
+	report, err := ProcessAlerts(config, &PrometheusAlert{
+		Version: "4",
+		Status:  "firing",
+		Alerts:  []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API", "env": "dev"}}},
+	})
+	assert.Nil(t, err)
+	assert.True(t, report.Alerts[0].Filtered)
+	assert.Equal(t, 1, fake.components[0].Status)
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FAKE_CACHET_VERSION is the CachetHQ version emulated by FakeCachet
+const FAKE_CACHET_VERSION = "2.4.1"
+
+type fakeCachetComponent struct {
+	Id          int               `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Link        string            `json:"link"`
+	Status      int               `json:"status"`
+	GroupId     int               `json:"group_id"`
+	Enabled     bool              `json:"enabled"`
+	Tags        map[string]string `json:"tags"`
+	UpdatedAt   string            `json:"updated_at"`
+}
+
+type fakeCachetGroup struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type fakeCachetIncident struct {
+	Id          int    `json:"id"`
+	ComponentId int    `json:"component_id"`
+	Name        string `json:"name"`
+	Message     string `json:"message"`
+	Status      int    `json:"status"`
+	Visible     int    `json:"visible"`
+	Stickied    bool   `json:"stickied"`
+	Notify      bool   `json:"notify"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+type fakeCachetIncidentUpdate struct {
+	Id         int    `json:"id"`
+	IncidentId int    `json:"incident_id"`
+	Status     int    `json:"status"`
+	Message    string `json:"message"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type fakeCachetSchedule struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Status      int    `json:"status"`
+	ScheduledAt string `json:"scheduled_at"`
+	CompletedAt string `json:"completed_at,omitempty"`
+	Components  []struct {
+		ComponentId int `json:"component_id"`
+	} `json:"components"`
+}
+
+// FakeCachet is an in-memory implementation of the subset of the CachetHQ API used by the bridge,
+// for local development and CI (cf the fake_cachet parameter)
+type FakeCachet struct {
+	mutex      sync.Mutex
+	components []*fakeCachetComponent
+	groups     []*fakeCachetGroup
+	incidents  []*fakeCachetIncident
+	updates    []*fakeCachetIncidentUpdate
+	schedules  []*fakeCachetSchedule
+	// metric points, by metric id
+	points map[int][]cachetHqMetricPoint
+}
+
+// NewFakeCachet creates a fake CachetHQ with the given components.
+// A component can be put in a group with the "group/component" syntax
+func NewFakeCachet(components []string) *FakeCachet {
+	f := &FakeCachet{
+		components: make([]*fakeCachetComponent, 0),
+		groups:     make([]*fakeCachetGroup, 0),
+		incidents:  make([]*fakeCachetIncident, 0),
+		updates:    make([]*fakeCachetIncidentUpdate, 0),
+		schedules:  make([]*fakeCachetSchedule, 0),
+		points:     make(map[int][]cachetHqMetricPoint),
+	}
+
+	for _, name := range components {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		groupID := 0
+		if i := strings.Index(name, "/"); i > 0 {
+			groupID = f.group(name[:i])
+			name = name[i+1:]
+		}
+
+		f.components = append(f.components, &fakeCachetComponent{
+			Id:      len(f.components) + 1,
+			Name:    name,
+			Status:  1,
+			GroupId: groupID,
+			Enabled: true,
+			Tags:    map[string]string{},
+		})
+	}
+	return f
+}
+
+// AddSchedule adds a scheduled maintenance of the components (completedAt being zero if its end is unknown),
+// and returns its id (the schedules are not editable with the API of the fake)
+func (f *FakeCachet) AddSchedule(name string, status int, scheduledAt, completedAt time.Time, componentIDs ...int) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	schedule := &fakeCachetSchedule{
+		Id:          len(f.schedules) + 1,
+		Name:        name,
+		Status:      status,
+		ScheduledAt: scheduledAt.UTC().Format(CACHET_TIME_LAYOUT),
+	}
+	if !completedAt.IsZero() {
+		schedule.CompletedAt = completedAt.UTC().Format(CACHET_TIME_LAYOUT)
+	}
+	for _, id := range componentIDs {
+		schedule.Components = append(schedule.Components, struct {
+			ComponentId int `json:"component_id"`
+		}{id})
+	}
+	f.schedules = append(f.schedules, schedule)
+	return schedule.Id
+}
+
+// RemoveSchedule removes a scheduled maintenance
+func (f *FakeCachet) RemoveSchedule(id int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for i, schedule := range f.schedules {
+		if schedule.Id == id {
+			f.schedules = append(f.schedules[:i], f.schedules[i+1:]...)
+			return
+		}
+	}
+}
+
+// MetricPoints returns the points added to a metric
+func (f *FakeCachet) MetricPoints(metricID int) []cachetHqMetricPoint {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]cachetHqMetricPoint{}, f.points[metricID]...)
+}
+
+func (f *FakeCachet) group(name string) int {
+	for _, group := range f.groups {
+		if group.Name == name {
+			return group.Id
+		}
+	}
+	f.groups = append(f.groups, &fakeCachetGroup{Id: len(f.groups) + 1, Name: name})
+	return len(f.groups)
+}
+
+func fakeCachetNow() string {
+	return time.Now().UTC().Format(CACHET_TIME_LAYOUT)
+}
+
+func fakeCachetList(w http.ResponseWriter, data interface{}, count int) {
+	list := map[string]interface{}{
+		"meta": map[string]interface{}{
+			"pagination": map[string]interface{}{
+				"total":        count,
+				"count":        count,
+				"per_page":     count,
+				"current_page": 1,
+				"total_pages":  1,
+			},
+		},
+		"data": data,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func fakeCachetItem(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func (f *FakeCachet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	path := strings.TrimPrefix(strings.TrimRight(r.URL.Path, "/"), "/api/v1")
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	switch {
+	case path == "/ping" && r.Method == http.MethodGet:
+		fakeCachetItem(w, "Pong!")
+
+	case path == "/version" && r.Method == http.MethodGet:
+		fakeCachetItem(w, FAKE_CACHET_VERSION)
+
+	case path == "/components" && r.Method == http.MethodGet:
+		components := make([]*fakeCachetComponent, 0)
+		for _, component := range f.components {
+			if name := r.FormValue("name"); name == "" || name == component.Name {
+				components = append(components, component)
+			}
+		}
+		fakeCachetList(w, components, len(components))
+
+	case path == "/components" && r.Method == http.MethodPost:
+		component := &fakeCachetComponent{Status: 1, Enabled: true, Tags: map[string]string{}}
+		if err := json.NewDecoder(r.Body).Decode(component); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		component.Id = len(f.components) + 1
+		f.components = append(f.components, component)
+		fakeCachetItem(w, component)
+
+	case path == "/components/groups" && r.Method == http.MethodGet:
+		fakeCachetList(w, f.groups, len(f.groups))
+
+	case path == "/components/groups" && r.Method == http.MethodPost:
+		var group fakeCachetGroup
+		if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id := f.group(group.Name)
+		fakeCachetItem(w, f.groups[id-1])
+
+	case len(parts) == 2 && parts[0] == "components" && r.Method == http.MethodPut:
+		component := f.findComponent(parts[1])
+		if component == nil {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, component); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		component.UpdatedAt = fakeCachetNow()
+		fakeCachetItem(w, component)
+
+	case path == "/schedules" && r.Method == http.MethodGet:
+		fakeCachetList(w, f.schedules, len(f.schedules))
+
+	case len(parts) == 3 && parts[0] == "metrics" && parts[2] == "points" && r.Method == http.MethodPost:
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		var point cachetHqMetricPoint
+		if err := json.NewDecoder(r.Body).Decode(&point); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.points[id] = append(f.points[id], point)
+		fakeCachetItem(w, &point)
+
+	case path == "/incidents" && r.Method == http.MethodGet:
+		incidents := make([]*fakeCachetIncident, 0)
+		for _, incident := range f.incidents {
+			if id := r.FormValue("component_id"); id != "" && id != strconv.Itoa(incident.ComponentId) {
+				continue
+			}
+			if status := r.FormValue("status"); status != "" && status != strconv.Itoa(incident.Status) {
+				continue
+			}
+			// same layout (and timezone): the dates compare as strings
+			if since := r.FormValue("since"); since != "" && incident.CreatedAt < since {
+				continue
+			}
+			incidents = append(incidents, incident)
+		}
+		// latest first
+		sort.Slice(incidents, func(i, j int) bool { return incidents[i].Id > incidents[j].Id })
+		fakeCachetList(w, incidents, len(incidents))
+
+	case path == "/incidents" && r.Method == http.MethodPost:
+		var request struct {
+			fakeCachetIncident
+			ComponentStatus int `json:"component_status"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		incident := request.fakeCachetIncident
+		incident.Id = len(f.incidents) + 1
+		incident.CreatedAt = fakeCachetNow()
+		incident.UpdatedAt = incident.CreatedAt
+		f.incidents = append(f.incidents, &incident)
+		f.setComponentStatus(incident.ComponentId, request.ComponentStatus)
+		fakeCachetItem(w, &incident)
+
+	case len(parts) == 2 && parts[0] == "incidents" && (r.Method == http.MethodGet || r.Method == http.MethodPut):
+		incident := f.findIncident(parts[1])
+		if incident == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			var request struct {
+				ComponentStatus int `json:"component_status"`
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(body, incident); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			json.Unmarshal(body, &request)
+			incident.UpdatedAt = fakeCachetNow()
+			f.setComponentStatus(incident.ComponentId, request.ComponentStatus)
+		}
+		fakeCachetItem(w, incident)
+
+	case len(parts) == 3 && parts[0] == "incidents" && parts[2] == "updates" && r.Method == http.MethodPost:
+		incident := f.findIncident(parts[1])
+		if incident == nil {
+			http.NotFound(w, r)
+			return
+		}
+		var update fakeCachetIncidentUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		update.Id = len(f.updates) + 1
+		update.IncidentId = incident.Id
+		update.CreatedAt = fakeCachetNow()
+		f.updates = append(f.updates, &update)
+		// the update sets the status of its incident
+		incident.Status = update.Status
+		incident.UpdatedAt = update.CreatedAt
+		fakeCachetItem(w, &update)
+
+	case len(parts) == 2 && parts[0] == "incidents" && r.Method == http.MethodDelete:
+		for i, incident := range f.incidents {
+			if strconv.Itoa(incident.Id) == parts[1] {
+				f.incidents = append(f.incidents[:i], f.incidents[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		http.NotFound(w, r)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *FakeCachet) findComponent(id string) *fakeCachetComponent {
+	for _, component := range f.components {
+		if strconv.Itoa(component.Id) == id {
+			return component
+		}
+	}
+	return nil
+}
+
+func (f *FakeCachet) findIncident(id string) *fakeCachetIncident {
+	for _, incident := range f.incidents {
+		if strconv.Itoa(incident.Id) == id {
+			return incident
+		}
+	}
+	return nil
+}
+
+func (f *FakeCachet) setComponentStatus(componentID, status int) {
+	if status == 0 {
+		return
+	}
+	if component := f.findComponent(strconv.Itoa(componentID)); component != nil {
+		component.Status = status
+		component.UpdatedAt = fakeCachetNow()
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeCachet(t *testing.T) {
+	ts := httptest.NewServer(NewFakeCachet([]string{"api", "EU Region/web", "EU Region/cdn"}))
+	defer ts.Close()
+
+	cachet := NewCachetImpl(ts.URL, "undefined", ts.Client())
+
+	assert.Nil(t, cachet.Ping())
+
+	components, err := cachet.ListComponents()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"api": 1, "web": 2, "cdn": 3}, components)
+
+	groups, err := cachet.ListComponentGroups()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"EU Region": 1}, groups)
+
+	componentID, err := cachet.SearchComponent("web")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, componentID)
+
+	incidentID, err := cachet.CreateIncident("api", 1, 4, 4, IncidentOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, incidentID)
+
+	incidents, err := cachet.SearchIncidents(IncidentFilter{ComponentID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(incidents))
+	assert.Equal(t, 2, incidents[0].Status)
+
+	assert.Nil(t, cachet.UpdateIncident("api", 1, incidentID, 1, "", "api is up"))
+	incident, err := cachet.ReadIncident(incidentID)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, incident.Status)
+
+	incidents, err = cachet.SearchIncidents(IncidentFilter{ComponentID: 1, Open: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(incidents))
+	incidents, err = cachet.SearchIncidents(IncidentFilter{ComponentID: 1, Since: time.Now().Add(time.Hour)})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(incidents))
+
+	assert.Nil(t, cachet.SetComponentStatus(2, 3))
+	assert.Nil(t, cachet.SetComponentEnabled(3, false))
+	assert.NotNil(t, cachet.SetComponentStatus(42, 3))
+}
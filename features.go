@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// the experimental behaviors, disabled by default
+const (
+	// the webhook payloads are answered right away (with a 202), and processed in the background
+	FEATURE_ASYNC_PIPELINE = "async_pipeline"
+)
+
+// knownFeatures describes the experimental behaviors (the features of the configuration file must be one of them)
+var knownFeatures = map[string]string{
+	FEATURE_ASYNC_PIPELINE: "answer the webhook payloads right away (202), and process them in the background",
+}
+
+// Features are the experimental behaviors enabled (or explicitly disabled), by name: the features of the
+// configuration file, overridden by the features parameter
+type Features map[string]bool
+
+// Enabled returns true if the experimental behavior is enabled
+func (f Features) Enabled(name string) bool {
+	return f[name]
+}
+
+// validate checks that the features are known
+func (f Features) validate() error {
+	for name := range f {
+		if _, ok := knownFeatures[name]; !ok {
+			known := make([]string, 0, len(knownFeatures))
+			for feature := range knownFeatures {
+				known = append(known, feature)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("unknown feature '%s' (%s)", name, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// ParseFeatures overrides the features with a comma separated list, ex: "async_pipeline" to enable a feature,
+// and "-async_pipeline" to disable it
+func ParseFeatures(list string, features Features) (Features, error) {
+	overridden := make(Features, len(features))
+	for name, enabled := range features {
+		overridden[name] = enabled
+	}
+	for _, name := range splitList(list) {
+		overridden[strings.TrimPrefix(name, "-")] = !strings.HasPrefix(name, "-")
+	}
+	return overridden, overridden.validate()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatures(t *testing.T) {
+	filename := writeConfigFile(t, `
+features:
+  async_pipeline: true
+`)
+	defer os.Remove(filename)
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+	assert.True(t, configFile.Features.Enabled(FEATURE_ASYNC_PIPELINE))
+
+	// the parameter overrides the file
+	features, err := ParseFeatures("-async_pipeline", configFile.Features)
+	assert.Nil(t, err)
+	assert.False(t, features.Enabled(FEATURE_ASYNC_PIPELINE))
+	assert.True(t, configFile.Features.Enabled(FEATURE_ASYNC_PIPELINE))
+
+	_, err = ParseFeatures("new_engine", nil)
+	assert.Equal(t, "unknown feature 'new_engine' (async_pipeline)", err.Error())
+
+	var none Features
+	assert.False(t, none.Enabled(FEATURE_ASYNC_PIPELINE))
+}
+
+func TestAsyncPipeline(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "token", ts.Client()),
+		Features:  Features{FEATURE_ASYNC_PIPELINE: true},
+	}
+	router := PrepareGinRouter(&config)
+	req, _ := http.NewRequest("POST", "/alert", bytes.NewBufferString(`{"version":"4","status":"firing","alerts":[{"labels":{"alertname":"API"}}]}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// processed in the background
+	assert.Eventually(t, func() bool {
+		fake.mutex.Lock()
+		defer fake.mutex.Unlock()
+		return fake.components[0].Status == 4
+	}, 5*time.Second, 10*time.Millisecond)
+}
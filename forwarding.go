@@ -0,0 +1,192 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// FORWARDING_QUEUE_SIZE is the max number of payloads queued while the forwarding is paused (the oldest ones are dropped)
+const FORWARDING_QUEUE_SIZE = 1000
+
+// ForwardingStatus is the state of the forwarding, as answered by the admin endpoints
+type ForwardingStatus struct {
+	Paused bool       `json:"paused"`
+	Since  *time.Time `json:"since,omitempty"`
+	// when the forwarding resumes by itself, paused by a CachetHQ rate limiting
+	Until   *time.Time `json:"until,omitempty"`
+	Queued  int        `json:"queued"`
+	Dropped int        `json:"dropped"`
+}
+
+// Forwarding pauses (and resumes) the writes to CachetHQ: while paused, the payloads are accepted
+// and queued, to be replayed in order on resume. It is paused by the admin, or until the Retry-After of a
+// CachetHQ rate limiting (cf Throttle)
+type Forwarding struct {
+	mutex   sync.Mutex
+	paused  bool
+	since   time.Time
+	until   time.Time // zero if paused by the admin
+	queue   []*PrometheusAlert
+	dropped int
+}
+
+// NewForwarding creates a new (not paused) Forwarding
+func NewForwarding() *Forwarding {
+	return &Forwarding{queue: make([]*PrometheusAlert, 0)}
+}
+
+// Pause stops the writes to CachetHQ, from now
+func (f *Forwarding) Pause(now time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.paused {
+		f.paused = true
+		f.since = now
+	}
+	// until resumed by the admin
+	f.until = time.Time{}
+}
+
+// Throttle pauses the writes to CachetHQ until now+retryAfter (unless paused by the admin), and returns true if so
+func (f *Forwarding) Throttle(now time.Time, retryAfter time.Duration) bool {
+	if f == nil {
+		return false
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.paused && f.until.IsZero() {
+		return false
+	}
+	if !f.paused {
+		f.paused = true
+		f.since = now
+	}
+	if until := now.Add(retryAfter); until.After(f.until) {
+		f.until = until
+	}
+	return true
+}
+
+// throttleOver returns true if the writes were paused by a rate limiting whose Retry-After is over
+func (f *Forwarding) throttleOver(now time.Time) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.paused && !f.until.IsZero() && !now.Before(f.until)
+}
+
+// Paused returns true if the writes to CachetHQ are paused
+func (f *Forwarding) Paused() bool {
+	if f == nil {
+		return false
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.paused
+}
+
+// enqueue keeps the payload for later if the forwarding is paused, and returns false otherwise
+func (f *Forwarding) enqueue(alerts *PrometheusAlert) bool {
+	if f == nil {
+		return false
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.paused {
+		return false
+	}
+	if len(f.queue) >= FORWARDING_QUEUE_SIZE {
+		f.queue = f.queue[1:]
+		f.dropped++
+	}
+	f.queue = append(f.queue, alerts)
+	return true
+}
+
+// Resume returns the next payloads to replay, oldest first. The forwarding stays paused (the new payloads
+// being queued after them) until Resume returns no payload, so the payloads are always processed in order
+func (f *Forwarding) Resume() []*PrometheusAlert {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	queued := f.queue
+	f.queue = make([]*PrometheusAlert, 0)
+	if len(queued) == 0 {
+		f.paused = false
+		f.since = time.Time{}
+		f.until = time.Time{}
+		f.dropped = 0
+	}
+	return queued
+}
+
+// requeue puts back payloads not replayed, before the ones queued since
+func (f *Forwarding) requeue(payloads []*PrometheusAlert) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.queue = append(append(make([]*PrometheusAlert, 0, len(payloads)+len(f.queue)), payloads...), f.queue...)
+}
+
+// Status returns the state of the forwarding
+func (f *Forwarding) Status() ForwardingStatus {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	status := ForwardingStatus{Paused: f.paused, Queued: len(f.queue), Dropped: f.dropped}
+	if f.paused {
+		since := f.since
+		status.Since = &since
+	}
+	if f.paused && !f.until.IsZero() {
+		until := f.until
+		status.Until = &until
+	}
+	return status
+}
+
+// throttleForwarding pauses the writes to CachetHQ if err is a rate limiting, until its Retry-After is over: the
+// payloads are queued meanwhile, and replayed then
+func throttleForwarding(config *PrometheusCachetConfig, err error) {
+	retryAfter, limited := RateLimited(err)
+	if !limited || !config.Forwarding.Throttle(time.Now(), retryAfter) {
+		return
+	}
+	log.Println("CachetHQ is rate limiting, forwarding paused for", retryAfter)
+	time.AfterFunc(retryAfter, func() {
+		// (unless paused by the admin since, or throttled again for longer)
+		if config.Forwarding.throttleOver(time.Now()) {
+			replayed, failed := replayForwarding(config)
+			log.Println("CachetHQ rate limiting over,", replayed, "payloads replayed,", failed, "failed")
+		}
+	})
+}
+
+// replayForwarding replays the payloads queued while paused, in order, and resumes the writes to CachetHQ. A rate
+// limiting pauses them again, with the payloads not replayed yet
+func replayForwarding(config *PrometheusCachetConfig) (int, int) {
+	replayed, failed := 0, 0
+	for queued := config.Forwarding.Resume(); len(queued) > 0; queued = config.Forwarding.Resume() {
+		for i, alerts := range queued {
+			// the errors are notified (and recorded in the history): the next payloads are still replayed
+			if _, err := ProcessAlerts(config, alerts); err != nil {
+				if _, limited := RateLimited(err); limited && config.Forwarding.Paused() {
+					config.Forwarding.requeue(queued[i:])
+					return replayed, failed
+				}
+				log.Println("not able to replay a payload of", alerts.Receiver, ":", err)
+				failed++
+			}
+			replayed++
+		}
+	}
+	return replayed, failed
+}
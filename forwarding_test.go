@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardingQueue(t *testing.T) {
+	forwarding := NewForwarding()
+	assert.False(t, forwarding.enqueue(&PrometheusAlert{}))
+
+	forwarding.Pause(time.Now())
+	for i := 0; i < FORWARDING_QUEUE_SIZE+2; i++ {
+		assert.True(t, forwarding.enqueue(&PrometheusAlert{Receiver: "r"}))
+	}
+	status := forwarding.Status()
+	assert.True(t, status.Paused)
+	assert.Equal(t, FORWARDING_QUEUE_SIZE, status.Queued)
+	assert.Equal(t, 2, status.Dropped)
+
+	// still paused until everything is replayed
+	assert.Equal(t, FORWARDING_QUEUE_SIZE, len(forwarding.Resume()))
+	assert.True(t, forwarding.Paused())
+	assert.Equal(t, 0, len(forwarding.Resume()))
+	assert.False(t, forwarding.Paused())
+
+	var none *Forwarding
+	assert.False(t, none.Paused())
+	assert.False(t, none.enqueue(&PrometheusAlert{}))
+}
+
+func TestPauseResumeEndpoints(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		PrometheusToken: "promToken",
+		LabelName:       "alertname",
+		Cachet:          NewCachetImpl(ts.URL, "token", ts.Client()),
+		Forwarding:      NewForwarding(),
+	}
+	router := PrepareGinRouter(config)
+	send := func(path, payload string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(payload))
+		req.Header.Set("Authorization", "Bearer promToken")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, 200, send("/admin/pause", ""))
+	assert.Equal(t, 202, send("/alert", `{"version":"4","status":"firing","alerts":[{"labels":{"alertname":"API"}}]}`))
+	assert.Equal(t, 202, send("/alert", `{"version":"4","status":"resolved","alerts":[{"labels":{"alertname":"API"}}]}`))
+	assert.Equal(t, 0, len(fake.incidents))
+	assert.Equal(t, 409, send("/test", `{"component":"API","status":"firing"}`))
+
+	// replayed in order
+	assert.Equal(t, 200, send("/admin/resume", ""))
+	assert.Equal(t, 2, len(fake.incidents))
+	assert.Equal(t, 1, fake.components[0].Status)
+	assert.False(t, config.Forwarding.Paused())
+
+	// admin endpoints need a token
+	config.PrometheusToken = ""
+	assert.Equal(t, 403, send("/admin/pause", ""))
+}
+
+func TestRateLimitedForwarding(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	limited := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limited && r.Method == http.MethodPost {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:  "alertname",
+		Cachet:     NewCachetImpl(ts.URL, "token", ts.Client()),
+		Forwarding: NewForwarding(),
+	}
+	router := PrepareGinRouter(config)
+	send := func(payload string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/alert", bytes.NewBufferString(payload))
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// rate limited: queued (and not retried) until the Retry-After
+	assert.Equal(t, 202, send(`{"version":"4","status":"firing","alerts":[{"labels":{"alertname":"API"}}]}`))
+	assert.Equal(t, 202, send(`{"version":"4","status":"firing","alerts":[{"labels":{"alertname":"WEB"}}]}`))
+	status := config.Forwarding.Status()
+	assert.True(t, status.Paused)
+	assert.Equal(t, 2, status.Queued)
+	assert.True(t, status.Until.After(time.Now().Add(110*time.Second)))
+	assert.False(t, config.Forwarding.throttleOver(time.Now()))
+	assert.True(t, config.Forwarding.throttleOver(time.Now().Add(2*time.Minute)))
+
+	// still rate limited on replay: paused again, in order
+	replayed, failed := replayForwarding(config)
+	assert.Equal(t, 0, replayed+failed)
+	assert.True(t, config.Forwarding.Paused())
+	queued := config.Forwarding.Resume()
+	assert.Equal(t, "API", queued[0].Alerts[0].Labels["alertname"])
+	config.Forwarding.requeue(queued)
+
+	limited = false
+	replayed, failed = replayForwarding(config)
+	assert.Equal(t, 2, replayed)
+	assert.Equal(t, 0, failed)
+	assert.False(t, config.Forwarding.Paused())
+	assert.Equal(t, 2, len(fake.incidents))
+
+	// paused by the admin: not resumed by the end of a rate limiting
+	config.Forwarding.Throttle(time.Now(), time.Minute)
+	config.Forwarding.Pause(time.Now())
+	assert.False(t, config.Forwarding.throttleOver(time.Now().Add(2*time.Minute)))
+	assert.False(t, config.Forwarding.Throttle(time.Now(), time.Minute))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter("Wed, 01 Jan 2020 10:01:30 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Wed, 01 Jan 2020 09:00:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+
+	retryAfter, limited := RateLimited(&CachetHTTPError{StatusCode: http.StatusTooManyRequests})
+	assert.True(t, limited)
+	assert.Equal(t, CACHETHQ_RATE_LIMIT_PAUSE, retryAfter)
+	retryAfter, _ = RateLimited(&CachetHTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: 24 * time.Hour})
+	assert.Equal(t, CACHETHQ_RATE_LIMIT_MAX_PAUSE, retryAfter)
+	_, limited = RateLimited(&CachetHTTPError{StatusCode: http.StatusServiceUnavailable})
+	assert.False(t, limited)
+}
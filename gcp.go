@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// cf https://cloud.google.com/monitoring/support/notification-options#webhooks
+type gcpNotification struct {
+	Incident *struct {
+		IncidentID          string            `json:"incident_id"`
+		State               string            `json:"state"` // open or closed
+		StartedAt           int64             `json:"started_at"`
+		Summary             string            `json:"summary"`
+		PolicyName          string            `json:"policy_name"`
+		ResourceDisplayName string            `json:"resource_display_name"`
+		Severity            string            `json:"severity"`
+		PolicyUserLabels    map[string]string `json:"policy_user_labels"`
+	} `json:"incident"`
+}
+
+// GCPAdapter translates the Google Cloud Monitoring incidents
+type GCPAdapter struct{}
+
+// Parse translates an opened (or closed) incident: the impacted component is the cachet_component user label
+// of the alerting policy, else the resource, or the policy name
+func (a *GCPAdapter) Parse(config *PrometheusCachetConfig, body []byte) (*PrometheusAlert, error) {
+	var notification gcpNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, err
+	}
+	incident := notification.Incident
+	if incident == nil || incident.IncidentID == "" {
+		return nil, fmt.Errorf("incident.incident_id is required")
+	}
+	var firing bool
+	switch incident.State {
+	case "open":
+		firing = true
+	case "closed":
+	default:
+		return nil, fmt.Errorf("unknown incident state '%s' (open or closed)", incident.State)
+	}
+
+	component := incident.PolicyName
+	if incident.ResourceDisplayName != "" {
+		component = incident.ResourceDisplayName
+	}
+	if value := incident.PolicyUserLabels[INPUT_COMPONENT_TAG]; value != "" {
+		component = value
+	}
+	if component == "" {
+		return nil, fmt.Errorf("no policy_name, resource_display_name nor %s user label", INPUT_COMPONENT_TAG)
+	}
+
+	alerts := newInputAlert(config, "gcp", firing, component, incident.IncidentID)
+	alert := &alerts.Alerts[0]
+	if incident.Severity != "" && incident.Severity != "No severity" {
+		alert.Labels[LABEL_SEVERITY] = strings.ToLower(incident.Severity)
+	}
+	if incident.Summary != "" {
+		alert.Annotations[ANNOTATION_SUMMARY] = incident.Summary
+	}
+	if incident.StartedAt > 0 {
+		alert.StartAt = time.Unix(incident.StartedAt, 0).UTC().Format(time.RFC3339)
+	}
+	return alerts, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGCPAdapter(t *testing.T) {
+	config := &PrometheusCachetConfig{LabelName: "alertname"}
+	adapter := &GCPAdapter{}
+
+	alerts, err := adapter.Parse(config, []byte(`{"version":"1.2","incident":{"incident_id":"0.mz7bqs4vs1ui","state":"open","started_at":1577872800,"summary":"CPU utilization for web01 is above the threshold","policy_name":"High CPU","resource_display_name":"web01","severity":"Critical"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "firing", alerts.Status)
+	assert.Equal(t, "web01", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "critical", alerts.Alerts[0].Labels[LABEL_SEVERITY])
+	assert.Equal(t, "CPU utilization for web01 is above the threshold", alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY])
+	assert.Equal(t, "2020-01-01T10:00:00Z", alerts.Alerts[0].StartAt)
+	assert.Equal(t, "gcp:0.mz7bqs4vs1ui", alerts.Alerts[0].fingerprint())
+
+	alerts, err = adapter.Parse(config, []byte(`{"version":"1.2","incident":{"incident_id":"0.mz7bqs4vs1ui","state":"closed","policy_name":"High CPU","severity":"No severity","policy_user_labels":{"cachet_component":"Website"}}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "resolved", alerts.Status)
+	assert.Equal(t, "Website", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "", alerts.Alerts[0].Labels[LABEL_SEVERITY])
+
+	_, err = adapter.Parse(config, []byte(`{"version":"1.2","incident":{"incident_id":"1","state":"acknowledged","policy_name":"High CPU"}}`))
+	assert.NotNil(t, err)
+	_, err = adapter.Parse(config, []byte(`{"version":"1.2"}`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GenericInput is an input adapter configured in the configuration file, for the tools without a dedicated adapter:
+// the fields of its json payloads are selected with (a subset of) JSONPath, ex: $.check.name or $.events[0].state
+//
+//	inputs:
+//	  mytool:
+//	    status: $.state
+//	    firing: [down, critical]
+//	    resolved: [up, ok]
+//	    component: $.service
+//	    summary: $.message
+//	    timestamp: $.time
+type GenericInput struct {
+	// the status field, and its values firing (and resolving) an incident (the other values are ignored)
+	Status   string   `yaml:"status"`
+	Firing   []string `yaml:"firing"`
+	Resolved []string `yaml:"resolved"`
+	// the component key: the impacted component, identifying the problem
+	Component string `yaml:"component"`
+	// optional
+	Summary   string `yaml:"summary"`
+	Timestamp string `yaml:"timestamp"` // RFC3339, or unix time (in seconds or milliseconds)
+
+	name string
+}
+
+func (g *GenericInput) validate(name string) error {
+	if _, ok := InputAdapters[name]; ok {
+		return fmt.Errorf("'%s' is already an input adapter", name)
+	}
+	if g.Status == "" || g.Component == "" {
+		return fmt.Errorf("status and component are required")
+	}
+	for _, path := range []string{g.Status, g.Component, g.Summary, g.Timestamp} {
+		if path == "" {
+			continue
+		}
+		if _, err := parseJSONPath(path); err != nil {
+			return err
+		}
+	}
+	if len(g.Firing) == 0 {
+		g.Firing = []string{"firing"}
+	}
+	if len(g.Resolved) == 0 {
+		g.Resolved = []string{"resolved"}
+	}
+	g.name = name
+	return nil
+}
+
+// Parse translates the payload with the configured fields
+func (g *GenericInput) Parse(config *PrometheusCachetConfig, body []byte) (*PrometheusAlert, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var payload interface{}
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	status, err := jsonPathString(payload, g.Status)
+	if err != nil {
+		return nil, err
+	}
+	var firing bool
+	switch {
+	case containsFold(g.Firing, status):
+		firing = true
+	case containsFold(g.Resolved, status):
+	default:
+		return nil, nil
+	}
+
+	component, err := jsonPathString(payload, g.Component)
+	if err != nil {
+		return nil, err
+	}
+	if component == "" {
+		return nil, fmt.Errorf("%s is empty", g.Component)
+	}
+
+	alerts := newInputAlert(config, g.name, firing, component, component)
+	alert := &alerts.Alerts[0]
+	if g.Summary != "" {
+		if summary, err := jsonPathString(payload, g.Summary); err == nil && summary != "" {
+			alert.Annotations[ANNOTATION_SUMMARY] = summary
+		}
+	}
+	if g.Timestamp != "" {
+		value, err := jsonPathString(payload, g.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := parseTimestamp(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", g.Timestamp, err)
+		}
+		alert.StartAt = timestamp.Format(time.RFC3339)
+	}
+	return alerts, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimestamp parses a RFC3339 date, or a unix time in seconds (or in milliseconds)
+func parseTimestamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds > 1e12 {
+			seconds /= 1000
+		}
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseJSONPath splits a JSONPath ($.a.b[0]["c d"]) into its keys (strings) and indexes (ints)
+func parseJSONPath(path string) ([]interface{}, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	keys := make([]interface{}, 0)
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSONPath '%s': empty key", path)
+			}
+			keys = append(keys, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath '%s': missing ]", path)
+			}
+			inside := rest[1:end]
+			if index, err := strconv.Atoi(inside); err == nil {
+				keys = append(keys, index)
+			} else if len(inside) >= 2 && (inside[0] == '"' || inside[0] == '\'') && inside[len(inside)-1] == inside[0] {
+				keys = append(keys, inside[1:len(inside)-1])
+			} else {
+				return nil, fmt.Errorf("invalid JSONPath '%s': [%s] is neither an index nor a quoted key", path, inside)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSONPath '%s': expected . or [", path)
+		}
+	}
+	return keys, nil
+}
+
+// jsonPathString returns the (scalar) value selected by path in a decoded json payload, as a string
+func jsonPathString(payload interface{}, path string) (string, error) {
+	keys, err := parseJSONPath(path)
+	if err != nil {
+		return "", err
+	}
+	value := payload
+	for _, key := range keys {
+		switch key := key.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("%s not found", path)
+			}
+			if value, ok = object[key]; !ok {
+				return "", fmt.Errorf("%s not found", path)
+			}
+		case int:
+			array, ok := value.([]interface{})
+			if !ok || key < 0 || key >= len(array) {
+				return "", fmt.Errorf("%s not found", path)
+			}
+			value = array[key]
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("%s is not a string, a number nor a boolean", path)
+	}
+}
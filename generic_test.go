@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenericInput(t *testing.T) {
+	filename := writeConfigFile(t, `
+inputs:
+  mytool:
+    status: $.check.state
+    firing: [down, critical]
+    resolved: [up]
+    component: $.check["service name"]
+    summary: $.events[0].message
+    timestamp: $.time
+`)
+	defer os.Remove(filename)
+
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+	config := &PrometheusCachetConfig{LabelName: "alertname", Inputs: configFile.Inputs}
+	adapter, ok := inputAdapter(config, "mytool")
+	assert.True(t, ok)
+
+	alerts, err := adapter.Parse(config, []byte(`{"check":{"state":"DOWN","service name":"API"},"events":[{"message":"503"}],"time":1577836800}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "firing", alerts.Status)
+	assert.Equal(t, "mytool", alerts.Receiver)
+	assert.Equal(t, "API", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "503", alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY])
+	assert.Equal(t, "mytool:API", alerts.Alerts[0].fingerprint())
+	assert.Equal(t, int64(1577836800), parseStartAt(t, alerts.Alerts[0].StartAt))
+
+	alerts, err = adapter.Parse(config, []byte(`{"check":{"state":"up","service name":"API"},"time":"2020-01-01T00:00:00Z"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "resolved", alerts.Status)
+	assert.Equal(t, "", alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY])
+
+	// neither firing nor resolved
+	alerts, err = adapter.Parse(config, []byte(`{"check":{"state":"pending","service name":"API"}}`))
+	assert.Nil(t, err)
+	assert.Nil(t, alerts)
+
+	_, err = adapter.Parse(config, []byte(`{"check":{"state":"down"},"time":1577836800}`))
+	assert.NotNil(t, err)
+	_, err = adapter.Parse(config, []byte(`{"check":{"state":"down","service name":"API"},"time":"yesterday"}`))
+	assert.NotNil(t, err)
+}
+
+func TestGenericInputWrongConfig(t *testing.T) {
+	for _, content := range []string{
+		"inputs:\n  mytool:\n    status: $.state\n",
+		"inputs:\n  mytool:\n    status: $.state\n    component: $.a[b]\n",
+		"inputs:\n  zabbix:\n    status: $.state\n    component: $.host\n",
+	} {
+		filename := writeConfigFile(t, content)
+		_, err := LoadConfigFile(filename, false)
+		assert.NotNil(t, err, content)
+		os.Remove(filename)
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	keys, err := parseJSONPath(`$.a.b[0]['c d']`)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"a", "b", 0, "c d"}, keys)
+
+	_, err = parseJSONPath(`$.a..b`)
+	assert.NotNil(t, err)
+	_, err = parseJSONPath(`a.b`)
+	assert.NotNil(t, err)
+}
+
+func parseStartAt(t *testing.T, startAt string) int64 {
+	timestamp, err := parseTimestamp(startAt)
+	assert.Nil(t, err)
+	return timestamp.Unix()
+}
@@ -3,6 +3,16 @@ module github.com/nzin/prometheus_cachethq
 go 1.13
 
 require (
+	github.com/antonmedv/expr v1.8.9
+	github.com/eclipse/paho.mqtt.golang v1.2.0
 	github.com/gin-gonic/gin v1.5.0
-	github.com/stretchr/testify v1.4.0
+	github.com/golang/protobuf v1.3.2
+	github.com/lib/pq v1.3.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/open-policy-agent/opa v0.17.3
+	github.com/prometheus/client_golang v1.4.1
+	github.com/stretchr/testify v1.5.1
+	google.golang.org/grpc v1.27.1
+	gopkg.in/go-playground/validator.v9 v9.29.1
+	gopkg.in/yaml.v2 v2.2.5
 )
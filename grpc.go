@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/nzin/prometheus_cachethq/alertpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCAlertService is the gRPC alert submission (cf alertpb/alerts.proto): the payloads are processed as the
+// Alertmanager webhook ones
+type GRPCAlertService struct {
+	config *PrometheusCachetConfig
+}
+
+// NewGRPCServer returns the gRPC server of the alert submission. With a certificate (and a key), it is served over
+// TLS, and with a client CA too, the clients must present a certificate signed by this CA
+func NewGRPCServer(config *PrometheusCachetConfig, certFile, keyFile, clientCAFile string) (*grpc.Server, error) {
+	options := []grpc.ServerOption{grpc.UnaryInterceptor(grpcAuthorization(config))}
+	if certFile != "" && keyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig := &tls.Config{Certificates: []tls.Certificate{certificate}}
+		if clientCAFile != "" {
+			pem, err := ioutil.ReadFile(clientCAFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.ClientCAs = x509.NewCertPool()
+			if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate found in %s", clientCAFile)
+			}
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else if clientCAFile != "" {
+		return nil, fmt.Errorf("grpc_client_ca_file needs ssl_cert_file and ssl_key_file")
+	}
+
+	server := grpc.NewServer(options...)
+	alertpb.RegisterAlertServiceServer(server, &GRPCAlertService{config: config})
+	return server, nil
+}
+
+// grpcAuthorization checks the prometheus_token (if any), sent in the authorization metadata ("Bearer <token>")
+func grpcAuthorization(config *PrometheusCachetConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if config.PrometheusToken != "" {
+			md, _ := metadata.FromIncomingContext(ctx)
+			authorization := md.Get("authorization")
+			if len(authorization) == 0 {
+				return nil, status.Error(codes.Unauthenticated, "authorization metadata is missing")
+			}
+			if authorization[0] != fmt.Sprintf("Bearer %s", config.PrometheusToken) {
+				return nil, status.Error(codes.Unauthenticated, "wrong prometheus token")
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// SubmitAlerts forwards a payload to CachetHQ, as POST /alert
+func (s *GRPCAlertService) SubmitAlerts(ctx context.Context, req *alertpb.SubmitAlertsRequest) (*alertpb.SubmitAlertsResponse, error) {
+	alerts := grpcPayload(req)
+	if err := validateWebhook(alerts); err != nil {
+		s.config.Metrics.WebhookPayload(alerts.Version, WEBHOOK_REJECTED)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.config.Metrics.WebhookPayload(alerts.Version, WEBHOOK_ACCEPTED)
+
+	report, queued, err := dispatchAlerts(s.config, alerts)
+	if queued {
+		return &alertpb.SubmitAlertsResponse{Status: "paused"}, nil
+	}
+	if err != nil {
+		if IsTransientError(err) {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &alertpb.SubmitAlertsResponse{Status: "OK"}
+	for _, alert := range report.Alerts {
+		response.Alerts = append(response.Alerts, &alertpb.AlertReport{
+			Label:      alert.Label,
+			Components: alert.Components,
+			Error:      alert.Error,
+			Skipped:    alert.Skipped,
+			Silenced:   alert.Silenced,
+		})
+	}
+	return response, nil
+}
+
+// grpcPayload translates a gRPC request into a webhook payload
+func grpcPayload(req *alertpb.SubmitAlertsRequest) *PrometheusAlert {
+	alerts := &PrometheusAlert{
+		Version:           req.Version,
+		GroupKey:          req.GroupKey,
+		Status:            req.Status,
+		Receiver:          req.Receiver,
+		GroupLabels:       req.GroupLabels,
+		CommonLabels:      req.CommonLabels,
+		CommonAnnotations: req.CommonAnnotations,
+		ExternalURL:       req.ExternalUrl,
+		Alerts:            []PrometheusAlertDetail{},
+	}
+	for _, alert := range req.Alerts {
+		alerts.Alerts = append(alerts.Alerts, PrometheusAlertDetail{
+			Labels:      alert.Labels,
+			Annotations: alert.Annotations,
+			StartAt:     alert.StartsAt,
+			EndsAt:      alert.EndsAt,
+			Fingerprint: alert.Fingerprint,
+		})
+	}
+	return alerts
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nzin/prometheus_cachethq/alertpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCSubmitAlerts(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := PrometheusCachetConfig{
+		LabelName:       "alertname",
+		PrometheusToken: "secret",
+		Cachet:          NewCachetImpl(ts.URL, "token", ts.Client()),
+	}
+	server, err := NewGRPCServer(&config, "", "", "")
+	assert.Nil(t, err)
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := alertpb.NewAlertServiceClient(conn)
+
+	request := &alertpb.SubmitAlertsRequest{
+		Version:  "4",
+		Status:   "firing",
+		Receiver: "cachethq",
+		Alerts:   []*alertpb.Alert{{Labels: map[string]string{"alertname": "API"}}},
+	}
+
+	// without the token
+	_, err = client.SubmitAlerts(context.Background(), request)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	response, err := client.SubmitAlerts(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", response.Status)
+	assert.Equal(t, []string{"API"}, response.Alerts[0].Components)
+	assert.Equal(t, 4, fake.components[0].Status)
+
+	// an invalid payload
+	_, err = client.SubmitAlerts(ctx, &alertpb.SubmitAlertsRequest{Version: "4", Status: "pending"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "status 'pending' is unknown")
+}
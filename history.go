@@ -0,0 +1,149 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	HISTORY_RESULT_OK          = "ok"
+	HISTORY_RESULT_ERROR       = "error"
+	HISTORY_RESULT_UNMATCHED   = "unmatched"
+	HISTORY_RESULT_NOT_APPLIED = "not applied" // skipped after a CachetHQ error on another component
+	HISTORY_RESULT_SILENCED    = "silenced"    // all its components are silenced
+)
+
+// HistoryEntry is what the bridge did with one alert
+type HistoryEntry struct {
+	Time       time.Time         `json:"time"`
+	Status     string            `json:"status"`
+	Receiver   string            `json:"receiver"`
+	Labels     map[string]string `json:"labels"`
+	Components []string          `json:"components"`
+	Result     string            `json:"result"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// History keeps the last processed alerts, in a ring buffer
+type History struct {
+	mutex   sync.Mutex
+	entries []*HistoryEntry
+	next    int
+	full    bool
+	store   *StateStore // nil if not persisted
+}
+
+// NewHistory creates a new History keeping the last size alerts
+func NewHistory(size int) *History {
+	if size < 0 {
+		size = 0
+	}
+	return &History{entries: make([]*HistoryEntry, size)}
+}
+
+// Restore loads the entries saved in the store, and saves the next ones in it
+func (h *History) Restore(store *StateStore) error {
+	if h == nil || len(h.entries) == 0 {
+		return nil
+	}
+	entries, err := store.history(len(h.entries))
+	if err != nil {
+		return err
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, entry := range entries {
+		h.add(entry)
+	}
+	h.store = store
+	return nil
+}
+
+func (h *History) add(entry *HistoryEntry) {
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Record adds the alerts of a payload, with the outcome of their processing
+func (h *History) Record(now time.Time, alerts *PrometheusAlert, report *ProcessReport, err error) {
+	if h == nil || len(h.entries) == 0 {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for i, alert := range alerts.Alerts {
+		entry := &HistoryEntry{
+			Time:       now,
+			Status:     alerts.Status,
+			Receiver:   alerts.Receiver,
+			Labels:     alert.Labels,
+			Components: make([]string, 0),
+			Result:     HISTORY_RESULT_OK,
+		}
+
+		if i < len(report.Alerts) {
+			alertReport := report.Alerts[i]
+			entry.Components = alertReport.Components
+			switch {
+			case alertReport.Error != "":
+				entry.Result = HISTORY_RESULT_ERROR
+				entry.Error = alertReport.Error
+			case len(alertReport.Components) == 0 && len(alertReport.Silenced) > 0:
+				entry.Result = HISTORY_RESULT_SILENCED
+			case len(alertReport.Components) == 0:
+				entry.Result = HISTORY_RESULT_UNMATCHED
+			case alertReport.Skipped:
+				entry.Result = HISTORY_RESULT_NOT_APPLIED
+			}
+		} else if err != nil {
+			// the payload failed before the alert was mapped
+			entry.Result = HISTORY_RESULT_ERROR
+			entry.Error = err.Error()
+		}
+
+		h.add(entry)
+		h.store.appendHistory(entry, len(h.entries))
+	}
+}
+
+// Entries returns the recorded alerts impacting componentName (all of them if empty), most recent first
+func (h *History) Entries(componentName string, limit int) []*HistoryEntry {
+	entries := make([]*HistoryEntry, 0)
+	if h == nil || len(h.entries) == 0 {
+		return entries
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	count := h.next
+	if h.full {
+		count = len(h.entries)
+	}
+	for i := 1; i <= count; i++ {
+		entry := h.entries[(h.next-i+len(h.entries))%len(h.entries)]
+		if componentName != "" && !containsString(entry.Components, componentName) {
+			continue
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+	}
+	return entries
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryRingBuffer(t *testing.T) {
+	history := NewHistory(3)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("component%d", i)
+		alerts := &PrometheusAlert{Status: "firing", Alerts: []PrometheusAlertDetail{{Labels: map[string]string{"alertname": name}}}}
+		report := &ProcessReport{Alerts: []*AlertReport{{Label: name, Components: []string{name}}}}
+		history.Record(now, alerts, report, nil)
+	}
+
+	entries := history.Entries("", 0)
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, []string{"component4"}, entries[0].Components)
+	assert.Equal(t, []string{"component2"}, entries[2].Components)
+
+	assert.Equal(t, 1, len(history.Entries("component3", 0)))
+	assert.Equal(t, 0, len(history.Entries("component1", 0)))
+	assert.Equal(t, 2, len(history.Entries("", 2)))
+
+	// disabled
+	var none *History
+	none.Record(now, &PrometheusAlert{}, &ProcessReport{}, nil)
+	assert.Equal(t, 0, len(none.Entries("", 0)))
+	NewHistory(0).Record(now, &PrometheusAlert{Alerts: []PrometheusAlertDetail{{}}}, &ProcessReport{}, nil)
+}
+
+func TestHistoryEndpoint(t *testing.T) {
+	fake := NewFakeCachet([]string{"component1", "component2"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		PrometheusToken: "promToken",
+		LabelName:       "alertname",
+		ComponentsLabel: "cachet_components",
+		Cachet:          NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident:  true,
+		History:         NewHistory(10),
+	}
+
+	// component1 fails (nothing to resolve), so component2 is not processed
+	ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{
+		{Labels: map[string]string{"alertname": "component1"}},
+		{Labels: map[string]string{"alertname": "component2"}},
+		{Labels: map[string]string{"alertname": "unknown"}},
+	}})
+	ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{
+		{Labels: map[string]string{"alertname": "edge", "cachet_components": "component2,component1"}},
+	}})
+
+	router := PrepareGinRouter(config)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/history?component=component1", nil)
+	req.Header.Set("Authorization", "Bearer promToken")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	var result struct {
+		Alerts []*HistoryEntry `json:"alerts"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 2, len(result.Alerts))
+	assert.Equal(t, "firing", result.Alerts[0].Status)
+	assert.Equal(t, []string{"component2", "component1"}, result.Alerts[0].Components)
+	assert.Equal(t, HISTORY_RESULT_OK, result.Alerts[0].Result)
+	assert.Equal(t, HISTORY_RESULT_ERROR, result.Alerts[1].Result)
+	assert.NotEqual(t, "", result.Alerts[1].Error)
+
+	entries := config.History.Entries("", 0)
+	assert.Equal(t, 4, len(entries))
+	assert.Equal(t, HISTORY_RESULT_UNMATCHED, entries[1].Result)
+	assert.Equal(t, HISTORY_RESULT_NOT_APPLIED, entries[2].Result)
+
+	// never served without a token
+	config.PrometheusToken = ""
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/history", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 403, w.Code)
+}
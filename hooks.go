@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// the incident actions a command can be hooked to
+const (
+	HOOK_BEFORE_CREATE = "before_create"
+	HOOK_AFTER_RESOLVE = "after_resolve"
+)
+
+// what to do when a hook fails (or times out)
+const (
+	HOOK_FAILURE_IGNORE = "ignore" // log (and notify) the failure, the incident action goes on
+	HOOK_FAILURE_ABORT  = "abort"  // the incident is not created, and the error is answered (to let Alertmanager retry)
+)
+
+// HookEvent is the json written on the stdin of the hooks
+type HookEvent struct {
+	Hook        string `json:"hook"`
+	Component   string `json:"component"`
+	ComponentID int    `json:"component_id"`
+	// 0 before the creation of the incident
+	IncidentID int                    `json:"incident_id,omitempty"`
+	Name       string                 `json:"name"`
+	Message    string                 `json:"message"`
+	Alert      *PrometheusAlertDetail `json:"alert"`
+}
+
+// ExecHooks runs external commands (ex: to post to a ticketing system) before creating and after resolving the
+// incidents, with the HookEvent as json on their stdin
+type ExecHooks struct {
+	BeforeCreate string
+	AfterResolve string
+	Timeout      time.Duration
+	Failure      string
+}
+
+// NewExecHooks creates new ExecHooks. The commands are run with sh -c, and killed after timeout (0 for no limit)
+func NewExecHooks(beforeCreate, afterResolve string, timeout time.Duration, failure string) (*ExecHooks, error) {
+	switch failure {
+	case HOOK_FAILURE_IGNORE, HOOK_FAILURE_ABORT:
+	default:
+		return nil, fmt.Errorf("unknown hook_failure_policy value '%s' (ignore or abort)", failure)
+	}
+	return &ExecHooks{
+		BeforeCreate: beforeCreate,
+		AfterResolve: afterResolve,
+		Timeout:      timeout,
+		Failure:      failure,
+	}, nil
+}
+
+// beforeCreate runs the before_create hook, an error meaning the incident must not be created
+func (h *ExecHooks) beforeCreate(config *PrometheusCachetConfig, event *HookEvent) error {
+	if h == nil || h.BeforeCreate == "" {
+		return nil
+	}
+	event.Hook = HOOK_BEFORE_CREATE
+	return h.failed(config, event, h.run(h.BeforeCreate, event))
+}
+
+// afterResolve runs the after_resolve hook (the incident being already resolved, abort only answers the error)
+func (h *ExecHooks) afterResolve(config *PrometheusCachetConfig, event *HookEvent) error {
+	if h == nil || h.AfterResolve == "" {
+		return nil
+	}
+	event.Hook = HOOK_AFTER_RESOLVE
+	return h.failed(config, event, h.run(h.AfterResolve, event))
+}
+
+// failed applies the failure policy to the error of a hook
+func (h *ExecHooks) failed(config *PrometheusCachetConfig, event *HookEvent, err error) error {
+	if err == nil {
+		return nil
+	}
+	notifyError(config, "prometheus-cachethq: %s hook failed for %s: %v", event.Hook, event.Component, err)
+	if h.Failure == HOOK_FAILURE_ABORT {
+		return err
+	}
+	return nil
+}
+
+func (h *ExecHooks) run(command string, event *HookEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("HOOK=%s", event.Hook))
+	cmd.Stdin = bytes.NewReader(payload)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", h.Timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(out))
+	}
+	if len(out) > 0 {
+		log.Println(event.Hook, "hook:", string(bytes.TrimSpace(out)))
+	}
+	return nil
+}
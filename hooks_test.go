@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecHooks(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "hooks")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	config := &PrometheusCachetConfig{
+		LabelName:    "alertname",
+		Cachet:       NewCachetImpl(ts.URL, "token", ts.Client()),
+		FiringAlerts: NewFiringAlerts(),
+	}
+	config.Hooks, err = NewExecHooks("cat > "+filepath.Join(dir, "created.json"), "cat > "+filepath.Join(dir, "resolved.json"), time.Second, HOOK_FAILURE_IGNORE)
+	assert.Nil(t, err)
+	send := func(status string) error {
+		_, err := ProcessAlerts(config, &PrometheusAlert{
+			Version: "4",
+			Status:  status,
+			Alerts:  []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API"}}},
+		})
+		return err
+	}
+	read := func(name string) *HookEvent {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.Nil(t, err)
+		var event HookEvent
+		assert.Nil(t, json.Unmarshal(content, &event))
+		return &event
+	}
+
+	// the alert is written on the stdin of the hooks
+	assert.Nil(t, send("firing"))
+	created := read("created.json")
+	assert.Equal(t, HOOK_BEFORE_CREATE, created.Hook)
+	assert.Equal(t, "API", created.Component)
+	assert.Equal(t, 0, created.IncidentID)
+	assert.Equal(t, "API", created.Alert.Labels["alertname"])
+
+	assert.Nil(t, send("resolved"))
+	resolved := read("resolved.json")
+	assert.Equal(t, HOOK_AFTER_RESOLVE, resolved.Hook)
+	assert.NotEqual(t, 0, resolved.IncidentID)
+
+	// a failing hook is ignored (without squash, the resolve created a second incident)
+	config.Hooks.BeforeCreate = "exit 1"
+	assert.Nil(t, send("firing"))
+	assert.Equal(t, 3, len(fake.incidents))
+
+	// or aborts the creation of the incident
+	config.Hooks.Failure = HOOK_FAILURE_ABORT
+	assert.NotNil(t, send("firing"))
+	assert.Equal(t, 3, len(fake.incidents))
+
+	// as a hook timing out
+	config.Hooks.BeforeCreate = "exec sleep 5"
+	config.Hooks.Timeout = 100 * time.Millisecond
+	assert.NotNil(t, send("firing"))
+	assert.Equal(t, 3, len(fake.incidents))
+
+	_, err = NewExecHooks("true", "", 0, "retry")
+	assert.NotNil(t, err)
+}
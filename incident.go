@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ANNOTATION_STICKIED allows an alert to pin (or not) its incident: cachet_stickied: "true"
+	ANNOTATION_STICKIED = "cachet_stickied"
+
+	// ANNOTATION_VISIBLE allows an alert to hide its incident from the status page: cachet_visible: "false"
+	ANNOTATION_VISIBLE = "cachet_visible"
+
+	// ANNOTATION_ACTION changes what the bridge does with the alert
+	// - cachet_action: disable => hide the component while the alert is firing (instead of creating an incident)
+	ANNOTATION_ACTION = "cachet_action"
+	ACTION_DISABLE    = "disable"
+
+	// ANNOTATION_TEMPLATE selects the named incident texts of the alert (cf ConfigFile.NamedTemplates): cachet_template: database-outage
+	ANNOTATION_TEMPLATE = "cachet_template"
+
+	// ANNOTATION_PROBE_URL is the health url of the component, checked before resolving its incident (cf ResolveProbe)
+	ANNOTATION_PROBE_URL = "cachet_probe_url"
+)
+
+// incidentMarker is embedded (as an html comment, not rendered) in the message of the incidents
+// created by the bridge, to find them back from the alert fingerprint
+var incidentMarker = regexp.MustCompile(`<!-- prometheus-cachethq fingerprint=([0-9a-zA-Z]+) -->`)
+
+// bridgeMessage is the beginning of the messages of the incidents created by older versions of the bridge (without marker)
+var bridgeMessage = regexp.MustCompile(`^Prometheus (flagged|still flags) service `)
+
+// IsBridgeIncident returns true if the incident was created by the bridge (and not by a human)
+func IsBridgeIncident(incident *CachetIncident) bool {
+	return IncidentFingerprint(incident.Message) != "" || bridgeMessage.MatchString(incident.Message)
+}
+
+// NewIncidentOptions computes the options of a new incident, from (by priority)
+// the alert annotations, the alert route, and the global configuration
+func NewIncidentOptions(config *PrometheusCachetConfig, route *Route, alert *PrometheusAlertDetail) IncidentOptions {
+	options := IncidentOptions{
+		Stickied:    config.StickiedIncident,
+		Fingerprint: alert.fingerprint(),
+		// the escalation steps progress the incident from "Investigating"
+		Investigating: route != nil && len(route.Escalation) > 0,
+		// not public during a maintenance
+		Private: config.Maintenance.Enabled(),
+	}
+
+	if route != nil && route.Stickied != nil {
+		options.Stickied = *route.Stickied
+	}
+
+	if stickied, err := strconv.ParseBool(alert.Annotations[ANNOTATION_STICKIED]); err == nil {
+		options.Stickied = stickied
+	}
+
+	// a hidden incident is only seen by the logged in users (and leaves the component status alone)
+	visible := true
+	if route != nil && route.Visible != nil {
+		visible = *route.Visible
+	}
+	if value, err := strconv.ParseBool(alert.Annotations[ANNOTATION_VISIBLE]); err == nil {
+		visible = value
+	}
+	options.Private = options.Private || !visible
+
+	// the subscribers are only emailed for the severities worth it (ex: critical)
+	if route != nil && route.NotifySubscribers != nil {
+		options.Notify = *route.NotifySubscribers
+	} else {
+		for _, severity := range config.NotifySeverities {
+			if severity == "*" || severity == alert.Labels[LABEL_SEVERITY] {
+				options.Notify = true
+			}
+		}
+	}
+
+	return options
+}
+
+// SilenceLink returns the link to the Alertmanager UI pre-filling a silence of the alert (i.e. matching all its
+// labels), or an empty string without the Alertmanager url
+func SilenceLink(externalURL string, alert *PrometheusAlertDetail) string {
+	if externalURL == "" || len(alert.Labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(alert.Labels))
+	for name := range alert.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matchers := make([]string, 0, len(names))
+	for _, name := range names {
+		matchers = append(matchers, fmt.Sprintf("%s=%s", name, strconv.Quote(alert.Labels[name])))
+	}
+	filter := "{" + strings.Join(matchers, ",") + "}"
+	return fmt.Sprintf("[Silence this alert](%s/#/silences/new?filter=%s)", strings.TrimSuffix(externalURL, "/"), url.QueryEscape(filter))
+}
+
+// IncidentMarker returns the marker to append to an incident message, for the given alert fingerprint
+func IncidentMarker(fingerprint string) string {
+	if fingerprint == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\n<!-- prometheus-cachethq fingerprint=%s -->", fingerprint)
+}
+
+// IncidentFingerprint returns the alert fingerprint embedded in an incident message (empty if none)
+func IncidentFingerprint(message string) string {
+	if match := incidentMarker.FindStringSubmatch(message); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// IncidentFingerprints returns all the alert fingerprints embedded in an incident message: the alert which created it,
+// then the alerts which joined it (cf squash_incident)
+func IncidentFingerprints(message string) []string {
+	fingerprints := make([]string, 0)
+	for _, match := range incidentMarker.FindAllStringSubmatch(message, -1) {
+		fingerprints = append(fingerprints, match[1])
+	}
+	return fingerprints
+}
+
+// hasFingerprint returns true if the alert fingerprint is embedded in the incident message
+func hasFingerprint(message, fingerprint string) bool {
+	for _, incidentFingerprint := range IncidentFingerprints(message) {
+		if incidentFingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// FindIncident returns the most recent incident (incidents are sorted latest first) created for (or joined by) the alert fingerprint.
+// If none of the incidents has a fingerprint (i.e. they were created by an older version of the bridge),
+// the latest incident with a message of the bridge is returned. It returns nil if nothing is found
+func FindIncident(incidents []*CachetIncident, fingerprint string) *CachetIncident {
+	marked := false
+	for _, incident := range incidents {
+		if fingerprint != "" && hasFingerprint(incident.Message, fingerprint) {
+			return incident
+		}
+		marked = marked || IncidentFingerprint(incident.Message) != ""
+	}
+
+	if marked {
+		return nil
+	}
+	// never an incident created by a human
+	for _, incident := range incidents {
+		if bridgeMessage.MatchString(incident.Message) {
+			return incident
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewIncidentOptionsStickied(t *testing.T) {
+	stickied := true
+	notStickied := false
+	config := &PrometheusCachetConfig{}
+	alert := &PrometheusAlertDetail{Annotations: map[string]string{}}
+
+	assert.False(t, NewIncidentOptions(config, nil, alert).Stickied)
+
+	// global configuration
+	config.StickiedIncident = true
+	assert.True(t, NewIncidentOptions(config, nil, alert).Stickied)
+
+	// route has priority over the global configuration
+	assert.False(t, NewIncidentOptions(config, &Route{Stickied: &notStickied}, alert).Stickied)
+
+	// annotation has priority over the route
+	config.StickiedIncident = false
+	alert.Annotations[ANNOTATION_STICKIED] = "false"
+	assert.False(t, NewIncidentOptions(config, &Route{Stickied: &stickied}, alert).Stickied)
+	alert.Annotations[ANNOTATION_STICKIED] = "true"
+	assert.True(t, NewIncidentOptions(config, nil, alert).Stickied)
+}
+
+func TestNewIncidentOptionsInvestigating(t *testing.T) {
+	config := &PrometheusCachetConfig{}
+	alert := &PrometheusAlertDetail{Annotations: map[string]string{}}
+
+	assert.False(t, NewIncidentOptions(config, nil, alert).Investigating)
+	assert.False(t, NewIncidentOptions(config, &Route{}, alert).Investigating)
+	assert.True(t, NewIncidentOptions(config, &Route{Escalation: []*EscalationStep{{After: time.Minute, Status: "identified"}}}, alert).Investigating)
+}
+
+func TestNewIncidentOptionsVisible(t *testing.T) {
+	visible := true
+	hidden := false
+	config := &PrometheusCachetConfig{}
+	alert := &PrometheusAlertDetail{Annotations: map[string]string{}}
+
+	assert.False(t, NewIncidentOptions(config, nil, alert).Private)
+	assert.False(t, NewIncidentOptions(config, &Route{Visible: &visible}, alert).Private)
+	assert.True(t, NewIncidentOptions(config, &Route{Visible: &hidden}, alert).Private)
+
+	// annotation has priority over the route
+	alert.Annotations[ANNOTATION_VISIBLE] = "true"
+	assert.False(t, NewIncidentOptions(config, &Route{Visible: &hidden}, alert).Private)
+	alert.Annotations[ANNOTATION_VISIBLE] = "false"
+	assert.True(t, NewIncidentOptions(config, nil, alert).Private)
+
+	// always hidden during a private maintenance
+	config.Maintenance = NewMaintenance(true, true)
+	alert.Annotations[ANNOTATION_VISIBLE] = "true"
+	assert.True(t, NewIncidentOptions(config, nil, alert).Private)
+}
+
+func TestNewIncidentOptionsNotify(t *testing.T) {
+	notify := true
+	silent := false
+	config := &PrometheusCachetConfig{}
+	critical := &PrometheusAlertDetail{Labels: map[string]string{"alertname": "API", "severity": "critical"}}
+	warning := &PrometheusAlertDetail{Labels: map[string]string{"alertname": "API", "severity": "warning"}}
+
+	assert.False(t, NewIncidentOptions(config, nil, critical).Notify)
+
+	config.NotifySeverities = []string{"critical"}
+	assert.True(t, NewIncidentOptions(config, nil, critical).Notify)
+	assert.False(t, NewIncidentOptions(config, nil, warning).Notify)
+	config.NotifySeverities = []string{"*"}
+	assert.True(t, NewIncidentOptions(config, nil, warning).Notify)
+
+	// route has priority over the global configuration
+	assert.False(t, NewIncidentOptions(config, &Route{NotifySubscribers: &silent}, critical).Notify)
+	config.NotifySeverities = nil
+	assert.True(t, NewIncidentOptions(config, &Route{NotifySubscribers: &notify}, warning).Notify)
+}
+
+func TestNotifySubscribers(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	hidden := false
+	config := &PrometheusCachetConfig{
+		LabelName:        "alertname",
+		Cachet:           NewCachetImpl(ts.URL, "token", ts.Client()),
+		NotifySeverities: []string{"critical"},
+		Routes:           []*Route{{Match: map[string]string{"alertname": "WEB"}, Visible: &hidden}},
+	}
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{
+		{Labels: map[string]string{"alertname": "API", "severity": "critical"}},
+		{Labels: map[string]string{"alertname": "WEB", "severity": "critical"}},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(fake.incidents))
+	assert.True(t, fake.incidents[0].Notify)
+	// never for a hidden incident
+	assert.False(t, fake.incidents[1].Notify)
+}
+
+func TestIncidentFingerprint(t *testing.T) {
+	assert.Equal(t, "", IncidentMarker(""))
+	assert.Equal(t, "abc123", IncidentFingerprint("API is down"+IncidentMarker("abc123")))
+	assert.Equal(t, "", IncidentFingerprint("API is down"))
+
+	// labels order does not matter
+	alert1 := &PrometheusAlertDetail{Labels: map[string]string{"alertname": "API", "severity": "critical"}}
+	alert2 := &PrometheusAlertDetail{Labels: map[string]string{"severity": "critical", "alertname": "API"}}
+	alert3 := &PrometheusAlertDetail{Labels: map[string]string{"alertname": "API"}}
+	assert.Equal(t, alert1.fingerprint(), alert2.fingerprint())
+	assert.NotEqual(t, alert1.fingerprint(), alert3.fingerprint())
+
+	// the Alertmanager fingerprint wins
+	alert1.Fingerprint = "f00d"
+	assert.Equal(t, "f00d", alert1.fingerprint())
+}
+
+func TestSilenceLink(t *testing.T) {
+	alert := &PrometheusAlertDetail{Labels: map[string]string{"severity": "critical", "alertname": "API", "path": `/v1 "beta"`}}
+	assert.Equal(t, `[Silence this alert](http://alertmanager:9093/#/silences/new?filter=%7Balertname%3D%22API%22%2Cpath%3D%22%2Fv1+%5C%22beta%5C%22%22%2Cseverity%3D%22critical%22%7D)`, SilenceLink("http://alertmanager:9093/", alert))
+	assert.Equal(t, "", SilenceLink("", alert))
+
+	// in the hidden incidents only
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	config := &PrometheusCachetConfig{
+		LabelName:   "alertname",
+		Cachet:      NewCachetImpl(ts.URL, "token", ts.Client()),
+		SilenceLink: true,
+	}
+	for _, visible := range []string{"false", "true"} {
+		_, err := ProcessAlerts(config, &PrometheusAlert{
+			Version:     "4",
+			Status:      "firing",
+			ExternalURL: "http://alertmanager:9093",
+			Alerts:      []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API"}, Annotations: map[string]string{ANNOTATION_VISIBLE: visible}}},
+		})
+		assert.Nil(t, err)
+	}
+	assert.True(t, strings.Contains(fake.incidents[0].Message, "[Silence this alert](http://alertmanager:9093/#/silences/new?filter="))
+	assert.False(t, strings.Contains(fake.incidents[1].Message, "Silence this alert"))
+}
+
+func TestFindIncident(t *testing.T) {
+	human := &CachetIncident{Id: 3, Message: "investigating"}
+	ours := &CachetIncident{Id: 2, Message: "API down" + IncidentMarker("abc")}
+	other := &CachetIncident{Id: 1, Message: "API down" + IncidentMarker("def")}
+
+	assert.Equal(t, ours, FindIncident([]*CachetIncident{human, ours, other}, "abc"))
+	assert.Equal(t, other, FindIncident([]*CachetIncident{human, ours, other}, "def"))
+	assert.Nil(t, FindIncident([]*CachetIncident{human, ours, other}, "123"))
+	assert.Nil(t, FindIncident([]*CachetIncident{}, "abc"))
+
+	// incidents created by an older version of the bridge
+	legacy := &CachetIncident{Id: 1, Message: "Prometheus flagged service API as down"}
+	assert.Equal(t, legacy, FindIncident([]*CachetIncident{human, legacy}, "abc"))
+	assert.Nil(t, FindIncident([]*CachetIncident{human}, "abc"))
+}
+
+func TestResolveTheIncidentOfTheAlert(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident: true,
+	}
+
+	alert := PrometheusAlertDetail{Labels: map[string]string{"alertname": "API"}, Fingerprint: "abc"}
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+
+	// a human opens another incident on the same component
+	_, err = config.Cachet.CreateIncident("API", 1, 4, 4, IncidentOptions{})
+	assert.Nil(t, err)
+
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, "abc", IncidentFingerprint(fake.incidents[0].Message))
+	assert.Equal(t, 2, fake.incidents[1].Status)
+
+	// resolved twice
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// INPUT_MAX_BODY is the max size of a payload sent to an input adapter
+const INPUT_MAX_BODY = 1 << 20
+
+// LABEL_SOURCE is the label of the alerts translated by an input adapter, set to the adapter name
+const LABEL_SOURCE = "source"
+
+// INPUT_COMPONENT_TAG is the tag (of the tools having tags) naming the impacted component, instead of the host
+const INPUT_COMPONENT_TAG = "cachet_component"
+
+// ANNOTATION_SUMMARY is the annotation set to the problem description, by the input adapters
+const ANNOTATION_SUMMARY = "summary"
+
+// InputAdapter translates the payload of another monitoring tool into a Prometheus payload (cf /inputs/<name>)
+type InputAdapter interface {
+	// Parse returns the alerts of the payload (nil, without error, if the payload is to be ignored)
+	Parse(config *PrometheusCachetConfig, body []byte) (*PrometheusAlert, error)
+}
+
+// InputAdapters are the input adapters, by name
+var InputAdapters = map[string]InputAdapter{
+	"zabbix":     &ZabbixAdapter{},
+	"nagios":     &NagiosAdapter{},
+	"sensu":      &SensuAdapter{},
+	"datadog":    &DatadogAdapter{},
+	"uptimekuma": &UptimeKumaAdapter{},
+	"cloudwatch": NewCloudWatchAdapter(),
+	"azure":      &AzureAdapter{},
+	"gcp":        &GCPAdapter{},
+}
+
+// inputAdapter returns the input adapter named name: a builtin one, or a generic one of the configuration file
+func inputAdapter(config *PrometheusCachetConfig, name string) (InputAdapter, bool) {
+	if adapter, ok := InputAdapters[name]; ok {
+		return adapter, true
+	}
+	if input, ok := config.Inputs[name]; ok {
+		return input, true
+	}
+	return nil, false
+}
+
+// newInputAlert returns a payload of one alert from source, impacting the component (the config.LabelName label).
+// The fingerprint identifies the problem in the source, so that its resolution resolves the same incident
+func newInputAlert(config *PrometheusCachetConfig, source string, firing bool, component, fingerprint string) *PrometheusAlert {
+	status := "resolved"
+	if firing {
+		status = "firing"
+	}
+	return &PrometheusAlert{
+		Version:  "4",
+		Status:   status,
+		Receiver: source,
+		Alerts: []PrometheusAlertDetail{
+			{
+				Labels:      map[string]string{config.LabelName: component, LABEL_SOURCE: source},
+				Annotations: make(map[string]string),
+				Fingerprint: fmt.Sprintf("%s:%s", source, fingerprint),
+			},
+		},
+	}
+}
+
+// checkInputAuthorization checks the token sent to an input adapter: as a Bearer (like Prometheus),
+// as the password of a basic authentication, or as the token query parameter (for the tools not able to set a header)
+func checkInputAuthorization(c *gin.Context, config *PrometheusCachetConfig) bool {
+	if config.PrometheusToken == "" {
+		return true
+	}
+	if _, password, ok := c.Request.BasicAuth(); ok && password == config.PrometheusToken {
+		return true
+	}
+	if c.Query("token") == config.PrometheusToken {
+		return true
+	}
+	// the tools only able to send a basic authentication send it once challenged
+	c.Writer.Header().Add("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, AUTHENTICATION_REALM))
+	return checkAuthorization(c, config)
+}
+
+// SubmitInput translates the payload of another monitoring tool with its input adapter, and forwards it to CachetHQ
+func SubmitInput(c *gin.Context, config *PrometheusCachetConfig) {
+	adapter, ok := inputAdapter(config, c.Param("adapter"))
+	if !ok {
+		answerProblem(c, http.StatusNotFound, PROBLEM_NOT_FOUND, fmt.Sprintf("unknown input adapter '%s'", c.Param("adapter")))
+		return
+	}
+	if !checkInputAuthorization(c, config) {
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, INPUT_MAX_BODY))
+	if err != nil {
+		answerProblem(c, http.StatusBadRequest, PROBLEM_INVALID_PAYLOAD, err.Error())
+		return
+	}
+	alerts, err := adapter.Parse(config, body)
+	if err != nil {
+		if config.debug() {
+			log.Println("not able to parse the", c.Param("adapter"), "payload:", err)
+		}
+		answerProblem(c, http.StatusBadRequest, PROBLEM_INVALID_PAYLOAD, err.Error())
+		return
+	}
+	if alerts == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+	forwardAlerts(c, config, alerts)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LOG_FILE_TIME_FORMAT is the suffix of the rotated log files (ex: access.log.20201014-153000)
+const LOG_FILE_TIME_FORMAT = "20060102-150405"
+
+// LogRotation is when the log files are rotated, and how many rotated files are kept
+type LogRotation struct {
+	// the size (in bytes) above which the file is rotated (0 for no limit)
+	MaxSize int64
+	// how often the file is rotated (0 for never)
+	Interval time.Duration
+	// how many rotated files are kept, the older ones being removed (0 to keep them all)
+	MaxBackups int
+}
+
+// RotatingFile is a log file (appended) rotated by size and/or by time: the file is renamed with the time of its
+// rotation, and a new one is created. The writes are serialized, for the loggers shared across goroutines
+type RotatingFile struct {
+	filename string
+	rotation LogRotation
+	now      func() time.Time
+
+	mutex    sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewRotatingFile opens (or creates) the log file, rotated with rotation
+func NewRotatingFile(filename string, rotation LogRotation) (*RotatingFile, error) {
+	f := &RotatingFile{filename: filename, rotation: rotation, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.openedAt = file, info.Size(), f.now()
+	return nil
+}
+
+// Write appends p to the file, rotating it first if p would exceed its size, or if it expired
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.size > 0 && f.expired(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) expired(size int64) bool {
+	if f.rotation.MaxSize > 0 && f.size+size > f.rotation.MaxSize {
+		return true
+	}
+	return f.rotation.Interval > 0 && f.now().Sub(f.openedAt) >= f.rotation.Interval
+}
+
+// rotate renames the file (with the time of its rotation), creates a new one, and removes the oldest rotated files
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	suffix := f.now().Format(LOG_FILE_TIME_FORMAT)
+	rotated := f.filename + "." + suffix
+	// rotated again within the second
+	for i := 1; fileExists(rotated); i++ {
+		rotated = fmt.Sprintf("%s.%s.%d", f.filename, suffix, i)
+	}
+	if err := os.Rename(f.filename, rotated); err != nil {
+		return fmt.Errorf("not able to rotate the log file %s: %v", f.filename, err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.prune()
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+func (f *RotatingFile) prune() error {
+	if f.rotation.MaxBackups <= 0 {
+		return nil
+	}
+	rotated, err := filepath.Glob(f.filename + ".*")
+	if err != nil {
+		return err
+	}
+	// the time suffixes sort chronologically
+	sort.Strings(rotated)
+	for len(rotated) > f.rotation.MaxBackups {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// Close closes the current file
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.file.Close()
+}
+
+// OpenLogFile returns where to write the logs: the rotating file, or fallback if filename is empty
+func OpenLogFile(filename string, rotation LogRotation, fallback io.Writer) (io.Writer, error) {
+	if filename == "" {
+		return fallback, nil
+	}
+	return NewRotatingFile(filename, rotation)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "access.log")
+
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	file, err := NewRotatingFile(filename, LogRotation{MaxSize: 10, Interval: time.Hour, MaxBackups: 2})
+	assert.Nil(t, err)
+	file.now = func() time.Time { return now }
+	file.openedAt = now
+	defer file.Close()
+
+	// by size (rotated twice within the second)
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n"} {
+		_, err = file.Write([]byte(line))
+		assert.Nil(t, err)
+	}
+	rotated, _ := filepath.Glob(filename + ".*")
+	assert.Equal(t, []string{filename + ".20200101-100000", filename + ".20200101-100000.1"}, rotated)
+
+	// by time, the oldest rotated file being removed
+	now = now.Add(time.Hour)
+	_, err = file.Write([]byte("line 4\n"))
+	assert.Nil(t, err)
+	rotated, _ = filepath.Glob(filename + ".*")
+	assert.Equal(t, []string{filename + ".20200101-100000.1", filename + ".20200101-110000"}, rotated)
+	content, _ := ioutil.ReadFile(filename + ".20200101-110000")
+	assert.Equal(t, "line 3\n", string(content))
+	content, _ = ioutil.ReadFile(filename)
+	assert.Equal(t, "line 4\n", string(content))
+
+	// appended on restart
+	reopened, err := NewRotatingFile(filename, LogRotation{})
+	assert.Nil(t, err)
+	_, err = reopened.Write([]byte("line 5\n"))
+	assert.Nil(t, err)
+	reopened.Close()
+	content, _ = ioutil.ReadFile(filename)
+	assert.Equal(t, "line 4\nline 5\n", string(content))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ParseLogLevel parses a log level name ([info|debug])
+func ParseLogLevel(name string) (int32, error) {
+	switch name {
+	case "debug":
+		return LOG_DEBUG, nil
+	case "info":
+		return LOG_INFO, nil
+	}
+	return LOG_INFO, fmt.Errorf("unknown log level '%s' (info or debug)", name)
+}
+
+// LogLevelName returns the name of a log level
+func LogLevelName(level int32) string {
+	if level == LOG_DEBUG {
+		return "debug"
+	}
+	return "info"
+}
+
+// debug returns true if the debug logs are enabled (the log level can be changed at runtime, cf /admin/loglevel)
+func (config *PrometheusCachetConfig) debug() bool {
+	return atomic.LoadInt32(&config.LogLevel) == LOG_DEBUG
+}
+
+// setLogLevel changes the log level, while the alerts are processed
+func (config *PrometheusCachetConfig) setLogLevel(level int32) {
+	atomic.StoreInt32(&config.LogLevel, level)
+}
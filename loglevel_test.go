@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogLevelEndpoint(t *testing.T) {
+	config := &PrometheusCachetConfig{PrometheusToken: "promToken", LogLevel: LOG_INFO}
+	router := PrepareGinRouter(config)
+	send := func(method, payload string) (int, string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/admin/loglevel", bytes.NewBufferString(payload))
+		req.Header.Set("Authorization", "Bearer promToken")
+		router.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	code, body := send("GET", "")
+	assert.Equal(t, 200, code)
+	assert.JSONEq(t, `{"level":"info"}`, body)
+
+	code, _ = send("PUT", `{"level":"debug"}`)
+	assert.Equal(t, 200, code)
+	assert.True(t, config.debug())
+
+	code, _ = send("PUT", `{"level":"trace"}`)
+	assert.Equal(t, 400, code)
+	assert.True(t, config.debug())
+}
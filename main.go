@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -19,17 +27,131 @@ const (
 )
 
 type PrometheusCachetParameters struct {
-	loglevel            string
-	httpPort            int
-	sslCert             string
-	sslKey              string
-	cachetRootCA        string
-	cachetSkipVerifySsl bool
-	cachetURL           string
-	cachetToken         string
-	prometheusToken     string
-	labelName           string
-	squashIncident      bool
+	loglevel                  string
+	httpPort                  int
+	sslCert                   string
+	sslKey                    string
+	cachetRootCA              string
+	cachetSkipVerifySsl       bool
+	cachetURL                 string
+	cachetToken               string
+	prometheusToken           string
+	labelName                 string
+	matchBy                   string
+	normalizeNames            bool
+	componentsLabel           string
+	componentsSeparator       string
+	groupLabel                string
+	squashIncident            bool
+	stickiedIncident          bool
+	descriptionAnnot          string
+	linkAnnot                 string
+	notifyWebhookURL          string
+	notifyUsername            string
+	watchdogDelay             time.Duration
+	watchdogInterval          time.Duration
+	watchdogActions           string
+	watchdogExec              string
+	configFile                string
+	fakeCachet                bool
+	fakeCachetComps           string
+	cachetErrorStatus         int
+	rateLimit                 float64
+	rateLimitBurst            int
+	concurrency               int
+	historySize               int
+	cachetVersion             string
+	cachetAuth                string
+	cachetUserAgent           string
+	cachetHeaders             string
+	cachetMaxIdleConns        int
+	cachetIdleTimeout         time.Duration
+	cachetTimeout             time.Duration
+	cachetTimezone            string
+	maintenanceMode           bool
+	maintenancePrivate        bool
+	accessLogFormat           string
+	accessLogFile             string
+	accessLogSkipPaths        string
+	corsAllowedOrigins        string
+	corsAllowedMethods        string
+	corsAllowedHeaders        string
+	prometheusURLs            string
+	prometheusInterval        time.Duration
+	pagerdutyRoutingKey       string
+	pagerdutyURL              string
+	opsgenieAPIKey            string
+	opsgenieURL               string
+	alertmanagerURL           string
+	scheduleInterval          time.Duration
+	alertsMetricID            int
+	alertsMetricInterval      time.Duration
+	incidentNameMaxLength     int
+	fetchTruncatedAlerts      bool
+	grpcPort                  int
+	grpcClientCA              string
+	mqttBroker                string
+	mqttTopic                 string
+	mqttQoS                   int
+	mqttClientID              string
+	mqttUsername              string
+	mqttPassword              string
+	mqttCAFile                string
+	componentsCacheInterval   time.Duration
+	snapshotPath              string
+	snapshotInterval          time.Duration
+	storeAndForward           bool
+	storeAndForwardInterval   time.Duration
+	retryMaxAttempts          int
+	retryBackoff              time.Duration
+	retryMultiplier           float64
+	retryMaxBackoff           time.Duration
+	retryJitter               float64
+	retryBudget               time.Duration
+	retryExhausted            string
+	metricsUsername           string
+	metricsPassword           string
+	metricsToken              string
+	adminListen               string
+	configSchema              bool
+	features                  string
+	hookBeforeCreate          string
+	hookAfterResolve          string
+	hookTimeout               time.Duration
+	hookFailurePolicy         string
+	opaPolicyFile             string
+	opaURL                    string
+	opaQuery                  string
+	opaFailClosed             bool
+	resolveStabilityWindow    time.Duration
+	resolveComponentStatus    int
+	probeInterval             time.Duration
+	occurrenceWindow          time.Duration
+	silenceLink               bool
+	componentSyncURL          string
+	componentSyncFile         string
+	componentSyncLabel        string
+	componentSyncGroup        string
+	componentSyncInterval     time.Duration
+	componentSyncGroupLabel   string
+	componentSyncRetireAfter  time.Duration
+	componentSyncRetireAction string
+	stateBackend              string
+	stateDSN                  string
+	startupCheck              bool
+	startupCheckIncident      bool
+	notifySeverities          string
+	notifyCooldown            time.Duration
+	incidentNameTemplate      string
+	ginMode                   string
+	trustedProxies            string
+	logFile                   string
+	logMaxSize                int
+	logRotateInterval         time.Duration
+	logMaxBackups             int
+	preserveEdits             bool
+	respectStatusOverrides    bool
+	statusOverrideExpiry      time.Duration
 }
 
 // NewPrometheusCachetParameters is here to fetch all env variable or parameters
@@ -45,8 +167,122 @@ func NewPrometheusCachetParameters() *PrometheusCachetParameters {
 	flag.StringVar(&p.sslCert, "ssl_cert_file", "", "to be used with ssl_key: enable https server")
 	flag.StringVar(&p.sslKey, "ssl_key_file", "", "to be used with ssl_cert: enable https server")
 	flag.StringVar(&p.labelName, "label_name", "alertname", "label to look for in Prometheus Alert info")
+	flag.StringVar(&p.matchBy, "match_by", MATCH_BY_NAME, "how the label value is matched against the CachetHQ components: [name|tag]")
+	flag.BoolVar(&p.normalizeNames, "normalize_names", false, "ignore case, spaces, dashes and underscores when matching component names")
+	flag.StringVar(&p.componentsLabel, "components_label", "cachet_components", "label listing several components impacted by one alert")
+	flag.StringVar(&p.componentsSeparator, "components_separator", ",", "separator used in the components_label label")
+	flag.StringVar(&p.groupLabel, "group_label", "cachet_group", "label naming a CachetHQ component group impacted by one alert")
 	flag.IntVar(&p.httpPort, "http_port", 8080, "port to listen on")
 	flag.BoolVar(&p.squashIncident, "squash_incident", false, "do we want to merge down and up event into one incident")
+	flag.BoolVar(&p.stickiedIncident, "stickied_incident", false, "pin the created incidents at the top of the status page")
+	flag.StringVar(&p.descriptionAnnot, "description_annotation", "", "alert annotation to copy into the component description when firing")
+	flag.StringVar(&p.linkAnnot, "link_annotation", "", "alert annotation to copy into the component link when firing")
+	flag.StringVar(&p.notifyWebhookURL, "notify_webhook_url", "", "Slack/Mattermost incoming webhook to warn when the bridge fails")
+	flag.StringVar(&p.notifyUsername, "notify_username", "prometheus-cachethq", "username used when posting to the notification webhook")
+	flag.DurationVar(&p.watchdogDelay, "watchdog_delay", 0, "trigger the watchdog actions when CachetHQ is unreachable for this long (0 to disable)")
+	flag.DurationVar(&p.watchdogInterval, "watchdog_interval", time.Minute, "how often the watchdog pings CachetHQ")
+	flag.StringVar(&p.watchdogActions, "watchdog_actions", "log", "comma separated watchdog actions: [log|notify|exec|readiness]")
+	flag.StringVar(&p.watchdogExec, "watchdog_exec", "", "command to run when the watchdog triggers the exec action")
+	flag.StringVar(&p.configFile, "config_file", "", "yaml configuration file (routes, ...)")
+	flag.BoolVar(&p.fakeCachet, "fake_cachet", false, "use an embedded fake CachetHQ instead of cachethq_url (for local development)")
+	flag.StringVar(&p.fakeCachetComps, "fake_cachet_components", "component21", "comma separated components ([group/]name) of the embedded fake CachetHQ")
+	flag.IntVar(&p.cachetErrorStatus, "cachethq_error_status", http.StatusBadRequest, "http status code answered to Prometheus on transient CachetHQ errors (ex: 503 to let Alertmanager retry)")
+	flag.Float64Var(&p.rateLimit, "rate_limit", 0, "max number of alert requests per second (0 to disable)")
+	flag.IntVar(&p.rateLimitBurst, "rate_limit_burst", 10, "max burst of alert requests")
+	flag.IntVar(&p.concurrency, "concurrency", 1, "number of components processed in parallel for one Prometheus payload")
+	flag.IntVar(&p.historySize, "history_size", 100, "number of processed alerts kept for /admin/history (0 to disable)")
+	flag.StringVar(&p.cachetVersion, "cachethq_version", CACHETHQ_VERSION_AUTO, "version of CachetHQ (ex: 2.3), or auto to probe it on startup")
+	flag.StringVar(&p.cachetAuth, "cachethq_auth", CACHETHQ_AUTH_TOKEN, "how the token is sent to CachetHQ: [token|bearer|basic] (basic expects a user:password token)")
+	flag.StringVar(&p.cachetUserAgent, "cachethq_user_agent", "prometheus-cachethq", "User-Agent sent to CachetHQ")
+	flag.StringVar(&p.cachetHeaders, "cachethq_headers", "", "comma separated list of 'Name: value' headers added to the CachetHQ requests")
+	flag.IntVar(&p.cachetMaxIdleConns, "cachethq_max_idle_conns", 10, "max number of idle (keep-alive) connections to CachetHQ")
+	flag.DurationVar(&p.cachetIdleTimeout, "cachethq_idle_timeout", 90*time.Second, "how long an idle connection to CachetHQ is kept alive")
+	flag.DurationVar(&p.cachetTimeout, "cachethq_timeout", 30*time.Second, "timeout of the requests sent to CachetHQ")
+	flag.StringVar(&p.cachetTimezone, "cachethq_timezone", "UTC", "timezone of the CachetHQ dates (its APP_TIMEZONE, ex: Europe/Paris)")
+	flag.BoolVar(&p.maintenanceMode, "maintenance_mode", false, "start in maintenance mode: the firing alerts do not change the status page")
+	flag.BoolVar(&p.maintenancePrivate, "maintenance_private_incidents", false, "during a maintenance, record the firing alerts as private incidents")
+	flag.StringVar(&p.accessLogFormat, "access_log_format", ACCESS_LOG_GIN, "format of the access logs: [gin|json|common]")
+	flag.StringVar(&p.accessLogFile, "access_log_file", "", "file the access logs are appended to (stdout if empty)")
+	flag.StringVar(&p.accessLogSkipPaths, "access_log_skip_paths", ACCESS_LOG_SKIP_PATHS, "comma separated list of the paths not logged")
+	flag.StringVar(&p.corsAllowedOrigins, "cors_allowed_origins", "", "comma separated list of the origins allowed to call the endpoints (* for any, CORS disabled if empty)")
+	flag.StringVar(&p.corsAllowedMethods, "cors_allowed_methods", CORS_ALLOWED_METHODS, "comma separated list of the methods allowed to the CORS origins")
+	flag.StringVar(&p.corsAllowedHeaders, "cors_allowed_headers", CORS_ALLOWED_HEADERS, "comma separated list of the headers allowed to the CORS origins")
+	flag.StringVar(&p.prometheusURLs, "prometheus_url", "", "comma separated list of Prometheus servers whose alerts are polled (without Alertmanager)")
+	flag.DurationVar(&p.prometheusInterval, "prometheus_poll_interval", time.Minute, "how often the Prometheus alerts are polled")
+	flag.StringVar(&p.pagerdutyRoutingKey, "pagerduty_routing_key", "", "PagerDuty routing key: the alerts are also sent as PagerDuty events (cf the pagerduty_routing_key of the routes)")
+	flag.StringVar(&p.pagerdutyURL, "pagerduty_url", PAGERDUTY_URL, "PagerDuty Events API v2 endpoint")
+	flag.StringVar(&p.opsgenieAPIKey, "opsgenie_api_key", "", "Opsgenie api key: the alerts are also sent as Opsgenie alerts (cf the opsgenie_api_key of the routes)")
+	flag.StringVar(&p.opsgenieURL, "opsgenie_url", OPSGENIE_URL, "Opsgenie API (https://api.eu.opsgenie.com for the EU instance)")
+	flag.StringVar(&p.alertmanagerURL, "alertmanager_url", "", "Alertmanager where the CachetHQ scheduled maintenances are silenced (disabled if empty)")
+	flag.DurationVar(&p.scheduleInterval, "schedule_poll_interval", time.Minute, "how often the CachetHQ schedules are polled (cf alertmanager_url)")
+	flag.IntVar(&p.alertsMetricID, "alerts_metric_id", 0, "CachetHQ metric counting the incidents created by the bridge (disabled if 0)")
+	flag.DurationVar(&p.alertsMetricInterval, "alerts_metric_interval", 0, "push the count of incidents every interval (0: a point for each incident)")
+	flag.IntVar(&p.incidentNameMaxLength, "incident_name_max_length", INCIDENT_NAME_MAX_LENGTH, "max length of the components named by an incident (the last ones are replaced by 'and N more', 0 for no limit)")
+	flag.BoolVar(&p.fetchTruncatedAlerts, "fetch_truncated_alerts", false, "read the alerts truncated by Alertmanager from its API (cf alertmanager_url)")
+	flag.IntVar(&p.grpcPort, "grpc_port", 0, "port of the gRPC alert submission (disabled if 0)")
+	flag.StringVar(&p.grpcClientCA, "grpc_client_ca_file", "", "CA of the gRPC client certificates (needs ssl_cert_file and ssl_key_file)")
+	flag.StringVar(&p.mqttBroker, "mqtt_broker", "", "MQTT broker of the alert payloads, ex: tcp://mosquitto:1883 or ssl://mosquitto:8883 (disabled if empty)")
+	flag.StringVar(&p.mqttTopic, "mqtt_topic", "prometheus-cachethq/alerts", "MQTT topic of the alert payloads")
+	flag.IntVar(&p.mqttQoS, "mqtt_qos", 1, "QoS of the MQTT subscription (0, 1 or 2)")
+	flag.StringVar(&p.mqttClientID, "mqtt_client_id", "prometheus-cachethq", "MQTT client id")
+	flag.StringVar(&p.mqttUsername, "mqtt_username", "", "MQTT username (if any)")
+	flag.StringVar(&p.mqttPassword, "mqtt_password", "", "MQTT password (if any)")
+	flag.StringVar(&p.mqttCAFile, "mqtt_ca_file", "", "CA of the MQTT broker certificate (ssl:// brokers, the system CAs if empty)")
+	flag.DurationVar(&p.componentsCacheInterval, "components_cache_interval", time.Minute, "how often the components served by GET /components are refreshed (disabled if 0)")
+	flag.StringVar(&p.snapshotPath, "snapshot_path", "", "path of the status snapshot served while CachetHQ is unreachable, ex: /status (disabled if empty)")
+	flag.DurationVar(&p.snapshotInterval, "snapshot_interval", time.Minute, "how often CachetHQ is checked, and the status snapshot rendered")
+	flag.BoolVar(&p.storeAndForward, "store_and_forward", false, "buffer the alerts not forwarded while CachetHQ is unreachable, and forward them once it is back")
+	flag.DurationVar(&p.storeAndForwardInterval, "store_and_forward_interval", 30*time.Second, "how often the buffered alerts are forwarded, if CachetHQ is back")
+	flag.IntVar(&p.retryMaxAttempts, "retry_max_attempts", 1, "max attempts of a payload on transient CachetHQ errors (1 = no retry)")
+	flag.DurationVar(&p.retryBackoff, "retry_backoff", 500*time.Millisecond, "delay before the first retry")
+	flag.Float64Var(&p.retryMultiplier, "retry_backoff_multiplier", 2, "multiplier of the delay between two retries")
+	flag.DurationVar(&p.retryMaxBackoff, "retry_max_backoff", 5*time.Second, "max delay between two retries")
+	flag.Float64Var(&p.retryJitter, "retry_jitter", 0.2, "randomization of the retry delays (0.2 = +/- 20%)")
+	flag.DurationVar(&p.retryBudget, "retry_budget", 8*time.Second, "max time spent on a payload, retries included (0 for no limit)")
+	flag.StringVar(&p.retryExhausted, "retry_exhausted", RETRY_EXHAUSTED_ERROR, "what to do once the retries are exhausted: [error|drop|dlq]")
+	flag.StringVar(&p.metricsUsername, "metrics_username", "", "username of the basic authentication of /metrics (with metrics_password, none if empty)")
+	flag.StringVar(&p.metricsPassword, "metrics_password", "", "password of the basic authentication of /metrics")
+	flag.StringVar(&p.metricsToken, "metrics_token", "", "bearer token of /metrics (none if empty)")
+	flag.StringVar(&p.adminListen, "admin_listen", "", "address of the admin listener (health, admin, metrics and pprof endpoints), ex: 127.0.0.1:9091 (all the endpoints on http_port if empty)")
+	flag.BoolVar(&p.configSchema, "config_schema", false, "print the JSON Schema of the configuration file, and exit")
+	flag.StringVar(&p.features, "features", "", "comma separated list of the experimental behaviors enabled (or disabled, with a - prefix), overriding the features of the configuration file")
+	flag.StringVar(&p.hookBeforeCreate, "hook_before_create", "", "command run (with sh -c, the alert as json on stdin) before creating an incident")
+	flag.StringVar(&p.hookAfterResolve, "hook_after_resolve", "", "command run (with sh -c, the alert and the incident as json on stdin) after resolving an incident")
+	flag.DurationVar(&p.hookTimeout, "hook_timeout", 10*time.Second, "max duration of the hooks, before they are killed (0 for no limit)")
+	flag.StringVar(&p.hookFailurePolicy, "hook_failure_policy", HOOK_FAILURE_IGNORE, "what to do when a hook fails: [ignore|abort]")
+	flag.StringVar(&p.opaPolicyFile, "opa_policy_file", "", "OPA (rego) policy deciding whether each incident is created, its visibility and its wording (embedded)")
+	flag.StringVar(&p.opaURL, "opa_url", "", "url of an OPA server holding the publish policy, ex: http://opa:8181 (instead of opa_policy_file)")
+	flag.StringVar(&p.opaQuery, "opa_query", "data.prometheus_cachethq.decision", "document of the publish policy holding the decision")
+	flag.BoolVar(&p.opaFailClosed, "opa_fail_closed", false, "if the publish policy fails, the incident is not created (and the error answered), instead of being created as is")
+	flag.DurationVar(&p.resolveStabilityWindow, "resolve_stability_window", 0, "with squash_incident, the incidents are first set as watching on resolve, and only fixed once the alert stayed resolved for this window (0 to fix them right away)")
+	flag.IntVar(&p.resolveComponentStatus, "resolve_component_status", 2, "status of the components during the stability window (cf https://docs.cachethq.io/docs/component-statuses)")
+	flag.DurationVar(&p.probeInterval, "probe_interval", 30*time.Second, "how often the resolves held by a failing probe (cf cachet_probe_url) are retried")
+	flag.DurationVar(&p.occurrenceWindow, "occurrence_window", 0, "rolling window of the occurrence counter noted in the hidden incidents, ex: 168h for \"3rd occurrence in the last 7d\" (0 to disable)")
+	flag.BoolVar(&p.silenceLink, "silence_link", false, "append to the hidden incidents a link to the Alertmanager UI pre-filling a silence of the alert")
+	flag.StringVar(&p.componentSyncURL, "component_sync_url", "", "Prometheus server whose targets are synced as CachetHQ components")
+	flag.StringVar(&p.componentSyncFile, "component_sync_file", "", "file_sd targets file (json or yaml) synced as CachetHQ components (if no component_sync_url)")
+	flag.StringVar(&p.componentSyncLabel, "component_sync_label", "job", "label of the synced targets naming their component")
+	flag.StringVar(&p.componentSyncGroup, "component_sync_group", "", "component group of the synced components (created if missing)")
+	flag.DurationVar(&p.componentSyncInterval, "component_sync_interval", 5*time.Minute, "how often the components are synced")
+	flag.StringVar(&p.componentSyncGroupLabel, "component_sync_group_label", "", "label of the synced targets naming the component group of their component (ex: team, created if missing)")
+	flag.DurationVar(&p.componentSyncRetireAfter, "component_sync_retire_after", 0, "grace period after which a synced component without targets is retired (0 to never retire)")
+	flag.StringVar(&p.componentSyncRetireAction, "component_sync_retire_action", COMPONENT_RETIRE_REPORT, "what to do with the retired components: [report|disable]")
+	flag.StringVar(&p.stateBackend, "state_backend", STATE_BACKEND_MEMORY, "where the state (firing alerts, incidents, history, store and forward queue) is persisted: [sqlite|postgres] (in memory only if empty)")
+	flag.StringVar(&p.stateDSN, "state_dsn", "prometheus-cachethq.db", "the state database (the file for sqlite, the connection string for postgres)")
+	flag.BoolVar(&p.startupCheck, "startup_check", false, "check on startup that CachetHQ answers, and lists the components (or stop)")
+	flag.BoolVar(&p.startupCheckIncident, "startup_check_incident", false, "also check the token on startup, by creating (and deleting) a hidden incident")
+	flag.StringVar(&p.notifySeverities, "notify_severities", "", "comma separated list of the severities of the incidents emailed to the CachetHQ subscribers (* for all)")
+	flag.DurationVar(&p.notifyCooldown, "notify_cooldown", 0, "the subscribers are not emailed again for a component whose previous incident was resolved within this cooldown, ex: 30m (0 to disable)")
+	flag.StringVar(&p.incidentNameTemplate, "incident_name_template", "", "Go template of the incident names, over the alert labels, ex: '{{ .Labels.service }} - {{ .Labels.alertname }}' (the component name if empty)")
+	flag.StringVar(&p.ginMode, "gin_mode", gin.ReleaseMode, "gin mode: [release|debug|test] (debug logs the routes, and warns about the defaults not suited for production)")
+	flag.StringVar(&p.trustedProxies, "trusted_proxies", "", "comma separated list of the CIDRs (or IPs) of the reverse proxies allowed to forward the client IP (X-Forwarded-For, X-Real-Ip), trusted from any client if empty")
+	flag.StringVar(&p.logFile, "log_file", "", "file the application logs are appended to (stderr if empty)")
+	flag.IntVar(&p.logMaxSize, "log_max_size", 100, "size (in MB) above which the log files (log_file, access_log_file) are rotated (0 for no limit)")
+	flag.DurationVar(&p.logRotateInterval, "log_rotate_interval", 0, "how often the log files are rotated (ex: 24h, 0 for never)")
+	flag.IntVar(&p.logMaxBackups, "log_max_backups", 7, "how many rotated log files are kept (0 to keep them all)")
+	flag.BoolVar(&p.preserveEdits, "preserve_edits", false, "only update (instead of renaming, or overwriting the status of) the incidents edited by a human since the bridge wrote them")
+	flag.BoolVar(&p.respectStatusOverrides, "respect_status_overrides", false, "do not set back to operational (on resolve) the components whose status was changed by a CachetHQ admin since the bridge set it")
+	flag.DurationVar(&p.statusOverrideExpiry, "status_override_expiry", 0, "with respect_status_overrides, the components overridden for longer than this are set back to operational anyway (ex: 2h, 0 for never)")
 	flag.Parse()
 
 	// grab env variable (docker compliant)
@@ -84,9 +320,481 @@ func NewPrometheusCachetParameters() *PrometheusCachetParameters {
 		p.labelName = os.Getenv("LABEL_NAME")
 	}
 
+	if os.Getenv("MATCH_BY") != "" {
+		p.matchBy = os.Getenv("MATCH_BY")
+	}
+
+	if os.Getenv("NORMALIZE_NAMES") == "true" {
+		p.normalizeNames = true
+	}
+
+	if os.Getenv("COMPONENTS_LABEL") != "" {
+		p.componentsLabel = os.Getenv("COMPONENTS_LABEL")
+	}
+	if os.Getenv("COMPONENTS_SEPARATOR") != "" {
+		p.componentsSeparator = os.Getenv("COMPONENTS_SEPARATOR")
+	}
+
+	if os.Getenv("GROUP_LABEL") != "" {
+		p.groupLabel = os.Getenv("GROUP_LABEL")
+	}
+
 	if os.Getenv("SQUASH_INCIDENT") == "true" {
 		p.squashIncident = true
 	}
+
+	if os.Getenv("STICKIED_INCIDENT") == "true" {
+		p.stickiedIncident = true
+	}
+
+	if os.Getenv("DESCRIPTION_ANNOTATION") != "" {
+		p.descriptionAnnot = os.Getenv("DESCRIPTION_ANNOTATION")
+	}
+	if os.Getenv("LINK_ANNOTATION") != "" {
+		p.linkAnnot = os.Getenv("LINK_ANNOTATION")
+	}
+
+	if os.Getenv("NOTIFY_WEBHOOK_URL") != "" {
+		p.notifyWebhookURL = os.Getenv("NOTIFY_WEBHOOK_URL")
+	}
+	if os.Getenv("NOTIFY_USERNAME") != "" {
+		p.notifyUsername = os.Getenv("NOTIFY_USERNAME")
+	}
+
+	if os.Getenv("WATCHDOG_DELAY") != "" {
+		if delay, err := time.ParseDuration(os.Getenv("WATCHDOG_DELAY")); err == nil {
+			p.watchdogDelay = delay
+		}
+	}
+	if os.Getenv("WATCHDOG_INTERVAL") != "" {
+		if interval, err := time.ParseDuration(os.Getenv("WATCHDOG_INTERVAL")); err == nil {
+			p.watchdogInterval = interval
+		}
+	}
+	if os.Getenv("WATCHDOG_ACTIONS") != "" {
+		p.watchdogActions = os.Getenv("WATCHDOG_ACTIONS")
+	}
+	if os.Getenv("WATCHDOG_EXEC") != "" {
+		p.watchdogExec = os.Getenv("WATCHDOG_EXEC")
+	}
+
+	if os.Getenv("CONFIG_FILE") != "" {
+		p.configFile = os.Getenv("CONFIG_FILE")
+	}
+
+	if os.Getenv("FAKE_CACHET") == "true" {
+		p.fakeCachet = true
+	}
+	if os.Getenv("FAKE_CACHET_COMPONENTS") != "" {
+		p.fakeCachetComps = os.Getenv("FAKE_CACHET_COMPONENTS")
+	}
+
+	if os.Getenv("CACHETHQ_ERROR_STATUS") != "" {
+		if status, err := strconv.Atoi(os.Getenv("CACHETHQ_ERROR_STATUS")); err == nil {
+			p.cachetErrorStatus = status
+		}
+	}
+
+	if os.Getenv("RATE_LIMIT") != "" {
+		if rate, err := strconv.ParseFloat(os.Getenv("RATE_LIMIT"), 64); err == nil {
+			p.rateLimit = rate
+		}
+	}
+	if os.Getenv("RATE_LIMIT_BURST") != "" {
+		if burst, err := strconv.Atoi(os.Getenv("RATE_LIMIT_BURST")); err == nil {
+			p.rateLimitBurst = burst
+		}
+	}
+
+	if os.Getenv("CONCURRENCY") != "" {
+		if concurrency, err := strconv.Atoi(os.Getenv("CONCURRENCY")); err == nil {
+			p.concurrency = concurrency
+		}
+	}
+
+	if os.Getenv("HISTORY_SIZE") != "" {
+		if size, err := strconv.Atoi(os.Getenv("HISTORY_SIZE")); err == nil {
+			p.historySize = size
+		}
+	}
+
+	if os.Getenv("CACHETHQ_VERSION") != "" {
+		p.cachetVersion = os.Getenv("CACHETHQ_VERSION")
+	}
+
+	if os.Getenv("CACHETHQ_AUTH") != "" {
+		p.cachetAuth = os.Getenv("CACHETHQ_AUTH")
+	}
+
+	if os.Getenv("CACHETHQ_USER_AGENT") != "" {
+		p.cachetUserAgent = os.Getenv("CACHETHQ_USER_AGENT")
+	}
+	if os.Getenv("CACHETHQ_HEADERS") != "" {
+		p.cachetHeaders = os.Getenv("CACHETHQ_HEADERS")
+	}
+
+	if os.Getenv("CACHETHQ_MAX_IDLE_CONNS") != "" {
+		if conns, err := strconv.Atoi(os.Getenv("CACHETHQ_MAX_IDLE_CONNS")); err == nil {
+			p.cachetMaxIdleConns = conns
+		}
+	}
+	if os.Getenv("CACHETHQ_IDLE_TIMEOUT") != "" {
+		if timeout, err := time.ParseDuration(os.Getenv("CACHETHQ_IDLE_TIMEOUT")); err == nil {
+			p.cachetIdleTimeout = timeout
+		}
+	}
+
+	if os.Getenv("CACHETHQ_TIMEOUT") != "" {
+		if timeout, err := time.ParseDuration(os.Getenv("CACHETHQ_TIMEOUT")); err == nil {
+			p.cachetTimeout = timeout
+		}
+	}
+
+	if os.Getenv("CACHETHQ_TIMEZONE") != "" {
+		p.cachetTimezone = os.Getenv("CACHETHQ_TIMEZONE")
+	}
+
+	if os.Getenv("MAINTENANCE_MODE") == "true" {
+		p.maintenanceMode = true
+	}
+	if os.Getenv("MAINTENANCE_PRIVATE_INCIDENTS") == "true" {
+		p.maintenancePrivate = true
+	}
+
+	if os.Getenv("ACCESS_LOG_FORMAT") != "" {
+		p.accessLogFormat = os.Getenv("ACCESS_LOG_FORMAT")
+	}
+	if os.Getenv("ACCESS_LOG_FILE") != "" {
+		p.accessLogFile = os.Getenv("ACCESS_LOG_FILE")
+	}
+	if os.Getenv("ACCESS_LOG_SKIP_PATHS") != "" {
+		p.accessLogSkipPaths = os.Getenv("ACCESS_LOG_SKIP_PATHS")
+	}
+
+	if os.Getenv("CORS_ALLOWED_ORIGINS") != "" {
+		p.corsAllowedOrigins = os.Getenv("CORS_ALLOWED_ORIGINS")
+	}
+	if os.Getenv("CORS_ALLOWED_METHODS") != "" {
+		p.corsAllowedMethods = os.Getenv("CORS_ALLOWED_METHODS")
+	}
+	if os.Getenv("CORS_ALLOWED_HEADERS") != "" {
+		p.corsAllowedHeaders = os.Getenv("CORS_ALLOWED_HEADERS")
+	}
+
+	if os.Getenv("PROMETHEUS_URL") != "" {
+		p.prometheusURLs = os.Getenv("PROMETHEUS_URL")
+	}
+	if os.Getenv("PROMETHEUS_POLL_INTERVAL") != "" {
+		if interval, err := time.ParseDuration(os.Getenv("PROMETHEUS_POLL_INTERVAL")); err == nil {
+			p.prometheusInterval = interval
+		}
+	}
+
+	if os.Getenv("PAGERDUTY_ROUTING_KEY") != "" {
+		p.pagerdutyRoutingKey = os.Getenv("PAGERDUTY_ROUTING_KEY")
+	}
+	if os.Getenv("PAGERDUTY_URL") != "" {
+		p.pagerdutyURL = os.Getenv("PAGERDUTY_URL")
+	}
+
+	if os.Getenv("OPSGENIE_API_KEY") != "" {
+		p.opsgenieAPIKey = os.Getenv("OPSGENIE_API_KEY")
+	}
+	if os.Getenv("OPSGENIE_URL") != "" {
+		p.opsgenieURL = os.Getenv("OPSGENIE_URL")
+	}
+
+	if os.Getenv("ALERTMANAGER_URL") != "" {
+		p.alertmanagerURL = os.Getenv("ALERTMANAGER_URL")
+	}
+	if os.Getenv("SCHEDULE_POLL_INTERVAL") != "" {
+		if interval, err := time.ParseDuration(os.Getenv("SCHEDULE_POLL_INTERVAL")); err == nil {
+			p.scheduleInterval = interval
+		}
+	}
+
+	if os.Getenv("ALERTS_METRIC_ID") != "" {
+		if id, err := strconv.Atoi(os.Getenv("ALERTS_METRIC_ID")); err == nil {
+			p.alertsMetricID = id
+		}
+	}
+	if os.Getenv("ALERTS_METRIC_INTERVAL") != "" {
+		if interval, err := time.ParseDuration(os.Getenv("ALERTS_METRIC_INTERVAL")); err == nil {
+			p.alertsMetricInterval = interval
+		}
+	}
+
+	if os.Getenv("INCIDENT_NAME_MAX_LENGTH") != "" {
+		if length, err := strconv.Atoi(os.Getenv("INCIDENT_NAME_MAX_LENGTH")); err == nil {
+			p.incidentNameMaxLength = length
+		}
+	}
+
+	if os.Getenv("FETCH_TRUNCATED_ALERTS") == "true" {
+		p.fetchTruncatedAlerts = true
+	}
+
+	if os.Getenv("GRPC_PORT") != "" {
+		if port, err := strconv.Atoi(os.Getenv("GRPC_PORT")); err == nil {
+			p.grpcPort = port
+		}
+	}
+	if os.Getenv("GRPC_CLIENT_CA_FILE") != "" {
+		p.grpcClientCA = os.Getenv("GRPC_CLIENT_CA_FILE")
+	}
+
+	if os.Getenv("MQTT_BROKER") != "" {
+		p.mqttBroker = os.Getenv("MQTT_BROKER")
+	}
+	if os.Getenv("MQTT_TOPIC") != "" {
+		p.mqttTopic = os.Getenv("MQTT_TOPIC")
+	}
+	if os.Getenv("MQTT_QOS") != "" {
+		if qos, err := strconv.Atoi(os.Getenv("MQTT_QOS")); err == nil {
+			p.mqttQoS = qos
+		}
+	}
+	if os.Getenv("MQTT_CLIENT_ID") != "" {
+		p.mqttClientID = os.Getenv("MQTT_CLIENT_ID")
+	}
+	if os.Getenv("MQTT_USERNAME") != "" {
+		p.mqttUsername = os.Getenv("MQTT_USERNAME")
+	}
+	if os.Getenv("MQTT_PASSWORD") != "" {
+		p.mqttPassword = os.Getenv("MQTT_PASSWORD")
+	}
+	if os.Getenv("MQTT_CA_FILE") != "" {
+		p.mqttCAFile = os.Getenv("MQTT_CA_FILE")
+	}
+
+	if os.Getenv("COMPONENTS_CACHE_INTERVAL") != "" {
+		if interval, err := time.ParseDuration(os.Getenv("COMPONENTS_CACHE_INTERVAL")); err == nil {
+			p.componentsCacheInterval = interval
+		}
+	}
+
+	if os.Getenv("SNAPSHOT_PATH") != "" {
+		p.snapshotPath = os.Getenv("SNAPSHOT_PATH")
+	}
+	if os.Getenv("SNAPSHOT_INTERVAL") != "" {
+		if interval, err := time.ParseDuration(os.Getenv("SNAPSHOT_INTERVAL")); err == nil {
+			p.snapshotInterval = interval
+		}
+	}
+
+	if os.Getenv("STORE_AND_FORWARD") == "true" {
+		p.storeAndForward = true
+	}
+	if os.Getenv("STORE_AND_FORWARD_INTERVAL") != "" {
+		if interval, err := time.ParseDuration(os.Getenv("STORE_AND_FORWARD_INTERVAL")); err == nil {
+			p.storeAndForwardInterval = interval
+		}
+	}
+
+	if os.Getenv("RETRY_MAX_ATTEMPTS") != "" {
+		if attempts, err := strconv.Atoi(os.Getenv("RETRY_MAX_ATTEMPTS")); err == nil {
+			p.retryMaxAttempts = attempts
+		}
+	}
+	if os.Getenv("RETRY_BACKOFF") != "" {
+		if backoff, err := time.ParseDuration(os.Getenv("RETRY_BACKOFF")); err == nil {
+			p.retryBackoff = backoff
+		}
+	}
+	if os.Getenv("RETRY_BACKOFF_MULTIPLIER") != "" {
+		if multiplier, err := strconv.ParseFloat(os.Getenv("RETRY_BACKOFF_MULTIPLIER"), 64); err == nil {
+			p.retryMultiplier = multiplier
+		}
+	}
+	if os.Getenv("RETRY_MAX_BACKOFF") != "" {
+		if backoff, err := time.ParseDuration(os.Getenv("RETRY_MAX_BACKOFF")); err == nil {
+			p.retryMaxBackoff = backoff
+		}
+	}
+	if os.Getenv("RETRY_JITTER") != "" {
+		if jitter, err := strconv.ParseFloat(os.Getenv("RETRY_JITTER"), 64); err == nil {
+			p.retryJitter = jitter
+		}
+	}
+	if os.Getenv("RETRY_BUDGET") != "" {
+		if budget, err := time.ParseDuration(os.Getenv("RETRY_BUDGET")); err == nil {
+			p.retryBudget = budget
+		}
+	}
+	if os.Getenv("RETRY_EXHAUSTED") != "" {
+		p.retryExhausted = os.Getenv("RETRY_EXHAUSTED")
+	}
+
+	if os.Getenv("METRICS_USERNAME") != "" {
+		p.metricsUsername = os.Getenv("METRICS_USERNAME")
+	}
+	if os.Getenv("METRICS_PASSWORD") != "" {
+		p.metricsPassword = os.Getenv("METRICS_PASSWORD")
+	}
+	if os.Getenv("METRICS_TOKEN") != "" {
+		p.metricsToken = os.Getenv("METRICS_TOKEN")
+	}
+
+	if os.Getenv("ADMIN_LISTEN") != "" {
+		p.adminListen = os.Getenv("ADMIN_LISTEN")
+	}
+
+	if os.Getenv("FEATURES") != "" {
+		p.features = os.Getenv("FEATURES")
+	}
+
+	if os.Getenv("HOOK_BEFORE_CREATE") != "" {
+		p.hookBeforeCreate = os.Getenv("HOOK_BEFORE_CREATE")
+	}
+	if os.Getenv("HOOK_AFTER_RESOLVE") != "" {
+		p.hookAfterResolve = os.Getenv("HOOK_AFTER_RESOLVE")
+	}
+	if os.Getenv("HOOK_TIMEOUT") != "" {
+		if timeout, err := time.ParseDuration(os.Getenv("HOOK_TIMEOUT")); err == nil {
+			p.hookTimeout = timeout
+		}
+	}
+	if os.Getenv("HOOK_FAILURE_POLICY") != "" {
+		p.hookFailurePolicy = os.Getenv("HOOK_FAILURE_POLICY")
+	}
+
+	if os.Getenv("OPA_POLICY_FILE") != "" {
+		p.opaPolicyFile = os.Getenv("OPA_POLICY_FILE")
+	}
+	if os.Getenv("OPA_URL") != "" {
+		p.opaURL = os.Getenv("OPA_URL")
+	}
+	if os.Getenv("OPA_QUERY") != "" {
+		p.opaQuery = os.Getenv("OPA_QUERY")
+	}
+	if os.Getenv("OPA_FAIL_CLOSED") == "true" {
+		p.opaFailClosed = true
+	}
+
+	if os.Getenv("RESOLVE_STABILITY_WINDOW") != "" {
+		if window, err := time.ParseDuration(os.Getenv("RESOLVE_STABILITY_WINDOW")); err == nil {
+			p.resolveStabilityWindow = window
+		}
+	}
+	if os.Getenv("RESOLVE_COMPONENT_STATUS") != "" {
+		if status, err := strconv.Atoi(os.Getenv("RESOLVE_COMPONENT_STATUS")); err == nil {
+			p.resolveComponentStatus = status
+		}
+	}
+
+	if os.Getenv("PROBE_INTERVAL") != "" {
+		if interval, err := time.ParseDuration(os.Getenv("PROBE_INTERVAL")); err == nil {
+			p.probeInterval = interval
+		}
+	}
+
+	if os.Getenv("OCCURRENCE_WINDOW") != "" {
+		if window, err := time.ParseDuration(os.Getenv("OCCURRENCE_WINDOW")); err == nil {
+			p.occurrenceWindow = window
+		}
+	}
+
+	if os.Getenv("SILENCE_LINK") == "true" {
+		p.silenceLink = true
+	}
+
+	if os.Getenv("COMPONENT_SYNC_URL") != "" {
+		p.componentSyncURL = os.Getenv("COMPONENT_SYNC_URL")
+	}
+	if os.Getenv("COMPONENT_SYNC_FILE") != "" {
+		p.componentSyncFile = os.Getenv("COMPONENT_SYNC_FILE")
+	}
+	if os.Getenv("COMPONENT_SYNC_LABEL") != "" {
+		p.componentSyncLabel = os.Getenv("COMPONENT_SYNC_LABEL")
+	}
+	if os.Getenv("COMPONENT_SYNC_GROUP") != "" {
+		p.componentSyncGroup = os.Getenv("COMPONENT_SYNC_GROUP")
+	}
+	if os.Getenv("COMPONENT_SYNC_INTERVAL") != "" {
+		if interval, err := time.ParseDuration(os.Getenv("COMPONENT_SYNC_INTERVAL")); err == nil {
+			p.componentSyncInterval = interval
+		}
+	}
+
+	if os.Getenv("COMPONENT_SYNC_GROUP_LABEL") != "" {
+		p.componentSyncGroupLabel = os.Getenv("COMPONENT_SYNC_GROUP_LABEL")
+	}
+
+	if os.Getenv("COMPONENT_SYNC_RETIRE_AFTER") != "" {
+		if after, err := time.ParseDuration(os.Getenv("COMPONENT_SYNC_RETIRE_AFTER")); err == nil {
+			p.componentSyncRetireAfter = after
+		}
+	}
+	if os.Getenv("COMPONENT_SYNC_RETIRE_ACTION") != "" {
+		p.componentSyncRetireAction = os.Getenv("COMPONENT_SYNC_RETIRE_ACTION")
+	}
+
+	if os.Getenv("STATE_BACKEND") != "" {
+		p.stateBackend = os.Getenv("STATE_BACKEND")
+	}
+	if os.Getenv("STATE_DSN") != "" {
+		p.stateDSN = os.Getenv("STATE_DSN")
+	}
+
+	if os.Getenv("STARTUP_CHECK") == "true" {
+		p.startupCheck = true
+	}
+	if os.Getenv("STARTUP_CHECK_INCIDENT") == "true" {
+		p.startupCheckIncident = true
+	}
+
+	if os.Getenv("NOTIFY_SEVERITIES") != "" {
+		p.notifySeverities = os.Getenv("NOTIFY_SEVERITIES")
+	}
+
+	if os.Getenv("NOTIFY_COOLDOWN") != "" {
+		if cooldown, err := time.ParseDuration(os.Getenv("NOTIFY_COOLDOWN")); err == nil {
+			p.notifyCooldown = cooldown
+		}
+	}
+
+	if os.Getenv("INCIDENT_NAME_TEMPLATE") != "" {
+		p.incidentNameTemplate = os.Getenv("INCIDENT_NAME_TEMPLATE")
+	}
+
+	if os.Getenv("GIN_MODE") != "" {
+		p.ginMode = os.Getenv("GIN_MODE")
+	}
+	if os.Getenv("TRUSTED_PROXIES") != "" {
+		p.trustedProxies = os.Getenv("TRUSTED_PROXIES")
+	}
+
+	if os.Getenv("LOG_FILE") != "" {
+		p.logFile = os.Getenv("LOG_FILE")
+	}
+	if os.Getenv("LOG_MAX_SIZE") != "" {
+		if size, err := strconv.Atoi(os.Getenv("LOG_MAX_SIZE")); err == nil {
+			p.logMaxSize = size
+		}
+	}
+	if os.Getenv("LOG_ROTATE_INTERVAL") != "" {
+		if interval, err := time.ParseDuration(os.Getenv("LOG_ROTATE_INTERVAL")); err == nil {
+			p.logRotateInterval = interval
+		}
+	}
+	if os.Getenv("LOG_MAX_BACKUPS") != "" {
+		if backups, err := strconv.Atoi(os.Getenv("LOG_MAX_BACKUPS")); err == nil {
+			p.logMaxBackups = backups
+		}
+	}
+
+	if os.Getenv("PRESERVE_EDITS") == "true" {
+		p.preserveEdits = true
+	}
+
+	if os.Getenv("RESPECT_STATUS_OVERRIDES") == "true" {
+		p.respectStatusOverrides = true
+	}
+	if os.Getenv("STATUS_OVERRIDE_EXPIRY") != "" {
+		if expiry, err := time.ParseDuration(os.Getenv("STATUS_OVERRIDE_EXPIRY")); err == nil {
+			p.statusOverrideExpiry = expiry
+		}
+	}
 	return p
 }
 
@@ -94,13 +802,146 @@ type PrometheusCachetConfig struct {
 	PrometheusToken string
 	Cachet          Cachet
 	LabelName       string
-	LogLevel        int
-	SquashIncident  bool
+	MatchBy         string
+	NormalizeNames  bool
+	// map[alias]componentname (with normalized aliases if NormalizeNames is set, cf ConfigFile.AliasMap)
+	Aliases map[string]string
+	// label listing several components impacted by one alert
+	ComponentsLabel     string
+	ComponentsSeparator string
+	// label naming a component group impacted by one alert
+	GroupLabel       string
+	LogLevel         int32 // accessed atomically (cf debug)
+	SquashIncident   bool
+	StickiedIncident bool
+	// annotations synced into the component description/link
+	DescriptionAnnotation string
+	LinkAnnotation        string
+	Notifier              Notifier
+	Watchdog              *CachetWatchdog
+	Routes                []*Route
+	Escalator             *IncidentEscalator
+	// http status code answered on transient CachetHQ errors
+	CachetErrorStatus int
+	RateLimiter       *RateLimiter
+	// number of components processed in parallel, and their serialization
+	Concurrency    int
+	ComponentLocks *ComponentLocks
+	// alerts firing on each component
+	FiringAlerts *FiringAlerts
+	// original description/link of the components changed by the alert annotations
+	ComponentDetails *ComponentDetails
+	Metrics          *Metrics
+	History          *History
+	Events           *EventBroker
+	// pause/resume of the writes to CachetHQ
+	Forwarding *Forwarding
+	// timezone of the CachetHQ dates
+	CachetLocation *time.Location
+	// global maintenance mode
+	Maintenance *Maintenance
+	// components excluded from the automation
+	Silences *Silences
+	// access logs of the http endpoints (the gin default if nil)
+	AccessLogger gin.HandlerFunc
+	// cross-origin requests allowed (nil if disabled)
+	CORS *CORS
+	// the generic input adapters of the configuration file, by name
+	Inputs map[string]*GenericInput
+	// the incident texts of the configuration file (the default wording if nil)
+	Templates *IncidentTemplates
+	// the incident texts selected by the annotation of the alerts, by name
+	NamedTemplates map[string]*IncidentTemplates
+	// the relabeling of the alerts received, before the filter (cf RelabelConfig)
+	Relabeling Relabeling
+	// the filter of the configuration file, the alerts not matching it are ignored (nil if none)
+	Filter *AlertExpression
+	// PagerDuty events of the alerts (nil if disabled)
+	PagerDuty *PagerDuty
+	// Opsgenie alerts of the alerts (nil if disabled)
+	Opsgenie *Opsgenie
+	// incidents created, pushed to a CachetHQ metric (nil if disabled)
+	AlertVolume *AlertVolume
+	// max length of the components named by an incident (0 for no limit)
+	IncidentNameMaxLength int
+	// the alert groups of Alertmanager, to complete the truncated payloads (nil if disabled)
+	AlertGroups *AlertGroups
+	// the last known components, served by GET /components (nil if disabled)
+	Components *ComponentCache
+	// the status snapshot served while CachetHQ is unreachable (nil if disabled)
+	Snapshot *StatusSnapshot
+	// the alerts buffered while CachetHQ is unreachable (nil if disabled)
+	StoreAndForward *StoreAndForward
+	// the retries of the payloads on transient CachetHQ errors (nil if disabled)
+	Retry *RetryPolicy
+	// the credentials of /metrics (none if empty)
+	MetricsUsername string
+	MetricsPassword string
+	MetricsToken    string
+	// address of the admin listener (all the endpoints on the main one if empty)
+	AdminListen string
+	// the effective configuration, served by GET /config
+	RuntimeConfig *RuntimeConfig
+	// the experimental behaviors enabled
+	Features Features
+	// the commands run before creating and after resolving the incidents (nil if none)
+	Hooks *ExecHooks
+	// the OPA policy deciding the publication of the incidents (nil if none)
+	Policy *PublishPolicy
+	// the alerts held until the end of the quiet hours of their route (nil if no route queues them)
+	QuietQueue *QuietQueue
+	// the two-phase resolution of the incidents (nil if disabled)
+	Stability *StabilityWindow
+	// the health urls checked before resolving the incidents (cf cachet_probe_url)
+	Probe *ResolveProbe
+	// the incidents created on each component, noted in the hidden incidents (nil if disabled)
+	Occurrences *OccurrenceCounter
+	// a link to silence the alert is appended to the hidden incidents
+	SilenceLink bool
+	// the sync of the components from the Prometheus targets (nil if disabled)
+	ComponentSync *ComponentSync
+	// the persisted state (nil if kept in memory only)
+	State *StateStore
+	// the severities of the incidents emailed to the subscribers (cf Route.NotifySubscribers)
+	NotifySeverities []string
+	// the components not notified again right after their recovery (nil if disabled)
+	NotifyCooldown *NotifyCooldown
+	// the reverse proxies allowed to forward the client IP (nil: the forwarded headers are trusted)
+	TrustedProxies TrustedProxies
+	// only update the incidents edited by a human (cf Route.PreserveEdits), with the revisions written by the bridge
+	PreserveEdits bool
+	Revisions     *IncidentRevisions
+	// the component statuses set by a CachetHQ admin, kept on resolve (nil if not respected)
+	StatusOverrides *StatusOverrides
 }
 
 func main() {
 	parameters := NewPrometheusCachetParameters()
 
+	if parameters.configSchema {
+		os.Stdout.Write(ConfigSchema())
+		return
+	}
+
+	// the log files are rotated alike
+	logRotation := LogRotation{MaxSize: int64(parameters.logMaxSize) << 20, Interval: parameters.logRotateInterval, MaxBackups: parameters.logMaxBackups}
+	logFile, err := OpenLogFile(parameters.logFile, logRotation, os.Stderr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(logFile)
+
+	if parameters.fakeCachet {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			log.Fatal(err)
+		}
+		go http.Serve(listener, NewFakeCachet(strings.Split(parameters.fakeCachetComps, ",")))
+
+		parameters.cachetURL = fmt.Sprintf("http://%s", listener.Addr().String())
+		log.Println("using the embedded fake CachetHQ on", parameters.cachetURL)
+	}
+
 	caCertPool := x509.NewCertPool()
 	if parameters.cachetRootCA != "" {
 		caCert, err := ioutil.ReadFile(parameters.cachetRootCA)
@@ -112,40 +953,407 @@ func main() {
 	}
 
 	httpClient := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				RootCAs:            caCertPool,
-				InsecureSkipVerify: parameters.cachetSkipVerifySsl,
-			},
-		},
+		Timeout: parameters.cachetTimeout,
+		Transport: NewCachetTransport(&tls.Config{
+			RootCAs:            caCertPool,
+			InsecureSkipVerify: parameters.cachetSkipVerifySsl,
+		}, parameters.cachetMaxIdleConns, parameters.cachetIdleTimeout),
+	}
+
+	cachet := NewCachetImpl(parameters.cachetURL, parameters.cachetToken, httpClient)
+	if err := cachet.SetAuthScheme(parameters.cachetAuth); err != nil {
+		log.Fatal(err)
+	}
+	cachet.SetUserAgent(parameters.cachetUserAgent)
+	headers, err := ParseHeaders(parameters.cachetHeaders)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cachet.SetHeaders(headers)
+	location, err := time.LoadLocation(parameters.cachetTimezone)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cachet.SetLocation(location)
+	if err := setCachetVersion(cachet, parameters.cachetVersion); err != nil {
+		log.Fatal(err)
+	}
+
+	if parameters.startupCheck || parameters.startupCheckIncident {
+		if err := SmokeTest(cachet, parameters.startupCheckIncident); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	config := PrometheusCachetConfig{
-		PrometheusToken: parameters.prometheusToken,
-		Cachet:          NewCachetImpl(parameters.cachetURL, parameters.cachetToken, httpClient),
-		LabelName:       parameters.labelName,
-		LogLevel:        LOG_INFO,
-		SquashIncident:  parameters.squashIncident,
+		PrometheusToken:       parameters.prometheusToken,
+		Cachet:                cachet,
+		LabelName:             parameters.labelName,
+		MatchBy:               parameters.matchBy,
+		NormalizeNames:        parameters.normalizeNames,
+		ComponentsLabel:       parameters.componentsLabel,
+		ComponentsSeparator:   parameters.componentsSeparator,
+		GroupLabel:            parameters.groupLabel,
+		LogLevel:              LOG_INFO,
+		SquashIncident:        parameters.squashIncident,
+		StickiedIncident:      parameters.stickiedIncident,
+		DescriptionAnnotation: parameters.descriptionAnnot,
+		LinkAnnotation:        parameters.linkAnnot,
+		CachetErrorStatus:     parameters.cachetErrorStatus,
+		Concurrency:           parameters.concurrency,
+		ComponentLocks:        NewComponentLocks(),
+		ComponentDetails:      NewComponentDetails(),
+		FiringAlerts:          NewFiringAlerts(),
+		Metrics:               NewMetrics(),
+		History:               NewHistory(parameters.historySize),
+		Events:                NewEventBroker(),
+		Forwarding:            NewForwarding(),
+		CachetLocation:        location,
+		Maintenance:           NewMaintenance(parameters.maintenanceMode, parameters.maintenancePrivate),
+		Silences:              NewSilences(),
+		IncidentNameMaxLength: parameters.incidentNameMaxLength,
+		MetricsUsername:       parameters.metricsUsername,
+		MetricsPassword:       parameters.metricsPassword,
+		MetricsToken:          parameters.metricsToken,
+		AdminListen:           parameters.adminListen,
+		SilenceLink:           parameters.silenceLink,
+		NotifySeverities:      splitList(parameters.notifySeverities),
+		PreserveEdits:         parameters.preserveEdits,
+	}
+
+	if parameters.notifyWebhookURL != "" {
+		// no flood of the channel (i.e. an unmapped alert repeated by Alertmanager)
+		config.Notifier = NewThrottledNotifier(NewSlackNotifier(parameters.notifyWebhookURL, parameters.notifyUsername, &http.Client{Timeout: 10 * time.Second}), NOTIFY_WINDOW, NOTIFY_BURST)
 	}
 
-	config.LogLevel = LOG_INFO
-	if parameters.loglevel == "debug" {
-		config.LogLevel = LOG_DEBUG
+	if parameters.componentsCacheInterval > 0 {
+		config.Components = NewComponentCache(config.Cachet, parameters.componentsCacheInterval)
+		config.Cachet = config.Components
 	}
 
+	if parameters.snapshotPath != "" {
+		if config.Components == nil {
+			log.Fatal("snapshot_path needs components_cache_interval")
+		}
+		config.Snapshot = NewStatusSnapshot(&config, parameters.snapshotPath, parameters.cachetURL, parameters.snapshotInterval)
+	}
+
+	if parameters.retryMaxAttempts != 1 || parameters.retryExhausted != RETRY_EXHAUSTED_ERROR {
+		if config.Retry, err = NewRetryPolicy(parameters.retryMaxAttempts, parameters.retryBackoff, parameters.retryMultiplier, parameters.retryMaxBackoff, parameters.retryJitter, parameters.retryBudget, parameters.retryExhausted); err != nil {
+			log.Fatal(err)
+		}
+		if config.Retry.Exhausted == RETRY_EXHAUSTED_DLQ && !parameters.storeAndForward {
+			log.Fatal("retry_exhausted=dlq needs store_and_forward")
+		}
+	}
+
+	if parameters.hookBeforeCreate != "" || parameters.hookAfterResolve != "" {
+		if config.Hooks, err = NewExecHooks(parameters.hookBeforeCreate, parameters.hookAfterResolve, parameters.hookTimeout, parameters.hookFailurePolicy); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if parameters.opaPolicyFile != "" || parameters.opaURL != "" {
+		if config.Policy, err = NewPublishPolicy(parameters.opaPolicyFile, parameters.opaURL, parameters.opaQuery, parameters.opaFailClosed); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if parameters.occurrenceWindow > 0 {
+		config.Occurrences = NewOccurrenceCounter(parameters.occurrenceWindow)
+	}
+
+	if parameters.notifyCooldown > 0 {
+		config.NotifyCooldown = NewNotifyCooldown(parameters.notifyCooldown)
+	}
+
+	if parameters.stateBackend != STATE_BACKEND_MEMORY {
+		if config.State, err = NewStateStore(parameters.stateBackend, parameters.stateDSN); err != nil {
+			log.Fatal(err)
+		}
+		config.ComponentLocks.Shared = config.State
+		if err := config.FiringAlerts.Restore(config.State); err != nil {
+			log.Fatal("not able to restore the firing alerts: ", err)
+		}
+		if err := config.History.Restore(config.State); err != nil {
+			log.Fatal("not able to restore the history: ", err)
+		}
+	}
+
+	// closed on shutdown, to stop the background loops
+	stop := make(chan struct{})
+
+	if parameters.resolveStabilityWindow > 0 {
+		if !config.SquashIncident {
+			log.Fatal("resolve_stability_window needs squash_incident")
+		}
+		if config.Stability, err = NewStabilityWindow(&config, parameters.resolveStabilityWindow, parameters.resolveComponentStatus, STABILITY_CHECK_INTERVAL); err != nil {
+			log.Fatal(err)
+		}
+		go config.Stability.Run(stop)
+	}
+	if parameters.watchdogDelay > 0 {
+		config.Watchdog = NewCachetWatchdog(config.Cachet, config.Notifier, parameters.watchdogInterval, parameters.watchdogDelay, parameters.watchdogActions, parameters.watchdogExec)
+		go config.Watchdog.Run(stop)
+	}
+
+	// the CachetHQ metric of the daily availability, by component name
+	var uptimeMetrics map[string]int
+	var probeURLs map[string]string
+	var configFile *ConfigFile
+	if parameters.configFile != "" {
+		if configFile, err = LoadConfigFile(parameters.configFile, config.NormalizeNames); err != nil {
+			log.Fatal(err)
+		}
+		config.Routes = configFile.Routes
+		config.Inputs = configFile.Inputs
+		config.Templates = configFile.Templates
+		config.NamedTemplates = configFile.NamedTemplates
+		config.Filter = configFile.filter
+		config.Relabeling = configFile.RelabelConfigs
+		uptimeMetrics = configFile.UptimeMetrics
+		probeURLs = configFile.Probes
+		if config.Aliases, err = configFile.AliasMap(config.NormalizeNames); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// the default incident names, the templates of the configuration file having priority
+	if parameters.incidentNameTemplate != "" {
+		names, err := NewNameTemplates(parameters.incidentNameTemplate)
+		if err != nil {
+			log.Fatal("incident_name_template: ", err)
+		}
+		config.Templates = config.Templates.inherit(names)
+		for _, route := range config.Routes {
+			if route.Templates != nil {
+				route.Templates = route.Templates.inherit(names)
+			}
+		}
+	}
+
+	var fileFeatures Features
+	if configFile != nil {
+		fileFeatures = configFile.Features
+	}
+	if config.Features, err = ParseFeatures(parameters.features, fileFeatures); err != nil {
+		log.Fatal(err)
+	}
+	if config.RuntimeConfig, err = NewRuntimeConfig(flag.CommandLine, configFile); err != nil {
+		log.Fatal(err)
+	}
+	config.RuntimeConfig.Features = config.Features
+
+	if config.PreserveEdits || routesPreserveEdits(config.Routes) {
+		config.Revisions = NewIncidentRevisions()
+		config.Cachet = NewRevisionCachet(config.Cachet, config.Revisions)
+	}
+
+	if parameters.respectStatusOverrides {
+		config.StatusOverrides = NewStatusOverrides(parameters.statusOverrideExpiry, config.CachetLocation)
+		config.Cachet = NewStatusOverrideCachet(config.Cachet, config.StatusOverrides)
+	}
+
+	if parameters.pagerdutyRoutingKey != "" || routesHavePagerDuty(config.Routes) {
+		config.PagerDuty = NewPagerDuty(parameters.pagerdutyURL, parameters.pagerdutyRoutingKey)
+	}
+
+	if parameters.opsgenieAPIKey != "" || routesHaveOpsgenie(config.Routes) {
+		config.Opsgenie = NewOpsgenie(parameters.opsgenieURL, parameters.opsgenieAPIKey)
+	}
+
+	config.Probe = NewResolveProbe(&config, probeURLs, parameters.probeInterval)
+	go config.Probe.Run(stop)
+
+	// once the routes are loaded
+	if NeedsQuietQueue(config.Routes) {
+		config.QuietQueue = NewQuietQueue(&config, QUIET_HOURS_INTERVAL)
+		go config.QuietQueue.Run(stop)
+	}
+
+	if parameters.alertsMetricID != 0 {
+		config.AlertVolume = NewAlertVolume(config.Cachet, parameters.alertsMetricID, parameters.alertsMetricInterval)
+		go config.AlertVolume.Run(stop)
+	}
+
+	config.Escalator = NewIncidentEscalator(config.Cachet, config.Metrics, 30*time.Second)
+	config.Escalator.SetNamedTemplates(config.NamedTemplates)
+	config.Escalator.SetForwarding(config.Forwarding)
+	config.Escalator.SetRevisions(config.Revisions, config.PreserveEdits)
+	go config.Escalator.Run(stop)
+
+	if parameters.rateLimit > 0 {
+		config.RateLimiter = NewRateLimiter(parameters.rateLimit, parameters.rateLimitBurst)
+	}
+
+	// an unknown level is "info"
+	level, _ := ParseLogLevel(parameters.loglevel)
+	config.setLogLevel(level)
+
+	if config.MatchBy != MATCH_BY_NAME && config.MatchBy != MATCH_BY_TAG {
+		log.Fatalf("unknown match_by value '%s'", config.MatchBy)
+	}
+
+	if parameters.corsAllowedOrigins != "" {
+		config.CORS = NewCORS(parameters.corsAllowedOrigins, parameters.corsAllowedMethods, parameters.corsAllowedHeaders)
+	}
+
+	accessLog, err := OpenLogFile(parameters.accessLogFile, logRotation, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.AccessLogger, err = NewAccessLogger(parameters.accessLogFormat, accessLog, splitList(parameters.accessLogSkipPaths)); err != nil {
+		log.Fatal(err)
+	}
+
+	if parameters.fetchTruncatedAlerts {
+		if parameters.alertmanagerURL == "" {
+			log.Fatal("fetch_truncated_alerts needs alertmanager_url")
+		}
+		config.AlertGroups = NewAlertGroups(parameters.alertmanagerURL)
+	}
+
+	if parameters.componentSyncURL != "" || parameters.componentSyncFile != "" {
+		if parameters.componentSyncRetireAction != COMPONENT_RETIRE_REPORT && parameters.componentSyncRetireAction != COMPONENT_RETIRE_DISABLE {
+			log.Fatal("component_sync_retire_action should be report or disable")
+		}
+		config.ComponentSync = NewComponentSync(&config, parameters.componentSyncURL, parameters.componentSyncFile, parameters.componentSyncLabel, parameters.componentSyncGroup, parameters.componentSyncInterval)
+		config.ComponentSync.GroupLabel = parameters.componentSyncGroupLabel
+		config.ComponentSync.RetireAfter = parameters.componentSyncRetireAfter
+		config.ComponentSync.RetireAction = parameters.componentSyncRetireAction
+		go config.ComponentSync.Run(stop)
+	}
+
+	switch parameters.ginMode {
+	case gin.ReleaseMode, gin.DebugMode, gin.TestMode:
+		gin.SetMode(parameters.ginMode)
+	default:
+		log.Fatal("gin_mode should be release, debug or test")
+	}
+	if parameters.trustedProxies != "" {
+		if config.TrustedProxies, err = NewTrustedProxies(parameters.trustedProxies); err != nil {
+			log.Fatal(err)
+		}
+	}
 	router := PrepareGinRouter(&config)
 
+	// before any poller or subscriber, buffering their first alerts if CachetHQ is unreachable
+	if parameters.storeAndForward {
+		config.StoreAndForward = NewStoreAndForward(&config, parameters.storeAndForwardInterval)
+		if config.State != nil {
+			if err := config.StoreAndForward.Restore(config.State); err != nil {
+				log.Fatal("not able to restore the store and forward queue: ", err)
+			}
+		}
+	}
+
+	// the config is complete: the alerts can be polled
+	for _, url := range splitList(parameters.prometheusURLs) {
+		go NewPrometheusPoller(&config, url, parameters.prometheusInterval).Run(stop)
+	}
+	if parameters.mqttBroker != "" {
+		subscriber, err := NewMQTTSubscriber(&config, parameters.mqttBroker, parameters.mqttTopic, parameters.mqttQoS, parameters.mqttClientID, parameters.mqttUsername, parameters.mqttPassword, parameters.mqttCAFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go subscriber.Run(stop)
+	}
+	if config.Components != nil {
+		go config.Components.Run(stop)
+	}
+	if config.Snapshot != nil {
+		go config.Snapshot.Run(stop)
+	}
+	if config.StoreAndForward != nil {
+		go config.StoreAndForward.Run(stop)
+	}
+	config.Metrics.WatchQueues(&config)
+	if len(uptimeMetrics) > 0 {
+		go NewUptimePusher(&config, uptimeMetrics).Run(stop)
+	}
+	if parameters.alertmanagerURL != "" {
+		go NewScheduleSilencer(&config, parameters.alertmanagerURL, parameters.scheduleInterval).Run(stop)
+	}
+
+	var grpcServer *grpc.Server
+	if parameters.grpcPort != 0 {
+		if grpcServer, err = NewGRPCServer(&config, parameters.sslCert, parameters.sslKey, parameters.grpcClientCA); err != nil {
+			log.Fatal(err)
+		}
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", parameters.grpcPort))
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
 	server := &http.Server{
 		Addr:           fmt.Sprintf(":%d", parameters.httpPort),
-		Handler:        router,
+		Handler:        WithWriteTimeout(router, 10*time.Second),
 		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
 
+	var adminServer *http.Server
+	if config.AdminListen != "" {
+		adminServer = &http.Server{
+			Addr:           config.AdminListen,
+			Handler:        WithWriteTimeout(PrepareAdminRouter(&config), 10*time.Second),
+			ReadTimeout:    10 * time.Second,
+			MaxHeaderBytes: 1 << 20,
+		}
+		go func() {
+			var err error
+			if parameters.sslCert != "" && parameters.sslKey != "" {
+				err = adminServer.ListenAndServeTLS(parameters.sslCert, parameters.sslKey)
+			} else {
+				err = adminServer.ListenAndServe()
+			}
+			if err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	// on SIGINT/SIGTERM, stop the background loops and finish the in-flight requests
+	shutdown := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+
+		log.Println("shutting down")
+		close(stop)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Println(err)
+		}
+		if adminServer != nil {
+			if err := adminServer.Shutdown(ctx); err != nil {
+				log.Println(err)
+			}
+		}
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		if err := config.State.Close(); err != nil {
+			log.Println(err)
+		}
+		close(shutdown)
+	}()
+
 	if parameters.sslCert != "" && parameters.sslKey != "" {
-		log.Fatal(server.ListenAndServeTLS(parameters.sslCert, parameters.sslKey))
+		err = server.ListenAndServeTLS(parameters.sslCert, parameters.sslKey)
 	} else {
-		log.Fatal(server.ListenAndServe())
+		err = server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
 	}
+	<-shutdown
 }
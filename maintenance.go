@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// MaintenanceStatus is the state of the maintenance mode, as set (and answered) by /admin/maintenance
+type MaintenanceStatus struct {
+	Enabled bool `json:"enabled"`
+	// PrivateIncidents records the firing alerts as incidents not visible on the status page
+	PrivateIncidents bool       `json:"private_incidents"`
+	Since            *time.Time `json:"since,omitempty"`
+}
+
+// Maintenance is the global maintenance mode: while enabled, the firing alerts do not change the status page
+// (a maintenance notice being already posted), at most they are recorded as private incidents
+type Maintenance struct {
+	mutex   sync.Mutex
+	enabled bool
+	private bool
+	since   time.Time
+}
+
+// NewMaintenance creates a new Maintenance, enabled from the start if enabled
+func NewMaintenance(enabled, privateIncidents bool) *Maintenance {
+	m := &Maintenance{}
+	m.Set(enabled, privateIncidents, time.Now())
+	return m
+}
+
+// Set enables (or disables) the maintenance mode
+func (m *Maintenance) Set(enabled, privateIncidents bool, now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if enabled && !m.enabled {
+		m.since = now
+	}
+	m.enabled = enabled
+	m.private = privateIncidents
+}
+
+// Enabled returns true during a maintenance
+func (m *Maintenance) Enabled() bool {
+	if m == nil {
+		return false
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.enabled
+}
+
+// Status returns the state of the maintenance mode
+func (m *Maintenance) Status() MaintenanceStatus {
+	if m == nil {
+		return MaintenanceStatus{}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	status := MaintenanceStatus{Enabled: m.enabled, PrivateIncidents: m.private}
+	if m.enabled {
+		since := m.since
+		status.Since = &since
+	}
+	return status
+}
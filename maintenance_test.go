@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "web"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		PrometheusToken: "promToken",
+		LabelName:       "alertname",
+		ComponentsLabel: "cachet_components",
+		Cachet:          NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident:  true,
+		Maintenance:     NewMaintenance(false, false),
+	}
+	router := PrepareGinRouter(config)
+	setMaintenance := func(payload string) MaintenanceStatus {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/admin/maintenance", bytes.NewBufferString(payload))
+		req.Header.Set("Authorization", "Bearer promToken")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+
+		var status MaintenanceStatus
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &status))
+		return status
+	}
+	alert := PrometheusAlertDetail{Labels: map[string]string{"alertname": "edge", "cachet_components": "API,web"}}
+
+	status := setMaintenance(`{"enabled":true}`)
+	assert.True(t, status.Enabled)
+	assert.NotNil(t, status.Since)
+
+	// nothing public
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(fake.incidents))
+	assert.Equal(t, 1, fake.components[0].Status)
+
+	// recorded privately
+	setMaintenance(`{"enabled":true,"private_incidents":true}`)
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, 0, fake.incidents[0].Visible)
+	assert.Equal(t, 1, fake.components[0].Status)
+	assert.Equal(t, 1, fake.components[1].Status)
+
+	// and still private once resolved
+	status = setMaintenance(`{"enabled":false}`)
+	assert.False(t, status.Enabled)
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, 0, fake.incidents[0].Visible)
+
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(fake.incidents))
+	assert.Equal(t, 1, fake.incidents[1].Visible)
+	assert.Equal(t, 4, fake.components[1].Status)
+}
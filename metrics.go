@@ -0,0 +1,179 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// label of the alerts used as the severity of the incident metrics
+	LABEL_SEVERITY = "severity"
+
+	METRIC_INCIDENT_CREATED  = "created"
+	METRIC_INCIDENT_UPDATED  = "updated"
+	METRIC_INCIDENT_RESOLVED = "resolved"
+
+	// the queues of the pipeline
+	METRIC_QUEUE_FORWARDING        = "forwarding"        // the payloads queued while the forwarding is paused
+	METRIC_QUEUE_STORE_AND_FORWARD = "store_and_forward" // the alerts waiting for CachetHQ (the dlq of retry_exhausted)
+	METRIC_QUEUE_QUIET_HOURS       = "quiet_hours"       // the alerts queued during the quiet hours
+	METRIC_QUEUE_STABILITY         = "stability"         // the resolves waiting for their stability window
+	METRIC_QUEUE_PROBE             = "probe"             // the resolves held by a failing probe
+)
+
+// Metrics are the Prometheus metrics exported by the bridge on /metrics
+type Metrics struct {
+	registry  *prometheus.Registry
+	incidents *prometheus.CounterVec
+	truncated *prometheus.CounterVec
+	webhooks  *prometheus.CounterVec
+	retries   prometheus.Counter
+	exhausted *prometheus.CounterVec
+}
+
+// NewMetrics creates (and registers) the metrics of the bridge
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		incidents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "prometheus_cachethq_incidents_total",
+			Help: "Number of CachetHQ incidents created, updated and resolved by the bridge.",
+		}, []string{"component", "severity", "action"}),
+		truncated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "prometheus_cachethq_truncated_alerts_total",
+			Help: "Number of alerts truncated by Alertmanager from the payloads received.",
+		}, []string{"receiver"}),
+		webhooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "prometheus_cachethq_webhook_payloads_total",
+			Help: "Number of Alertmanager webhook payloads received, by version and result (accepted, compatible or rejected).",
+		}, []string{"version", "result"}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prometheus_cachethq_retries_total",
+			Help: "Number of payloads processed again after a transient CachetHQ error.",
+		}),
+		exhausted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "prometheus_cachethq_retries_exhausted_total",
+			Help: "Number of payloads still failing once the retries are exhausted, by action (error, drop or dlq).",
+		}, []string{"action"}),
+	}
+	m.registry.MustRegister(m.incidents)
+	m.registry.MustRegister(m.truncated)
+	m.registry.MustRegister(m.webhooks)
+	m.registry.MustRegister(m.retries)
+	m.registry.MustRegister(m.exhausted)
+	m.registry.MustRegister(prometheus.NewGoCollector())
+	m.registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	return m
+}
+
+// IncidentAction counts an incident action ([created|updated|resolved]) for each of the components of the incident
+func (m *Metrics) IncidentAction(componentNames []string, severity, action string) {
+	if m == nil {
+		return
+	}
+	for _, componentName := range componentNames {
+		m.incidents.WithLabelValues(componentName, severity, action).Inc()
+	}
+}
+
+// TruncatedAlerts counts the alerts truncated by Alertmanager from a payload of the receiver
+func (m *Metrics) TruncatedAlerts(receiver string, count int) {
+	if m == nil {
+		return
+	}
+	m.truncated.WithLabelValues(receiver).Add(float64(count))
+}
+
+// WebhookPayload counts an Alertmanager webhook payload of the version, by result ([accepted|compatible|rejected])
+func (m *Metrics) WebhookPayload(version, result string) {
+	if m == nil {
+		return
+	}
+	m.webhooks.WithLabelValues(version, result).Inc()
+}
+
+// Retries counts the payloads processed again after a transient CachetHQ error
+func (m *Metrics) Retries(count int) {
+	if m == nil || count <= 0 {
+		return
+	}
+	m.retries.Add(float64(count))
+}
+
+// RetriesExhausted counts a payload still failing once the retries are exhausted, by action ([error|drop|dlq])
+func (m *Metrics) RetriesExhausted(action string) {
+	if m == nil {
+		return
+	}
+	m.exhausted.WithLabelValues(action).Inc()
+}
+
+// WatchQueues exports the state of the queues of the pipeline (read on each scrape), so that a pipeline backing up
+// can be alerted on
+func (m *Metrics) WatchQueues(config *PrometheusCachetConfig) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(&queueCollector{
+		config: config,
+		depth:  prometheus.NewDesc("prometheus_cachethq_queue_depth", "Number of items waiting in the queues of the pipeline.", []string{"queue"}, nil),
+		oldest: prometheus.NewDesc("prometheus_cachethq_queue_oldest_age_seconds", "Age of the oldest item waiting in the queue (0 if empty).", []string{"queue"}, nil),
+		paused: prometheus.NewDesc("prometheus_cachethq_forwarding_paused", "1 if the forwarding to CachetHQ is paused.", nil, nil),
+	})
+}
+
+// queueCollector collects the state of the queues of the pipeline
+type queueCollector struct {
+	config *PrometheusCachetConfig
+	depth  *prometheus.Desc
+	oldest *prometheus.Desc
+	paused *prometheus.Desc
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.depth
+	ch <- c.oldest
+	ch <- c.paused
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	age := func(queue string, since time.Time) {
+		seconds := 0.0
+		if !since.IsZero() {
+			seconds = now.Sub(since).Seconds()
+		}
+		ch <- prometheus.MustNewConstMetric(c.oldest, prometheus.GaugeValue, seconds, queue)
+	}
+
+	forwarding := ForwardingStatus{}
+	if c.config.Forwarding != nil {
+		forwarding = c.config.Forwarding.Status()
+	}
+	paused := 0.0
+	if forwarding.Paused {
+		paused = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.paused, prometheus.GaugeValue, paused)
+	ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(forwarding.Queued), METRIC_QUEUE_FORWARDING)
+	// the payloads are queued since the pause
+	if forwarding.Queued > 0 && forwarding.Since != nil {
+		age(METRIC_QUEUE_FORWARDING, *forwarding.Since)
+	} else {
+		age(METRIC_QUEUE_FORWARDING, time.Time{})
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(c.config.StoreAndForward.Buffered()), METRIC_QUEUE_STORE_AND_FORWARD)
+	age(METRIC_QUEUE_STORE_AND_FORWARD, c.config.StoreAndForward.Oldest())
+	ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(c.config.QuietQueue.Queued()), METRIC_QUEUE_QUIET_HOURS)
+	ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(c.config.Stability.Watched()), METRIC_QUEUE_STABILITY)
+	ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(c.config.Probe.Pending()), METRIC_QUEUE_PROBE)
+}
+
+// Handler serves the metrics, in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
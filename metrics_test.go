@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncidentMetrics(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident: true,
+		Metrics:        NewMetrics(),
+	}
+
+	alert := PrometheusAlertDetail{Labels: map[string]string{"alertname": "API", "severity": "critical"}}
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	config.Metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Contains(t, string(body), `prometheus_cachethq_incidents_total{action="created",component="API",severity="critical"} 1`)
+	assert.Contains(t, string(body), `prometheus_cachethq_incidents_total{action="resolved",component="API",severity="critical"} 1`)
+
+	// one incident for several components: counted for each of them
+	config.ComponentsLabel = "cachet_components"
+	fake.components = append(fake.components, &fakeCachetComponent{Id: 2, Name: "CDN", Status: 1, Enabled: true})
+	alert = PrometheusAlertDetail{Labels: map[string]string{"alertname": "edge", "cachet_components": "API,CDN"}}
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+
+	w = httptest.NewRecorder()
+	config.Metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ = ioutil.ReadAll(w.Body)
+	assert.Contains(t, string(body), `prometheus_cachethq_incidents_total{action="created",component="API",severity=""} 1`)
+	assert.Contains(t, string(body), `prometheus_cachethq_incidents_total{action="created",component="CDN",severity=""} 1`)
+	assert.NotContains(t, string(body), `component="API, CDN"`)
+
+	// nil metrics are a no-op
+	var none *Metrics
+	none.IncidentAction([]string{"API"}, "", METRIC_INCIDENT_CREATED)
+}
+
+func TestMetricsAuthorization(t *testing.T) {
+	config := &PrometheusCachetConfig{LabelName: "alertname", Metrics: NewMetrics()}
+	router := PrepareGinRouter(config)
+	get := func(prepare func(req *http.Request)) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		prepare(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	none := func(req *http.Request) {}
+
+	// not protected by default
+	assert.Equal(t, http.StatusOK, get(none).Code)
+
+	config.MetricsUsername, config.MetricsPassword, config.MetricsToken = "prometheus", "secret", "token"
+	w := get(none)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, []string{`Basic realm="prometheus-cachethq"`, `Bearer realm="prometheus-cachethq"`}, w.Header()["Www-Authenticate"])
+	assert.Equal(t, http.StatusUnauthorized, get(func(req *http.Request) { req.SetBasicAuth("prometheus", "wrong") }).Code)
+
+	assert.Equal(t, http.StatusOK, get(func(req *http.Request) { req.SetBasicAuth("prometheus", "secret") }).Code)
+	assert.Equal(t, http.StatusOK, get(func(req *http.Request) { req.Header.Set("Authorization", "Bearer token") }).Code)
+}
+
+func TestQueueMetrics(t *testing.T) {
+	failing := true
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:  "alertname",
+		Cachet:     NewCachetImpl(ts.URL, "token", ts.Client()),
+		Metrics:    NewMetrics(),
+		Forwarding: NewForwarding(),
+	}
+	config.Retry, _ = NewRetryPolicy(3, time.Millisecond, 1, 0, 0, 0, RETRY_EXHAUSTED_DLQ)
+	config.StoreAndForward = NewStoreAndForward(config, 0)
+	config.Metrics.WatchQueues(config)
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		config.Metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		body, _ := ioutil.ReadAll(w.Body)
+		return string(body)
+	}
+
+	body := scrape()
+	assert.Contains(t, body, `prometheus_cachethq_queue_depth{queue="store_and_forward"} 0`)
+	assert.Contains(t, body, `prometheus_cachethq_queue_oldest_age_seconds{queue="store_and_forward"} 0`)
+	assert.Contains(t, body, `prometheus_cachethq_forwarding_paused 0`)
+
+	// CachetHQ is down: retried, then buffered
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API"}}}})
+	assert.Nil(t, err)
+	config.Forwarding.Pause(time.Now().Add(-time.Minute))
+	config.Forwarding.enqueue(&PrometheusAlert{Version: "4", Status: "firing"})
+	body = scrape()
+	assert.Contains(t, body, `prometheus_cachethq_retries_total 2`)
+	assert.Contains(t, body, `prometheus_cachethq_retries_exhausted_total{action="dlq"} 1`)
+	assert.Contains(t, body, `prometheus_cachethq_queue_depth{queue="store_and_forward"} 1`)
+	assert.NotContains(t, body, "prometheus_cachethq_queue_oldest_age_seconds{queue=\"store_and_forward\"} 0\n")
+	assert.Contains(t, body, `prometheus_cachethq_queue_depth{queue="forwarding"} 1`)
+	assert.Contains(t, body, `prometheus_cachethq_forwarding_paused 1`)
+	assert.Contains(t, body, `prometheus_cachethq_queue_depth{queue="quiet_hours"} 0`)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTT_RETRY_INTERVAL is the delay between two connections to an unreachable broker (once connected, the client
+// reconnects by itself)
+const MQTT_RETRY_INTERVAL = 30 * time.Second
+
+// MQTTSubscriber consumes the Alertmanager webhook payloads published on a topic of a MQTT broker, and forwards them
+// to CachetHQ, as POST /alert
+type MQTTSubscriber struct {
+	config  *PrometheusCachetConfig
+	options *mqtt.ClientOptions
+	topic   string
+	qos     byte
+}
+
+// NewMQTTSubscriber creates a new MQTTSubscriber of the topic of the broker (tcp://host:1883, or ssl://host:8883 with
+// TLS, verified with the CA of caFile if set)
+func NewMQTTSubscriber(config *PrometheusCachetConfig, broker, topic string, qos int, clientID, username, password, caFile string) (*MQTTSubscriber, error) {
+	if qos < 0 || qos > 2 {
+		return nil, fmt.Errorf("unknown MQTT QoS %d (0, 1 or 2)", qos)
+	}
+	s := &MQTTSubscriber{config: config, topic: topic, qos: byte(qos)}
+	s.options = mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(clientID).
+		SetUsername(username).
+		SetPassword(password).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(s.subscribe).
+		SetConnectionLostHandler(func(client mqtt.Client, err error) {
+			log.Println("MQTT connection to", broker, "lost:", err)
+		})
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+		s.options.SetTLSConfig(&tls.Config{RootCAs: roots})
+	}
+	return s, nil
+}
+
+// Run connects to the broker (retrying every MQTT_RETRY_INTERVAL), and consumes the payloads until stop is closed
+func (s *MQTTSubscriber) Run(stop <-chan struct{}) {
+	client := mqtt.NewClient(s.options)
+	for {
+		token := client.Connect()
+		token.Wait()
+		if token.Error() == nil {
+			break
+		}
+		log.Println("not able to connect to the MQTT broker:", token.Error())
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(MQTT_RETRY_INTERVAL):
+		}
+	}
+
+	<-stop
+	client.Disconnect(250)
+}
+
+// subscribe (re)subscribes to the topic, on each connection
+func (s *MQTTSubscriber) subscribe(client mqtt.Client) {
+	token := client.Subscribe(s.topic, s.qos, func(client mqtt.Client, message mqtt.Message) {
+		if err := s.handle(message.Payload()); err != nil {
+			log.Println("MQTT payload of", message.Topic(), "not forwarded:", err)
+		}
+	})
+	if token.Wait() && token.Error() != nil {
+		log.Println("not able to subscribe to the MQTT topic", s.topic, ":", token.Error())
+	}
+}
+
+// handle forwards a payload to CachetHQ
+func (s *MQTTSubscriber) handle(payload []byte) error {
+	alerts, err := decodeWebhook(s.config, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	handleTruncatedAlerts(s.config, alerts)
+	_, _, err = dispatchAlerts(s.config, alerts)
+	return err
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMQTTSubscriber(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "token", ts.Client()),
+	}
+	subscriber, err := NewMQTTSubscriber(&config, "tcp://localhost:1883", "prometheus-cachethq/alerts", 1, "prometheus-cachethq", "", "", "")
+	assert.Nil(t, err)
+
+	assert.Nil(t, subscriber.handle([]byte(`{"version":"4","status":"firing","receiver":"edge","alerts":[{"labels":{"alertname":"API"}}]}`)))
+	assert.Equal(t, 4, fake.components[0].Status)
+
+	err = subscriber.handle([]byte(`{"version":"4","status":"up","alerts":[]}`))
+	assert.Equal(t, ValidationError{{Field: "status", Reason: "'up' is unknown (firing or resolved)"}}, err)
+
+	_, err = NewMQTTSubscriber(&config, "tcp://localhost:1883", "alerts", 3, "prometheus-cachethq", "", "", "")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// nagiosNotification is the payload posted by the Nagios/Icinga2 notification script (cf contrib/nagios)
+type nagiosNotification struct {
+	Type      string `json:"type"` // host or service
+	Host      string `json:"host"`
+	Service   string `json:"service"`
+	State     string `json:"state"`
+	StateType string `json:"state_type"` // HARD or SOFT
+	Output    string `json:"output"`
+	// Component overrides the impacted component (the service, or the host, by default)
+	Component string `json:"component"`
+}
+
+// NagiosAdapter translates the Nagios/Icinga2 host and service notifications
+type NagiosAdapter struct{}
+
+// Parse translates a HARD state change: DOWN/UNREACHABLE (CRITICAL for a service) fire, UP (OK) resolve,
+// the other states (and the SOFT ones) are ignored
+func (a *NagiosAdapter) Parse(config *PrometheusCachetConfig, body []byte) (*PrometheusAlert, error) {
+	var notification nagiosNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, err
+	}
+	if notification.Host == "" {
+		return nil, fmt.Errorf("host is required")
+	}
+	if notification.StateType != "" && !strings.EqualFold(notification.StateType, "HARD") {
+		return nil, nil
+	}
+
+	var firing bool
+	component, key := notification.Host, notification.Host
+	switch strings.ToLower(notification.Type) {
+	case "host", "":
+		switch strings.ToUpper(notification.State) {
+		case "DOWN", "UNREACHABLE":
+			firing = true
+		case "UP":
+		default:
+			return nil, fmt.Errorf("unknown host state '%s'", notification.State)
+		}
+	case "service":
+		if notification.Service == "" {
+			return nil, fmt.Errorf("service is required")
+		}
+		component, key = notification.Service, notification.Host+"/"+notification.Service
+		switch strings.ToUpper(notification.State) {
+		case "CRITICAL":
+			firing = true
+		case "OK":
+		case "WARNING", "UNKNOWN":
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unknown service state '%s'", notification.State)
+		}
+	default:
+		return nil, fmt.Errorf("unknown notification type '%s' (host or service)", notification.Type)
+	}
+	if notification.Component != "" {
+		component = notification.Component
+	}
+
+	alerts := newInputAlert(config, "nagios", firing, component, key)
+	if notification.Output != "" {
+		alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY] = notification.Output
+	}
+	return alerts, nil
+}
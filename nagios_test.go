@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNagiosAdapter(t *testing.T) {
+	config := &PrometheusCachetConfig{LabelName: "alertname"}
+	adapter := &NagiosAdapter{}
+
+	alerts, err := adapter.Parse(config, []byte(`{"type":"host","host":"web01","state":"DOWN","state_type":"HARD","output":"PING CRITICAL"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "firing", alerts.Status)
+	assert.Equal(t, "web01", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "PING CRITICAL", alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY])
+	assert.Equal(t, "nagios:web01", alerts.Alerts[0].fingerprint())
+
+	alerts, err = adapter.Parse(config, []byte(`{"type":"service","host":"web01","service":"HTTP","state":"OK","state_type":"HARD","component":"API"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "resolved", alerts.Status)
+	assert.Equal(t, "API", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "nagios:web01/HTTP", alerts.Alerts[0].fingerprint())
+
+	// ignored
+	for _, payload := range []string{
+		`{"type":"host","host":"web01","state":"DOWN","state_type":"SOFT"}`,
+		`{"type":"service","host":"web01","service":"HTTP","state":"WARNING","state_type":"HARD"}`,
+	} {
+		alerts, err = adapter.Parse(config, []byte(payload))
+		assert.Nil(t, err)
+		assert.Nil(t, alerts)
+	}
+
+	_, err = adapter.Parse(config, []byte(`{"type":"host","host":"web01","state":"CRITICAL"}`))
+	assert.NotNil(t, err)
+	_, err = adapter.Parse(config, []byte(`{"type":"service","host":"web01","state":"OK"}`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// the same message is sent at most once per NOTIFY_WINDOW,
+	// and at most NOTIFY_BURST messages are sent per NOTIFY_WINDOW
+	NOTIFY_WINDOW = 10 * time.Minute
+	NOTIFY_BURST  = 10
+)
+
+// Notifier is used to warn operations when the bridge itself fails
+// (wrong token, CachetHQ down, unmapped components, ...)
+type Notifier interface {
+	Notify(message string) error
+}
+
+// cf https://api.slack.com/messaging/webhooks
+// and https://docs.mattermost.com/developer/webhooks-incoming.html
+// {
+//    "text": "Hello, this is some text",
+//    "username": "prometheus-cachethq"
+// }
+type slackMessage struct {
+	Text     string `json:"text"`
+	Username string `json:"username,omitempty"`
+}
+
+// SlackNotifier posts messages to a Slack (or Mattermost) incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	username   string
+	client     *http.Client
+}
+
+// NewSlackNotifier creates a new Notifier posting to a Slack/Mattermost incoming webhook
+func NewSlackNotifier(webhookURL, username string, client *http.Client) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL: webhookURL,
+		username:   username,
+		client:     client,
+	}
+}
+
+func (s *SlackNotifier) Notify(message string) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(&slackMessage{Text: message, Username: s.username}); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.webhookURL, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("notification webhook returned %d: %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
+
+// ThrottledNotifier is a Notifier decorator sending the messages asynchronously (from one single goroutine),
+// dropping the duplicated messages, and the messages over the rate limit
+type ThrottledNotifier struct {
+	notifier Notifier
+	window   time.Duration
+	burst    int
+	queue    chan string
+
+	mutex       sync.Mutex
+	sent        map[string]time.Time
+	windowStart time.Time
+	windowCount int
+}
+
+// NewThrottledNotifier creates a new ThrottledNotifier, sending at most burst messages per window,
+// and each message at most once per window
+func NewThrottledNotifier(notifier Notifier, window time.Duration, burst int) *ThrottledNotifier {
+	t := &ThrottledNotifier{
+		notifier: notifier,
+		window:   window,
+		burst:    burst,
+		queue:    make(chan string, burst),
+		sent:     make(map[string]time.Time),
+	}
+	go t.run()
+	return t
+}
+
+// Notify queues the message (it never blocks, and never fails)
+func (t *ThrottledNotifier) Notify(message string) error {
+	if !t.accept(time.Now(), message) {
+		return nil
+	}
+	select {
+	case t.queue <- message:
+	default:
+		log.Println("notification queue full, dropping:", message)
+	}
+	return nil
+}
+
+func (t *ThrottledNotifier) accept(now time.Time, message string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if now.Sub(t.windowStart) >= t.window {
+		t.windowStart = now
+		t.windowCount = 0
+		for sent, at := range t.sent {
+			if now.Sub(at) >= t.window {
+				delete(t.sent, sent)
+			}
+		}
+	}
+
+	if at, ok := t.sent[message]; ok && now.Sub(at) < t.window {
+		return false
+	}
+	if t.windowCount >= t.burst {
+		return false
+	}
+	t.sent[message] = now
+	t.windowCount++
+	return true
+}
+
+func (t *ThrottledNotifier) run() {
+	for message := range t.queue {
+		if err := t.notifier.Notify(message); err != nil {
+			log.Println("not able to send notification:", err)
+		}
+	}
+}
+
+// notifyError sends a message to the configured Notifier, if any, and to the /events subscribers
+// (config.Notifier is expected to be asynchronous, cf ThrottledNotifier)
+func notifyError(config *PrometheusCachetConfig, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	config.Events.Publish(&Event{Type: EVENT_ERROR, Message: message})
+
+	if config.Notifier == nil {
+		return
+	}
+	if err := config.Notifier.Notify(message); err != nil {
+		log.Println("not able to send notification:", err)
+	}
+}
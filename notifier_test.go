@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockNotifier struct {
+	messages chan string
+}
+
+func (m *mockNotifier) Notify(message string) error {
+	m.messages <- message
+	return nil
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var received slackMessage
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	notifier := NewSlackNotifier(ts.URL, "bridge", ts.Client())
+	err := notifier.Notify("CachetHQ is down")
+	assert.Nil(t, err)
+	assert.Equal(t, "CachetHQ is down", received.Text)
+	assert.Equal(t, "bridge", received.Username)
+}
+
+func TestSlackNotifierFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	notifier := NewSlackNotifier(ts.URL, "", ts.Client())
+	assert.NotNil(t, notifier.Notify("CachetHQ is down"))
+}
+
+func TestNotifyWrongToken(t *testing.T) {
+	notifier := &mockNotifier{messages: make(chan string, 1)}
+	config := PrometheusCachetConfig{
+		LabelName:       "alertname",
+		PrometheusToken: "promToken",
+		LogLevel:        LOG_DEBUG,
+		Notifier:        notifier,
+	}
+
+	router := PrepareGinRouter(&config)
+
+	req, _ := http.NewRequest("POST", "/alert", bytes.NewBufferString(`{}`))
+	req.Header.Set("Authorization", "Bearer wrongToken")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// anybody can send unauthenticated requests: no notification
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	select {
+	case message := <-notifier.messages:
+		t.Error("unexpected notification:", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestThrottledNotifier(t *testing.T) {
+	notifier := &mockNotifier{messages: make(chan string, 10)}
+	throttled := NewThrottledNotifier(notifier, time.Minute, 2)
+
+	assert.Nil(t, throttled.Notify("component22 not found"))
+	assert.Nil(t, throttled.Notify("component22 not found"))
+	assert.Equal(t, "component22 not found", <-notifier.messages)
+
+	// duplicates are dropped
+	now := time.Now()
+	assert.False(t, throttled.accept(now, "component22 not found"))
+	assert.True(t, throttled.accept(now, "component23 not found"))
+
+	// over the rate limit
+	assert.False(t, throttled.accept(now, "component24 not found"))
+
+	// next window
+	assert.True(t, throttled.accept(now.Add(time.Minute), "component22 not found"))
+	assert.True(t, throttled.accept(now.Add(time.Minute), "component24 not found"))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// OccurrenceCounter counts the incidents created by the bridge on each component, within a rolling window, so
+// that the hidden incidents give the flapping context to the responders ("3rd occurrence in the last 7d"). The
+// counters start with the bridge
+type OccurrenceCounter struct {
+	window time.Duration
+
+	mutex       sync.Mutex
+	occurrences map[int][]time.Time // the creation dates, by component id
+}
+
+// NewOccurrenceCounter creates a new OccurrenceCounter
+func NewOccurrenceCounter(window time.Duration) *OccurrenceCounter {
+	return &OccurrenceCounter{
+		window:      window,
+		occurrences: make(map[int][]time.Time),
+	}
+}
+
+// count returns the number of incidents of the component within the window
+func (o *OccurrenceCounter) count(componentID int, now time.Time) int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	kept := o.occurrences[componentID][:0]
+	for _, at := range o.occurrences[componentID] {
+		if now.Sub(at) < o.window {
+			kept = append(kept, at)
+		}
+	}
+	o.occurrences[componentID] = kept
+	return len(kept)
+}
+
+// record counts a new incident of the component
+func (o *OccurrenceCounter) record(componentID int, now time.Time) {
+	if o == nil {
+		return
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.occurrences[componentID] = append(o.occurrences[componentID], now)
+}
+
+// note returns the footnote of a new hidden incident of the component (empty if the counters are disabled)
+func (o *OccurrenceCounter) note(componentID int, now time.Time) string {
+	if o == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s occurrence in the last %s", ordinal(o.count(componentID, now)+1), humanizeDuration(o.window))
+}
+
+// ordinal returns 1st, 2nd, 3rd, 4th... 11th, 12th, 13th... 21st
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOccurrenceCounter(t *testing.T) {
+	counter := NewOccurrenceCounter(7 * 24 * time.Hour)
+	now := time.Now()
+	assert.Equal(t, "1st occurrence in the last 7d", counter.note(1, now))
+	counter.record(1, now.Add(-8*24*time.Hour))
+	counter.record(1, now.Add(-2*24*time.Hour))
+	counter.record(1, now.Add(-time.Hour))
+	assert.Equal(t, "3rd occurrence in the last 7d", counter.note(1, now))
+	assert.Equal(t, "1st occurrence in the last 7d", counter.note(2, now))
+
+	for n, expected := range map[int]string{2: "2nd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 22: "22nd", 101: "101st", 111: "111th"} {
+		assert.Equal(t, expected, ordinal(n))
+	}
+}
+
+func TestOccurrencesInHiddenIncidents(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	visible := false
+	config := &PrometheusCachetConfig{
+		LabelName:   "alertname",
+		Cachet:      NewCachetImpl(ts.URL, "token", ts.Client()),
+		Routes:      []*Route{{Name: "internal", Visible: &visible}},
+		Occurrences: NewOccurrenceCounter(time.Hour),
+	}
+	for i := 0; i < 2; i++ {
+		_, err := ProcessAlerts(config, &PrometheusAlert{
+			Version: "4",
+			Status:  "firing",
+			Alerts:  []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API"}}},
+		})
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 2, len(fake.incidents))
+	assert.True(t, strings.Contains(fake.incidents[0].Message, "Prometheus flagged service API as down\n\n1st occurrence in the last 1h"), fake.incidents[0].Message)
+	assert.True(t, strings.Contains(fake.incidents[1].Message, "2nd occurrence in the last 1h"))
+
+	// not in the public incidents
+	config.Routes = nil
+	_, err := ProcessAlerts(config, &PrometheusAlert{
+		Version: "4",
+		Status:  "firing",
+		Alerts:  []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API"}}},
+	})
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(fake.incidents[2].Message, "occurrence"))
+}
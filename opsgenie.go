@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OPSGENIE_URL is the Opsgenie API (https://api.eu.opsgenie.com for the EU instance)
+const OPSGENIE_URL = "https://api.opsgenie.com"
+
+// OPSGENIE_TIMEOUT bounds an Opsgenie request
+const OPSGENIE_TIMEOUT = 10 * time.Second
+
+const (
+	// the max length of an Opsgenie alert message, and the max number of tags
+	OPSGENIE_MAX_MESSAGE = 130
+	OPSGENIE_MAX_TAGS    = 20
+	// LABEL_PRIORITY is the label setting the Opsgenie priority (P1 to P5) of an alert, instead of its severity
+	LABEL_PRIORITY = "priority"
+)
+
+// opsgeniePriorities are the Opsgenie priorities of the severities (P3 otherwise)
+var opsgeniePriorities = map[string]string{
+	"critical": "P1",
+	"error":    "P2",
+	"warning":  "P3",
+	"info":     "P5",
+}
+
+// cf https://docs.opsgenie.com/docs/alert-api#create-alert
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Source      string            `json:"source"`
+	Priority    string            `json:"priority"`
+}
+
+// Opsgenie creates (and closes) an Opsgenie alert for each alert posted to CachetHQ (with the api key of their route,
+// or the default one), the alert fingerprint being its alias
+type Opsgenie struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewOpsgenie creates a new Opsgenie output, apiKey being the default api key
+func NewOpsgenie(url, apiKey string) *Opsgenie {
+	return &Opsgenie{
+		url:    strings.TrimSuffix(url, "/"),
+		apiKey: apiKey,
+		client: &http.Client{Timeout: OPSGENIE_TIMEOUT},
+	}
+}
+
+// Send creates (or closes) the Opsgenie alert of each alert. The alerts without an api key are not sent
+func (o *Opsgenie) Send(config *PrometheusCachetConfig, alerts *PrometheusAlert) {
+	if o == nil {
+		return
+	}
+	for i := range alerts.Alerts {
+		alert := &alerts.Alerts[i]
+		apiKey := o.apiKey
+		if route := MatchRoute(config.Routes, alerts.Receiver, alert); route != nil && route.OpsgenieAPIKey != "" {
+			apiKey = route.OpsgenieAPIKey
+		}
+		if apiKey == "" {
+			continue
+		}
+
+		var err error
+		if alerts.Status == "firing" {
+			err = o.post(apiKey, "/v2/alerts", newOpsgenieAlert(config, alert))
+		} else {
+			err = o.post(apiKey, "/v2/alerts/"+url.PathEscape(alert.fingerprint())+"/close?identifierType=alias", map[string]string{"source": "prometheus-cachethq"})
+		}
+		if err != nil {
+			notifyError(config, "prometheus-cachethq: not able to send the Opsgenie alert of %s: %v", alert.Labels[config.LabelName], err)
+		}
+	}
+}
+
+// newOpsgenieAlert returns the Opsgenie alert of an alert, tagged with its labels (as label:value)
+func newOpsgenieAlert(config *PrometheusCachetConfig, alert *PrometheusAlertDetail) *opsgenieAlert {
+	message := alert.Annotations[ANNOTATION_SUMMARY]
+	if message == "" {
+		message = alert.Labels[config.LabelName]
+	}
+	if message == "" {
+		message = alert.fingerprint()
+	}
+	if len(message) > OPSGENIE_MAX_MESSAGE {
+		message = message[:OPSGENIE_MAX_MESSAGE]
+	}
+
+	priority := alert.Labels[LABEL_PRIORITY]
+	if len(priority) != 2 || priority[0] != 'P' || priority[1] < '1' || priority[1] > '5' {
+		if priority = opsgeniePriorities[alert.Labels[LABEL_SEVERITY]]; priority == "" {
+			priority = "P3"
+		}
+	}
+
+	tags := make([]string, 0, len(alert.Labels))
+	for label, value := range alert.Labels {
+		tags = append(tags, label+":"+value)
+	}
+	sort.Strings(tags)
+	if len(tags) > OPSGENIE_MAX_TAGS {
+		tags = tags[:OPSGENIE_MAX_TAGS]
+	}
+
+	return &opsgenieAlert{
+		Message:     message,
+		Alias:       alert.fingerprint(),
+		Description: alert.Annotations["description"],
+		Tags:        tags,
+		Details:     alert.Annotations,
+		Source:      "prometheus-cachethq",
+		Priority:    priority,
+	}
+}
+
+func (o *Opsgenie) post(apiKey, path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, o.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+apiKey)
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Opsgenie returned %d: %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
+
+// routesHaveOpsgenie returns if one of the routes has an Opsgenie api key
+func routesHaveOpsgenie(routes []*Route) bool {
+	for _, route := range routes {
+		if route.OpsgenieAPIKey != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpsgenie(t *testing.T) {
+	paths := make([]string, 0)
+	keys := make([]string, 0)
+	created := make([]opsgenieAlert, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+		keys = append(keys, r.Header.Get("Authorization"))
+		if r.URL.Path == "/v2/alerts" {
+			var alert opsgenieAlert
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&alert))
+			created = append(created, alert)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Routes: []*Route{
+			{Name: "database", Match: map[string]string{"team": "database"}, OpsgenieAPIKey: "dbkey"},
+		},
+	}
+	config.Opsgenie = NewOpsgenie(server.URL+"/", "defaultkey")
+
+	alerts := &PrometheusAlert{
+		Status: "firing",
+		Alerts: []PrometheusAlertDetail{
+			{Labels: map[string]string{"alertname": "component21", "severity": "critical"}, Annotations: map[string]string{"summary": "slow", "description": "the p99 is above 1s"}},
+			{Labels: map[string]string{"alertname": "component22", "team": "database", "priority": "P2"}, Fingerprint: "zabbix:12"},
+		},
+	}
+	config.Opsgenie.Send(config, alerts)
+	assert.Equal(t, []string{"/v2/alerts", "/v2/alerts"}, paths)
+	assert.Equal(t, []string{"GenieKey defaultkey", "GenieKey dbkey"}, keys)
+	assert.Equal(t, "slow", created[0].Message)
+	assert.Equal(t, "the p99 is above 1s", created[0].Description)
+	assert.Equal(t, alerts.Alerts[0].fingerprint(), created[0].Alias)
+	assert.Equal(t, "P1", created[0].Priority)
+	assert.Equal(t, []string{"alertname:component21", "severity:critical"}, created[0].Tags)
+	assert.Equal(t, "component22", created[1].Message)
+	assert.Equal(t, "P2", created[1].Priority)
+
+	alerts.Status = "resolved"
+	config.Opsgenie.Send(config, alerts)
+	assert.Equal(t, "/v2/alerts/zabbix:12/close?identifierType=alias", paths[3])
+
+	config.Opsgenie = nil
+	config.Opsgenie.Send(config, alerts)
+	assert.Equal(t, 4, len(paths))
+}
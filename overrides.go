@@ -0,0 +1,109 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// StatusOverrides remembers the status of the components last set by the bridge, to tell the statuses set since by a
+// CachetHQ admin (ex: a component kept at partial outage during the follow-up work): the resolved alerts do not set
+// them back to operational (cf respect_status_overrides), unless the override is older than expiry (if not 0). The
+// statuses are kept in memory: the components set before a restart of the bridge are not known to be overridden
+type StatusOverrides struct {
+	expiry time.Duration
+	// timezone of the CachetHQ dates
+	location *time.Location
+
+	mutex    sync.Mutex
+	statuses map[int]int // by component id
+}
+
+// NewStatusOverrides creates a new StatusOverrides
+func NewStatusOverrides(expiry time.Duration, location *time.Location) *StatusOverrides {
+	return &StatusOverrides{
+		expiry:   expiry,
+		location: location,
+		statuses: make(map[int]int),
+	}
+}
+
+// set records the status of a component set by the bridge (0 being unchanged)
+func (o *StatusOverrides) set(componentID, componentStatus int) {
+	if componentStatus == 0 {
+		return
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.statuses[componentID] = componentStatus
+}
+
+// overridden returns true if the status of the component (as listed) was changed since the bridge set it, less than
+// expiry ago (as of its updated_at)
+func (o *StatusOverrides) overridden(component *CachetComponent, now time.Time) bool {
+	if o == nil || component == nil {
+		return false
+	}
+
+	o.mutex.Lock()
+	status, ok := o.statuses[component.Id]
+	o.mutex.Unlock()
+	if !ok || status == component.Status {
+		return false
+	}
+	if o.expiry > 0 {
+		if updatedAt, err := ParseCachetTime(component.UpdatedAt, o.location); err == nil && now.Sub(updatedAt) >= o.expiry {
+			return false
+		}
+	}
+	return true
+}
+
+// StatusOverrideCachet is a Cachet decorator recording the status of the components set by the bridge
+type StatusOverrideCachet struct {
+	Cachet
+	overrides *StatusOverrides
+}
+
+// NewStatusOverrideCachet creates a new Cachet decorator, recording the statuses in overrides
+func NewStatusOverrideCachet(cachet Cachet, overrides *StatusOverrides) *StatusOverrideCachet {
+	return &StatusOverrideCachet{Cachet: cachet, overrides: overrides}
+}
+
+func (c *StatusOverrideCachet) SetComponentStatus(componentID, componentStatus int) error {
+	if err := c.Cachet.SetComponentStatus(componentID, componentStatus); err != nil {
+		return err
+	}
+	c.overrides.set(componentID, componentStatus)
+	return nil
+}
+
+func (c *StatusOverrideCachet) CreateIncident(componentName string, componentID, status int, componentStatus int, options IncidentOptions) (int, error) {
+	incidentId, err := c.Cachet.CreateIncident(componentName, componentID, status, componentStatus, options)
+	// (a hidden firing incident leaves the component unchanged)
+	if err == nil && !(options.Private && status != 1) {
+		c.overrides.set(componentID, componentStatus)
+	}
+	return incidentId, err
+}
+
+func (c *StatusOverrideCachet) UpdateIncident(componentName string, componentID, incidentId, status int, name, message string) error {
+	if err := c.Cachet.UpdateIncident(componentName, componentID, incidentId, status, name, message); err != nil {
+		return err
+	}
+	componentStatus := 4 // "Major Outage"
+	if status == 1 {
+		componentStatus = 1 // "Operational"
+	}
+	c.overrides.set(componentID, componentStatus)
+	return nil
+}
+
+func (c *StatusOverrideCachet) PublishIncident(incidentId, componentID, componentStatus, incidentStatus int) error {
+	if err := c.Cachet.PublishIncident(incidentId, componentID, componentStatus, incidentStatus); err != nil {
+		return err
+	}
+	c.overrides.set(componentID, componentStatus)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusOverrides(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	overrides := NewStatusOverrides(0, time.UTC)
+	config := &PrometheusCachetConfig{
+		LabelName:       "alertname",
+		Cachet:          NewStatusOverrideCachet(NewCachetImpl(ts.URL, "token", ts.Client()), overrides),
+		StatusOverrides: overrides,
+	}
+	api := PrometheusAlertDetail{Labels: map[string]string{"alertname": "API"}}
+	web := PrometheusAlertDetail{Labels: map[string]string{"alertname": "WEB"}}
+
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{api, web}})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, fake.components[0].Status)
+
+	// kept at partial outage by an admin during the follow-up work
+	fake.components[0].Status = 3
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{api, web}})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, fake.components[0].Status)
+	assert.Equal(t, 4, fake.incidents[2].Status)
+	assert.Equal(t, 1, fake.components[1].Status)
+
+	// squashed (the firing incident being still open): fixed with an update
+	config.SquashIncident = true
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{api}})
+	assert.Nil(t, err)
+	fake.components[0].Status = 2
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{api}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, fake.components[0].Status)
+	assert.Equal(t, 4, len(fake.incidents))
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, "API down", fake.incidents[0].Name)
+
+	// an override older than the expiry is not kept
+	overrides.expiry = time.Hour
+	assert.True(t, overrides.overridden(&CachetComponent{Id: 1, Status: 2, UpdatedAt: time.Now().UTC().Add(-30 * time.Minute).Format(CACHET_TIME_LAYOUT)}, time.Now()))
+	assert.False(t, overrides.overridden(&CachetComponent{Id: 1, Status: 2, UpdatedAt: time.Now().UTC().Add(-2 * time.Hour).Format(CACHET_TIME_LAYOUT)}, time.Now()))
+	// not set by the bridge
+	assert.False(t, overrides.overridden(&CachetComponent{Id: 42, Status: 2}, time.Now()))
+	var none *StatusOverrides
+	assert.False(t, none.overridden(&CachetComponent{Id: 1, Status: 2}, time.Now()))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// PAGERDUTY_URL is the PagerDuty Events API v2 endpoint
+const PAGERDUTY_URL = "https://events.pagerduty.com/v2/enqueue"
+
+// PAGERDUTY_TIMEOUT bounds the sending of a PagerDuty event
+const PAGERDUTY_TIMEOUT = 10 * time.Second
+
+// cf https://developer.pagerduty.com/docs/events-api-v2/trigger-events/
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"` // trigger or resolve
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"` // critical, error, warning or info
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// pagerDutySeverities are the PagerDuty severities (the other severity labels are sent as critical)
+var pagerDutySeverities = map[string]bool{"critical": true, "error": true, "warning": true, "info": true}
+
+// PagerDuty sends the alerts posted to CachetHQ as PagerDuty events too (with the routing key of their route,
+// or the default one), so the paging and the status page stay consistent
+type PagerDuty struct {
+	url        string
+	routingKey string
+	client     *http.Client
+}
+
+// NewPagerDuty creates a new PagerDuty output, routingKey being the default routing key
+func NewPagerDuty(url, routingKey string) *PagerDuty {
+	return &PagerDuty{
+		url:        url,
+		routingKey: routingKey,
+		client:     &http.Client{Timeout: PAGERDUTY_TIMEOUT},
+	}
+}
+
+// Send triggers (or resolves) a PagerDuty event for each alert, deduplicated by the alert fingerprint.
+// The alerts without a routing key are not sent
+func (p *PagerDuty) Send(config *PrometheusCachetConfig, alerts *PrometheusAlert) {
+	if p == nil {
+		return
+	}
+	for i := range alerts.Alerts {
+		alert := &alerts.Alerts[i]
+		routingKey := p.routingKey
+		if route := MatchRoute(config.Routes, alerts.Receiver, alert); route != nil && route.PagerDutyRoutingKey != "" {
+			routingKey = route.PagerDutyRoutingKey
+		}
+		if routingKey == "" {
+			continue
+		}
+		if err := p.send(newPagerDutyEvent(config, routingKey, alerts, alert)); err != nil {
+			notifyError(config, "prometheus-cachethq: not able to send the PagerDuty event of %s: %v", alert.Labels[config.LabelName], err)
+		}
+	}
+}
+
+func newPagerDutyEvent(config *PrometheusCachetConfig, routingKey string, alerts *PrometheusAlert, alert *PrometheusAlertDetail) *pagerDutyEvent {
+	event := &pagerDutyEvent{
+		RoutingKey:  routingKey,
+		EventAction: "resolve",
+		DedupKey:    alert.fingerprint(),
+	}
+	if alerts.Status != "firing" {
+		return event
+	}
+
+	event.EventAction = "trigger"
+	severity := alert.Labels[LABEL_SEVERITY]
+	if !pagerDutySeverities[severity] {
+		severity = "critical"
+	}
+	summary := alert.Annotations[ANNOTATION_SUMMARY]
+	if summary == "" {
+		summary = alert.Labels[config.LabelName]
+	}
+	if summary == "" {
+		summary = alert.fingerprint()
+	}
+	source := alerts.ExternalURL
+	if source == "" {
+		source = "prometheus-cachethq"
+	}
+	event.Payload = &pagerDutyPayload{
+		Summary:       summary,
+		Source:        source,
+		Severity:      severity,
+		CustomDetails: alert.Labels,
+	}
+	return event
+}
+
+func (p *PagerDuty) send(event *pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Post(p.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("PagerDuty returned %d: %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
+
+// routesHavePagerDuty returns if one of the routes has a PagerDuty routing key
+func routesHavePagerDuty(routes []*Route) bool {
+	for _, route := range routes {
+		if route.PagerDutyRoutingKey != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPagerDuty(t *testing.T) {
+	events := make([]pagerDutyEvent, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event pagerDutyEvent
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&event))
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Routes: []*Route{
+			{Name: "database", Match: map[string]string{"team": "database"}, PagerDutyRoutingKey: "dbkey"},
+		},
+	}
+	config.PagerDuty = NewPagerDuty(server.URL, "defaultkey")
+
+	alerts := &PrometheusAlert{
+		Status: "firing",
+		Alerts: []PrometheusAlertDetail{
+			{Labels: map[string]string{"alertname": "component21", "severity": "warning"}, Annotations: map[string]string{"summary": "slow"}},
+			{Labels: map[string]string{"alertname": "component22", "team": "database", "severity": "page"}},
+		},
+	}
+	config.PagerDuty.Send(config, alerts)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "defaultkey", events[0].RoutingKey)
+	assert.Equal(t, "trigger", events[0].EventAction)
+	assert.Equal(t, alerts.Alerts[0].fingerprint(), events[0].DedupKey)
+	assert.Equal(t, "slow", events[0].Payload.Summary)
+	assert.Equal(t, "warning", events[0].Payload.Severity)
+	assert.Equal(t, "dbkey", events[1].RoutingKey)
+	assert.Equal(t, "component22", events[1].Payload.Summary)
+	assert.Equal(t, "critical", events[1].Payload.Severity)
+
+	alerts.Status = "resolved"
+	config.PagerDuty.Send(config, alerts)
+	assert.Equal(t, 4, len(events))
+	assert.Equal(t, "resolve", events[2].EventAction)
+	assert.Equal(t, alerts.Alerts[0].fingerprint(), events[2].DedupKey)
+	assert.Nil(t, events[2].Payload)
+
+	// without default routing key, only the routes with a key are paged
+	config.PagerDuty = NewPagerDuty(server.URL, "")
+	config.PagerDuty.Send(config, alerts)
+	assert.Equal(t, 5, len(events))
+	assert.Equal(t, "dbkey", events[4].RoutingKey)
+
+	// disabled
+	config.PagerDuty = nil
+	config.PagerDuty.Send(config, alerts)
+	assert.Equal(t, 5, len(events))
+}
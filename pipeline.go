@@ -0,0 +1,839 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AlertReport is what the bridge did with one of the alerts
+type AlertReport struct {
+	Label      string   `json:"label"`
+	Components []string `json:"components"`
+	Error      string   `json:"error,omitempty"`
+	// Skipped is set when (some of) the components were not processed, after a CachetHQ error on another one
+	Skipped bool `json:"skipped,omitempty"`
+	// Silenced are the components not processed, silenced with /admin/silence
+	Silenced []string `json:"silenced,omitempty"`
+	// Filtered is set when the alert was ignored, not matching the filter of the configuration file
+	Filtered bool `json:"filtered,omitempty"`
+	// Queued is set when the alert is held until the end of the quiet hours of its route (cf QuietQueue)
+	Queued bool `json:"queued,omitempty"`
+}
+
+// ProcessReport is what the bridge did with a Prometheus payload
+type ProcessReport struct {
+	Alerts []*AlertReport `json:"alerts"`
+}
+
+// ProcessAlerts forwards the alerts received from Prometheus to CachetHQ (and to PagerDuty/Opsgenie), and records them in the history
+// It stops at the first CachetHQ error (retried as configured by config.Retry)
+func ProcessAlerts(config *PrometheusCachetConfig, alerts *PrometheusAlert) (*ProcessReport, error) {
+	config.PagerDuty.Send(config, alerts)
+	config.Opsgenie.Send(config, alerts)
+	config.Snapshot.record(alerts)
+	var report *ProcessReport
+	attempts := 0
+	err := config.Retry.Do(func() (err error) {
+		attempts++
+		report, err = processAlerts(config, alerts)
+		return err
+	})
+	config.Metrics.Retries(attempts - 1)
+	config.History.Record(time.Now(), alerts, report, err)
+	config.StoreAndForward.track(alerts, err)
+	throttleForwarding(config, err)
+	return report, config.Retry.exhausted(config, alerts, err)
+}
+
+func processAlerts(config *PrometheusCachetConfig, alerts *PrometheusAlert) (*ProcessReport, error) {
+	report := &ProcessReport{Alerts: make([]*AlertReport, 0)}
+
+	status := 1 // "resolved"
+	componentStatus := 1
+	if alerts.Status == "firing" {
+		status = 4
+		componentStatus = 4
+	}
+
+	list, err := config.Cachet.ListComponentsDetails()
+	if err != nil {
+		notifyError(config, "prometheus-cachethq: not able to list CachetHQ components: %v", err)
+		return report, err
+	}
+
+	// the component groups are only needed for group-wide alerts
+	var groups map[string]int
+	for _, alert := range alerts.Alerts {
+		if config.GroupLabel != "" && alert.Labels[config.GroupLabel] != "" {
+			if groups, err = config.Cachet.ListComponentGroups(); err != nil {
+				notifyError(config, "prometheus-cachethq: not able to list CachetHQ component groups: %v", err)
+				return report, err
+			}
+			break
+		}
+	}
+
+	batch := newStatusBatch(list)
+
+	// prometheus can send 2 times the same alerts info in one call
+	alreadyFired := make(map[int]int)
+	work := make([]*componentWork, 0)
+	for i := range alerts.Alerts {
+		alert := &alerts.Alerts[i]
+		alertReport := &AlertReport{
+			Label:      alert.Labels[config.LabelName],
+			Components: make([]string, 0),
+		}
+		report.Alerts = append(report.Alerts, alertReport)
+
+		if !config.Filter.matches(alerts.Receiver, alert) {
+			alertReport.Filtered = true
+			continue
+		}
+		if config.QuietQueue.hold(alerts, alert, status, time.Now()) {
+			alertReport.Queued = true
+			continue
+		}
+		components := MatchComponents(config, list, groups, alert)
+
+		if len(components) == 0 {
+			notifyError(config, "prometheus-cachethq: no CachetHQ component found for %s=%s", config.LabelName, alert.Labels[config.LabelName])
+		}
+
+		components, silenced := config.Silences.filter(config, batch, components, time.Now())
+		if len(silenced) > 0 {
+			alertReport.Silenced = silenced
+		}
+		for _, component := range components {
+			// the components one by one (i.e. not the group name)
+			alertReport.Components = append(alertReport.Components, batch.names(component)...)
+		}
+
+		// fire something
+		for _, component := range components {
+			if alreadyFired[component.ID] == 0 {
+				for _, id := range component.IDs() {
+					alreadyFired[id] = 1
+				}
+
+				work = append(work, &componentWork{alert: alert, component: component, report: alertReport})
+			} else if status != 1 {
+				// not sent twice, but the component is still kept down by this alert
+				config.FiringAlerts.fire(component.IDs(), alert.fingerprint())
+			}
+		}
+	}
+
+	if err := submitComponentAlerts(config, alerts, work, batch, status, componentStatus); err != nil {
+		// the status of the components already processed is still sent
+		batch.flush(config)
+		return report, err
+	}
+	return report, batch.flush(config)
+}
+
+type componentWork struct {
+	alert     *PrometheusAlertDetail
+	component ImpactedComponent
+	report    *AlertReport
+	done      bool
+}
+
+// submitComponentAlerts processes the impacted components with config.Concurrency workers.
+// It stops at the first CachetHQ error (the remaining components are not processed)
+func submitComponentAlerts(config *PrometheusCachetConfig, alerts *PrometheusAlert, work []*componentWork, batch *statusBatch, status, componentStatus int) error {
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mutex sync.Mutex
+	var firstErr error
+	failed := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return firstErr != nil
+	}
+
+	items := make(chan *componentWork)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				if failed() {
+					continue
+				}
+
+				unlock := config.ComponentLocks.Lock(item.component.IDs())
+				err := submitComponentAlert(config, alerts, item.alert, item.component, batch, status, componentStatus)
+				unlock()
+				item.done = true
+
+				if err != nil {
+					mutex.Lock()
+					item.report.Error = err.Error()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, item := range work {
+		if failed() {
+			break
+		}
+		items <- item
+	}
+	close(items)
+	wg.Wait()
+
+	for _, item := range work {
+		if !item.done {
+			item.report.Skipped = true
+		}
+	}
+
+	return firstErr
+}
+
+// COMPONENT_LOCK_STRIPES is the number of locks shared by the components (i.e. the memory does not grow with the components)
+const COMPONENT_LOCK_STRIPES = 64
+
+// ComponentLocks serializes the CachetHQ calls for a given component, across the concurrent webhooks.
+// The components share a fixed set of locks (two components may wait for each other, but never run concurrently)
+type ComponentLocks struct {
+	stripes [COMPONENT_LOCK_STRIPES]sync.Mutex
+	// Shared (if not nil) also locks the components across the replicas of the bridge
+	Shared *StateStore
+}
+
+// NewComponentLocks creates a new ComponentLocks
+func NewComponentLocks() *ComponentLocks {
+	return &ComponentLocks{}
+}
+
+// Lock takes the lock of all the components (always in the same order, to avoid deadlocks),
+// and returns the function to release them
+func (l *ComponentLocks) Lock(componentIDs []int) func() {
+	if l == nil {
+		return func() {}
+	}
+
+	stripes := make([]int, 0, len(componentIDs))
+	for _, id := range componentIDs {
+		stripes = append(stripes, ((id%COMPONENT_LOCK_STRIPES)+COMPONENT_LOCK_STRIPES)%COMPONENT_LOCK_STRIPES)
+	}
+	sort.Ints(stripes)
+
+	locks := make([]*sync.Mutex, 0, len(stripes))
+	for i, stripe := range stripes {
+		if i > 0 && stripes[i-1] == stripe {
+			continue
+		}
+		locks = append(locks, &l.stripes[stripe])
+	}
+
+	for _, lock := range locks {
+		lock.Lock()
+	}
+	unlockShared := l.Shared.lockComponents(componentIDs)
+	return func() {
+		unlockShared()
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+// submitComponentAlert forwards the alert to CachetHQ for one of the impacted components
+func submitComponentAlert(config *PrometheusCachetConfig, alerts *PrometheusAlert, alert *PrometheusAlertDetail, component ImpactedComponent, batch *statusBatch, status, componentStatus int) error {
+	// during a maintenance, the firing alerts do not change the status page (at most, a private incident is recorded)
+	maintenance := status != 1 && config.Maintenance.Enabled()
+	if maintenance && !config.Maintenance.Status().PrivateIncidents {
+		return nil
+	}
+	if maintenance {
+		if err := submitComponentIncident(config, alerts, alert, component.ID, component.Name, batch.names(component), status, componentStatus); err != nil {
+			return err
+		}
+		config.FiringAlerts.fire(component.IDs(), alert.fingerprint())
+		return nil
+	}
+
+	// the component is hidden while the alert is firing
+	if alert.Annotations[ANNOTATION_ACTION] == ACTION_DISABLE {
+		for _, componentID := range component.IDs() {
+			if err := config.Cachet.SetComponentEnabled(componentID, status == 1); err != nil {
+				notifyError(config, "prometheus-cachethq: not able to enable/disable the CachetHQ component %s: %v", component.Name, err)
+				return err
+			}
+		}
+		return nil
+	}
+
+	// sync the component description/link from the alert annotations (and restore them once resolved)
+	description, link := alert.annotation(config.DescriptionAnnotation), alert.annotation(config.LinkAnnotation)
+	for _, componentID := range component.IDs() {
+		if status != 1 {
+			if description == "" && link == "" {
+				continue
+			}
+			config.ComponentDetails.save(batch.component(componentID))
+			if err := config.Cachet.SetComponentDetails(componentID, description, link); err != nil {
+				// not fatal: we still want the incident to be created
+				log.Println("not able to update the CachetHQ component", component.Name, ":", err)
+				notifyError(config, "prometheus-cachethq: not able to update the CachetHQ component %s: %v", component.Name, err)
+			}
+		} else if original := config.ComponentDetails.take(componentID); original != nil {
+			if err := config.Cachet.RestoreComponentDetails(componentID, original.Description, original.Link); err != nil {
+				// we will retry on the next resolve
+				config.ComponentDetails.save(original)
+				log.Println("not able to restore the CachetHQ component", component.Name, ":", err)
+				notifyError(config, "prometheus-cachethq: not able to restore the CachetHQ component %s: %v", component.Name, err)
+			}
+		}
+	}
+
+	// a status set by a CachetHQ admin since the bridge set it is kept (cf StatusOverrides)
+	now := time.Now()
+	incidentComponentStatus := componentStatus
+	if status == 1 && config.StatusOverrides.overridden(batch.component(component.ID), now) {
+		log.Println("keeping the status of the component", component.Name, "set by a CachetHQ admin")
+		incidentComponentStatus = 0 // unchanged
+	}
+	if err := submitComponentIncident(config, alerts, alert, component.ID, component.Name, batch.names(component), status, incidentComponentStatus); err != nil {
+		return err
+	}
+
+	stillFiring := make(map[int]bool)
+	if status != 1 {
+		config.FiringAlerts.fire(component.IDs(), alert.fingerprint())
+	} else {
+		stillFiring = config.FiringAlerts.resolve(component.IDs(), alert.fingerprint())
+	}
+
+	// the incident is attached to the first component, the others follow (once the whole payload is processed)
+	for _, componentID := range component.Others {
+		// another alert still keeps the component down
+		if stillFiring[componentID] {
+			continue
+		}
+		if status == 1 && config.StatusOverrides.overridden(batch.component(componentID), now) {
+			log.Println("keeping the status of the component", componentID, "set by a CachetHQ admin")
+			continue
+		}
+		batch.set(componentID, componentStatus)
+	}
+	return nil
+}
+
+// FiringAlerts tracks the alerts firing on each component, so that a component impacted by several alerts
+// (i.e. listed by an alert, and part of a group alert) is only set back as operational once all of them are resolved
+type FiringAlerts struct {
+	mutex  sync.Mutex
+	alerts map[int]map[string]bool // component id => alert fingerprints
+	store  *StateStore             // nil if not persisted
+}
+
+// NewFiringAlerts creates a new FiringAlerts
+func NewFiringAlerts() *FiringAlerts {
+	return &FiringAlerts{alerts: make(map[int]map[string]bool)}
+}
+
+// Restore loads the firing alerts saved in the store, and saves the next changes in it
+func (f *FiringAlerts) Restore(store *StateStore) error {
+	alerts, err := store.firing()
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.alerts = alerts
+	f.store = store
+	return nil
+}
+
+// fire records an alert firing on components
+func (f *FiringAlerts) fire(componentIDs []int, fingerprint string) {
+	if f == nil {
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, componentID := range componentIDs {
+		if f.alerts[componentID] == nil {
+			f.alerts[componentID] = make(map[string]bool)
+		}
+		if !f.alerts[componentID][fingerprint] {
+			f.store.saveFiring(componentID, fingerprint)
+		}
+		f.alerts[componentID][fingerprint] = true
+	}
+}
+
+// firing returns true if the alert is firing on the component
+func (f *FiringAlerts) firing(componentID int, fingerprint string) bool {
+	if f == nil {
+		return false
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.store.shared() {
+		// maybe fired on another replica
+		if firing, err := f.store.isFiring(componentID, fingerprint); err == nil {
+			return firing
+		}
+	}
+	return f.alerts[componentID][fingerprint]
+}
+
+// resolve forgets a resolved alert, and returns the components still impacted by other firing alerts
+func (f *FiringAlerts) resolve(componentIDs []int, fingerprint string) map[int]bool {
+	stillFiring := make(map[int]bool)
+	if f == nil {
+		return stillFiring
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, componentID := range componentIDs {
+		// with a shared state, maybe fired on another replica
+		if f.alerts[componentID][fingerprint] || f.store.shared() {
+			f.store.deleteFiring(componentID, fingerprint)
+		}
+		delete(f.alerts[componentID], fingerprint)
+		if f.store.shared() {
+			if count, err := f.store.firingCount(componentID); err == nil {
+				if count > 0 {
+					stillFiring[componentID] = true
+				}
+				continue
+			}
+		}
+		if len(f.alerts[componentID]) > 0 {
+			stillFiring[componentID] = true
+		} else {
+			delete(f.alerts, componentID)
+		}
+	}
+	return stillFiring
+}
+
+// ComponentDetails keeps the original description/link of the components changed by the firing alerts,
+// to restore them once the alerts are resolved
+type ComponentDetails struct {
+	mutex     sync.Mutex
+	originals map[int]*CachetComponent
+}
+
+// NewComponentDetails creates a new ComponentDetails
+func NewComponentDetails() *ComponentDetails {
+	return &ComponentDetails{originals: make(map[int]*CachetComponent)}
+}
+
+// save keeps the details of a component, unless they were already changed by another alert
+func (d *ComponentDetails) save(component *CachetComponent) {
+	if d == nil || component == nil {
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.originals[component.Id]; !ok {
+		d.originals[component.Id] = component
+	}
+}
+
+// take returns (and forgets) the original details of a component (nil if they were not changed)
+func (d *ComponentDetails) take(componentID int) *CachetComponent {
+	if d == nil {
+		return nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	original := d.originals[componentID]
+	delete(d.originals, componentID)
+	return original
+}
+
+// statusBatch coalesces the component status updates of a payload: each component is updated once,
+// and only if its status changes
+type statusBatch struct {
+	mutex   sync.Mutex
+	listed  map[int]*CachetComponent // the components, as listed at the start of the payload
+	current map[int]int
+	pending map[int]int
+	order   []int
+}
+
+func newStatusBatch(components []*CachetComponent) *statusBatch {
+	b := &statusBatch{
+		listed:  make(map[int]*CachetComponent),
+		current: make(map[int]int),
+		pending: make(map[int]int),
+		order:   make([]int, 0),
+	}
+	for _, component := range components {
+		b.listed[component.Id] = component
+		b.current[component.Id] = component.Status
+	}
+	return b
+}
+
+// names returns the name of each component impacted (i.e. not joined, for the components of a list or of a group)
+func (b *statusBatch) names(component ImpactedComponent) []string {
+	names := make([]string, 0)
+	for _, componentID := range component.IDs() {
+		if listed := b.component(componentID); listed != nil {
+			names = append(names, listed.Name)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, component.Name)
+	}
+	return names
+}
+
+// component returns a component as listed at the start of the payload (nil if unknown)
+func (b *statusBatch) component(componentID int) *CachetComponent {
+	return b.listed[componentID]
+}
+
+func (b *statusBatch) set(componentID, componentStatus int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.pending[componentID]; !ok {
+		b.order = append(b.order, componentID)
+	}
+	b.pending[componentID] = componentStatus
+}
+
+// flush sends the status updates to CachetHQ, and stops at the first error
+func (b *statusBatch) flush(config *PrometheusCachetConfig) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, componentID := range b.order {
+		componentStatus := b.pending[componentID]
+		if b.current[componentID] == componentStatus {
+			continue
+		}
+		if err := config.Cachet.SetComponentStatus(componentID, componentStatus); err != nil {
+			notifyError(config, "prometheus-cachethq: not able to update the status of the CachetHQ component %d: %v", componentID, err)
+			return err
+		}
+		b.current[componentID] = componentStatus
+	}
+	b.order = b.order[:0]
+	b.pending = make(map[int]int)
+	return nil
+}
+
+// incidentAction keeps track of what the bridge did with an incident (metrics, events and alert volume)
+func incidentAction(config *PrometheusCachetConfig, componentNames []string, incidentID int, severity, action string) {
+	config.Metrics.IncidentAction(componentNames, severity, action)
+	if action == METRIC_INCIDENT_CREATED {
+		config.AlertVolume.Created()
+	}
+	config.Events.Publish(&Event{Type: EVENT_INCIDENT, Components: componentNames, IncidentID: incidentID, Action: action})
+}
+
+// INCIDENT_SEARCH_MARGIN is how long before the alert start an incident of the alert is searched (for a clock skew)
+const INCIDENT_SEARCH_MARGIN = 1 * time.Hour
+
+// findAlertIncident returns the incident previously created for the alert (nil if none), among the incidents of the filter
+func findAlertIncident(config *PrometheusCachetConfig, alert *PrometheusAlertDetail, filter IncidentFilter) (*CachetIncident, error) {
+	// the incident saved in the state, if still there (and not edited by hand), saves the search
+	if incidentID := config.State.incident(filter.ComponentID, alert.fingerprint()); incidentID > 0 {
+		if incident, err := config.Cachet.ReadIncident(incidentID); err == nil && incident.ComponentId == filter.ComponentID {
+			if found := FindIncident([]*CachetIncident{incident}, alert.fingerprint()); found != nil {
+				return found, nil
+			}
+		}
+	}
+	incidents, err := config.Cachet.SearchIncidents(filter)
+	if err != nil {
+		return nil, err
+	}
+	return FindIncident(incidents, alert.fingerprint()), nil
+}
+
+// resolvedSince returns the date since which the incident of a resolved alert is searched (zero, i.e. all the incidents, if unknown)
+func resolvedSince(alert *PrometheusAlertDetail) time.Time {
+	startsAt, err := time.Parse(time.RFC3339, alert.StartAt)
+	if err != nil || startsAt.Year() <= 1 {
+		return time.Time{}
+	}
+	return startsAt.Add(-INCIDENT_SEARCH_MARGIN)
+}
+
+// submitComponentIncident creates (or resolves) the CachetHQ incident of a component
+// (componentNames being the name of each component of the incident, for the metrics)
+func submitComponentIncident(config *PrometheusCachetConfig, alerts *PrometheusAlert, alert *PrometheusAlertDetail, componentID int, componentName string, componentNames []string, status, componentStatus int) error {
+	route := MatchRoute(config.Routes, alerts.Receiver, alert)
+	severity := alert.Labels[LABEL_SEVERITY]
+
+	templates := incidentTemplates(config, route, alert)
+	options := NewIncidentOptions(config, route, alert)
+	if status != 1 {
+		options.Name, options.Message = templates.Firing.render(NewIncidentTemplateData(componentName, alert, 0), "", fmt.Sprintf("Prometheus flagged service %s as down", componentName))
+	} else {
+		options.Name, options.Message = templates.Resolved.render(NewIncidentTemplateData(componentName, alert, 0), "", fmt.Sprintf("Prometheus flagged service %s as recovered", componentName))
+	}
+	// the quiet hours of the route only let hidden incidents through (published at their end, if deferred)
+	now := time.Now()
+	if status != 1 && route != nil && route.QuietHours.hidden(now) {
+		options.Private = true
+	}
+	// the subscribers were already emailed about the component, recovered moments ago
+	if status != 1 && options.Notify && config.NotifyCooldown.cooling(componentID, now) {
+		log.Println("not notifying the subscribers of", componentName, "within the cooldown of its last incident")
+		options.Notify = false
+	}
+
+	hook := &HookEvent{Component: componentName, ComponentID: componentID, Name: options.Name, Message: options.Message, Alert: alert}
+
+	if status != 1 {
+		config.Probe.forget(componentID, alert)
+	} else if !config.SquashIncident && !config.Probe.confirm(alerts, alert, componentID, componentName) {
+		// the health url of the component still fails (cf ResolveProbe)
+		return nil
+	}
+
+	// we dont 'squash' so let's create a new incident
+	if !config.SquashIncident {
+		incidentID, err := createIncident(config, alerts, hook, status, componentStatus, options)
+		if err != nil || incidentID == 0 {
+			if status == 1 {
+				config.Escalator.Forget(componentID)
+			}
+			return err
+		}
+		if status != 1 {
+			incidentAction(config, componentNames, incidentID, severity, METRIC_INCIDENT_CREATED)
+			config.Escalator.Track(componentID, incidentID, componentName, componentNames, route, alert)
+			config.QuietQueue.deferIncident(route, incidentID, componentID, componentStatus, now)
+			return nil
+		}
+		incidentAction(config, componentNames, incidentID, severity, METRIC_INCIDENT_RESOLVED)
+		config.Escalator.Forget(componentID)
+		config.NotifyCooldown.resolve(componentID, now)
+		hook.IncidentID = incidentID
+		return config.Hooks.afterResolve(config, hook)
+	}
+
+	// the open incident previously created for (or joined by) this alert
+	open, err := config.Cachet.SearchIncidents(IncidentFilter{ComponentID: componentID, Open: true})
+	var incident *CachetIncident
+	if err == nil {
+		incident = FindIncident(open, alert.fingerprint())
+	}
+	if err == nil && incident == nil && status == 1 {
+		// or already fixed: an alert can be resolved several times
+		incident, err = findAlertIncident(config, alert, IncidentFilter{ComponentID: componentID, Since: resolvedSince(alert)})
+	}
+	if err != nil {
+		notifyError(config, "prometheus-cachethq: not able to search CachetHQ incidents for %s: %v", componentName, err)
+		return err
+	}
+
+	// firing
+	if status != 1 {
+		// another alert of the component already opened an incident: this alert joins it
+		if joined := joinableIncident(open); incident == nil && joined != nil {
+			return joinIncident(config, templates, alert, joined, componentID, componentName, componentNames, severity)
+		}
+		// the alert fires again within the stability window of its incident
+		if incident != nil {
+			reopened, err := config.Stability.reopen(config, alert, incident, componentID, componentName, componentStatus)
+			if reopened {
+				config.Escalator.Track(componentID, incident.Id, componentName, componentNames, route, alert)
+			}
+			return err
+		}
+		// if no open incident currently, let's create a new one
+		incidentID, err := createIncident(config, alerts, hook, status, componentStatus, options)
+		if err != nil || incidentID == 0 {
+			return err
+		}
+		incidentAction(config, componentNames, incidentID, severity, METRIC_INCIDENT_CREATED)
+		config.Escalator.Track(componentID, incidentID, componentName, componentNames, route, alert)
+		config.QuietQueue.deferIncident(route, incidentID, componentID, componentStatus, now)
+		return nil
+	}
+
+	// resolved
+	// if we want to "squash" event for a given incident
+	if incident == nil {
+		config.Escalator.Forget(componentID)
+		notifyError(config, "prometheus-cachethq: no CachetHQ incident found to resolve for %s", componentName)
+		return fmt.Errorf("No incident found for component %d\n", componentID)
+	}
+
+	// the other alerts of the incident keep it open
+	for _, fingerprint := range IncidentFingerprints(incident.Message) {
+		if fingerprint != alert.fingerprint() && config.FiringAlerts.firing(componentID, fingerprint) {
+			return leaveIncident(config, alert, incident, componentName, componentNames, severity)
+		}
+	}
+	config.Escalator.Forget(componentID)
+
+	// the incident is only fixed once the alert stayed resolved for the stability window
+	if watched, err := config.Stability.watch(config, alerts, alert, incident, componentID, componentName, time.Now()); watched || err != nil {
+		return err
+	}
+	// and once the health url of the component answers (cf ResolveProbe)
+	if !config.Probe.confirm(alerts, alert, componentID, componentName) {
+		return nil
+	}
+
+	// the resolution is added to the timeline of the incident (its message, with the markers, is kept)
+	incidentID := incident.Id
+	data := NewIncidentTemplateData(componentName, alert, 0)
+	message := fmt.Sprintf("Prometheus flagged service %s as up", componentName)
+	if createdAt, err := ParseCachetTime(incident.CreatedAt, config.CachetLocation); err == nil {
+		data = NewIncidentTemplateData(componentName, alert, now.Sub(createdAt))
+		message = fmt.Sprintf("Prometheus flagged service %s as up (service was down for %s)", componentName, data.Downtime)
+	}
+	// a templated incident name is kept (unless the resolved one is templated too), else renamed "<component> up"
+	name, _ := templates.Firing.render(data, "", "")
+	name, message = templates.Resolved.render(data, name, message)
+	// edited by a human since: only fixed, with an update (its name, and its message, are kept)
+	if route.preserveEdits(config.PreserveEdits) && config.Revisions.edited(incident) {
+		log.Println("incident", incidentID, "of", componentName, "edited since the bridge wrote it, keeping its name")
+		name = incident.Name
+	}
+	// the component keeps the status set by a CachetHQ admin: the incident is only fixed with an update
+	if componentStatus == 0 {
+		if err := config.Cachet.CreateIncidentUpdate(incidentID, incidentStatuses["fixed"], message); err != nil {
+			notifyError(config, "prometheus-cachethq: not able to resolve the CachetHQ incident of %s: %v", componentName, err)
+			return err
+		}
+	} else if err := config.Cachet.UpdateIncident(componentName, componentID, incidentID, status, name, message); err != nil {
+		notifyError(config, "prometheus-cachethq: not able to resolve the CachetHQ incident of %s: %v", componentName, err)
+		return err
+	}
+	incidentAction(config, componentNames, incidentID, severity, METRIC_INCIDENT_RESOLVED)
+	config.NotifyCooldown.resolve(componentID, now)
+
+	hook.IncidentID, hook.Message = incidentID, message
+	return config.Hooks.afterResolve(config, hook)
+}
+
+// createIncident creates the CachetHQ incident of the alert, once allowed by the publish policy
+// and the before_create hook. It returns 0 if the policy prevents the creation of the incident
+// (the status of the component being still changed)
+func createIncident(config *PrometheusCachetConfig, alerts *PrometheusAlert, hook *HookEvent, status, componentStatus int, options IncidentOptions) (int, error) {
+	input := &PolicyInput{Component: hook.Component, ComponentID: hook.ComponentID, Status: "firing", Receiver: alerts.Receiver, Alert: hook.Alert}
+	if status == 1 {
+		input.Status = "resolved"
+	}
+	create, err := config.Policy.apply(config, input, &options)
+	if err != nil {
+		return 0, err
+	}
+	if !create {
+		log.Println("incident of", hook.Component, "not created, per the publish policy")
+		if err := config.Cachet.SetComponentStatus(hook.ComponentID, componentStatus); err != nil {
+			notifyError(config, "prometheus-cachethq: not able to change the status of the CachetHQ component %s: %v", hook.Component, err)
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	now := time.Now()
+	if status != 1 {
+		// the flapping context, and a silence of the alert, for the responders only
+		if options.Private {
+			notes := make([]string, 0)
+			if note := config.Occurrences.note(hook.ComponentID, now); note != "" {
+				notes = append(notes, note)
+			}
+			if link := SilenceLink(alerts.ExternalURL, hook.Alert); config.SilenceLink && link != "" {
+				notes = append(notes, link)
+			}
+			options.Footnote = strings.Join(notes, "\n\n")
+		}
+		hook.Name, hook.Message = options.Name, options.Message
+		if err := config.Hooks.beforeCreate(config, hook); err != nil {
+			return 0, err
+		}
+	}
+	incidentID, err := config.Cachet.CreateIncident(hook.Component, hook.ComponentID, status, componentStatus, options)
+	if err != nil {
+		notifyError(config, "prometheus-cachethq: not able to create a CachetHQ incident for %s: %v", hook.Component, err)
+		return 0, err
+	}
+	config.State.saveIncident(hook.ComponentID, options.Fingerprint, incidentID, now)
+	if status != 1 {
+		config.Occurrences.record(hook.ComponentID, now)
+	}
+	return incidentID, nil
+}
+
+// joinableIncident returns the latest open incident created by the bridge (nil if none), for another alert to join it
+func joinableIncident(open []*CachetIncident) *CachetIncident {
+	for _, incident := range open {
+		if IncidentFingerprint(incident.Message) != "" {
+			return incident
+		}
+	}
+	return nil
+}
+
+// joinIncident notes an additional alert of the component in its open incident, with the alert marker (to find it back on resolve)
+func joinIncident(config *PrometheusCachetConfig, templates *IncidentTemplates, alert *PrometheusAlertDetail, incident *CachetIncident, componentID int, componentName string, componentNames []string, severity string) error {
+	_, note := templates.Joined.render(NewIncidentTemplateData(componentName, alert, 0), "", fmt.Sprintf("Prometheus also flags service %s as down%s", componentName, alertDescription(alert)))
+	// the marker of the alert goes in the incident message (to find it back on resolve), the note in its timeline
+	if err := config.Cachet.SetIncidentStatus(incident.Id, incident.Status, incident.Message+IncidentMarker(alert.fingerprint())); err != nil {
+		notifyError(config, "prometheus-cachethq: not able to update the CachetHQ incident of %s: %v", componentName, err)
+		return err
+	}
+	if err := config.Cachet.CreateIncidentUpdate(incident.Id, incident.Status, note); err != nil {
+		notifyError(config, "prometheus-cachethq: not able to update the CachetHQ incident of %s: %v", componentName, err)
+		return err
+	}
+	incidentAction(config, componentNames, incident.Id, severity, METRIC_INCIDENT_UPDATED)
+	return nil
+}
+
+// leaveIncident notes the resolution of an alert in the incident it shares with other alerts still firing
+func leaveIncident(config *PrometheusCachetConfig, alert *PrometheusAlertDetail, incident *CachetIncident, componentName string, componentNames []string, severity string) error {
+	message := fmt.Sprintf("Prometheus no longer flags service %s as down%s, other alerts are still firing", componentName, alertDescription(alert))
+	if err := config.Cachet.CreateIncidentUpdate(incident.Id, incident.Status, message); err != nil {
+		notifyError(config, "prometheus-cachethq: not able to update the CachetHQ incident of %s: %v", componentName, err)
+		return err
+	}
+	incidentAction(config, componentNames, incident.Id, severity, METRIC_INCIDENT_UPDATED)
+	return nil
+}
+
+// alertDescription returns the summary annotation (or the name) of the alert, to tell the alerts of an incident apart
+func alertDescription(alert *PrometheusAlertDetail) string {
+	if summary := alert.Annotations[ANNOTATION_SUMMARY]; summary != "" {
+		return ": " + summary
+	}
+	if name := alert.Labels["alertname"]; name != "" {
+		return " (" + name + ")"
+	}
+	return ""
+}
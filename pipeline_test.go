@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessAlertsConcurrently(t *testing.T) {
+	names := make([]string, 0)
+	alerts := &PrometheusAlert{Version: "4", Status: "firing"}
+	for i := 0; i < 20; i++ {
+		names = append(names, fmt.Sprintf("component%d", i))
+		alerts.Alerts = append(alerts.Alerts, PrometheusAlertDetail{Labels: map[string]string{"alertname": fmt.Sprintf("component%d", i)}})
+	}
+	// the same component twice
+	alerts.Alerts = append(alerts.Alerts, alerts.Alerts[0])
+
+	fake := NewFakeCachet(names)
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		Concurrency:    5,
+		ComponentLocks: NewComponentLocks(),
+	}
+
+	report, err := ProcessAlerts(config, alerts)
+	assert.Nil(t, err)
+	assert.Equal(t, 21, len(report.Alerts))
+	assert.Equal(t, 20, len(fake.incidents))
+}
+
+func TestProcessAlertsStopsOnError(t *testing.T) {
+	fake := NewFakeCachet([]string{"component1", "component2"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	recorder := NewRecordingCachet(NewCachetImpl(ts.URL, "token", ts.Client()), false)
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         recorder,
+		SquashIncident: true,
+	}
+
+	// nothing to resolve: the first component fails, the second one is not processed
+	alerts := &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{
+		{Labels: map[string]string{"alertname": "component1"}},
+		{Labels: map[string]string{"alertname": "component2"}},
+	}}
+	report, err := ProcessAlerts(config, alerts)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, "", report.Alerts[0].Error)
+	assert.Equal(t, "", report.Alerts[1].Error)
+}
+
+func TestComponentLocks(t *testing.T) {
+	locks := NewComponentLocks()
+
+	unlock := locks.Lock([]int{2, 1, 2})
+
+	var wg sync.WaitGroup
+	locked := true
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		unlock := locks.Lock([]int{1})
+		assert.False(t, locked)
+		unlock()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	locked = false
+	unlock()
+	wg.Wait()
+
+	// components sharing the same lock
+	locks.Lock([]int{1, 1 + COMPONENT_LOCK_STRIPES})()
+
+	// nil locks are a no-op
+	var none *ComponentLocks
+	none.Lock([]int{1})()
+}
+
+func TestProcessAlertsBatchesStatusUpdates(t *testing.T) {
+	fake := NewFakeCachet([]string{"EU/api", "EU/web", "EU/cdn", "db"})
+	fake.components[2].Status = 4 // cdn is already down
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	recorder := NewRecordingCachet(NewCachetImpl(ts.URL, "token", ts.Client()), false)
+	config := &PrometheusCachetConfig{
+		LabelName:           "alertname",
+		Cachet:              recorder,
+		ComponentsLabel:     "cachet_components",
+		ComponentsSeparator: ",",
+		GroupLabel:          "cachet_group",
+	}
+
+	// web is impacted twice
+	alerts := &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{
+		{Labels: map[string]string{"alertname": "region", "cachet_group": "EU"}},
+		{Labels: map[string]string{"alertname": "backend", "cachet_components": "db,web"}},
+	}}
+	report, err := ProcessAlerts(config, alerts)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"api", "web", "cdn"}, report.Alerts[0].Components)
+
+	updates := 0
+	for _, action := range recorder.Actions() {
+		if strings.HasPrefix(action, "set component") {
+			updates++
+		}
+	}
+	assert.Equal(t, 1, updates)
+	assert.Contains(t, recorder.Actions(), "set component 2 status=4")
+	assert.Equal(t, 2, len(fake.incidents))
+	for _, component := range fake.components {
+		assert.Equal(t, 4, component.Status)
+	}
+}
+
+func TestRestoreComponentDetails(t *testing.T) {
+	fake := NewFakeCachet([]string{"api"})
+	fake.components[0].Description = "Public API"
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:             "alertname",
+		Cachet:                NewCachetImpl(ts.URL, "token", ts.Client()),
+		DescriptionAnnotation: "summary",
+		ComponentDetails:      NewComponentDetails(),
+	}
+
+	alert := PrometheusAlertDetail{
+		Labels:      map[string]string{"alertname": "api"},
+		Annotations: map[string]string{"summary": "API is slow", "": "not configured"},
+	}
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, "API is slow", fake.components[0].Description)
+	// no link annotation configured
+	assert.Equal(t, "", fake.components[0].Link)
+
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, "Public API", fake.components[0].Description)
+}
+
+func TestResolveKeepsTheComponentsOfOtherFiringAlerts(t *testing.T) {
+	fake := NewFakeCachet([]string{"api", "cdn"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:       "alertname",
+		ComponentsLabel: "cachet_components",
+		Cachet:          NewCachetImpl(ts.URL, "token", ts.Client()),
+		FiringAlerts:    NewFiringAlerts(),
+	}
+
+	both := PrometheusAlertDetail{Labels: map[string]string{"alertname": "edge", "cachet_components": "api,cdn"}}
+	cdn := PrometheusAlertDetail{Labels: map[string]string{"alertname": "cdn"}}
+
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{both, cdn}})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, fake.components[1].Status)
+
+	// the cdn alert is still firing
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{both}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, fake.components[0].Status)
+	assert.Equal(t, 4, fake.components[1].Status)
+}
+
+func TestSquashResolveReportsUpdateErrors(t *testing.T) {
+	fake := NewFakeCachet([]string{"api"})
+	failing := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing && r.Method != http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/v1/incidents/") {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident: true,
+	}
+	alert := PrometheusAlertDetail{Labels: map[string]string{"alertname": "api"}}
+
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+
+	failing = true
+	report, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.NotNil(t, err)
+	assert.True(t, IsTransientError(err))
+	assert.NotEqual(t, "", report.Alerts[0].Error)
+	assert.Equal(t, 2, fake.incidents[0].Status)
+}
+
+func TestSquashSearchesOpenIncidents(t *testing.T) {
+	fake := NewFakeCachet([]string{"api"})
+	queries := make([]string, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/api/v1/incidents" {
+			queries = append(queries, r.URL.RawQuery)
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident: true,
+	}
+	alert := PrometheusAlertDetail{Labels: map[string]string{"alertname": "api"}, StartAt: time.Now().UTC().Format(time.RFC3339)}
+
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fake.incidents))
+	// one search per open status
+	assert.Equal(t, 3, len(queries))
+	for _, query := range queries {
+		assert.Contains(t, query, "status=")
+	}
+
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, fake.incidents[0].Status)
+
+	// resolved again: the fixed incident is searched since the alert start
+	queries = queries[:0]
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(queries))
+	assert.Contains(t, queries[3], "since=")
+
+	// firing again: a new incident
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(fake.incidents))
+}
+
+func TestProcessAlertsFlushesTheStatusesBeforeAnError(t *testing.T) {
+	fake := NewFakeCachet([]string{"api", "cdn"})
+	created := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/v1/incidents" {
+			created++
+			if created > 1 {
+				http.Error(w, "maintenance", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "token", ts.Client()),
+	}
+	alerts := []PrometheusAlertDetail{
+		{Labels: map[string]string{"alertname": "api"}},
+		{Labels: map[string]string{"alertname": "cdn"}},
+	}
+
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: alerts})
+	assert.NotNil(t, err)
+	assert.Equal(t, 4, fake.components[0].Status)
+	assert.Equal(t, 1, fake.components[1].Status)
+}
+
+func TestSquashJoinsTheOpenIncident(t *testing.T) {
+	fake := NewFakeCachet([]string{"api"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "component",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident: true,
+		FiringAlerts:   NewFiringAlerts(),
+	}
+	latency := PrometheusAlertDetail{Labels: map[string]string{"component": "api", "alertname": "HighLatency"}, Fingerprint: "aaa"}
+	errors := PrometheusAlertDetail{Labels: map[string]string{"component": "api", "alertname": "HighErrorRate"}, Annotations: map[string]string{"summary": "5% of 500s"}, Fingerprint: "bbb"}
+
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{latency}})
+	assert.Nil(t, err)
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{errors}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, "Prometheus also flags service api as down: 5% of 500s", fake.updates[0].Message)
+	assert.Equal(t, []string{"aaa", "bbb"}, IncidentFingerprints(fake.incidents[0].Message))
+
+	// the latency alert is gone, the error one keeps the incident open
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{latency}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, fake.incidents[0].Status)
+	assert.Equal(t, 4, fake.components[0].Status)
+	assert.Equal(t, "Prometheus no longer flags service api as down (HighLatency), other alerts are still firing", fake.updates[1].Message)
+
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{errors}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, 1, fake.components[0].Status)
+}
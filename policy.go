@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// POLICY_TIMEOUT is the max duration of an evaluation of the publish policy (embedded or remote)
+const POLICY_TIMEOUT = 5 * time.Second
+
+// PolicyIncident is the incident the bridge is about to create
+type PolicyIncident struct {
+	// empty for the default texts (cf IncidentTemplates)
+	Name     string `json:"name"`
+	Message  string `json:"message"`
+	Visible  bool   `json:"visible"`
+	Stickied bool   `json:"stickied"`
+}
+
+// PolicyInput is the input document of the publish policy
+type PolicyInput struct {
+	Component   string                 `json:"component"`
+	ComponentID int                    `json:"component_id"`
+	Status      string                 `json:"status"` // firing or resolved
+	Receiver    string                 `json:"receiver"`
+	Alert       *PrometheusAlertDetail `json:"alert"`
+	Incident    PolicyIncident         `json:"incident"`
+}
+
+// PolicyDecision is the result of the publish policy, an undefined field keeping the incident as is
+type PolicyDecision struct {
+	// false to not create the incident (the status of the component is still changed)
+	Create  *bool  `json:"create"`
+	Visible *bool  `json:"visible"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// PublishPolicy is an OPA (Rego) policy deciding, for each incident the bridge is about to create, whether it is
+// created, its visibility, and its wording. The policy is either embedded (a .rego file, evaluated by the bridge), or
+// remote (queried with the OPA REST API)
+type PublishPolicy struct {
+	// ex: data.prometheus_cachethq.decision
+	query string
+	// the embedded policy
+	prepared *rego.PreparedEvalQuery
+	// the remote policy
+	url    string
+	client *http.Client
+	// a policy failing (or timing out) prevents the creation of the incidents, instead of letting them through
+	FailClosed bool
+}
+
+// NewPublishPolicy creates a new PublishPolicy, from a .rego file or from an OPA server url (ex: http://opa:8181)
+func NewPublishPolicy(file, url, query string, failClosed bool) (*PublishPolicy, error) {
+	if (file == "") == (url == "") {
+		return nil, fmt.Errorf("the publish policy is either a file (opa_policy_file) or an OPA server (opa_url)")
+	}
+	if !strings.HasPrefix(query, "data.") {
+		return nil, fmt.Errorf("the policy query must be a document of data (ex: data.prometheus_cachethq.decision)")
+	}
+	p := &PublishPolicy{query: query, FailClosed: failClosed}
+
+	if url != "" {
+		p.url = strings.TrimSuffix(url, "/") + "/v1/data/" + strings.Replace(strings.TrimPrefix(query, "data."), ".", "/", -1)
+		p.client = &http.Client{Timeout: POLICY_TIMEOUT}
+		return p, nil
+	}
+
+	module, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), POLICY_TIMEOUT)
+	defer cancel()
+	prepared, err := rego.New(rego.Query(query), rego.Module(file, string(module))).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("not able to compile the policy %s: %v", file, err)
+	}
+	p.prepared = &prepared
+	return p, nil
+}
+
+// decide evaluates the policy (an undefined decision keeping the incident as is)
+func (p *PublishPolicy) decide(input *PolicyInput) (*PolicyDecision, error) {
+	// the structs as plain json documents
+	content, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	var document interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	if p.prepared != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), POLICY_TIMEOUT)
+		defer cancel()
+		results, err := p.prepared.Eval(ctx, rego.EvalInput(document))
+		if err != nil {
+			return nil, err
+		}
+		if len(results) > 0 && len(results[0].Expressions) > 0 {
+			result = results[0].Expressions[0].Value
+		}
+	} else if result, err = p.remote(document); err != nil {
+		return nil, err
+	}
+
+	decision := &PolicyDecision{}
+	if result == nil {
+		return decision, nil
+	}
+	if content, err = json.Marshal(result); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, decision); err != nil {
+		return nil, fmt.Errorf("invalid policy decision %s: %v", content, err)
+	}
+	return decision, nil
+}
+
+// remote asks the OPA server, cf https://www.openpolicyagent.org/docs/latest/rest-api/#get-a-document-with-input
+func (p *PublishPolicy) remote(document interface{}) (interface{}, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": document})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Post(p.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OPA returned %d", resp.StatusCode)
+	}
+	var response struct {
+		Result interface{} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response.Result, nil
+}
+
+// apply evaluates the policy for the incident about to be created, and updates its options. It returns false if the
+// incident must not be created
+func (p *PublishPolicy) apply(config *PrometheusCachetConfig, input *PolicyInput, options *IncidentOptions) (bool, error) {
+	if p == nil {
+		return true, nil
+	}
+	input.Incident = PolicyIncident{Name: options.Name, Message: options.Message, Visible: !options.Private, Stickied: options.Stickied}
+
+	decision, err := p.decide(input)
+	if err != nil {
+		notifyError(config, "prometheus-cachethq: not able to evaluate the publish policy for %s: %v", input.Component, err)
+		if p.FailClosed {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if decision.Create != nil && !*decision.Create {
+		return false, nil
+	}
+	if decision.Visible != nil {
+		options.Private = !*decision.Visible
+	}
+	if decision.Name != "" {
+		options.Name = decision.Name
+	}
+	if decision.Message != "" {
+		options.Message = decision.Message
+	}
+	return true, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicy = `
+package prometheus_cachethq
+
+default decision = {}
+
+# the test components are not published
+decision = {"create": false} {
+	startswith(input.component, "test-")
+}
+
+# the security incidents stay private, with a neutral wording
+decision = {"visible": false, "name": "Service disruption"} {
+	input.alert.labels.team == "security"
+}
+`
+
+func TestPublishPolicy(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "test-API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	filename := writeConfigFile(t, testPolicy)
+	defer os.Remove(filename)
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "token", ts.Client()),
+	}
+	var err error
+	config.Policy, err = NewPublishPolicy(filename, "", "data.prometheus_cachethq.decision", false)
+	assert.Nil(t, err)
+	send := func(component string, labels map[string]string) error {
+		labels["alertname"] = component
+		_, err := ProcessAlerts(config, &PrometheusAlert{
+			Version: "4",
+			Status:  "firing",
+			Alerts:  []PrometheusAlertDetail{{Labels: labels}},
+		})
+		return err
+	}
+
+	// no incident, but the component is still down
+	assert.Nil(t, send("test-API", map[string]string{}))
+	assert.Equal(t, 0, len(fake.incidents))
+	assert.Equal(t, 4, fake.components[1].Status)
+
+	assert.Nil(t, send("API", map[string]string{"team": "security"}))
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, "Service disruption", fake.incidents[0].Name)
+	assert.Equal(t, 0, fake.incidents[0].Visible)
+
+	// not compiling
+	filename = writeConfigFile(t, "package prometheus_cachethq\n\ndecision = {")
+	defer os.Remove(filename)
+	_, err = NewPublishPolicy(filename, "", "data.prometheus_cachethq.decision", false)
+	assert.NotNil(t, err)
+}
+
+func TestRemotePublishPolicy(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	failing := false
+	opa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/data/comms/decision", r.URL.Path)
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var body struct {
+			Input *PolicyInput `json:"input"`
+		}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "API", body.Input.Component)
+		assert.Equal(t, "firing", body.Input.Status)
+		assert.True(t, body.Input.Incident.Visible)
+		w.Write([]byte(`{"result":{"message":"We are investigating an issue"}}`))
+	}))
+	defer opa.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "token", ts.Client()),
+	}
+	var err error
+	config.Policy, err = NewPublishPolicy("", opa.URL, "data.comms.decision", true)
+	assert.Nil(t, err)
+	payload := &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API"}}}}
+
+	_, err = ProcessAlerts(config, payload)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.True(t, strings.HasPrefix(fake.incidents[0].Message, "We are investigating an issue"))
+
+	// failing closed
+	failing = true
+	_, err = ProcessAlerts(config, payload)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(fake.incidents))
+
+	_, err = NewPublishPolicy("policy.rego", opa.URL, "data.comms.decision", true)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PROMETHEUS_POLL_RECEIVER is the receiver of the polled alerts (for the routes)
+const PROMETHEUS_POLL_RECEIVER = "prometheus"
+
+// PROMETHEUS_POLL_TIMEOUT bounds a poll of the Prometheus alerts
+const PROMETHEUS_POLL_TIMEOUT = 10 * time.Second
+
+// cf https://prometheus.io/docs/prometheus/latest/querying/api/#alerts
+type prometheusAlertsAnswer struct {
+	Status string `json:"status"`
+	Data   struct {
+		Alerts []struct {
+			Labels      map[string]string `json:"labels"`
+			Annotations map[string]string `json:"annotations"`
+			State       string            `json:"state"`
+			ActiveAt    string            `json:"activeAt"`
+		} `json:"alerts"`
+	} `json:"data"`
+	Error string `json:"error"`
+}
+
+// PrometheusPoller polls the alerts API of a Prometheus server (i.e. without Alertmanager), and forwards
+// the firing alerts to CachetHQ: once when they start to fire, and once when they are gone
+type PrometheusPoller struct {
+	config   *PrometheusCachetConfig
+	url      string
+	client   *http.Client
+	interval time.Duration
+
+	// the firing alerts already forwarded, by fingerprint
+	firing map[string]PrometheusAlertDetail
+}
+
+// NewPrometheusPoller creates a new PrometheusPoller of the Prometheus server at url, polled every interval
+func NewPrometheusPoller(config *PrometheusCachetConfig, url string, interval time.Duration) *PrometheusPoller {
+	return &PrometheusPoller{
+		config:   config,
+		url:      strings.TrimSuffix(url, "/"),
+		client:   &http.Client{Timeout: PROMETHEUS_POLL_TIMEOUT},
+		interval: interval,
+		firing:   make(map[string]PrometheusAlertDetail),
+	}
+}
+
+// Run polls Prometheus every interval (and right away), until stop is closed
+func (p *PrometheusPoller) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.poll(); err != nil {
+			log.Println("not able to poll the alerts of", p.url, ":", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// firingAlerts returns the alerts currently firing on Prometheus (the pending ones being ignored)
+func (p *PrometheusPoller) firingAlerts() (map[string]PrometheusAlertDetail, error) {
+	resp, err := p.client.Get(p.url + "/api/v1/alerts")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var answer prometheusAlertsAnswer
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return nil, fmt.Errorf("not a Prometheus alerts answer (http status %d): %v", resp.StatusCode, err)
+	}
+	if answer.Status != "success" {
+		return nil, fmt.Errorf("Prometheus answered %s: %s", answer.Status, answer.Error)
+	}
+
+	firing := make(map[string]PrometheusAlertDetail)
+	for _, alert := range answer.Data.Alerts {
+		if alert.State != "firing" {
+			continue
+		}
+		detail := PrometheusAlertDetail{Labels: alert.Labels, Annotations: alert.Annotations, StartAt: alert.ActiveAt}
+		firing[detail.fingerprint()] = detail
+	}
+	return firing, nil
+}
+
+// poll forwards the alerts which started to fire, and the ones resolved, since the previous poll.
+// The alerts are forwarded one by one: the ones which failed are sent again on the next poll
+func (p *PrometheusPoller) poll() error {
+	firing, err := p.firingAlerts()
+	if err != nil {
+		// nothing is resolved while Prometheus is unreachable
+		return err
+	}
+
+	var firstErr error
+	for fingerprint, alert := range firing {
+		if _, ok := p.firing[fingerprint]; ok {
+			continue
+		}
+		if err := p.forward("firing", alert); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		p.firing[fingerprint] = alert
+	}
+	for fingerprint, alert := range p.firing {
+		if _, ok := firing[fingerprint]; ok {
+			continue
+		}
+		if err := p.forward("resolved", alert); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		delete(p.firing, fingerprint)
+	}
+	return firstErr
+}
+
+// forward sends an alert to the pipeline, as an Alertmanager payload would (or queues it while the forwarding is paused)
+func (p *PrometheusPoller) forward(status string, alert PrometheusAlertDetail) error {
+	payload := &PrometheusAlert{
+		Version:     "4",
+		Status:      status,
+		Receiver:    PROMETHEUS_POLL_RECEIVER,
+		ExternalURL: p.url,
+		Alerts:      []PrometheusAlertDetail{alert},
+	}
+
+	p.config.Relabeling.apply(payload)
+	if p.config.Forwarding.enqueue(payload) {
+		return nil
+	}
+	_, err := ProcessAlerts(p.config, payload)
+	if _, limited := RateLimited(err); limited && p.config.Forwarding.enqueue(payload) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusPoller(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "web"})
+	cachet := httptest.NewServer(fake)
+	defer cachet.Close()
+
+	answer := `{"status":"success","data":{"alerts":[
+		{"labels":{"alertname":"API"},"state":"firing","activeAt":"2020-01-12T10:02:00.123+01:00"},
+		{"labels":{"alertname":"web"},"state":"pending"}]}}`
+	prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/alerts", r.URL.Path)
+		io.WriteString(w, answer)
+	}))
+	defer prometheus.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(cachet.URL, "token", cachet.Client()),
+		SquashIncident: true,
+	}
+	poller := NewPrometheusPoller(config, prometheus.URL+"/", time.Minute)
+
+	assert.Nil(t, poller.poll())
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, 4, fake.components[0].Status)
+	assert.Equal(t, 1, fake.components[1].Status)
+
+	// still firing: nothing new
+	assert.Nil(t, poller.poll())
+	assert.Equal(t, 1, len(fake.incidents))
+
+	// gone: resolved
+	answer = `{"status":"success","data":{"alerts":[]}}`
+	assert.Nil(t, poller.poll())
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, 1, fake.components[0].Status)
+
+	// nothing resolved while Prometheus fails
+	answer = `{"status":"success","data":{"alerts":[{"labels":{"alertname":"API"},"state":"firing"}]}}`
+	assert.Nil(t, poller.poll())
+	answer = `{"status":"error","error":"boom"}`
+	assert.NotNil(t, poller.poll())
+	assert.Equal(t, 1, len(poller.firing))
+}
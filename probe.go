@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PROBE_TIMEOUT bounds a request to the health url of a component
+const PROBE_TIMEOUT = 5 * time.Second
+
+// ResolveProbe checks the health url of a component (the cachet_probe_url annotation of the alert, or the probes of
+// the configuration file) before resolving its incident: while the url does not answer a 2xx or 3xx, the incident
+// is held open, even though the alert is resolved, and the resolve is retried every interval
+type ResolveProbe struct {
+	config   *PrometheusCachetConfig
+	urls     map[string]string // by component name
+	client   *http.Client
+	interval time.Duration
+
+	mutex   sync.Mutex
+	pending map[string]*PrometheusAlert // the resolves held, by component id and fingerprint (with this alert only)
+}
+
+// NewResolveProbe creates a new ResolveProbe, urls being the health urls of the components, by name
+func NewResolveProbe(config *PrometheusCachetConfig, urls map[string]string, interval time.Duration) *ResolveProbe {
+	return &ResolveProbe{
+		config:   config,
+		urls:     urls,
+		client:   &http.Client{Timeout: PROBE_TIMEOUT},
+		interval: interval,
+		pending:  make(map[string]*PrometheusAlert),
+	}
+}
+
+// Run retries the resolves held every interval, until stop is closed
+func (p *ResolveProbe) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.flush()
+		}
+	}
+}
+
+// Pending returns the number of resolves held, their probe still failing
+func (p *ResolveProbe) Pending() int {
+	if p == nil {
+		return 0
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return len(p.pending)
+}
+
+// confirm returns true if the incident of the resolved alert can be resolved: the component has no health url, or
+// the url answers. Otherwise the resolve is held, to be retried
+func (p *ResolveProbe) confirm(alerts *PrometheusAlert, alert *PrometheusAlertDetail, componentID int, componentName string) bool {
+	if p == nil {
+		return true
+	}
+	url := alert.Annotations[ANNOTATION_PROBE_URL]
+	if url == "" {
+		url = p.urls[componentName]
+	}
+	if url == "" {
+		return true
+	}
+
+	key := fmt.Sprintf("%d/%s", componentID, alert.fingerprint())
+	err := p.probe(url)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err == nil {
+		delete(p.pending, key)
+		return true
+	}
+	if _, ok := p.pending[key]; !ok {
+		log.Println("the alert of", componentName, "is resolved, but its probe still fails, holding its incident open:", err)
+	}
+	p.pending[key] = &PrometheusAlert{
+		Version:  alerts.Version,
+		GroupKey: alerts.GroupKey,
+		Status:   alerts.Status,
+		Receiver: alerts.Receiver,
+		Alerts:   []PrometheusAlertDetail{*alert},
+	}
+	return false
+}
+
+// forget drops the resolve held, the alert firing again
+func (p *ResolveProbe) forget(componentID int, alert *PrometheusAlertDetail) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.pending, fmt.Sprintf("%d/%s", componentID, alert.fingerprint()))
+}
+
+func (p *ResolveProbe) probe(url string) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// flush processes again the resolves held (confirm being called again on each of them)
+func (p *ResolveProbe) flush() {
+	p.mutex.Lock()
+	pending := make([]*PrometheusAlert, 0, len(p.pending))
+	for _, payload := range p.pending {
+		pending = append(pending, payload)
+	}
+	p.mutex.Unlock()
+
+	for _, payload := range pending {
+		if _, err := ProcessAlerts(p.config, payload); err != nil {
+			log.Println("not able to resolve the CachetHQ incident of", payload.Alerts[0].Labels[p.config.LabelName], ":", err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveProbe(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	healthy := false
+	health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer health.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		FiringAlerts:   NewFiringAlerts(),
+		SquashIncident: true,
+	}
+	config.Probe = NewResolveProbe(config, map[string]string{"API": health.URL}, 0)
+	send := func(status, component string, annotations map[string]string) {
+		_, err := ProcessAlerts(config, &PrometheusAlert{
+			Version: "4",
+			Status:  status,
+			Alerts:  []PrometheusAlertDetail{{Labels: map[string]string{"alertname": component}, Annotations: annotations}},
+		})
+		assert.Nil(t, err)
+	}
+
+	// the alert is resolved, but the probe still fails: held open
+	send("firing", "API", nil)
+	send("resolved", "API", nil)
+	assert.Equal(t, 4, fake.components[0].Status)
+	assert.Equal(t, 1, config.Probe.Pending())
+
+	config.Probe.flush()
+	assert.Equal(t, 4, fake.components[0].Status)
+
+	// the component is back
+	healthy = true
+	config.Probe.flush()
+	assert.Equal(t, 1, fake.components[0].Status)
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, 0, config.Probe.Pending())
+
+	// the url of the annotation, forgotten when the alert fires again
+	healthy = false
+	annotations := map[string]string{ANNOTATION_PROBE_URL: health.URL}
+	send("firing", "WEB", annotations)
+	send("resolved", "WEB", annotations)
+	assert.Equal(t, 1, config.Probe.Pending())
+	send("firing", "WEB", annotations)
+	assert.Equal(t, 0, config.Probe.Pending())
+	assert.Equal(t, 4, fake.components[1].Status)
+
+	// without any url, resolved right away
+	send("resolved", "WEB", nil)
+	assert.Equal(t, 1, fake.components[1].Status)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PROBLEM_CONTENT_TYPE is the content type of the error answers (cf RFC 7807)
+const PROBLEM_CONTENT_TYPE = "application/problem+json"
+
+// PROBLEM_TYPE_PREFIX prefixes the type of the problems (ex: urn:prometheus-cachethq:problem:invalid-payload)
+const PROBLEM_TYPE_PREFIX = "urn:prometheus-cachethq:problem:"
+
+// REQUEST_ID_HEADER is the header of the request id: the one of the request (if set by a proxy), or a generated one
+const REQUEST_ID_HEADER = "X-Request-Id"
+
+// the problem types, i.e. the classes of errors answered by the bridge
+const (
+	PROBLEM_UNAUTHORIZED      = "unauthorized"
+	PROBLEM_FORBIDDEN         = "forbidden"
+	PROBLEM_INVALID_PAYLOAD   = "invalid-payload"
+	PROBLEM_INVALID_PARAMETER = "invalid-parameter"
+	PROBLEM_NOT_FOUND         = "not-found"
+	PROBLEM_DISABLED          = "disabled"
+	PROBLEM_PAUSED            = "paused"
+	PROBLEM_CACHETHQ          = "cachethq-error"
+	PROBLEM_RATE_LIMITED      = "rate-limited"
+)
+
+var problemTitles = map[string]string{
+	PROBLEM_UNAUTHORIZED:      "Wrong or missing credentials",
+	PROBLEM_FORBIDDEN:         "Endpoint not allowed",
+	PROBLEM_INVALID_PAYLOAD:   "Invalid payload",
+	PROBLEM_INVALID_PARAMETER: "Invalid parameter",
+	PROBLEM_NOT_FOUND:         "Not found",
+	PROBLEM_DISABLED:          "Feature disabled",
+	PROBLEM_PAUSED:            "Forwarding paused",
+	PROBLEM_CACHETHQ:          "CachetHQ error",
+	PROBLEM_RATE_LIMITED:      "Rate limit exceeded",
+}
+
+// Problem is an error answer, as an RFC 7807 problem
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance"`
+	// extension members: the request id, and the detail (as the former "error" answers)
+	RequestID string `json:"request_id"`
+	Error     string `json:"error"`
+	// the invalid fields of the payload (cf ValidationError)
+	InvalidParams []FieldError `json:"invalid_params,omitempty"`
+	// what the bridge did with the alerts of a payload, before the error
+	Alerts []*AlertReport `json:"alerts,omitempty"`
+}
+
+// NewProblem returns the problem of the request, of the given type ([unauthorized|invalid-payload|...])
+func NewProblem(c *gin.Context, status int, problemType, detail string) *Problem {
+	return &Problem{
+		Type:      PROBLEM_TYPE_PREFIX + problemType,
+		Title:     problemTitles[problemType],
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		RequestID: requestID(c),
+		Error:     detail,
+	}
+}
+
+// Send answers with the problem
+func (p *Problem) Send(c *gin.Context) {
+	body, _ := json.Marshal(p)
+	c.Data(p.Status, PROBLEM_CONTENT_TYPE, body)
+}
+
+// answerProblem answers with a problem of the given type
+func answerProblem(c *gin.Context, status int, problemType, detail string) {
+	NewProblem(c, status, problemType, detail).Send(c)
+}
+
+// answerInvalidPayload answers with an invalid-payload problem, listing the invalid fields (if known)
+func answerInvalidPayload(c *gin.Context, err error) {
+	problem := NewProblem(c, http.StatusBadRequest, PROBLEM_INVALID_PAYLOAD, err.Error())
+	if fields, ok := err.(ValidationError); ok {
+		problem.InvalidParams = fields
+	}
+	problem.Send(c)
+}
+
+// requestID returns the request id: the X-Request-Id of the request, or a generated one (sent back in the answer)
+func requestID(c *gin.Context) string {
+	id := c.GetHeader(REQUEST_ID_HEADER)
+	if id == "" {
+		random := make([]byte, 8)
+		rand.Read(random)
+		id = hex.EncodeToString(random)
+	}
+	c.Header(REQUEST_ID_HEADER, id)
+	return id
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblemAnswers(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := PrometheusCachetConfig{
+		LabelName:       "alertname",
+		PrometheusToken: "secret",
+		Cachet:          NewCachetImpl(ts.URL, "token", ts.Client()),
+	}
+	router := PrepareGinRouter(&config)
+
+	req, _ := http.NewRequest("POST", "/alert", bytes.NewBufferString(`{"version":"4","status":"pending","alerts":[]}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set(REQUEST_ID_HEADER, "req-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, PROBLEM_CONTENT_TYPE, w.Header().Get("Content-Type"))
+	assert.Equal(t, "req-42", w.Header().Get(REQUEST_ID_HEADER))
+	var problem Problem
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, "urn:prometheus-cachethq:problem:invalid-payload", problem.Type)
+	assert.Equal(t, "Invalid payload", problem.Title)
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, "invalid payload: status 'pending' is unknown (firing or resolved)", problem.Detail)
+	assert.Equal(t, []FieldError{{Field: "status", Reason: "'pending' is unknown (firing or resolved)"}}, problem.InvalidParams)
+	assert.Equal(t, problem.Detail, problem.Error)
+	assert.Equal(t, "/alert", problem.Instance)
+	assert.Equal(t, "req-42", problem.RequestID)
+
+	// without request id: a generated one
+	req, _ = http.NewRequest("POST", "/alert", bytes.NewBufferString(`{}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	problem = Problem{}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, PROBLEM_TYPE_PREFIX+PROBLEM_UNAUTHORIZED, problem.Type)
+	assert.NotEqual(t, "", problem.RequestID)
+	assert.Equal(t, problem.RequestID, w.Header().Get(REQUEST_ID_HEADER))
+}
+
+func TestAuthenticationErrors(t *testing.T) {
+	config := PrometheusCachetConfig{LabelName: "alertname", PrometheusToken: "secret"}
+	router := PrepareGinRouter(&config)
+	send := func(path, authorization string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(`{}`))
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// missing credentials
+	w := send("/alert", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, []string{`Bearer realm="prometheus-cachethq"`}, w.Header()["Www-Authenticate"])
+
+	// wrong credentials
+	w = send("/alert", "Bearer wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, []string{`Bearer realm="prometheus-cachethq", error="invalid_token"`}, w.Header()["Www-Authenticate"])
+
+	// the input adapters accept a basic authentication too
+	w = send("/inputs/zabbix", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, []string{`Basic realm="prometheus-cachethq"`, `Bearer realm="prometheus-cachethq"`}, w.Header()["Www-Authenticate"])
+
+	// the admin endpoints are never served without a prometheus_token, whatever the credentials
+	config.PrometheusToken = ""
+	w = send("/admin/pause", "Bearer secret")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, 0, len(w.Header()["Www-Authenticate"]))
+}
+
+func TestBindingFieldErrors(t *testing.T) {
+	config := PrometheusCachetConfig{LabelName: "alertname"}
+	router := PrepareGinRouter(&config)
+
+	req, _ := http.NewRequest("POST", "/test", bytes.NewBufferString(`{"labels":{}}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var problem Problem
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, []FieldError{{Field: "component", Reason: "is required"}, {Field: "status", Reason: "is required"}}, problem.InvalidParams)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustedProxies are the networks of the reverse proxies (ex: the load balancer) allowed to forward the client IP of
+// the requests with X-Forwarded-For (or X-Real-Ip), for the access logs and the client IP allowlists
+type TrustedProxies []*net.IPNet
+
+// NewTrustedProxies parses a comma separated list of CIDRs or IPs (ex: 10.0.0.0/8,192.168.1.10)
+func NewTrustedProxies(list string) (TrustedProxies, error) {
+	proxies := TrustedProxies{}
+	for _, proxy := range splitList(list) {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted_proxies: invalid IP '%s'", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted_proxies: %v", err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+func (proxies TrustedProxies) trusted(address string) bool {
+	ip := net.ParseIP(strings.TrimSpace(address))
+	if ip == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client of a request received from remoteAddr: the last forwarded address not
+// added by a trusted proxy, or remoteAddr itself if it is not a trusted proxy
+func (proxies TrustedProxies) clientIP(remoteAddr, forwardedFor, realIP string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+	if !proxies.trusted(remoteAddr) {
+		return remoteAddr
+	}
+	if forwardedFor == "" {
+		forwardedFor = realIP
+	}
+	client := remoteAddr
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		client = hop
+		if !proxies.trusted(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// TrustedProxiesMiddleware resolves the client IP of the requests with the trusted proxies (instead of trusting the
+// forwarded headers of any client): the headers are replaced by the resolved address, as read by gin's ClientIP
+func TrustedProxiesMiddleware(proxies TrustedProxies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := proxies.clientIP(c.Request.RemoteAddr, c.GetHeader("X-Forwarded-For"), c.GetHeader("X-Real-Ip"))
+		c.Request.Header.Set("X-Forwarded-For", client)
+		c.Request.Header.Del("X-Real-Ip")
+		c.Next()
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	proxies, err := NewTrustedProxies("10.0.0.0/8, 192.168.1.10")
+	assert.Nil(t, err)
+
+	// the client of the load balancer, the addresses spoofed by the client being ignored
+	assert.Equal(t, "203.0.113.7", proxies.clientIP("10.1.2.3:4242", "198.51.100.1, 203.0.113.7", ""))
+	assert.Equal(t, "203.0.113.7", proxies.clientIP("10.1.2.3:4242", "203.0.113.7, 192.168.1.10", ""))
+	assert.Equal(t, "203.0.113.7", proxies.clientIP("192.168.1.10:4242", "", "203.0.113.7"))
+	// not behind a trusted proxy: the forwarded headers are not trusted
+	assert.Equal(t, "203.0.113.7", proxies.clientIP("203.0.113.7:4242", "127.0.0.1", ""))
+	assert.Equal(t, "10.1.2.3", proxies.clientIP("10.1.2.3:4242", "", ""))
+
+	var output bytes.Buffer
+	logger, err := NewAccessLogger(ACCESS_LOG_JSON, &output, nil)
+	assert.Nil(t, err)
+	router := PrepareGinRouter(&PrometheusCachetConfig{AccessLogger: logger, TrustedProxies: proxies})
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.RemoteAddr = "203.0.113.7:4242"
+	req.Header.Set("X-Forwarded-For", "127.0.0.1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry accessLogEntry
+	assert.Nil(t, json.Unmarshal(output.Bytes(), &entry))
+	assert.Equal(t, "203.0.113.7", entry.ClientIP)
+
+	for _, invalid := range []string{"10.0.0.0/33", "localhost"} {
+		_, err := NewTrustedProxies(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
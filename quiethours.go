@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// what happens to the incidents during the quiet hours of a route
+const (
+	QUIET_HOURS_QUEUE  = "queue"  // not created, until the end of the quiet hours (if the alert is still firing)
+	QUIET_HOURS_HIDDEN = "hidden" // created hidden (cf Route.Visible)
+	// created hidden, and published at the end of the quiet hours (unless resolved before)
+	QUIET_HOURS_DEFERRED = "deferred"
+)
+
+// QUIET_HOURS_INTERVAL is how often the end of the quiet hours is checked, to publish the queued alerts (and the
+// deferred incidents)
+const QUIET_HOURS_INTERVAL = 1 * time.Minute
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// QuietWindow is a weekly time window, ex: from 19:00 to 08:00 (the next day) on weekdays. Without days, the window
+// is every day, and without from/to it lasts the whole day
+type QuietWindow struct {
+	Days []string `yaml:"days"` // mon, tue, wed, thu, fri, sat, sun
+	From string   `yaml:"from"` // HH:MM
+	To   string   `yaml:"to"`   // HH:MM, the next day if not after From
+
+	days     map[time.Weekday]bool
+	from, to int // minutes since midnight
+}
+
+func (w *QuietWindow) validate() error {
+	w.days = make(map[time.Weekday]bool)
+	for _, day := range w.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("unknown day '%s'", day)
+		}
+		w.days[weekday] = true
+	}
+	if len(w.Days) == 0 {
+		for _, weekday := range weekdays {
+			w.days[weekday] = true
+		}
+	}
+
+	var err error
+	if w.from, err = parseClock(w.From); err != nil {
+		return err
+	}
+	w.to, err = parseClock(w.To)
+	return err
+}
+
+// parseClock returns the minutes since midnight of HH:MM (0 if empty)
+func parseClock(clock string) (int, error) {
+	if clock == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s' (HH:MM)", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w *QuietWindow) contains(t time.Time) bool {
+	minutes := t.Hour()*60 + t.Minute()
+	if w.from < w.to {
+		return w.days[t.Weekday()] && minutes >= w.from && minutes < w.to
+	}
+	// across midnight: the end of the window is the next day
+	return (w.days[t.Weekday()] && minutes >= w.from) || (w.days[(t.Weekday()+6)%7] && minutes < w.to)
+}
+
+// QuietHours are the time windows of a route during which its incidents are not published, ex: for a product
+// whose SLA only covers the business hours
+//
+//	quiet_hours:
+//	  timezone: Europe/Paris
+//	  action: queue
+//	  windows:
+//	    - days: [mon, tue, wed, thu, fri]
+//	      from: "19:00"
+//	      to: "08:00"
+//	    - days: [sat, sun]
+type QuietHours struct {
+	// the timezone of the windows (the local one if empty)
+	Timezone string         `yaml:"timezone"`
+	Windows  []*QuietWindow `yaml:"windows"`
+	// queue (the default), hidden or deferred
+	Action string `yaml:"action"`
+	// the incident status set when a deferred incident is published, ex: identified (unchanged if empty)
+	Status string `yaml:"status"`
+
+	location       *time.Location
+	incidentStatus int
+}
+
+func (q *QuietHours) validate() error {
+	if q == nil {
+		return nil
+	}
+	switch q.Action {
+	case "":
+		q.Action = QUIET_HOURS_QUEUE
+	case QUIET_HOURS_QUEUE, QUIET_HOURS_HIDDEN, QUIET_HOURS_DEFERRED:
+	default:
+		return fmt.Errorf("unknown quiet hours action '%s' (queue, hidden or deferred)", q.Action)
+	}
+	if q.Status != "" {
+		status, ok := incidentStatuses[q.Status]
+		if !ok || status == 0 {
+			return fmt.Errorf("unknown incident status '%s'", q.Status)
+		}
+		q.incidentStatus = status
+	}
+
+	var err error
+	if q.location, err = time.LoadLocation(q.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone '%s'", q.Timezone)
+	}
+	for _, window := range q.Windows {
+		if err := window.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// active returns true during the quiet hours
+func (q *QuietHours) active(now time.Time) bool {
+	if q == nil {
+		return false
+	}
+	now = now.In(q.location)
+	for _, window := range q.Windows {
+		if window.contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// hidden returns true if the incidents are created hidden right now
+func (q *QuietHours) hidden(now time.Time) bool {
+	return q.active(now) && (q.Action == QUIET_HOURS_HIDDEN || q.Action == QUIET_HOURS_DEFERRED)
+}
+
+// deferredIncident is a hidden incident, to publish at the end of the quiet hours of its route
+type deferredIncident struct {
+	route           *Route
+	componentID     int
+	componentStatus int
+}
+
+// QuietQueue holds the firing alerts of the routes in their quiet hours (the queue action), and forwards the ones
+// still firing once the quiet hours are over. An alert resolved before is dropped, without any incident.
+// It also publishes the hidden incidents of the deferred action, once the quiet hours are over (unless resolved)
+type QuietQueue struct {
+	config   *PrometheusCachetConfig
+	interval time.Duration
+
+	mutex     sync.Mutex
+	alerts    map[string]*PrometheusAlert // by fingerprint, with this alert only
+	incidents map[int]*deferredIncident   // by incident id
+}
+
+// NewQuietQueue creates a new QuietQueue, checking every interval the end of the quiet hours
+func NewQuietQueue(config *PrometheusCachetConfig, interval time.Duration) *QuietQueue {
+	return &QuietQueue{
+		config:    config,
+		interval:  interval,
+		alerts:    make(map[string]*PrometheusAlert),
+		incidents: make(map[int]*deferredIncident),
+	}
+}
+
+// NeedsQuietQueue returns true if a route queues its alerts (or defers its incidents) during its quiet hours
+func NeedsQuietQueue(routes []*Route) bool {
+	for _, route := range routes {
+		if route.QuietHours != nil && route.QuietHours.Action != QUIET_HOURS_HIDDEN {
+			return true
+		}
+	}
+	return false
+}
+
+// Run forwards the alerts out of their quiet hours every interval, until stop is closed
+func (q *QuietQueue) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			q.flush(now)
+		}
+	}
+}
+
+// Queued returns the number of alerts waiting for the end of their quiet hours
+func (q *QuietQueue) Queued() int {
+	if q == nil {
+		return 0
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.alerts)
+}
+
+// Deferred returns the number of hidden incidents waiting for the end of their quiet hours
+func (q *QuietQueue) Deferred() int {
+	if q == nil {
+		return 0
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.incidents)
+}
+
+// deferIncident records the incident just created, if it is to be published at the end of the quiet hours of its route
+func (q *QuietQueue) deferIncident(route *Route, incidentID, componentID, componentStatus int, now time.Time) {
+	if q == nil || route == nil || !route.QuietHours.active(now) || route.QuietHours.Action != QUIET_HOURS_DEFERRED {
+		return
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.incidents[incidentID] = &deferredIncident{route: route, componentID: componentID, componentStatus: componentStatus}
+}
+
+// hold returns true if the alert is held by the quiet hours of its route: a firing alert queued, or the resolve of
+// a queued alert (dropped with it)
+func (q *QuietQueue) hold(alerts *PrometheusAlert, alert *PrometheusAlertDetail, status int, now time.Time) bool {
+	if q == nil {
+		return false
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	fingerprint := alert.fingerprint()
+	if status == 1 {
+		_, queued := q.alerts[fingerprint]
+		delete(q.alerts, fingerprint)
+		return queued
+	}
+
+	route := MatchRoute(q.config.Routes, alerts.Receiver, alert)
+	if route == nil || !route.QuietHours.active(now) || route.QuietHours.Action != QUIET_HOURS_QUEUE {
+		// forwarded right away: not twice
+		delete(q.alerts, fingerprint)
+		return false
+	}
+	q.alerts[fingerprint] = &PrometheusAlert{
+		Version:  alerts.Version,
+		GroupKey: alerts.GroupKey,
+		Status:   alerts.Status,
+		Receiver: alerts.Receiver,
+		Alerts:   []PrometheusAlertDetail{*alert},
+	}
+	return true
+}
+
+// flush forwards the alerts (and publishes the incidents) whose route is out of its quiet hours
+func (q *QuietQueue) flush(now time.Time) {
+	q.mutex.Lock()
+	due := make(map[string]*PrometheusAlert)
+	for fingerprint, payload := range q.alerts {
+		route := MatchRoute(q.config.Routes, payload.Receiver, &payload.Alerts[0])
+		if route == nil || !route.QuietHours.active(now) {
+			due[fingerprint] = payload
+			delete(q.alerts, fingerprint)
+		}
+	}
+	incidents := make(map[int]*deferredIncident)
+	for incidentID, incident := range q.incidents {
+		if !incident.route.QuietHours.active(now) {
+			incidents[incidentID] = incident
+			delete(q.incidents, incidentID)
+		}
+	}
+	q.mutex.Unlock()
+
+	for incidentID, incident := range incidents {
+		if err := q.publish(incidentID, incident); err != nil {
+			log.Println("not able to publish the deferred CachetHQ incident", incidentID, ":", err)
+			// retried on the next check
+			q.mutex.Lock()
+			q.incidents[incidentID] = incident
+			q.mutex.Unlock()
+		}
+	}
+
+	for fingerprint, payload := range due {
+		log.Println("quiet hours over, forwarding the alert", fingerprint, "of", payload.Receiver)
+		if _, err := ProcessAlerts(q.config, payload); err != nil {
+			// retried on the next check
+			q.mutex.Lock()
+			q.alerts[fingerprint] = payload
+			q.mutex.Unlock()
+		}
+	}
+}
+
+// publish makes the deferred incident visible, unless it is already fixed
+func (q *QuietQueue) publish(incidentID int, incident *deferredIncident) error {
+	current, err := q.config.Cachet.ReadIncident(incidentID)
+	if err != nil {
+		return err
+	}
+	if current.Status == incidentStatuses["fixed"] {
+		return nil
+	}
+	log.Println("quiet hours over, publishing the CachetHQ incident", incidentID)
+	return q.config.Cachet.PublishIncident(incidentID, incident.componentID, incident.componentStatus, incident.route.QuietHours.incidentStatus)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietHours(t *testing.T) {
+	quiet := &QuietHours{
+		Timezone: "UTC",
+		Windows: []*QuietWindow{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, From: "19:00", To: "08:00"},
+			{Days: []string{"sat", "sun"}},
+		},
+	}
+	assert.Nil(t, quiet.validate())
+	assert.Equal(t, QUIET_HOURS_QUEUE, quiet.Action)
+
+	// 2020-03-02 is a monday
+	for at, active := range map[string]bool{
+		"2020-03-02T07:00:00Z": false, // the previous day is a sunday (the whole day), not a weekday
+		"2020-03-02T12:00:00Z": false,
+		"2020-03-02T19:00:00Z": true,
+		"2020-03-03T07:59:00Z": true,
+		"2020-03-03T08:00:00Z": false,
+		"2020-03-07T12:00:00Z": true,  // saturday
+		"2020-03-07T07:00:00Z": true,  // the end of friday night
+		"2020-03-09T00:30:00Z": false, // the end of sunday (until midnight)
+	} {
+		now, _ := time.Parse(time.RFC3339, at)
+		assert.Equal(t, active, quiet.active(now), at)
+	}
+
+	assert.NotNil(t, (&QuietHours{Windows: []*QuietWindow{{Days: []string{"monday"}}}}).validate())
+	assert.NotNil(t, (&QuietHours{Windows: []*QuietWindow{{From: "7pm"}}}).validate())
+	assert.NotNil(t, (&QuietHours{Action: "drop"}).validate())
+	assert.NotNil(t, (&QuietHours{Timezone: "Mars/Olympus"}).validate())
+}
+
+func TestQuietQueue(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	// always quiet, until the test ends it
+	filename := writeConfigFile(t, `
+routes:
+  - name: business
+    quiet_hours:
+      windows:
+        - from: "00:00"
+          to: "00:00"
+`)
+	defer os.Remove(filename)
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		FiringAlerts:   NewFiringAlerts(),
+		Routes:         configFile.Routes,
+		SquashIncident: true,
+	}
+	assert.True(t, NeedsQuietQueue(config.Routes))
+	config.QuietQueue = NewQuietQueue(config, 0)
+	send := func(status, component string) *ProcessReport {
+		report, err := ProcessAlerts(config, &PrometheusAlert{
+			Version: "4",
+			Status:  status,
+			Alerts:  []PrometheusAlertDetail{{Labels: map[string]string{"alertname": component}}},
+		})
+		assert.Nil(t, err)
+		return report
+	}
+
+	// queued: nothing published
+	assert.True(t, send("firing", "API").Alerts[0].Queued)
+	assert.True(t, send("firing", "WEB").Alerts[0].Queued)
+	assert.Equal(t, 2, config.QuietQueue.Queued())
+	assert.Equal(t, 0, len(fake.incidents))
+
+	// resolved during the quiet hours: dropped
+	assert.True(t, send("resolved", "WEB").Alerts[0].Queued)
+	assert.Equal(t, 1, config.QuietQueue.Queued())
+
+	// still quiet
+	config.QuietQueue.flush(time.Now())
+	assert.Equal(t, 0, len(fake.incidents))
+
+	// the quiet hours are over: published
+	config.Routes[0].QuietHours.Windows = nil
+	config.QuietQueue.flush(time.Now())
+	assert.Equal(t, 0, config.QuietQueue.Queued())
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, 4, fake.components[0].Status)
+	assert.Equal(t, 1, fake.components[1].Status)
+
+	// hidden instead
+	config.Routes[0].QuietHours.Windows = []*QuietWindow{{}}
+	assert.Nil(t, config.Routes[0].QuietHours.validate())
+	config.Routes[0].QuietHours.Action = QUIET_HOURS_HIDDEN
+	assert.False(t, send("firing", "WEB").Alerts[0].Queued)
+	assert.Equal(t, 2, len(fake.incidents))
+	assert.Equal(t, 0, fake.incidents[1].Visible)
+	assert.Equal(t, 1, fake.components[1].Status)
+}
+
+func TestQuietQueueDryRun(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	filename := writeConfigFile(t, `
+routes:
+  - name: business
+    quiet_hours:
+      windows:
+        - from: "00:00"
+          to: "00:00"
+`)
+	defer os.Remove(filename)
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+
+	config := &PrometheusCachetConfig{
+		LabelName:       "alertname",
+		PrometheusToken: "promToken",
+		Cachet:          NewCachetImpl(ts.URL, "token", ts.Client()),
+		Routes:          configFile.Routes,
+	}
+	config.QuietQueue = NewQuietQueue(config, 0)
+	router := PrepareGinRouter(config)
+
+	req, _ := http.NewRequest("POST", "/test", bytes.NewBufferString(`{"component":"API","status":"firing","dry_run":true}`))
+	req.Header.Set("Authorization", "Bearer "+config.PrometheusToken)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// not queued: nothing published at the end of the quiet hours
+	assert.Equal(t, 0, config.QuietQueue.Queued())
+	config.Routes[0].QuietHours.Windows = nil
+	config.QuietQueue.flush(time.Now())
+	assert.Equal(t, 0, len(fake.incidents))
+	assert.Equal(t, 1, fake.components[0].Status)
+}
+
+func TestQuietHoursDeferred(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	quiet := &QuietHours{Action: QUIET_HOURS_DEFERRED, Status: "investigating", Windows: []*QuietWindow{{}}}
+	assert.Nil(t, quiet.validate())
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		FiringAlerts:   NewFiringAlerts(),
+		Routes:         []*Route{{Name: "business", QuietHours: quiet}},
+		SquashIncident: true,
+	}
+	assert.True(t, NeedsQuietQueue(config.Routes))
+	config.QuietQueue = NewQuietQueue(config, 0)
+	send := func(status, component string) {
+		_, err := ProcessAlerts(config, &PrometheusAlert{
+			Version: "4",
+			Status:  status,
+			Alerts:  []PrometheusAlertDetail{{Labels: map[string]string{"alertname": component}}},
+		})
+		assert.Nil(t, err)
+	}
+
+	// created hidden, the components are unchanged
+	send("firing", "API")
+	send("firing", "WEB")
+	assert.Equal(t, 2, config.QuietQueue.Deferred())
+	assert.Equal(t, 0, fake.incidents[0].Visible)
+	assert.Equal(t, 1, fake.components[0].Status)
+
+	// WEB is resolved during the quiet hours: it stays hidden
+	send("resolved", "WEB")
+
+	// the quiet hours are over: API is published
+	quiet.Windows = nil
+	config.QuietQueue.flush(time.Now())
+	assert.Equal(t, 0, config.QuietQueue.Deferred())
+	assert.Equal(t, 1, fake.incidents[0].Visible)
+	assert.Equal(t, 1, fake.incidents[0].Status)
+	assert.Equal(t, 4, fake.components[0].Status)
+	assert.Equal(t, 0, fake.incidents[1].Visible)
+	assert.Equal(t, 1, fake.components[1].Status)
+
+	assert.NotNil(t, (&QuietHours{Action: QUIET_HOURS_DEFERRED, Status: "fixing"}).validate())
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter is a token bucket: it allows rate requests per second, with bursts of burst requests
+type RateLimiter struct {
+	rate  float64
+	burst float64
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a new token bucket (full)
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// Allow takes a token if possible. It returns the remaining tokens, and how long to wait
+// for the next one (if not allowed), or for the bucket to be full (if allowed)
+func (r *RateLimiter) Allow(now time.Time) (bool, int, time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.last.IsZero() {
+		r.tokens = math.Min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	}
+	r.last = now
+
+	if r.tokens < 1 {
+		return false, 0, time.Duration((1 - r.tokens) / r.rate * float64(time.Second))
+	}
+	r.tokens--
+	return true, int(r.tokens), time.Duration((r.burst - r.tokens) / r.rate * float64(time.Second))
+}
+
+func durationToSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// RateLimitMiddleware sheds the load with a 429 (and a Retry-After header) when the limiter is exhausted.
+// The (draft) standard RateLimit-* headers are set on every response
+func RateLimitMiddleware(limiter *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, remaining, wait := limiter.Allow(time.Now())
+
+		c.Header("RateLimit-Limit", strconv.Itoa(int(limiter.burst)))
+		c.Header("RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("RateLimit-Reset", durationToSeconds(wait))
+
+		if !allowed {
+			c.Header("Retry-After", durationToSeconds(wait))
+			answerProblem(c, http.StatusTooManyRequests, PROBLEM_RATE_LIMITED, "rate limit exceeded")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
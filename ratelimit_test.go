@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(1, 2)
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	allowed, remaining, _ := limiter.Allow(now)
+	assert.True(t, allowed)
+	assert.Equal(t, 1, remaining)
+
+	allowed, remaining, _ = limiter.Allow(now)
+	assert.True(t, allowed)
+	assert.Equal(t, 0, remaining)
+
+	allowed, _, wait := limiter.Allow(now)
+	assert.False(t, allowed)
+	assert.Equal(t, time.Second, wait)
+
+	// the bucket refills
+	allowed, _, _ = limiter.Allow(now.Add(time.Second))
+	assert.True(t, allowed)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	config := PrometheusCachetConfig{
+		LabelName:       "alertname",
+		PrometheusToken: "promToken",
+		RateLimiter:     NewRateLimiter(0.1, 1),
+	}
+
+	router := PrepareGinRouter(&config)
+
+	// the first one goes through (and fails on the Authorization header)
+	req, _ := http.NewRequest("POST", "/alert", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	// the second one is throttled
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RecordingCachet is a Cachet decorator keeping track of all the write calls.
+// In DryRun mode, the write calls are only recorded, and not sent to CachetHQ
+type RecordingCachet struct {
+	Cachet
+	DryRun bool
+
+	mutex   sync.Mutex
+	actions []string
+}
+
+// NewRecordingCachet creates a new Cachet decorator
+func NewRecordingCachet(cachet Cachet, dryRun bool) *RecordingCachet {
+	return &RecordingCachet{
+		Cachet:  cachet,
+		DryRun:  dryRun,
+		actions: make([]string, 0),
+	}
+}
+
+// Actions returns the recorded write calls
+func (r *RecordingCachet) Actions() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]string{}, r.actions...)
+}
+
+func (r *RecordingCachet) record(format string, args ...interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.actions = append(r.actions, fmt.Sprintf(format, args...))
+}
+
+func (r *RecordingCachet) SetComponentEnabled(componentID int, enabled bool) error {
+	r.record("set component %d enabled=%v", componentID, enabled)
+	if r.DryRun {
+		return nil
+	}
+	return r.Cachet.SetComponentEnabled(componentID, enabled)
+}
+
+func (r *RecordingCachet) SetComponentStatus(componentID, componentStatus int) error {
+	r.record("set component %d status=%d", componentID, componentStatus)
+	if r.DryRun {
+		return nil
+	}
+	return r.Cachet.SetComponentStatus(componentID, componentStatus)
+}
+
+func (r *RecordingCachet) SetComponentDetails(componentID int, description, link string) error {
+	r.record("set component %d description=%q link=%q", componentID, description, link)
+	if r.DryRun {
+		return nil
+	}
+	return r.Cachet.SetComponentDetails(componentID, description, link)
+}
+
+func (r *RecordingCachet) CreateComponent(name, description string, groupID int) (int, error) {
+	r.record("create component %s group=%d", name, groupID)
+	if r.DryRun {
+		return 0, nil
+	}
+	return r.Cachet.CreateComponent(name, description, groupID)
+}
+
+func (r *RecordingCachet) CreateComponentGroup(name string) (int, error) {
+	r.record("create component group %s", name)
+	if r.DryRun {
+		return 0, nil
+	}
+	return r.Cachet.CreateComponentGroup(name)
+}
+
+func (r *RecordingCachet) SetComponentGroup(componentID, groupID int) error {
+	r.record("set component %d group=%d", componentID, groupID)
+	if r.DryRun {
+		return nil
+	}
+	return r.Cachet.SetComponentGroup(componentID, groupID)
+}
+
+func (r *RecordingCachet) RestoreComponentDetails(componentID int, description, link string) error {
+	r.record("restore component %d description=%q link=%q", componentID, description, link)
+	if r.DryRun {
+		return nil
+	}
+	return r.Cachet.RestoreComponentDetails(componentID, description, link)
+}
+
+func (r *RecordingCachet) CreateIncident(componentName string, componentID, status int, componentStatus int, options IncidentOptions) (int, error) {
+	r.record("create incident for component %s (%d) status=%d component_status=%d", componentName, componentID, status, componentStatus)
+	if r.DryRun {
+		return 0, nil
+	}
+	return r.Cachet.CreateIncident(componentName, componentID, status, componentStatus, options)
+}
+
+func (r *RecordingCachet) UpdateIncident(componentName string, componentID, incidentId, status int, name, message string) error {
+	r.record("update incident %d for component %s (%d) status=%d message=%q", incidentId, componentName, componentID, status, message)
+	if r.DryRun {
+		return nil
+	}
+	return r.Cachet.UpdateIncident(componentName, componentID, incidentId, status, name, message)
+}
+
+func (r *RecordingCachet) SetIncidentStatus(incidentId, incidentStatus int, message string) error {
+	r.record("set incident %d status=%d message=%q", incidentId, incidentStatus, message)
+	if r.DryRun {
+		return nil
+	}
+	return r.Cachet.SetIncidentStatus(incidentId, incidentStatus, message)
+}
+
+func (r *RecordingCachet) CreateIncidentUpdate(incidentId, incidentStatus int, message string) error {
+	r.record("add update to incident %d status=%d message=%q", incidentId, incidentStatus, message)
+	if r.DryRun {
+		return nil
+	}
+	return r.Cachet.CreateIncidentUpdate(incidentId, incidentStatus, message)
+}
+
+func (r *RecordingCachet) DeleteIncident(incidentId int) error {
+	r.record("delete incident %d", incidentId)
+	if r.DryRun {
+		return nil
+	}
+	return r.Cachet.DeleteIncident(incidentId)
+}
+
+func (r *RecordingCachet) PublishIncident(incidentId, componentID, componentStatus, incidentStatus int) error {
+	r.record("publish incident %d for component %d component_status=%d status=%d", incidentId, componentID, componentStatus, incidentStatus)
+	if r.DryRun {
+		return nil
+	}
+	return r.Cachet.PublishIncident(incidentId, componentID, componentStatus, incidentStatus)
+}
+
+func (r *RecordingCachet) AddMetricPoint(metricID int, value float64, timestamp time.Time) error {
+	r.record("add metric %d point %v at %s", metricID, value, timestamp.Format(time.RFC3339))
+	if r.DryRun {
+		return nil
+	}
+	return r.Cachet.AddMetricPoint(metricID, value, timestamp)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordingCachet(t *testing.T) {
+	fake := NewFakeCachet([]string{"api"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	recorder := NewRecordingCachet(NewCachetImpl(ts.URL, "token", ts.Client()), false)
+	incidentID, err := recorder.CreateIncident("api", 1, 4, 4, IncidentOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, incidentID)
+	assert.Nil(t, recorder.SetComponentStatus(1, 1))
+
+	// the reads are not recorded
+	_, err = recorder.ListComponentsDetails()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"create incident for component api (1) status=4 component_status=4",
+		"set component 1 status=1",
+	}, recorder.Actions())
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, 1, fake.components[0].Status)
+}
+
+func TestRecordingCachetDryRun(t *testing.T) {
+	fake := NewFakeCachet([]string{"api"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	recorder := NewRecordingCachet(NewCachetImpl(ts.URL, "token", ts.Client()), true)
+	_, err := recorder.CreateIncident("api", 1, 4, 4, IncidentOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, recorder.SetComponentEnabled(1, false))
+	assert.Nil(t, recorder.UpdateIncident("api", 1, 12, 1, "", "api is up"))
+
+	assert.Equal(t, []string{
+		"create incident for component api (1) status=4 component_status=4",
+		"set component 1 enabled=false",
+		`update incident 12 for component api (1) status=1 message="api is up"`,
+	}, recorder.Actions())
+
+	// nothing sent to CachetHQ
+	assert.Equal(t, 0, len(fake.incidents))
+	assert.True(t, fake.components[0].Enabled)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	RELABEL_REPLACE   = "replace"
+	RELABEL_KEEP      = "keep"
+	RELABEL_DROP      = "drop"
+	RELABEL_LABELMAP  = "labelmap"
+	RELABEL_LABELDROP = "labeldrop"
+	RELABEL_LABELKEEP = "labelkeep"
+
+	// RELABEL_ANNOTATION_PREFIX exposes the annotations of the alerts to the relabeling, as pseudo labels
+	// (ex: __annotation_summary)
+	RELABEL_ANNOTATION_PREFIX = "__annotation_"
+)
+
+// RelabelConfig is a Prometheus-style relabeling step of the labels (and annotations) of the incoming alerts,
+// cf https://prometheus.io/docs/prometheus/latest/configuration/configuration/#relabel_config
+//
+//	relabel_configs:
+//	  - source_labels: [service, region]
+//	    separator: "-"
+//	    target_label: component
+//	  - source_labels: [env]
+//	    regex: dev|test
+//	    action: drop
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels"`
+	// default ;
+	Separator string `yaml:"separator"`
+	// anchored, default (.*)
+	Regex       string `yaml:"regex"`
+	TargetLabel string `yaml:"target_label"`
+	// default $1
+	Replacement string `yaml:"replacement"`
+	// replace (default), keep, drop, labelmap, labeldrop or labelkeep
+	Action string `yaml:"action"`
+
+	regex *regexp.Regexp
+}
+
+// Relabeling are the relabeling steps, applied in order to each alert received (before the filter and the
+// component mapping)
+type Relabeling []*RelabelConfig
+
+func (r *RelabelConfig) validate() error {
+	if r.Separator == "" {
+		r.Separator = ";"
+	}
+	if r.Regex == "" {
+		r.Regex = "(.*)"
+	}
+	if r.Replacement == "" {
+		r.Replacement = "$1"
+	}
+	if r.Action == "" {
+		r.Action = RELABEL_REPLACE
+	}
+
+	var err error
+	if r.regex, err = regexp.Compile("^(?:" + r.Regex + ")$"); err != nil {
+		return fmt.Errorf("regex: %v", err)
+	}
+	switch r.Action {
+	case RELABEL_REPLACE:
+		if r.TargetLabel == "" {
+			return fmt.Errorf("the replace action needs a target_label")
+		}
+	case RELABEL_KEEP, RELABEL_DROP:
+		if len(r.SourceLabels) == 0 {
+			return fmt.Errorf("the %s action needs source_labels", r.Action)
+		}
+	case RELABEL_LABELMAP, RELABEL_LABELDROP, RELABEL_LABELKEEP:
+	default:
+		return fmt.Errorf("unknown action '%s'", r.Action)
+	}
+	return nil
+}
+
+func (r Relabeling) validate() error {
+	for i, step := range r {
+		if err := step.validate(); err != nil {
+			return fmt.Errorf("%d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// apply relabels the alerts of the payload, the ones dropped (by a keep or a drop action) being removed from it
+func (r Relabeling) apply(alerts *PrometheusAlert) {
+	if len(r) == 0 {
+		return
+	}
+	kept := alerts.Alerts[:0]
+	for _, alert := range alerts.Alerts {
+		if r.relabel(&alert) {
+			kept = append(kept, alert)
+		}
+	}
+	alerts.Alerts = kept
+}
+
+// relabel applies the steps to the alert, and returns false if the alert is dropped
+func (r Relabeling) relabel(alert *PrometheusAlertDetail) bool {
+	labels := make(map[string]string, len(alert.Labels)+len(alert.Annotations))
+	for name, value := range alert.Labels {
+		labels[name] = value
+	}
+	for name, value := range alert.Annotations {
+		labels[RELABEL_ANNOTATION_PREFIX+name] = value
+	}
+
+	for _, step := range r {
+		if !step.relabel(labels) {
+			return false
+		}
+	}
+
+	alert.Labels = make(map[string]string)
+	alert.Annotations = make(map[string]string)
+	for name, value := range labels {
+		if strings.HasPrefix(name, RELABEL_ANNOTATION_PREFIX) {
+			alert.Annotations[strings.TrimPrefix(name, RELABEL_ANNOTATION_PREFIX)] = value
+		} else {
+			alert.Labels[name] = value
+		}
+	}
+	return true
+}
+
+func (r *RelabelConfig) relabel(labels map[string]string) bool {
+	values := make([]string, 0, len(r.SourceLabels))
+	for _, name := range r.SourceLabels {
+		values = append(values, labels[name])
+	}
+	value := strings.Join(values, r.Separator)
+
+	switch r.Action {
+	case RELABEL_KEEP:
+		return r.regex.MatchString(value)
+	case RELABEL_DROP:
+		return !r.regex.MatchString(value)
+	case RELABEL_REPLACE:
+		match := r.regex.FindStringSubmatchIndex(value)
+		if match == nil {
+			break
+		}
+		target := string(r.regex.ExpandString(nil, r.Replacement, value, match))
+		if target == "" {
+			delete(labels, r.TargetLabel)
+		} else {
+			labels[r.TargetLabel] = target
+		}
+	case RELABEL_LABELMAP:
+		// the new labels are not mapped again
+		mapped := make(map[string]string)
+		for name, value := range labels {
+			if match := r.regex.FindStringSubmatchIndex(name); match != nil {
+				mapped[string(r.regex.ExpandString(nil, r.Replacement, name, match))] = value
+			}
+		}
+		for name, value := range mapped {
+			labels[name] = value
+		}
+	case RELABEL_LABELDROP, RELABEL_LABELKEEP:
+		for name := range labels {
+			if r.regex.MatchString(name) == (r.Action == RELABEL_LABELDROP) {
+				delete(labels, name)
+			}
+		}
+	}
+	return true
+}
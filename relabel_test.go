@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelabeling(t *testing.T) {
+	filename := writeConfigFile(t, `
+relabel_configs:
+  - source_labels: [env]
+    regex: dev|test
+    action: drop
+  - source_labels: [service, region]
+    separator: "-"
+    target_label: component
+  - source_labels: [app]
+    regex: "(.+)-svc"
+    target_label: component
+  - regex: "team_(.+)"
+    replacement: "owner_$1"
+    action: labelmap
+  - regex: "team_.+|tmp"
+    action: labeldrop
+  - source_labels: [__annotation_description]
+    target_label: __annotation_summary
+`)
+	defer os.Remove(filename)
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+	assert.Equal(t, ";", configFile.RelabelConfigs[2].Separator)
+
+	alerts := &PrometheusAlert{Alerts: []PrometheusAlertDetail{
+		{Labels: map[string]string{"service": "api", "region": "eu", "team_name": "core", "tmp": "x"}, Annotations: map[string]string{"description": "API is slow"}},
+		{Labels: map[string]string{"service": "api", "env": "dev"}},
+		{Labels: map[string]string{"app": "web-svc"}},
+	}}
+	configFile.RelabelConfigs.apply(alerts)
+	assert.Equal(t, 2, len(alerts.Alerts))
+	assert.Equal(t, map[string]string{"service": "api", "region": "eu", "component": "api-eu", "owner_name": "core"}, alerts.Alerts[0].Labels)
+	assert.Equal(t, "API is slow", alerts.Alerts[0].Annotations["summary"])
+	assert.Equal(t, "web", alerts.Alerts[1].Labels["component"])
+
+	for _, invalid := range []string{
+		"relabel_configs:\n  - source_labels: [a]\n    regex: '('\n    target_label: b\n",
+		"relabel_configs:\n  - source_labels: [a]\n",
+		"relabel_configs:\n  - action: keep\n",
+		"relabel_configs:\n  - action: hashmod\n",
+	} {
+		filename := writeConfigFile(t, invalid)
+		_, err := LoadConfigFile(filename, false)
+		assert.NotNil(t, err, invalid)
+		os.Remove(filename)
+	}
+}
+
+func TestRelabelingBeforeMapping(t *testing.T) {
+	fake := NewFakeCachet([]string{"api-eu"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	relabeling := Relabeling{{SourceLabels: []string{"service", "region"}, Separator: "-", TargetLabel: "alertname"}}
+	assert.Nil(t, relabeling.validate())
+	config := &PrometheusCachetConfig{
+		LabelName:  "alertname",
+		Cachet:     NewCachetImpl(ts.URL, "token", ts.Client()),
+		Relabeling: relabeling,
+	}
+	router := PrepareGinRouter(config)
+
+	payload := `{"version":"4","status":"firing","alerts":[{"labels":{"alertname":"HighLatency","service":"api","region":"eu"}}]}`
+	req, _ := http.NewRequest("POST", "/alert", bytes.NewBufferString(payload))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, 4, fake.components[0].Status)
+}
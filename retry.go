@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// what to do with a payload still failing once the retries are exhausted
+const (
+	RETRY_EXHAUSTED_ERROR = "error" // answer the error (cf cachethq_error_status)
+	RETRY_EXHAUSTED_DROP  = "drop"  // log (and notify) the error, and answer OK
+	RETRY_EXHAUSTED_DLQ   = "dlq"   // buffer the alerts (cf StoreAndForward), and answer OK
+)
+
+// RetryPolicy retries the processing of a payload on transient CachetHQ errors, with an exponential backoff
+// (capped, and with some jitter), within a number of attempts and a time budget
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	Multiplier  float64
+	MaxBackoff  time.Duration
+	// the backoffs are randomized by +/- Jitter (0.2 = 20%)
+	Jitter float64
+	// max time spent on a payload, retries included
+	Budget    time.Duration
+	Exhausted string
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewRetryPolicy creates a new RetryPolicy
+func NewRetryPolicy(maxAttempts int, backoff time.Duration, multiplier float64, maxBackoff time.Duration, jitter float64, budget time.Duration, exhausted string) (*RetryPolicy, error) {
+	if maxAttempts < 1 {
+		return nil, fmt.Errorf("retry_max_attempts must be at least 1")
+	}
+	if jitter < 0 || jitter > 1 {
+		return nil, fmt.Errorf("retry_jitter must be between 0 and 1")
+	}
+	switch exhausted {
+	case RETRY_EXHAUSTED_ERROR, RETRY_EXHAUSTED_DROP, RETRY_EXHAUSTED_DLQ:
+	default:
+		return nil, fmt.Errorf("unknown retry_exhausted value '%s' (error, drop or dlq)", exhausted)
+	}
+	return &RetryPolicy{
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff,
+		Multiplier:  multiplier,
+		MaxBackoff:  maxBackoff,
+		Jitter:      jitter,
+		Budget:      budget,
+		Exhausted:   exhausted,
+		now:         time.Now,
+		sleep:       time.Sleep,
+	}, nil
+}
+
+// Do calls fn until it succeeds, fails on a non transient error, or the attempts (or the budget) are exhausted
+func (p *RetryPolicy) Do(fn func() error) error {
+	if p == nil {
+		return fn()
+	}
+
+	start := p.now()
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || !IsTransientError(err) || attempt >= p.MaxAttempts {
+			return err
+		}
+		backoff := p.backoff(attempt)
+		// not before the Retry-After of a rate limiting
+		if retryAfter, limited := RateLimited(err); limited && retryAfter > backoff {
+			backoff = retryAfter
+		}
+		if p.Budget > 0 && p.now().Sub(start)+backoff > p.Budget {
+			return err
+		}
+		log.Println("transient CachetHQ error, attempt", attempt, "of", p.MaxAttempts, "- retrying in", backoff, ":", err)
+		p.sleep(backoff)
+	}
+}
+
+// backoff returns the delay after the given attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.Backoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	backoff *= 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(backoff)
+}
+
+// exhausted applies the policy to the error of a payload, once the retries are exhausted
+func (p *RetryPolicy) exhausted(config *PrometheusCachetConfig, alerts *PrometheusAlert, err error) error {
+	if p == nil || err == nil || !IsTransientError(err) {
+		return err
+	}
+	config.Metrics.RetriesExhausted(p.Exhausted)
+
+	switch p.Exhausted {
+	case RETRY_EXHAUSTED_DROP:
+		notifyError(config, "prometheus-cachethq: payload of %s dropped, CachetHQ still failing: %v", alerts.Receiver, err)
+		return nil
+	case RETRY_EXHAUSTED_DLQ:
+		// already buffered by config.StoreAndForward
+		log.Println("payload of", alerts.Receiver, "buffered, CachetHQ still failing:", err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy, err := NewRetryPolicy(4, time.Second, 2, 3*time.Second, 0, 0, RETRY_EXHAUSTED_ERROR)
+	assert.Nil(t, err)
+	var sleeps []time.Duration
+	now := time.Now()
+	policy.now = func() time.Time { return now }
+	policy.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+	}
+
+	// transient errors are retried, with a capped backoff
+	attempts := 0
+	transient := &CachetHTTPError{StatusCode: http.StatusBadGateway}
+	assert.Equal(t, transient, policy.Do(func() error {
+		attempts++
+		return transient
+	}))
+	assert.Equal(t, 4, attempts)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, sleeps)
+
+	// the others are not
+	attempts = 0
+	assert.NotNil(t, policy.Do(func() error {
+		attempts++
+		return fmt.Errorf("CachetHQ returned 422")
+	}))
+	assert.Equal(t, 1, attempts)
+
+	// nor beyond the budget
+	policy.Budget = 2500 * time.Millisecond
+	attempts, sleeps = 0, nil
+	policy.Do(func() error {
+		attempts++
+		return transient
+	})
+	assert.Equal(t, 2, attempts)
+
+	// a rate limiting is retried after its Retry-After
+	policy.Budget = 0
+	sleeps = nil
+	policy.Do(func() error {
+		return &CachetHTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second}
+	})
+	assert.Equal(t, []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second}, sleeps)
+
+	// the jitter stays within its bounds
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.backoff(1)
+		assert.True(t, backoff >= 500*time.Millisecond && backoff <= 1500*time.Millisecond, backoff)
+	}
+
+	_, err = NewRetryPolicy(3, time.Second, 2, 0, 0, 0, "retry")
+	assert.NotNil(t, err)
+}
+
+func TestRetryExhausted(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	failures := 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "token", ts.Client()),
+	}
+	config.Retry, _ = NewRetryPolicy(2, time.Millisecond, 2, 0, 0, 0, RETRY_EXHAUSTED_ERROR)
+	payload := &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API"}}}}
+
+	// the failure is retried
+	_, err := ProcessAlerts(config, payload)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, fake.components[0].Status)
+
+	// exhausted: the error is answered
+	failures = 2
+	_, err = ProcessAlerts(config, payload)
+	assert.NotNil(t, err)
+
+	// or the payload is dropped
+	failures = 2
+	config.Retry.Exhausted = RETRY_EXHAUSTED_DROP
+	_, err = ProcessAlerts(config, payload)
+	assert.Nil(t, err)
+
+	// or buffered, to be forwarded later
+	failures = 2
+	config.Retry.Exhausted = RETRY_EXHAUSTED_DLQ
+	config.StoreAndForward = NewStoreAndForward(config, 0)
+	_, err = ProcessAlerts(config, payload)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, config.StoreAndForward.Buffered())
+}
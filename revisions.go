@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// IncidentRevisions remembers the last revision (a hash of the status and of the message) of the incidents written
+// by the bridge, to tell the incidents edited since by a human on the status page (cf preserve_edits). An incident seen
+// edited stays so, the next writes of the bridge (ex: an escalation update) not making it forget the edit. The revisions
+// are kept in memory: the incidents written before a restart are not known to be edited
+type IncidentRevisions struct {
+	mutex     sync.Mutex
+	revisions map[int]string // by incident id
+	edits     map[int]bool   // by incident id
+}
+
+// NewIncidentRevisions creates a new IncidentRevisions
+func NewIncidentRevisions() *IncidentRevisions {
+	return &IncidentRevisions{revisions: make(map[int]string), edits: make(map[int]bool)}
+}
+
+func incidentRevision(incident *CachetIncident) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%d\n%s", incident.Status, incident.Message))))
+}
+
+func (r *IncidentRevisions) record(incident *CachetIncident) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.revisions[incident.Id] = incidentRevision(incident)
+}
+
+// edited returns true if the incident changed since the bridge last wrote it, or was once seen edited (false if not
+// written by the bridge)
+func (r *IncidentRevisions) edited(incident *CachetIncident) bool {
+	if r == nil || incident == nil {
+		return false
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.edits[incident.Id] {
+		return true
+	}
+	revision, ok := r.revisions[incident.Id]
+	if ok && revision != incidentRevision(incident) {
+		r.edits[incident.Id] = true
+		return true
+	}
+	return false
+}
+
+// RevisionCachet is a Cachet decorator recording the revision of the incidents after each write of the bridge
+// (reading them back)
+type RevisionCachet struct {
+	Cachet
+	revisions *IncidentRevisions
+}
+
+// NewRevisionCachet creates a new Cachet decorator, recording the revisions in revisions
+func NewRevisionCachet(cachet Cachet, revisions *IncidentRevisions) *RevisionCachet {
+	return &RevisionCachet{Cachet: cachet, revisions: revisions}
+}
+
+func (r *RevisionCachet) track(incidentId int) {
+	// (a dry run)
+	if incidentId <= 0 {
+		return
+	}
+	incident, err := r.Cachet.ReadIncident(incidentId)
+	if err != nil {
+		log.Println("not able to read back the CachetHQ incident", incidentId, ":", err)
+		return
+	}
+	r.revisions.record(incident)
+}
+
+func (r *RevisionCachet) CreateIncident(componentName string, componentID, status int, componentStatus int, options IncidentOptions) (int, error) {
+	incidentId, err := r.Cachet.CreateIncident(componentName, componentID, status, componentStatus, options)
+	if err == nil {
+		r.track(incidentId)
+	}
+	return incidentId, err
+}
+
+func (r *RevisionCachet) UpdateIncident(componentName string, componentID, incidentId, status int, name, message string) error {
+	if err := r.Cachet.UpdateIncident(componentName, componentID, incidentId, status, name, message); err != nil {
+		return err
+	}
+	r.track(incidentId)
+	return nil
+}
+
+func (r *RevisionCachet) SetIncidentStatus(incidentId, incidentStatus int, message string) error {
+	if err := r.Cachet.SetIncidentStatus(incidentId, incidentStatus, message); err != nil {
+		return err
+	}
+	r.track(incidentId)
+	return nil
+}
+
+func (r *RevisionCachet) CreateIncidentUpdate(incidentId, incidentStatus int, message string) error {
+	if err := r.Cachet.CreateIncidentUpdate(incidentId, incidentStatus, message); err != nil {
+		return err
+	}
+	r.track(incidentId)
+	return nil
+}
+
+func (r *RevisionCachet) PublishIncident(incidentId, componentID, componentStatus, incidentStatus int) error {
+	if err := r.Cachet.PublishIncident(incidentId, componentID, componentStatus, incidentStatus); err != nil {
+		return err
+	}
+	r.track(incidentId)
+	return nil
+}
+
+// preserveEdits returns true if the incidents of the route edited by a human are only updated (instead of being
+// renamed, or having their status overwritten): the route preserve_edits, or defaults
+func (r *Route) preserveEdits(defaults bool) bool {
+	if r != nil && r.PreserveEdits != nil {
+		return *r.PreserveEdits
+	}
+	return defaults
+}
+
+// routesPreserveEdits returns true if a route preserves the human edits of its incidents
+func routesPreserveEdits(routes []*Route) bool {
+	for _, route := range routes {
+		if route.preserveEdits(false) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreserveEdits(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	revisions := NewIncidentRevisions()
+	preserve, overwrite := true, false
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewRevisionCachet(NewCachetImpl(ts.URL, "token", ts.Client()), revisions),
+		SquashIncident: true,
+		Revisions:      revisions,
+		Routes: []*Route{
+			{Name: "web", Match: map[string]string{"alertname": "WEB"}, PreserveEdits: &overwrite},
+			{Name: "default", PreserveEdits: &preserve},
+		},
+	}
+	api := PrometheusAlertDetail{Labels: map[string]string{"alertname": "API"}}
+	web := PrometheusAlertDetail{Labels: map[string]string{"alertname": "WEB"}}
+
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{api, web}})
+	assert.Nil(t, err)
+	assert.False(t, revisions.edited(&CachetIncident{Id: 1, Status: fake.incidents[0].Status, Message: fake.incidents[0].Message}))
+
+	// edited on the status page (keeping the marker of the alert)
+	for _, incident := range fake.incidents {
+		incident.Name = "Degraded performance"
+		incident.Status = 3
+	}
+	fake.incidents[0].Message = "We are looking into it" + IncidentMarker(api.fingerprint())
+	fake.incidents[1].Message = "We are looking into it" + IncidentMarker(web.fingerprint())
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{api, web}})
+	assert.Nil(t, err)
+
+	// only fixed with an update
+	assert.Equal(t, "Degraded performance", fake.incidents[0].Name)
+	assert.Equal(t, "We are looking into it"+IncidentMarker(api.fingerprint()), fake.incidents[0].Message)
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, 1, fake.components[0].Status)
+	// the route overwriting the edits
+	assert.Equal(t, "WEB up", fake.incidents[1].Name)
+	assert.Equal(t, 2, len(fake.updates))
+
+	// not known to the bridge
+	assert.False(t, revisions.edited(&CachetIncident{Id: 42, Message: "by hand"}))
+	var none *IncidentRevisions
+	assert.False(t, none.edited(&CachetIncident{Id: 1}))
+}
+
+func TestIncidentEscalatorPreserveEdits(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	revisions := NewIncidentRevisions()
+	cachet := NewRevisionCachet(NewCachetImpl(ts.URL, "token", ts.Client()), revisions)
+	incidentID, err := cachet.CreateIncident("API", 1, 0, 4, IncidentOptions{})
+	assert.Nil(t, err)
+
+	route := &Route{Escalation: []*EscalationStep{{After: 10 * time.Minute, incidentStatus: 2}, {After: time.Hour, incidentStatus: 1}}}
+	escalator := NewIncidentEscalator(cachet, nil, time.Minute)
+	escalator.SetRevisions(revisions, true)
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	escalator.Track(1, incidentID, "API", []string{"API"}, route, &PrometheusAlertDetail{StartAt: start.Format(time.RFC3339)})
+
+	escalator.check(start.Add(10 * time.Minute))
+	assert.Equal(t, 2, fake.updates[0].Status)
+
+	// watched by the on-call engineer: the next step keeps the status
+	fake.incidents[0].Status = 3
+	escalator.check(start.Add(time.Hour))
+	assert.Equal(t, 2, len(fake.updates))
+	assert.Equal(t, 3, fake.updates[1].Status)
+}
+
+func TestIncidentEscalatorPreserveEditsTwoSteps(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	revisions := NewIncidentRevisions()
+	cachet := NewRevisionCachet(NewCachetImpl(ts.URL, "token", ts.Client()), revisions)
+	incidentID, err := cachet.CreateIncident("API", 1, 0, 4, IncidentOptions{})
+	assert.Nil(t, err)
+
+	route := &Route{Escalation: []*EscalationStep{{After: 10 * time.Minute, incidentStatus: 2}, {After: time.Hour, incidentStatus: 2}, {After: 2 * time.Hour, incidentStatus: 1}}}
+	escalator := NewIncidentEscalator(cachet, nil, time.Minute)
+	escalator.SetRevisions(revisions, true)
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	escalator.Track(1, incidentID, "API", []string{"API"}, route, &PrometheusAlertDetail{StartAt: start.Format(time.RFC3339)})
+
+	escalator.check(start.Add(10 * time.Minute))
+	assert.Equal(t, 2, fake.updates[0].Status)
+
+	// edited once: the edit is not forgotten after the next update of the bridge
+	fake.incidents[0].Status = 3
+	escalator.check(start.Add(time.Hour))
+	assert.Equal(t, 3, fake.updates[1].Status)
+	escalator.check(start.Add(2 * time.Hour))
+	assert.Equal(t, 3, len(fake.updates))
+	assert.Equal(t, 3, fake.updates[2].Status)
+	assert.True(t, revisions.edited(&CachetIncident{Id: incidentID, Status: fake.incidents[0].Status, Message: fake.incidents[0].Message}))
+}
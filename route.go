@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// cf https://docs.cachethq.io/docs/incident-statuses
+var incidentStatuses = map[string]int{
+	"scheduled":     0,
+	"investigating": 1,
+	"identified":    2,
+	"watching":      3,
+	"fixed":         4,
+}
+
+// EscalationStep is an incident status update, sent when the alert
+// is firing for more than After
+type EscalationStep struct {
+	After   time.Duration `yaml:"after"`
+	Status  string        `yaml:"status"`
+	Message string        `yaml:"message"`
+
+	incidentStatus int
+}
+
+// Route allows to customize the behaviour of the bridge for a subset of the
+// alerts. An alert belongs to the first route where all the Match labels are
+// equal, and the When expression is true (and where the Alertmanager receiver
+// is the same, if Receiver is set)
+type Route struct {
+	Name     string            `yaml:"name"`
+	Receiver string            `yaml:"receiver"`
+	Match    map[string]string `yaml:"match"`
+	// ex: labels.severity == "critical" && labels.env in ["prod", "staging"] (cf AlertExpression)
+	When       string            `yaml:"when"`
+	Escalation []*EscalationStep `yaml:"escalation"`
+	Stickied   *bool             `yaml:"stickied"`
+	// Visible set to false hides the incidents from the status page (for the logged in users only)
+	Visible *bool `yaml:"visible"`
+	// NotifySubscribers emails (or not) the CachetHQ subscribers of the incidents of this route (instead of notify_severities)
+	NotifySubscribers *bool `yaml:"notify_subscribers"`
+	// PreserveEdits only updates (instead of renaming, or overwriting the status of) the incidents of this route edited
+	// by a human since the bridge wrote them (instead of preserve_edits)
+	PreserveEdits *bool `yaml:"preserve_edits"`
+	// the PagerDuty routing key of the alerts of this route (instead of pagerduty_routing_key)
+	PagerDutyRoutingKey string `yaml:"pagerduty_routing_key"`
+	// the Opsgenie api key of the alerts of this route (instead of opsgenie_api_key)
+	OpsgenieAPIKey string `yaml:"opsgenie_api_key"`
+	// the incident texts of the alerts of this route (completed with the global ones, or the ones of its locale)
+	Templates *IncidentTemplates `yaml:"templates"`
+	// the locale of the incident texts of this route (cf ConfigFile.Locales)
+	Locale string `yaml:"locale"`
+	// the time windows during which the incidents are not published (cf QuietHours)
+	QuietHours *QuietHours `yaml:"quiet_hours"`
+
+	when *AlertExpression
+}
+
+func (r *Route) validate() error {
+	var err error
+	if r.when, err = CompileExpression(r.When); err != nil {
+		return err
+	}
+
+	var previous time.Duration
+	for _, step := range r.Escalation {
+		status, ok := incidentStatuses[step.Status]
+		if !ok {
+			return fmt.Errorf("unknown incident status '%s'", step.Status)
+		}
+		if step.After <= previous {
+			return fmt.Errorf("escalation steps must be sorted by increasing 'after' delays")
+		}
+		step.incidentStatus = status
+		previous = step.After
+	}
+	if err := r.QuietHours.validate(); err != nil {
+		return fmt.Errorf("quiet_hours: %v", err)
+	}
+	return r.Templates.validate()
+}
+
+func (r *Route) matches(receiver string, alert *PrometheusAlertDetail) bool {
+	if r.Receiver != "" && r.Receiver != receiver {
+		return false
+	}
+	for label, value := range r.Match {
+		if alert.Labels[label] != value {
+			return false
+		}
+	}
+	return r.when.matches(receiver, alert)
+}
+
+// MatchRoute returns the first route matching the alert, or nil
+func MatchRoute(routes []*Route, receiver string, alert *PrometheusAlertDetail) *Route {
+	for _, route := range routes {
+		if route.matches(receiver, alert) {
+			return route
+		}
+	}
+	return nil
+}
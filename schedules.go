@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SCHEDULE_SILENCE_CREATED_BY is the author of the Alertmanager silences of the scheduled maintenances
+const SCHEDULE_SILENCE_CREATED_BY = "prometheus-cachethq"
+
+// SCHEDULE_SILENCE_EXTENSION is how long the silence of a maintenance without end is extended, on each poll
+const SCHEDULE_SILENCE_EXTENSION = 1 * time.Hour
+
+// ALERTMANAGER_TIMEOUT bounds an Alertmanager request
+const ALERTMANAGER_TIMEOUT = 10 * time.Second
+
+// scheduleSilenceComment matches the comment of a silence created for a scheduled maintenance (with its schedule id)
+var scheduleSilenceComment = regexp.MustCompile(`^CachetHQ scheduled maintenance #(\d+)`)
+
+// cf https://github.com/prometheus/alertmanager/blob/master/api/v2/openapi.yaml
+type alertmanagerMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+}
+
+type alertmanagerSilence struct {
+	ID        string                `json:"id,omitempty"`
+	Matchers  []alertmanagerMatcher `json:"matchers"`
+	StartsAt  time.Time             `json:"startsAt"`
+	EndsAt    time.Time             `json:"endsAt"`
+	CreatedBy string                `json:"createdBy"`
+	Comment   string                `json:"comment"`
+	Status    *struct {
+		State string `json:"state"` // active, pending or expired
+	} `json:"status,omitempty"`
+}
+
+// ScheduleSilencer creates an Alertmanager silence for each maintenance scheduled in CachetHQ (on the label_name
+// label of its components, and of their aliases), so the planned work neither pages nor creates incidents.
+// The silence follows the changes of its maintenance, and is expired if the maintenance is completed (or removed)
+type ScheduleSilencer struct {
+	config       *PrometheusCachetConfig
+	alertmanager string
+	client       *http.Client
+	interval     time.Duration
+
+	// the silences created, by schedule id (nil until the existing ones are loaded from Alertmanager)
+	silences map[int]*alertmanagerSilence
+}
+
+// NewScheduleSilencer creates a new ScheduleSilencer, polling the CachetHQ schedules every interval
+func NewScheduleSilencer(config *PrometheusCachetConfig, alertmanager string, interval time.Duration) *ScheduleSilencer {
+	return &ScheduleSilencer{
+		config:       config,
+		alertmanager: strings.TrimSuffix(alertmanager, "/"),
+		client:       &http.Client{Timeout: ALERTMANAGER_TIMEOUT},
+		interval:     interval,
+	}
+}
+
+// Run polls the schedules every interval (and right away), until stop is closed
+func (s *ScheduleSilencer) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.poll(time.Now()); err != nil {
+			log.Println("not able to sync the Alertmanager silences of the CachetHQ schedules:", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll creates (or updates) the silences of the current and upcoming maintenances, and expires the other ones
+func (s *ScheduleSilencer) poll(now time.Time) error {
+	if s.silences == nil {
+		// the silences created before a restart
+		silences, err := s.existingSilences()
+		if err != nil {
+			return err
+		}
+		s.silences = silences
+	}
+
+	schedules, err := s.config.Cachet.ListSchedules()
+	if err != nil {
+		return err
+	}
+	components, err := s.config.Cachet.ListComponentsDetails()
+	if err != nil {
+		return err
+	}
+	names := make(map[int]string)
+	for _, component := range components {
+		names[component.Id] = component.Name
+	}
+
+	var firstErr error
+	wanted := make(map[int]bool)
+	for _, schedule := range schedules {
+		silence := s.scheduleSilence(schedule, names, now)
+		if silence == nil {
+			continue
+		}
+		wanted[schedule.Id] = true
+
+		previous := s.silences[schedule.Id]
+		if previous != nil && sameSilence(previous, silence) {
+			continue
+		}
+		if previous != nil {
+			silence.ID = previous.ID
+		}
+		if err := s.post(silence); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		s.silences[schedule.Id] = silence
+	}
+
+	for id, silence := range s.silences {
+		if wanted[id] {
+			continue
+		}
+		// completed, or removed: the silence is expired right away (if not already)
+		if silence.EndsAt.After(now) {
+			if err := s.expire(silence.ID); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+		}
+		delete(s.silences, id)
+	}
+	return firstErr
+}
+
+// scheduleSilence returns the silence of a maintenance, nil if it is over (or if its components are unknown)
+func (s *ScheduleSilencer) scheduleSilence(schedule *CachetSchedule, names map[int]string, now time.Time) *alertmanagerSilence {
+	if schedule.Status == SCHEDULE_COMPLETE {
+		return nil
+	}
+	endsAt := schedule.CompletedAt
+	if endsAt.IsZero() {
+		// extended until the maintenance is completed
+		endsAt = now.Add(SCHEDULE_SILENCE_EXTENSION).Truncate(time.Minute)
+		if endsAt.Before(schedule.ScheduledAt) {
+			endsAt = schedule.ScheduledAt.Add(SCHEDULE_SILENCE_EXTENSION)
+		}
+	}
+	if !endsAt.After(now) {
+		return nil
+	}
+
+	values := make([]string, 0)
+	for _, id := range schedule.ComponentIDs {
+		name, ok := names[id]
+		if !ok {
+			continue
+		}
+		values = append(values, regexp.QuoteMeta(name))
+		for alias, component := range s.config.Aliases {
+			if component == name {
+				values = append(values, regexp.QuoteMeta(alias))
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Strings(values)
+	value := strings.Join(values, "|")
+	if s.config.NormalizeNames {
+		value = "(?i)" + value
+	}
+
+	return &alertmanagerSilence{
+		Matchers:  []alertmanagerMatcher{{Name: s.config.LabelName, Value: value, IsRegex: true}},
+		StartsAt:  schedule.ScheduledAt,
+		EndsAt:    endsAt,
+		CreatedBy: SCHEDULE_SILENCE_CREATED_BY,
+		Comment:   fmt.Sprintf("CachetHQ scheduled maintenance #%d: %s", schedule.Id, schedule.Name),
+	}
+}
+
+// sameSilence returns if the silence b does not change the silence a (the silences of the maintenances without end
+// being extended once half of the extension is elapsed)
+func sameSilence(a, b *alertmanagerSilence) bool {
+	if len(a.Matchers) != len(b.Matchers) || a.Comment != b.Comment || !a.StartsAt.Equal(b.StartsAt) {
+		return false
+	}
+	for i := range a.Matchers {
+		if a.Matchers[i] != b.Matchers[i] {
+			return false
+		}
+	}
+	if a.EndsAt.Equal(b.EndsAt) {
+		return true
+	}
+	return b.EndsAt.After(a.EndsAt) && b.EndsAt.Sub(a.EndsAt) < SCHEDULE_SILENCE_EXTENSION/2
+}
+
+// existingSilences returns the silences previously created for the maintenances, not expired yet
+func (s *ScheduleSilencer) existingSilences() (map[int]*alertmanagerSilence, error) {
+	resp, err := s.client.Get(s.alertmanager + "/api/v2/silences")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Alertmanager returned %d: %s", resp.StatusCode, string(b))
+	}
+	var list []*alertmanagerSilence
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("not an Alertmanager silences answer: %v", err)
+	}
+
+	silences := make(map[int]*alertmanagerSilence)
+	for _, silence := range list {
+		if silence.CreatedBy != SCHEDULE_SILENCE_CREATED_BY || (silence.Status != nil && silence.Status.State == "expired") {
+			continue
+		}
+		if match := scheduleSilenceComment.FindStringSubmatch(silence.Comment); match != nil {
+			id, _ := strconv.Atoi(match[1])
+			silence.Status = nil
+			silences[id] = silence
+		}
+	}
+	return silences, nil
+}
+
+// post creates (or updates, if its id is set) a silence, and sets its id
+func (s *ScheduleSilencer) post(silence *alertmanagerSilence) error {
+	body, err := json.Marshal(silence)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.alertmanager+"/api/v2/silences", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Alertmanager returned %d: %s", resp.StatusCode, string(b))
+	}
+	var answer struct {
+		SilenceID string `json:"silenceID"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return fmt.Errorf("not an Alertmanager silence answer: %v", err)
+	}
+	// a silence already active gets a new id when updated
+	silence.ID = answer.SilenceID
+	return nil
+}
+
+// expire expires a silence
+func (s *ScheduleSilencer) expire(id string) error {
+	req, err := http.NewRequest(http.MethodDelete, s.alertmanager+"/api/v2/silence/"+id, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// already expired, or removed
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Alertmanager returned %d: %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAlertmanager implements the silences of the Alertmanager API v2
+type fakeAlertmanager struct {
+	mutex    sync.Mutex
+	silences map[string]*alertmanagerSilence
+	posts    int
+}
+
+func (f *fakeAlertmanager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch {
+	case r.URL.Path == "/api/v2/silences" && r.Method == http.MethodGet:
+		list := make([]*alertmanagerSilence, 0)
+		for _, silence := range f.silences {
+			list = append(list, silence)
+		}
+		json.NewEncoder(w).Encode(list)
+	case r.URL.Path == "/api/v2/silences" && r.Method == http.MethodPost:
+		var silence alertmanagerSilence
+		json.NewDecoder(r.Body).Decode(&silence)
+		f.posts++
+		if silence.ID == "" {
+			silence.ID = fmt.Sprintf("silence%d", f.posts)
+		}
+		f.silences[silence.ID] = &silence
+		json.NewEncoder(w).Encode(map[string]string{"silenceID": silence.ID})
+	case strings.HasPrefix(r.URL.Path, "/api/v2/silence/") && r.Method == http.MethodDelete:
+		id := strings.TrimPrefix(r.URL.Path, "/api/v2/silence/")
+		if _, ok := f.silences[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.silences, id)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestScheduleSilencer(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "Website"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	alertmanager := &fakeAlertmanager{silences: make(map[string]*alertmanagerSilence)}
+	am := httptest.NewServer(alertmanager)
+	defer am.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "undefined", ts.Client()),
+		Aliases:   map[string]string{"api-gateway": "API"},
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	upgrade := fake.AddSchedule("API upgrade", 0, now.Add(time.Hour), now.Add(2*time.Hour), 1)
+	fake.AddSchedule("Website migration", 1, now.Add(-time.Hour), time.Time{}, 2)
+	fake.AddSchedule("Past", SCHEDULE_COMPLETE, now.Add(-3*time.Hour), now.Add(-2*time.Hour), 1)
+
+	silencer := NewScheduleSilencer(config, am.URL+"/", time.Minute)
+	assert.Nil(t, silencer.poll(now))
+	assert.Equal(t, 2, len(alertmanager.silences))
+	silence := alertmanager.silences[silencer.silences[upgrade].ID]
+	assert.Equal(t, []alertmanagerMatcher{{Name: "alertname", Value: "API|api-gateway", IsRegex: true}}, silence.Matchers)
+	assert.True(t, silence.StartsAt.Equal(now.Add(time.Hour)))
+	assert.True(t, silence.EndsAt.Equal(now.Add(2*time.Hour)))
+	assert.Equal(t, SCHEDULE_SILENCE_CREATED_BY, silence.CreatedBy)
+
+	// nothing changed (the open-ended maintenance being extended later)
+	assert.Nil(t, silencer.poll(now.Add(time.Minute)))
+	assert.Equal(t, 2, alertmanager.posts)
+	assert.Nil(t, silencer.poll(now.Add(SCHEDULE_SILENCE_EXTENSION)))
+	assert.Equal(t, 3, alertmanager.posts)
+
+	// a restart does not create the silences again
+	silencer = NewScheduleSilencer(config, am.URL, time.Minute)
+	assert.Nil(t, silencer.poll(now.Add(SCHEDULE_SILENCE_EXTENSION)))
+	assert.Equal(t, 2, len(alertmanager.silences))
+
+	// a removed maintenance is not silenced anymore
+	fake.RemoveSchedule(upgrade)
+	assert.Nil(t, silencer.poll(now.Add(SCHEDULE_SILENCE_EXTENSION)))
+	assert.Equal(t, 1, len(alertmanager.silences))
+	assert.Nil(t, silencer.silences[upgrade])
+}
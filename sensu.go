@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// cf https://docs.sensu.io/sensu-go/latest/observability-pipeline/observe-events/events/
+type sensuMetadata struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Labels    map[string]string `json:"labels"`
+}
+
+// sensuEvent is the event piped by a Sensu Go handler (cf contrib/sensu)
+type sensuEvent struct {
+	Entity *struct {
+		Metadata sensuMetadata `json:"metadata"`
+	} `json:"entity"`
+	Check *struct {
+		Metadata sensuMetadata `json:"metadata"`
+		Status   *int          `json:"status"`
+		Output   string        `json:"output"`
+	} `json:"check"`
+}
+
+// sensuSeverities are the severities of the check statuses firing an incident (the other statuses, but 0, are ignored)
+var sensuSeverities = map[int]string{
+	1: "warning",
+	2: "critical",
+}
+
+// SensuAdapter translates the Sensu Go check events
+type SensuAdapter struct{}
+
+// Parse translates a check event: the status 0 resolves, 1 (warning) and 2 (critical) fire.
+// The impacted component is the cachet_component label of the check (or of the entity), else "entity/check"
+func (a *SensuAdapter) Parse(config *PrometheusCachetConfig, body []byte) (*PrometheusAlert, error) {
+	var event sensuEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	if event.Entity == nil || event.Entity.Metadata.Name == "" || event.Check == nil || event.Check.Metadata.Name == "" || event.Check.Status == nil {
+		return nil, fmt.Errorf("not a check event: entity, check and check status are required")
+	}
+
+	status := *event.Check.Status
+	severity, firing := sensuSeverities[status]
+	if !firing && status != 0 {
+		return nil, nil
+	}
+
+	key := event.Entity.Metadata.Name + "/" + event.Check.Metadata.Name
+	if event.Check.Metadata.Namespace != "" {
+		key = event.Check.Metadata.Namespace + "/" + key
+	}
+	component := event.Entity.Metadata.Name + "/" + event.Check.Metadata.Name
+	if value, ok := event.Entity.Metadata.Labels[INPUT_COMPONENT_TAG]; ok && value != "" {
+		component = value
+	}
+	if value, ok := event.Check.Metadata.Labels[INPUT_COMPONENT_TAG]; ok && value != "" {
+		component = value
+	}
+
+	alerts := newInputAlert(config, "sensu", firing, component, key)
+	alert := &alerts.Alerts[0]
+	if firing {
+		alert.Labels[LABEL_SEVERITY] = severity
+	}
+	if event.Check.Output != "" {
+		alert.Annotations[ANNOTATION_SUMMARY] = event.Check.Output
+	}
+	return alerts, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSensuAdapter(t *testing.T) {
+	config := &PrometheusCachetConfig{LabelName: "alertname"}
+	adapter := &SensuAdapter{}
+
+	alerts, err := adapter.Parse(config, []byte(`{"entity":{"metadata":{"name":"web01"}},"check":{"metadata":{"name":"check-http","namespace":"default"},"status":2,"output":"HTTP 503"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "firing", alerts.Status)
+	assert.Equal(t, "web01/check-http", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "critical", alerts.Alerts[0].Labels[LABEL_SEVERITY])
+	assert.Equal(t, "HTTP 503", alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY])
+	assert.Equal(t, "sensu:default/web01/check-http", alerts.Alerts[0].fingerprint())
+
+	// the check label wins over the entity one
+	alerts, err = adapter.Parse(config, []byte(`{"entity":{"metadata":{"name":"web01","labels":{"cachet_component":"Web"}}},"check":{"metadata":{"name":"check-http","namespace":"default","labels":{"cachet_component":"API"}},"status":0}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "resolved", alerts.Status)
+	assert.Equal(t, "API", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "", alerts.Alerts[0].Labels[LABEL_SEVERITY])
+	assert.Equal(t, "sensu:default/web01/check-http", alerts.Alerts[0].fingerprint())
+
+	// unknown status
+	alerts, err = adapter.Parse(config, []byte(`{"entity":{"metadata":{"name":"web01"}},"check":{"metadata":{"name":"check-http"},"status":3}}`))
+	assert.Nil(t, err)
+	assert.Nil(t, alerts)
+
+	_, err = adapter.Parse(config, []byte(`{"entity":{"metadata":{"name":"web01"}},"check":{"metadata":{"name":"check-http"}}}`))
+	assert.NotNil(t, err)
+	_, err = adapter.Parse(config, []byte(`{"metrics":{}}`))
+	assert.NotNil(t, err)
+}
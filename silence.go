@@ -0,0 +1,112 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Silence excludes a component from the automation until a date
+type Silence struct {
+	Component string    `json:"component" binding:"required"`
+	Until     time.Time `json:"until" binding:"required"`
+}
+
+// Silences are the components excluded from the automation (cf /admin/silence)
+type Silences struct {
+	mutex    sync.Mutex
+	silences map[string]time.Time // component name => until
+}
+
+// NewSilences creates a new Silences
+func NewSilences() *Silences {
+	return &Silences{silences: make(map[string]time.Time)}
+}
+
+// Add silences a component until silence.Until (replacing its previous silence, if any)
+func (s *Silences) Add(silence Silence) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.silences[silence.Component] = silence.Until
+}
+
+// Remove stops the silence of a component, and returns false if it was not silenced
+func (s *Silences) Remove(component string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.silences[component]
+	delete(s.silences, component)
+	return ok
+}
+
+// List returns the silences not expired yet, sorted by component name
+func (s *Silences) List(now time.Time) []Silence {
+	list := make([]Silence, 0)
+	if s == nil {
+		return list
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for component, until := range s.silences {
+		if !until.After(now) {
+			delete(s.silences, component)
+			continue
+		}
+		list = append(list, Silence{Component: component, Until: until})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Component < list[j].Component })
+	return list
+}
+
+// silenced returns true if the component name is silenced (compared as the config compares the names)
+func (s *Silences) silenced(config *PrometheusCachetConfig, name string, now time.Time) bool {
+	for _, silence := range s.List(now) {
+		if namesEqual(config, silence.Component, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter removes the silenced components from the impacted ones (a component sharing an incident takes it over,
+// if the component of the incident is silenced), and returns the names of the silenced components
+func (s *Silences) filter(config *PrometheusCachetConfig, batch *statusBatch, components []ImpactedComponent, now time.Time) ([]ImpactedComponent, []string) {
+	silencedNames := make([]string, 0)
+	if s == nil {
+		return components, silencedNames
+	}
+
+	kept := make([]ImpactedComponent, 0, len(components))
+	for _, component := range components {
+		ids := make([]int, 0)
+		names := make([]string, 0)
+		for _, componentID := range component.IDs() {
+			name := component.Name
+			if listed := batch.component(componentID); listed != nil {
+				name = listed.Name
+			}
+			if s.silenced(config, name, now) {
+				silencedNames = append(silencedNames, name)
+				continue
+			}
+			ids = append(ids, componentID)
+			names = append(names, name)
+		}
+
+		switch {
+		case len(ids) == 0:
+			continue
+		case ids[0] != component.ID:
+			// the component of the incident is silenced
+			component = ImpactedComponent{ID: ids[0], Name: names[0], Others: ids[1:]}
+		default:
+			component.Others = ids[1:]
+		}
+		kept = append(kept, component)
+	}
+	return kept, silencedNames
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSilencesFilter(t *testing.T) {
+	now := time.Now()
+	silences := NewSilences()
+	silences.Add(Silence{Component: "api", Until: now.Add(time.Hour)})
+	silences.Add(Silence{Component: "cdn", Until: now.Add(-time.Minute)})
+
+	batch := newStatusBatch([]*CachetComponent{{Id: 1, Name: "api"}, {Id: 2, Name: "web"}, {Id: 3, Name: "cdn"}})
+	config := &PrometheusCachetConfig{}
+
+	// the expired silences are dropped
+	assert.Equal(t, []Silence{{Component: "api", Until: now.Add(time.Hour)}}, silences.List(now))
+
+	kept, silenced := silences.filter(config, batch, []ImpactedComponent{
+		{ID: 1, Name: "api"},
+		{ID: 1, Name: "edge", Others: []int{2, 3}},
+		{ID: 3, Name: "cdn"},
+	}, now)
+	assert.Equal(t, []string{"api", "api"}, silenced)
+	assert.Equal(t, []ImpactedComponent{{ID: 2, Name: "web", Others: []int{3}}, {ID: 3, Name: "cdn", Others: []int{}}}, kept)
+
+	// compared as the component names
+	config.NormalizeNames = true
+	assert.True(t, silences.silenced(config, "API", now))
+	config.NormalizeNames = false
+	assert.False(t, silences.silenced(config, "API", now))
+
+	var none *Silences
+	kept, _ = none.filter(config, batch, []ImpactedComponent{{ID: 1, Name: "api"}}, now)
+	assert.Equal(t, 1, len(kept))
+}
+
+func TestSilenceEndpoint(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "web"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		PrometheusToken: "promToken",
+		LabelName:       "alertname",
+		Cachet:          NewCachetImpl(ts.URL, "token", ts.Client()),
+		Silences:        NewSilences(),
+		History:         NewHistory(10),
+	}
+	router := PrepareGinRouter(config)
+	send := func(method, path, payload string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(payload))
+		req.Header.Set("Authorization", "Bearer promToken")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	until := time.Now().Add(time.Hour).Format(time.RFC3339)
+	assert.Equal(t, 200, send("POST", "/admin/silence", `{"component":"API","until":"`+until+`"}`))
+	assert.Equal(t, 400, send("POST", "/admin/silence", `{"component":"web","until":"2000-01-01T00:00:00Z"}`))
+
+	report, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{
+		{Labels: map[string]string{"alertname": "API"}},
+		{Labels: map[string]string{"alertname": "web"}},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"API"}, report.Alerts[0].Silenced)
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, 1, fake.components[0].Status)
+	assert.Equal(t, HISTORY_RESULT_SILENCED, config.History.Entries("", 0)[1].Result)
+
+	assert.Equal(t, 200, send("DELETE", "/admin/silence/API", ""))
+	assert.Equal(t, 404, send("DELETE", "/admin/silence/API", ""))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// SMOKE_TEST_INCIDENT is the name of the hidden incident created (and deleted) by the startup check
+const SMOKE_TEST_INCIDENT = "prometheus-cachethq startup check"
+
+// SmokeTest checks on startup that the bridge can work with CachetHQ, so that a misconfigured deployment fails
+// fast instead of looking healthy while dropping every alert: CachetHQ answers its ping, and lists the components.
+// As the CachetHQ reads are public, the token is only checked with writeCheck, by creating (and deleting) a hidden
+// incident, on no component
+func SmokeTest(cachet Cachet, writeCheck bool) error {
+	if err := cachet.Ping(); err != nil {
+		return fmt.Errorf("startup check: CachetHQ is not reachable (cachethq_url): %v", err)
+	}
+
+	components, err := cachet.ListComponents()
+	if err != nil {
+		return fmt.Errorf("startup check: not able to list the CachetHQ components: %v", smokeTestError(err))
+	}
+	if len(components) == 0 {
+		log.Println("startup check: CachetHQ has no component, no alert will be matched")
+	}
+
+	if writeCheck {
+		incidentID, err := cachet.CreateIncident("", 0, 1, 0, IncidentOptions{
+			Private: true,
+			Name:    SMOKE_TEST_INCIDENT,
+			Message: "Checking the CachetHQ token on startup, this incident is deleted right away.",
+		})
+		if err != nil {
+			return fmt.Errorf("startup check: not able to create a CachetHQ incident: %v", smokeTestError(err))
+		}
+		if err := cachet.DeleteIncident(incidentID); err != nil {
+			return fmt.Errorf("startup check: not able to delete the hidden CachetHQ incident %d (%s): %v", incidentID, SMOKE_TEST_INCIDENT, smokeTestError(err))
+		}
+	}
+	log.Println("startup check: CachetHQ is reachable, with", len(components), "components")
+	return nil
+}
+
+// smokeTestError explains the errors of a misconfigured bridge
+func smokeTestError(err error) error {
+	if httpErr, ok := err.(*CachetHTTPError); ok {
+		switch httpErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("the CachetHQ token is refused (cachethq_token, cachethq_auth): %v", err)
+		case http.StatusNotFound:
+			return fmt.Errorf("not a CachetHQ API (cachethq_url): %v", err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmokeTest(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	token := "token"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// like CachetHQ, only the writes need the token
+		if r.Method != http.MethodGet && r.Header.Get("X-Cachet-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	assert.Nil(t, SmokeTest(NewCachetImpl(ts.URL, token, ts.Client()), false))
+	assert.Nil(t, SmokeTest(NewCachetImpl(ts.URL, token, ts.Client()), true))
+	assert.Equal(t, 0, len(fake.incidents))
+
+	// a wrong token is only seen by the write check
+	assert.Nil(t, SmokeTest(NewCachetImpl(ts.URL, "wrong", ts.Client()), false))
+	err := SmokeTest(NewCachetImpl(ts.URL, "wrong", ts.Client()), true)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "the CachetHQ token is refused"), err.Error())
+
+	// not a CachetHQ
+	other := httptest.NewServer(http.NotFoundHandler())
+	defer other.Close()
+	assert.NotNil(t, SmokeTest(NewCachetImpl(other.URL, token, other.Client()), false))
+	other.Close()
+	err = SmokeTest(NewCachetImpl(other.URL, token, other.Client()), false)
+	assert.True(t, strings.Contains(err.Error(), "CachetHQ is not reachable"), err.Error())
+}
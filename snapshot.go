@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var snapshotTemplate = template.Must(template.New("snapshot").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Status</title></head>
+<body>
+<h1>Status</h1>
+<p>The status page is unreachable: this is the status known by the monitoring on {{.GeneratedAt}}.</p>
+<table>
+{{- range .Components}}
+<tr><td>{{.Name}}</td><td>{{.StatusName}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// Snapshot is the minimal status page served while CachetHQ is unreachable
+type Snapshot struct {
+	GeneratedAt     string            `json:"generated_at"`
+	CachetReachable bool              `json:"cachet_reachable"`
+	Components      []CachedComponent `json:"components"`
+}
+
+// StatusSnapshot renders, every interval, the status of the components: the last known one (cf ComponentCache),
+// or a major outage if an alert processed since is firing on the component. While CachetHQ is unreachable, the
+// snapshot is served on its path (as HTML, or as JSON), else the path redirects to the CachetHQ status page
+type StatusSnapshot struct {
+	config     *PrometheusCachetConfig
+	path       string
+	statusPage string
+	interval   time.Duration
+
+	mutex sync.RWMutex
+	// the alerts firing on each component, by fingerprint (matched with the last known components)
+	firing    map[int]map[string]bool
+	reachable bool
+	snapshot  *Snapshot
+	html      []byte
+}
+
+// NewStatusSnapshot creates a new StatusSnapshot, served on path (the components being the ones of config.Components)
+func NewStatusSnapshot(config *PrometheusCachetConfig, path, statusPage string, interval time.Duration) *StatusSnapshot {
+	return &StatusSnapshot{
+		config:     config,
+		path:       path,
+		statusPage: strings.TrimSuffix(statusPage, "/"),
+		interval:   interval,
+		firing:     make(map[int]map[string]bool),
+		reachable:  true,
+	}
+}
+
+// Run checks CachetHQ and renders the snapshot every interval (and right away), until stop is closed
+func (s *StatusSnapshot) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.refresh()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh checks that CachetHQ is reachable, and renders the snapshot
+func (s *StatusSnapshot) refresh() {
+	err := s.config.Cachet.Ping()
+	if err != nil {
+		log.Println("CachetHQ is unreachable, the status snapshot is served on", s.path, ":", err)
+	}
+
+	components, _ := s.config.Components.Components()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := range components {
+		if len(s.firing[components[i].ID]) > 0 {
+			components[i].Status = 4
+			components[i].StatusName = componentStatusNames[4]
+		}
+	}
+	s.reachable = err == nil
+	s.snapshot = &Snapshot{
+		GeneratedAt:     time.Now().UTC().Format(time.RFC3339),
+		CachetReachable: s.reachable,
+		Components:      components,
+	}
+	var html bytes.Buffer
+	if err := snapshotTemplate.Execute(&html, s.snapshot); err != nil {
+		log.Println("not able to render the status snapshot:", err)
+	}
+	s.html = html.Bytes()
+}
+
+// record tracks the alerts of a payload, whether CachetHQ is reachable or not
+func (s *StatusSnapshot) record(alerts *PrometheusAlert) {
+	if s == nil {
+		return
+	}
+
+	components := s.config.Components.details()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := range alerts.Alerts {
+		alert := &alerts.Alerts[i]
+		// the group-wide alerts are ignored: the groups are not cached
+		for _, component := range MatchComponents(s.config, components, nil, alert) {
+			for _, componentID := range component.IDs() {
+				if alerts.Status == "firing" {
+					if s.firing[componentID] == nil {
+						s.firing[componentID] = make(map[string]bool)
+					}
+					s.firing[componentID][alert.fingerprint()] = true
+				} else {
+					delete(s.firing[componentID], alert.fingerprint())
+				}
+			}
+		}
+	}
+}
+
+// Serve answers with the snapshot while CachetHQ is unreachable (as JSON with format=json, or an Accept of
+// application/json), and redirects to the status page else
+func (s *StatusSnapshot) Serve(c *gin.Context) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.reachable || s.snapshot == nil {
+		c.Redirect(http.StatusTemporaryRedirect, s.statusPage)
+		return
+	}
+	c.Header("Cache-Control", "no-cache")
+	if c.Query("format") == "json" || strings.Contains(c.GetHeader("Accept"), "application/json") {
+		body, _ := json.Marshal(s.snapshot)
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", s.html)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusSnapshot(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	ts := httptest.NewServer(fake)
+
+	cache := NewComponentCache(NewCachetImpl(ts.URL, "token", ts.Client()), 0)
+	config := PrometheusCachetConfig{
+		LabelName:  "alertname",
+		Cachet:     cache,
+		Components: cache,
+	}
+	config.Snapshot = NewStatusSnapshot(&config, "/status", "https://status.example.com/", 0)
+	router := PrepareGinRouter(&config)
+	get := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// CachetHQ is reachable: its status page is the right one
+	_, err := cache.ListComponentsDetails()
+	assert.Nil(t, err)
+	config.Snapshot.refresh()
+	w := get("/status")
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://status.example.com", w.Header().Get("Location"))
+
+	// CachetHQ is down: the alerts still change the snapshot
+	ts.Close()
+	req, _ := http.NewRequest("POST", "/alert", bytes.NewBufferString(`{"version":"4","status":"firing","alerts":[{"labels":{"alertname":"API"}}]}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	config.Snapshot.refresh()
+
+	w = get("/status?format=json")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var snapshot Snapshot
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&snapshot))
+	assert.False(t, snapshot.CachetReachable)
+	assert.Equal(t, []CachedComponent{
+		{ID: 1, Name: "API", Status: 4, StatusName: "Major Outage"},
+		{ID: 2, Name: "WEB", Status: 1, StatusName: "Operational"},
+	}, snapshot.Components)
+
+	w = get("/status")
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<tr><td>API</td><td>Major Outage</td></tr>")
+
+	// once resolved
+	req, _ = http.NewRequest("POST", "/alert", bytes.NewBufferString(`{"version":"4","status":"resolved","alerts":[{"labels":{"alertname":"API"}}]}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	config.Snapshot.refresh()
+	assert.Contains(t, get("/status").Body.String(), "<tr><td>API</td><td>Operational</td></tr>")
+}
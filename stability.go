@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// STABILITY_CHECK_INTERVAL is how often the end of the stability windows is checked
+const STABILITY_CHECK_INTERVAL = 10 * time.Second
+
+// watchedResolve is a resolved alert, whose incident is "Watching" until the end of its stability window
+type watchedResolve struct {
+	payload *PrometheusAlert // with this alert only
+	until   time.Time
+	// the end of the window was reached (cf flush)
+	due bool
+}
+
+// StabilityWindow resolves the incidents in two phases (with squash_incident): on resolve, the incident is first set
+// as "Watching", and its component to an intermediate status. The incident is only fixed (and the component
+// operational) once the alert stayed resolved for the whole window, an alert firing again meanwhile re-opening it
+type StabilityWindow struct {
+	config          *PrometheusCachetConfig
+	window          time.Duration
+	componentStatus int
+	interval        time.Duration
+
+	mutex    sync.Mutex
+	resolves map[string]*watchedResolve // by component id and fingerprint
+}
+
+// NewStabilityWindow creates a new StabilityWindow. componentStatus is the status of the components while watched
+// (cf https://docs.cachethq.io/docs/component-statuses)
+func NewStabilityWindow(config *PrometheusCachetConfig, window time.Duration, componentStatus int, interval time.Duration) (*StabilityWindow, error) {
+	if componentStatus < 1 || componentStatus > 4 {
+		return nil, fmt.Errorf("resolve_component_status must be between 1 and 4")
+	}
+	return &StabilityWindow{
+		config:          config,
+		window:          window,
+		componentStatus: componentStatus,
+		interval:        interval,
+		resolves:        make(map[string]*watchedResolve),
+	}, nil
+}
+
+// Run fixes the incidents at the end of their stability window, until stop is closed
+func (s *StabilityWindow) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.flush(now)
+		}
+	}
+}
+
+// Watched returns the number of resolved alerts within their stability window
+func (s *StabilityWindow) Watched() int {
+	if s == nil {
+		return 0
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.resolves)
+}
+
+func stabilityKey(componentID int, alert *PrometheusAlertDetail) string {
+	return fmt.Sprintf("%d/%s", componentID, alert.fingerprint())
+}
+
+// watch is called on the resolve of the incident (written through config, the one processing the alert): it returns true
+// while the alert is within its stability window (the incident being set as "Watching" on the first call), and false once
+// the incident can be fixed
+func (s *StabilityWindow) watch(config *PrometheusCachetConfig, alerts *PrometheusAlert, alert *PrometheusAlertDetail, incident *CachetIncident, componentID int, componentName string, now time.Time) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	key := stabilityKey(componentID, alert)
+	s.mutex.Lock()
+	resolve, ok := s.resolves[key]
+	if ok && (resolve.due || !now.Before(resolve.until)) {
+		delete(s.resolves, key)
+		s.mutex.Unlock()
+		return false, nil
+	}
+	s.mutex.Unlock()
+	if ok {
+		return true, nil
+	}
+
+	message := fmt.Sprintf("Prometheus flagged service %s as up%s, watching it for %s", componentName, alertDescription(alert), humanizeDuration(s.window))
+	if err := config.Cachet.CreateIncidentUpdate(incident.Id, incidentStatuses["watching"], message); err != nil {
+		notifyError(config, "prometheus-cachethq: not able to update the CachetHQ incident of %s: %v", componentName, err)
+		return true, err
+	}
+	if err := config.Cachet.SetComponentStatus(componentID, s.componentStatus); err != nil {
+		notifyError(config, "prometheus-cachethq: not able to change the status of the CachetHQ component %s: %v", componentName, err)
+		return true, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.resolves[key] = &watchedResolve{
+		payload: &PrometheusAlert{
+			Version:  alerts.Version,
+			GroupKey: alerts.GroupKey,
+			Status:   alerts.Status,
+			Receiver: alerts.Receiver,
+			Alerts:   []PrometheusAlertDetail{*alert},
+		},
+		until: now.Add(s.window),
+	}
+	return true, nil
+}
+
+// reopen is called when the alert of an open incident fires (written through config): it returns true if the incident
+// was watched, and is identified again (its component being set back to componentStatus)
+func (s *StabilityWindow) reopen(config *PrometheusCachetConfig, alert *PrometheusAlertDetail, incident *CachetIncident, componentID int, componentName string, componentStatus int) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	key := stabilityKey(componentID, alert)
+	s.mutex.Lock()
+	resolve, ok := s.resolves[key]
+	delete(s.resolves, key)
+	s.mutex.Unlock()
+	if !ok {
+		return false, nil
+	}
+
+	message := fmt.Sprintf("Prometheus flagged service %s as down again%s", componentName, alertDescription(alert))
+	if err := config.Cachet.CreateIncidentUpdate(incident.Id, incidentStatuses["identified"], message); err != nil {
+		// still watched, until the next firing
+		s.mutex.Lock()
+		s.resolves[key] = resolve
+		s.mutex.Unlock()
+		notifyError(config, "prometheus-cachethq: not able to re-open the CachetHQ incident of %s: %v", componentName, err)
+		return false, err
+	}
+	if err := config.Cachet.SetComponentStatus(componentID, componentStatus); err != nil {
+		notifyError(config, "prometheus-cachethq: not able to change the status of the CachetHQ component %s: %v", componentName, err)
+		return true, err
+	}
+	return true, nil
+}
+
+// flush processes again the resolves at the end of their stability window, to fix their incident
+func (s *StabilityWindow) flush(now time.Time) {
+	s.mutex.Lock()
+	due := make(map[string]*watchedResolve)
+	for key, resolve := range s.resolves {
+		if !now.Before(resolve.until) {
+			resolve.due = true
+			due[key] = resolve
+		}
+	}
+	s.mutex.Unlock()
+
+	for key, resolve := range due {
+		if _, err := ProcessAlerts(s.config, resolve.payload); err != nil {
+			log.Println("not able to fix the CachetHQ incident of", resolve.payload.Alerts[0].Labels[s.config.LabelName], "at the end of its stability window:", err)
+			// retried on the next check
+			s.mutex.Lock()
+			s.resolves[key] = resolve
+			s.mutex.Unlock()
+		}
+	}
+}
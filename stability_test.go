@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStabilityWindow(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		FiringAlerts:   NewFiringAlerts(),
+		SquashIncident: true,
+	}
+	var err error
+	config.Stability, err = NewStabilityWindow(config, time.Hour, 2, 0)
+	assert.Nil(t, err)
+	send := func(status string) {
+		_, err := ProcessAlerts(config, &PrometheusAlert{
+			Version: "4",
+			Status:  status,
+			Alerts:  []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API"}}},
+		})
+		assert.Nil(t, err)
+	}
+
+	// resolved: watching first
+	send("firing")
+	send("resolved")
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, 3, fake.incidents[0].Status)
+	assert.Equal(t, 2, fake.components[0].Status)
+	assert.Equal(t, 1, config.Stability.Watched())
+
+	// bouncing: the same incident is identified again
+	send("firing")
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, 2, fake.incidents[0].Status)
+	assert.Equal(t, 4, fake.components[0].Status)
+	assert.Equal(t, 0, config.Stability.Watched())
+
+	// stable for the whole window: fixed
+	send("resolved")
+	config.Stability.flush(time.Now())
+	assert.Equal(t, 3, fake.incidents[0].Status)
+	config.Stability.flush(time.Now().Add(time.Hour))
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, 1, fake.components[0].Status)
+	assert.Equal(t, 0, config.Stability.Watched())
+
+	_, err = NewStabilityWindow(config, time.Hour, 5, 0)
+	assert.NotNil(t, err)
+}
+
+func TestStabilityWindowDryRun(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:       "alertname",
+		PrometheusToken: "promToken",
+		Cachet:          NewCachetImpl(ts.URL, "token", ts.Client()),
+		FiringAlerts:    NewFiringAlerts(),
+		SquashIncident:  true,
+	}
+	var err error
+	config.Stability, err = NewStabilityWindow(config, time.Hour, 2, 0)
+	assert.Nil(t, err)
+	_, err = ProcessAlerts(config, &PrometheusAlert{
+		Version: "4",
+		Status:  "firing",
+		Alerts:  []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API"}}},
+	})
+	assert.Nil(t, err)
+	status := fake.incidents[0].Status
+
+	// a dry run resolve neither watches, nor updates the incident
+	router := PrepareGinRouter(config)
+	req, _ := http.NewRequest("POST", "/test", bytes.NewBufferString(`{"component":"API","status":"resolved","dry_run":true}`))
+	req.Header.Set("Authorization", "Bearer "+config.PrometheusToken)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, config.Stability.Watched())
+	assert.Equal(t, status, fake.incidents[0].Status)
+	assert.Equal(t, 4, fake.components[0].Status)
+}
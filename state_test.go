@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	dsn := filepath.Join(dir, "state.db")
+
+	_, err = NewStateStore("redis", dsn)
+	assert.NotNil(t, err)
+
+	store, err := NewStateStore(STATE_BACKEND_SQLITE, dsn)
+	assert.Nil(t, err)
+	firing := NewFiringAlerts()
+	assert.Nil(t, firing.Restore(store))
+	firing.fire([]int{1, 2}, "abc")
+	firing.fire([]int{3}, "def")
+	firing.resolve([]int{2}, "abc")
+
+	history := NewHistory(2)
+	assert.Nil(t, history.Restore(store))
+	report := &ProcessReport{Alerts: []*AlertReport{{Components: []string{"API"}}}}
+	for _, name := range []string{"A", "B", "C"} {
+		history.Record(time.Now(), &PrometheusAlert{Status: "firing", Alerts: []PrometheusAlertDetail{{Labels: map[string]string{"alertname": name}}}}, report, nil)
+	}
+
+	queue := NewStoreAndForward(&PrometheusCachetConfig{}, 0)
+	assert.Nil(t, queue.Restore(store))
+	unreachable := &CachetHTTPError{StatusCode: 503}
+	queue.track(&PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{
+		{Labels: map[string]string{"alertname": "API"}},
+		{Labels: map[string]string{"alertname": "WEB"}},
+	}}, unreachable)
+	queue.track(&PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API"}}}}, nil)
+	queue.track(&PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "DB"}}}}, errors.New("not transient"))
+	assert.Nil(t, store.Close())
+
+	// after a restart (the migrations being applied once)
+	store, err = NewStateStore(STATE_BACKEND_SQLITE, dsn)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	firing = NewFiringAlerts()
+	assert.Nil(t, firing.Restore(store))
+	assert.True(t, firing.firing(1, "abc"))
+	assert.False(t, firing.firing(2, "abc"))
+	assert.True(t, firing.firing(3, "def"))
+
+	history = NewHistory(2)
+	assert.Nil(t, history.Restore(store))
+	entries := history.Entries("", 0)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "C", entries[0].Labels["alertname"])
+	assert.Equal(t, "B", entries[1].Labels["alertname"])
+
+	queue = NewStoreAndForward(&PrometheusCachetConfig{}, 0)
+	assert.Nil(t, queue.Restore(store))
+	pending := queue.pending()
+	assert.Equal(t, 1, len(pending))
+	assert.Equal(t, "WEB", pending[0].Alerts[0].Labels["alertname"])
+	assert.Equal(t, 2, queue.seq)
+}
+
+func TestStateStoreIncidents(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	store, err := NewStateStore(STATE_BACKEND_SQLITE, ":memory:")
+	assert.Nil(t, err)
+	defer store.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident: true,
+		State:          store,
+	}
+	alert := PrometheusAlertDetail{Labels: map[string]string{"alertname": "API"}}
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, store.incident(1, alert.fingerprint()))
+	assert.Equal(t, 0, store.incident(2, alert.fingerprint()))
+	// a dry run does not overwrite it
+	store.saveIncident(1, alert.fingerprint(), 0, time.Now())
+	assert.Equal(t, 1, store.incident(1, alert.fingerprint()))
+
+	// found without searching
+	incident, err := findAlertIncident(config, &alert, IncidentFilter{ComponentID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, incident.Id)
+
+	// the incident saved is not the one of the alert anymore: searched
+	fake.incidents[0].Message = "edited by hand"
+	incident, err = findAlertIncident(config, &alert, IncidentFilter{ComponentID: 1})
+	assert.Nil(t, err)
+	assert.Nil(t, incident)
+}
+
+func TestStateStoreRebind(t *testing.T) {
+	query := `SELECT incident_id FROM incidents WHERE component_id = ? AND fingerprint = ?`
+	assert.Equal(t, query, (&StateStore{backend: STATE_BACKEND_SQLITE}).rebind(query))
+	assert.Equal(t, `SELECT incident_id FROM incidents WHERE component_id = $1 AND fingerprint = $2`, (&StateStore{backend: STATE_BACKEND_POSTGRES}).rebind(query))
+}
+
+// needs a PostgreSQL database (ex: STATE_POSTGRES_DSN=postgres://postgres@localhost/test?sslmode=disable)
+func TestStateStorePostgres(t *testing.T) {
+	dsn := os.Getenv("STATE_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("STATE_POSTGRES_DSN not set")
+	}
+	replica1, err := NewStateStore(STATE_BACKEND_POSTGRES, dsn)
+	assert.Nil(t, err)
+	defer replica1.Close()
+	replica2, err := NewStateStore(STATE_BACKEND_POSTGRES, dsn)
+	assert.Nil(t, err)
+	defer replica2.Close()
+	replica1.exec(`DELETE FROM firing_alerts`)
+
+	// fired on a replica, resolved on the other one
+	firing1, firing2 := NewFiringAlerts(), NewFiringAlerts()
+	assert.Nil(t, firing1.Restore(replica1))
+	assert.Nil(t, firing2.Restore(replica2))
+	firing1.fire([]int{1}, "abc")
+	firing1.fire([]int{1}, "def")
+	assert.True(t, firing2.firing(1, "abc"))
+	assert.Equal(t, map[int]bool{1: true}, firing2.resolve([]int{1}, "abc"))
+	assert.Equal(t, map[int]bool{}, firing2.resolve([]int{1}, "def"))
+	assert.False(t, firing1.firing(1, "def"))
+
+	// the components are locked across the replicas
+	unlock := replica1.lockComponents([]int{1, 2})
+	locked := make(chan struct{})
+	go func() {
+		defer replica2.lockComponents([]int{2})()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("the component should be locked")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
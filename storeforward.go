@@ -0,0 +1,195 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// STORE_FORWARD_SIZE is the max number of alerts buffered while CachetHQ is unreachable (the oldest ones are dropped)
+const STORE_FORWARD_SIZE = 1000
+
+// bufferedAlert is the last state of an alert not forwarded to CachetHQ
+type bufferedAlert struct {
+	payload  *PrometheusAlert // with this alert only
+	seq      int
+	queuedAt time.Time // when the alert was first buffered
+}
+
+// StoreAndForward buffers the alerts whose forwarding failed on a transient CachetHQ error, and forwards them once
+// CachetHQ is reachable again. Only the last state of each alert is kept (i.e. an alert fired, then resolved, while
+// CachetHQ was unreachable is only resolved), and an alert forwarded since (by a new payload) is not buffered anymore
+type StoreAndForward struct {
+	config   *PrometheusCachetConfig
+	interval time.Duration
+
+	mutex   sync.Mutex
+	alerts  map[string]*bufferedAlert // by fingerprint
+	seq     int
+	dropped int
+	store   *StateStore // nil if not persisted
+}
+
+// NewStoreAndForward creates a new StoreAndForward, checking every interval if CachetHQ is back
+func NewStoreAndForward(config *PrometheusCachetConfig, interval time.Duration) *StoreAndForward {
+	return &StoreAndForward{
+		config:   config,
+		interval: interval,
+		alerts:   make(map[string]*bufferedAlert),
+	}
+}
+
+// Restore loads the alerts buffered in the store, and saves the next changes in it
+func (s *StoreAndForward) Restore(store *StateStore) error {
+	alerts, err := store.queued()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.alerts = alerts
+	for _, alert := range alerts {
+		if alert.seq > s.seq {
+			s.seq = alert.seq
+		}
+	}
+	s.store = store
+	return nil
+}
+
+// Run forwards the buffered alerts every interval, until stop is closed
+func (s *StoreAndForward) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+// Buffered returns the number of alerts waiting for CachetHQ
+func (s *StoreAndForward) Buffered() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.alerts)
+}
+
+// Oldest returns when the oldest buffered alert was first buffered (zero if none)
+func (s *StoreAndForward) Oldest() time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var oldest time.Time
+	for _, alert := range s.alerts {
+		if oldest.IsZero() || alert.queuedAt.Before(oldest) {
+			oldest = alert.queuedAt
+		}
+	}
+	return oldest
+}
+
+// track buffers the alerts of a payload not forwarded on a transient error, and forgets the ones forwarded
+func (s *StoreAndForward) track(alerts *PrometheusAlert, err error) {
+	if s == nil || (err != nil && !IsTransientError(err)) {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, alert := range alerts.Alerts {
+		fingerprint := alert.fingerprint()
+		if err == nil {
+			if _, ok := s.alerts[fingerprint]; ok {
+				s.store.deleteQueued(fingerprint)
+			}
+			delete(s.alerts, fingerprint)
+			continue
+		}
+
+		queuedAt := time.Now()
+		if buffered, ok := s.alerts[fingerprint]; ok {
+			queuedAt = buffered.queuedAt
+		} else if len(s.alerts) >= STORE_FORWARD_SIZE {
+			s.dropOldest()
+		}
+		payload := *alerts
+		payload.Alerts = []PrometheusAlertDetail{alert}
+		s.seq++
+		s.alerts[fingerprint] = &bufferedAlert{payload: &payload, seq: s.seq, queuedAt: queuedAt}
+		s.store.saveQueued(fingerprint, s.alerts[fingerprint])
+	}
+}
+
+func (s *StoreAndForward) dropOldest() {
+	oldest := ""
+	for fingerprint, alert := range s.alerts {
+		if oldest == "" || alert.seq < s.alerts[oldest].seq {
+			oldest = fingerprint
+		}
+	}
+	delete(s.alerts, oldest)
+	s.store.deleteQueued(oldest)
+	s.dropped++
+	log.Println("store and forward: buffer full, the oldest alert is dropped")
+}
+
+// pending returns the buffered payloads, oldest first
+func (s *StoreAndForward) pending() []*PrometheusAlert {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	buffered := make([]*bufferedAlert, 0, len(s.alerts))
+	for _, alert := range s.alerts {
+		buffered = append(buffered, alert)
+	}
+	sort.Slice(buffered, func(i, j int) bool { return buffered[i].seq < buffered[j].seq })
+
+	payloads := make([]*PrometheusAlert, 0, len(buffered))
+	for _, alert := range buffered {
+		payloads = append(payloads, alert.payload)
+	}
+	return payloads
+}
+
+// flush forwards the buffered alerts, oldest first, if CachetHQ is reachable (and the forwarding not paused).
+// It stops at the first transient error, the remaining alerts being forwarded on the next flush
+func (s *StoreAndForward) flush() {
+	payloads := s.pending()
+	if len(payloads) == 0 || s.config.Forwarding.Paused() {
+		return
+	}
+	if err := s.config.Cachet.Ping(); err != nil {
+		return
+	}
+
+	forwarded := 0
+	for _, payload := range payloads {
+		// PagerDuty/Opsgenie already got the alert when it was received
+		report, err := processAlerts(s.config, payload)
+		s.config.History.Record(time.Now(), payload, report, err)
+		s.track(payload, err)
+		if err != nil && IsTransientError(err) {
+			log.Println("store and forward: CachetHQ is unreachable again:", err)
+			break
+		}
+		forwarded++
+	}
+	log.Println("store and forward:", forwarded, "buffered alerts forwarded to CachetHQ")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreAndForward(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "WEB"})
+	down := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:    "alertname",
+		Cachet:       NewCachetImpl(ts.URL, "token", ts.Client()),
+		FiringAlerts: NewFiringAlerts(),
+	}
+	config.StoreAndForward = NewStoreAndForward(config, 0)
+	send := func(status, component string) error {
+		_, err := ProcessAlerts(config, &PrometheusAlert{
+			Version: "4",
+			Status:  status,
+			Alerts:  []PrometheusAlertDetail{{Labels: map[string]string{"alertname": component}}},
+		})
+		return err
+	}
+
+	// CachetHQ is unreachable: API fires, WEB fires and is resolved (only its resolve is kept)
+	assert.NotNil(t, send("firing", "API"))
+	assert.NotNil(t, send("firing", "WEB"))
+	assert.NotNil(t, send("resolved", "WEB"))
+	assert.Equal(t, 2, config.StoreAndForward.Buffered())
+
+	// still unreachable: nothing is lost
+	config.StoreAndForward.flush()
+	assert.Equal(t, 2, config.StoreAndForward.Buffered())
+
+	// CachetHQ is back
+	down = false
+	config.StoreAndForward.flush()
+	assert.Equal(t, 0, config.StoreAndForward.Buffered())
+	assert.Equal(t, 4, fake.components[0].Status)
+	assert.Equal(t, 1, fake.components[1].Status)
+	// the resolve of WEB is forwarded as if received live
+	assert.Equal(t, 2, len(fake.incidents))
+	assert.Equal(t, "API down", fake.incidents[0].Name)
+	assert.Equal(t, "WEB up", fake.incidents[1].Name)
+
+	// an alert forwarded by a new payload is not buffered anymore
+	down = true
+	assert.NotNil(t, send("resolved", "API"))
+	down = false
+	assert.Nil(t, send("resolved", "API"))
+	assert.Equal(t, 0, config.StoreAndForward.Buffered())
+}
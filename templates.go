@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"text/template"
+	"time"
+)
+
+// IncidentText is the text/template of the name and of the message of an incident (cf IncidentTemplateData),
+// the empty ones keeping the default wording. The prefix of the transition (ex: 🔴, [OUTAGE]) starts the default
+// messages, and is .Prefix for the templates
+type IncidentText struct {
+	Name    string `yaml:"name"`
+	Message string `yaml:"message"`
+	Prefix  string `yaml:"prefix"`
+
+	name    *template.Template
+	message *template.Template
+}
+
+// IncidentTemplates are the incident texts of each transition of an alert
+type IncidentTemplates struct {
+	// the incident created when the alert starts to fire
+	Firing *IncidentText `yaml:"firing"`
+	// the updates of the incident while the alert keeps firing (the escalation steps without message)
+	Updated *IncidentText `yaml:"updated"`
+	// the update of an open incident joined by another alert of the component (cf squash_incident)
+	Joined *IncidentText `yaml:"joined"`
+	// the resolution of the incident (a squashed incident keeps its firing name, if templated)
+	Resolved *IncidentText `yaml:"resolved"`
+}
+
+// IncidentTemplateData is what the incident templates are executed with
+type IncidentTemplateData struct {
+	Component string
+	// the prefix of the transition (cf IncidentText)
+	Prefix      string
+	Labels      map[string]string
+	Annotations map[string]string
+	// how long the alert has been firing (0 when not known yet), and the same humanized (ex: 3h 20m)
+	Duration time.Duration
+	Minutes  int
+	Downtime string
+}
+
+// templateFuncs are the functions available to the incident templates, besides the text/template ones
+var templateFuncs = template.FuncMap{
+	"humanizeDuration": humanizeDuration,
+}
+
+// humanizeDuration returns a duration with its two most significant units: 45s, 12m, 3h 20m, 2d 4h
+func humanizeDuration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	days, hours, minutes := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	switch {
+	case days > 0 && hours > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case days > 0:
+		return fmt.Sprintf("%dd", days)
+	case hours > 0 && minutes > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// NewIncidentTemplateData returns the data of the templates of an alert of the component, firing for duration
+func NewIncidentTemplateData(component string, alert *PrometheusAlertDetail, duration time.Duration) *IncidentTemplateData {
+	return &IncidentTemplateData{
+		Component:   component,
+		Labels:      alert.Labels,
+		Annotations: alert.Annotations,
+		Duration:    duration,
+		Minutes:     int(duration.Minutes()),
+		Downtime:    humanizeDuration(duration),
+	}
+}
+
+func (t *IncidentText) parse(transition string) error {
+	if t == nil {
+		return nil
+	}
+	var err error
+	if t.Name != "" {
+		if t.name, err = template.New(transition + ".name").Funcs(templateFuncs).Parse(t.Name); err != nil {
+			return err
+		}
+	}
+	if t.Message != "" {
+		if t.message, err = template.New(transition + ".message").Funcs(templateFuncs).Parse(t.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *IncidentTemplates) validate() error {
+	if t == nil {
+		return nil
+	}
+	for transition, text := range map[string]*IncidentText{"updated": t.Updated, "joined": t.Joined} {
+		if text != nil && text.Name != "" {
+			return fmt.Errorf("%s: only the message of an update can be templated", transition)
+		}
+	}
+	for transition, text := range map[string]*IncidentText{"firing": t.Firing, "updated": t.Updated, "joined": t.Joined, "resolved": t.Resolved} {
+		if err := text.parse(transition); err != nil {
+			return fmt.Errorf("%s: %v", transition, err)
+		}
+	}
+	return nil
+}
+
+// NewNameTemplates returns the templates naming the firing and the resolved incidents with the name template
+// (cf incident_name_template)
+func NewNameTemplates(name string) (*IncidentTemplates, error) {
+	templates := &IncidentTemplates{Firing: &IncidentText{Name: name}, Resolved: &IncidentText{Name: name}}
+	if err := templates.validate(); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// inherit returns the templates, completed with the defaults ones (i.e. the route ones completed with the global ones)
+func (t *IncidentTemplates) inherit(defaults *IncidentTemplates) *IncidentTemplates {
+	if t == nil {
+		return defaults
+	}
+	if defaults == nil {
+		return t
+	}
+	return &IncidentTemplates{
+		Firing:   t.Firing.inherit(defaults.Firing),
+		Updated:  t.Updated.inherit(defaults.Updated),
+		Joined:   t.Joined.inherit(defaults.Joined),
+		Resolved: t.Resolved.inherit(defaults.Resolved),
+	}
+}
+
+func (t *IncidentText) inherit(defaults *IncidentText) *IncidentText {
+	if t == nil {
+		return defaults
+	}
+	if defaults == nil {
+		return t
+	}
+	text := *t
+	if text.name == nil {
+		text.Name, text.name = defaults.Name, defaults.name
+	}
+	if text.message == nil {
+		text.Message, text.message = defaults.Message, defaults.message
+	}
+	if text.Prefix == "" {
+		text.Prefix = defaults.Prefix
+	}
+	return &text
+}
+
+// incidentTemplates returns the templates of an alert: the named ones selected by its annotation (cf
+// ANNOTATION_TEMPLATE), completed with the ones of its route (already completed with the global ones)
+func incidentTemplates(config *PrometheusCachetConfig, route *Route, alert *PrometheusAlertDetail) *IncidentTemplates {
+	templates := &IncidentTemplates{}
+	if route != nil && route.Templates != nil {
+		templates = route.Templates
+	} else if config.Templates != nil {
+		templates = config.Templates
+	}
+	return selectTemplates(config.NamedTemplates, templates, alert)
+}
+
+// selectTemplates returns the named templates selected by the annotation of the alert, completed with templates (or
+// templates alone, without annotation)
+func selectTemplates(named map[string]*IncidentTemplates, templates *IncidentTemplates, alert *PrometheusAlertDetail) *IncidentTemplates {
+	name := alert.Annotations[ANNOTATION_TEMPLATE]
+	if name == "" {
+		return templates
+	}
+	selected, ok := named[name]
+	if !ok {
+		log.Println("unknown incident templates", name, "of the", ANNOTATION_TEMPLATE, "annotation, keeping the default ones")
+		return templates
+	}
+	return selected.inherit(templates)
+}
+
+// render returns the name and the message of the incident: the executed templates, or the given defaults (the
+// message starting with the prefix)
+func (t *IncidentText) render(data *IncidentTemplateData, name, message string) (string, string) {
+	if t == nil {
+		return name, message
+	}
+	if t.Prefix != "" {
+		prefixed := *data
+		prefixed.Prefix = t.Prefix
+		data = &prefixed
+		if message != "" {
+			message = t.Prefix + " " + message
+		}
+	}
+	return execute(t.name, data, name), execute(t.message, data, message)
+}
+
+// execute returns the executed template, or fallback if none (or if the template fails)
+func execute(tmpl *template.Template, data *IncidentTemplateData, fallback string) string {
+	if tmpl == nil {
+		return fallback
+	}
+	var text bytes.Buffer
+	if err := tmpl.Execute(&text, data); err != nil {
+		log.Println("not able to execute the incident template", tmpl.Name(), ":", err)
+		return fallback
+	}
+	return text.String()
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigFileTemplates(t *testing.T) {
+	filename := writeConfigFile(t, `
+templates:
+  firing:
+    name: "{{ .Component }} degraded"
+    message: "{{ .Component }}: {{ .Annotations.summary }}"
+  resolved:
+    message: "{{ .Component }} is back"
+routes:
+  - name: batch
+    match:
+      team: data
+    templates:
+      resolved:
+        name: "{{ .Component }} completed"
+        message: "{{ .Component }} completed its run"
+  - name: default
+`)
+	defer os.Remove(filename)
+
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+
+	// the route templates are completed with the global ones
+	batch := configFile.Routes[0].Templates
+	assert.Equal(t, "{{ .Component }} degraded", batch.Firing.Name)
+	assert.Equal(t, "{{ .Component }} completed its run", batch.Resolved.Message)
+	assert.Equal(t, configFile.Templates, configFile.Routes[1].Templates)
+
+	data := NewIncidentTemplateData("etl", &PrometheusAlertDetail{Annotations: map[string]string{"summary": "late"}}, 0)
+	name, message := batch.Firing.render(data, "", "")
+	assert.Equal(t, "etl degraded", name)
+	assert.Equal(t, "etl: late", message)
+
+	// no updated template: the defaults
+	name, message = batch.Updated.render(data, "etl down", "still down")
+	assert.Equal(t, "etl down", name)
+	assert.Equal(t, "still down", message)
+
+	for _, invalid := range []string{
+		"templates:\n  firing:\n    name: '{{ .Component'\n",
+		"templates:\n  updated:\n    name: '{{ .Component }}'\n",
+		"routes:\n  - templates:\n      resolved:\n        message: '{{ end }}'\n",
+		"named_templates:\n  outage:\n    firing:\n      message: '{{ .Labels'\n",
+	} {
+		filename := writeConfigFile(t, invalid)
+		_, err := LoadConfigFile(filename, false)
+		assert.NotNil(t, err, invalid)
+		os.Remove(filename)
+	}
+}
+
+func TestIncidentTemplates(t *testing.T) {
+	templates := &IncidentTemplates{
+		Firing:   &IncidentText{Name: "{{ .Component }} run failed", Message: "The {{ .Labels.job }} run of {{ .Component }} failed"},
+		Resolved: &IncidentText{Name: "{{ .Component }} run succeeded", Message: "{{ .Component }} succeeded (after {{ .Minutes }} minutes)"},
+	}
+	assert.Nil(t, templates.validate())
+
+	fake := NewFakeCachet([]string{"etl"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName: "alertname",
+		Cachet:    NewCachetImpl(ts.URL, "token", ts.Client()),
+		Templates: templates,
+	}
+	alert := PrometheusAlertDetail{Labels: map[string]string{"alertname": "etl", "job": "nightly"}, Fingerprint: "abc123"}
+
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, "etl run failed", fake.incidents[0].Name)
+	assert.Equal(t, "The nightly run of etl failed"+IncidentMarker("abc123"), fake.incidents[0].Message)
+
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, "etl run succeeded", fake.incidents[1].Name)
+	assert.Equal(t, "etl succeeded (after 0 minutes)"+IncidentMarker("abc123"), fake.incidents[1].Message)
+
+	// squashed: the resolution message is an update of the (still open) firing incident
+	config.SquashIncident = true
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(fake.incidents))
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, "The nightly run of etl failed"+IncidentMarker("abc123"), fake.incidents[0].Message)
+	assert.Equal(t, 1, len(fake.updates))
+	assert.Equal(t, "etl succeeded (after 0 minutes)", fake.updates[0].Message)
+}
+
+func TestIncidentPrefixes(t *testing.T) {
+	filename := writeConfigFile(t, `
+templates:
+  firing:
+    prefix: "[OUTAGE]"
+  resolved:
+    prefix: "[RESOLVED]"
+routes:
+  - name: batch
+    match:
+      team: data
+    templates:
+      firing:
+        name: "{{ .Prefix }} {{ .Component }}"
+`)
+	defer os.Remove(filename)
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+
+	fake := NewFakeCachet([]string{"etl", "api"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident: true,
+		Templates:      configFile.Templates,
+		Routes:         configFile.Routes,
+	}
+	api := PrometheusAlertDetail{Labels: map[string]string{"alertname": "api"}}
+	etl := PrometheusAlertDetail{Labels: map[string]string{"alertname": "etl", "team": "data"}}
+
+	// the default messages start with the prefix, the templates place it themselves
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{api, etl}})
+	assert.Nil(t, err)
+	assert.Equal(t, "api down", fake.incidents[0].Name)
+	assert.True(t, strings.HasPrefix(fake.incidents[0].Message, "[OUTAGE] Prometheus flagged service api as down"), fake.incidents[0].Message)
+	assert.Equal(t, "[OUTAGE] etl", fake.incidents[1].Name)
+
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{api}})
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(fake.updates[0].Message, "[RESOLVED] Prometheus flagged service api as up"), fake.updates[0].Message)
+}
+
+func TestIncidentNameTemplate(t *testing.T) {
+	_, err := NewNameTemplates("{{ .Labels.service ")
+	assert.NotNil(t, err)
+	names, err := NewNameTemplates("{{ .Labels.service }} - {{ .Labels.alertname }}")
+	assert.Nil(t, err)
+
+	// the configuration file templates have priority
+	templates := &IncidentTemplates{Firing: &IncidentText{Message: "{{ .Annotations.summary }}"}}
+	assert.Nil(t, templates.validate())
+
+	fake := NewFakeCachet([]string{"api"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "component",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident: true,
+		Templates:      templates.inherit(names),
+	}
+	alert := PrometheusAlertDetail{
+		Labels:      map[string]string{"component": "api", "service": "Payments API", "alertname": "HighErrorRate"},
+		Annotations: map[string]string{"summary": "5% of 500s"},
+	}
+
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, "Payments API - HighErrorRate", fake.incidents[0].Name)
+	assert.Equal(t, "5% of 500s"+IncidentMarker(alert.fingerprint()), fake.incidents[0].Message)
+
+	// the squashed incident keeps its name (instead of "api up")
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, "Payments API - HighErrorRate", fake.incidents[0].Name)
+}
+
+func TestNamedTemplates(t *testing.T) {
+	filename := writeConfigFile(t, `
+templates:
+  firing:
+    name: "{{ .Component }} degraded"
+named_templates:
+  database-outage:
+    firing:
+      message: "The {{ .Component }} database is unavailable, the team is on it"
+    updated:
+      message: "The {{ .Component }} database is still unavailable"
+routes:
+  - name: critical
+    match:
+      severity: critical
+    escalation:
+      - after: 10m
+        status: identified
+`)
+	defer os.Remove(filename)
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+
+	fake := NewFakeCachet([]string{"orders", "payments", "web"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		Routes:         configFile.Routes,
+		Templates:      configFile.Templates,
+		NamedTemplates: configFile.NamedTemplates,
+	}
+	outage := map[string]string{ANNOTATION_TEMPLATE: "database-outage"}
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{
+		{Labels: map[string]string{"alertname": "orders"}, Annotations: outage},
+		{Labels: map[string]string{"alertname": "payments"}, Annotations: map[string]string{ANNOTATION_TEMPLATE: "unknown"}},
+		{Labels: map[string]string{"alertname": "web"}},
+	}})
+	assert.Nil(t, err)
+	// completed with the global templates
+	assert.Equal(t, "orders degraded", fake.incidents[0].Name)
+	assert.True(t, strings.HasPrefix(fake.incidents[0].Message, "The orders database is unavailable, the team is on it"))
+	assert.Equal(t, "payments degraded", fake.incidents[1].Name)
+	assert.True(t, strings.HasPrefix(fake.incidents[1].Message, "Prometheus flagged service payments as down"))
+	assert.True(t, strings.HasPrefix(fake.incidents[2].Message, "Prometheus flagged service web as down"))
+
+	// and the escalation updates
+	escalator := NewIncidentEscalator(config.Cachet, nil, time.Minute)
+	escalator.SetNamedTemplates(config.NamedTemplates)
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	escalator.Track(1, 1, "orders", []string{"orders"}, config.Routes[0], &PrometheusAlertDetail{StartAt: start.Format(time.RFC3339), Annotations: outage})
+	escalator.check(start.Add(10 * time.Minute))
+	assert.Equal(t, "The orders database is still unavailable", fake.updates[0].Message)
+}
+
+func TestLocales(t *testing.T) {
+	filename := writeConfigFile(t, `
+templates:
+  firing:
+    name: "{{ .Component }} degraded"
+    message: "{{ .Component }} is slow"
+  resolved:
+    message: "{{ .Component }} is back"
+locale: fr
+locales:
+  fr:
+    firing:
+      message: "{{ .Component }} est lent"
+  de:
+    firing:
+      message: "{{ .Component }} ist langsam"
+routes:
+  - name: germany
+    match:
+      region: de
+    locale: de
+    templates:
+      resolved:
+        message: "{{ .Component }} ist wieder da"
+  - name: default
+`)
+	defer os.Remove(filename)
+	configFile, err := LoadConfigFile(filename, false)
+	assert.Nil(t, err)
+
+	data := NewIncidentTemplateData("api", &PrometheusAlertDetail{}, 0)
+	// the locale of the status page, completed with the global templates
+	name, message := configFile.Templates.Firing.render(data, "", "")
+	assert.Equal(t, "api degraded", name)
+	assert.Equal(t, "api est lent", message)
+	_, message = configFile.Templates.Resolved.render(data, "", "")
+	assert.Equal(t, "api is back", message)
+	_, message = configFile.Routes[1].Templates.Firing.render(data, "", "")
+	assert.Equal(t, "api est lent", message)
+
+	// the locale of a route
+	germany := configFile.Routes[0].Templates
+	_, message = germany.Firing.render(data, "", "")
+	assert.Equal(t, "api ist langsam", message)
+	_, message = germany.Resolved.render(data, "", "")
+	assert.Equal(t, "api ist wieder da", message)
+
+	for _, invalid := range []string{
+		"locale: fr\n",
+		"locales:\n  fr:\n    firing:\n      message: '{{ .Component'\n",
+		"routes:\n  - locale: it\n",
+	} {
+		filename := writeConfigFile(t, invalid)
+		_, err := LoadConfigFile(filename, false)
+		assert.NotNil(t, err, invalid)
+		os.Remove(filename)
+	}
+}
+
+func TestIncidentEscalatorUpdatedTemplate(t *testing.T) {
+	fake := NewFakeCachet([]string{"etl"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	cachet := NewCachetImpl(ts.URL, "token", ts.Client())
+	incidentID, err := cachet.CreateIncident("etl", 1, 4, 4, IncidentOptions{Fingerprint: "abc123"})
+	assert.Nil(t, err)
+
+	templates := &IncidentTemplates{Updated: &IncidentText{Message: "{{ .Component }} is still running late ({{ .Duration }})"}}
+	assert.Nil(t, templates.validate())
+	route := &Route{Escalation: []*EscalationStep{{After: 10 * time.Minute, incidentStatus: 3}}, Templates: templates}
+
+	escalator := NewIncidentEscalator(cachet, nil, time.Minute)
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	escalator.Track(1, incidentID, "etl", []string{"etl"}, route, &PrometheusAlertDetail{StartAt: start.Format(time.RFC3339), Fingerprint: "abc123"})
+
+	escalator.check(start.Add(10 * time.Minute))
+	assert.Equal(t, 3, fake.incidents[0].Status)
+	assert.Equal(t, "etl is still running late (10m0s)", fake.updates[0].Message)
+}
+
+func TestHumanizeDuration(t *testing.T) {
+	assert.Equal(t, "0s", humanizeDuration(0))
+	assert.Equal(t, "45s", humanizeDuration(45*time.Second))
+	assert.Equal(t, "12m", humanizeDuration(12*time.Minute+30*time.Second))
+	assert.Equal(t, "3h", humanizeDuration(3*time.Hour))
+	assert.Equal(t, "3h 20m", humanizeDuration(3*time.Hour+20*time.Minute))
+	assert.Equal(t, "2d", humanizeDuration(48*time.Hour+10*time.Minute))
+	assert.Equal(t, "2d 4h", humanizeDuration(52*time.Hour))
+
+	text := &IncidentText{Message: "{{ .Component }} was down for {{ .Downtime }} ({{ humanizeDuration .Duration }})"}
+	assert.Nil(t, text.parse("resolved"))
+	_, message := text.render(NewIncidentTemplateData("api", &PrometheusAlertDetail{}, 90*time.Minute), "", "")
+	assert.Equal(t, "api was down for 1h 30m (1h 30m)", message)
+}
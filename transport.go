@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// CACHETHQ_RESPONSE_HEADER_TIMEOUT is how long to wait for the response headers of CachetHQ, once the request is sent
+const CACHETHQ_RESPONSE_HEADER_TIMEOUT = 20 * time.Second
+
+// NewCachetTransport creates the http transport shared by all the CachetHQ calls,
+// keeping up to maxIdleConnsPerHost connections (and their TLS sessions) alive for idleConnTimeout
+func NewCachetTransport(tlsConfig *tls.Config, maxIdleConnsPerHost int, idleConnTimeout time.Duration) *http.Transport {
+	if tlsConfig.ClientSessionCache == nil {
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	maxIdleConns := 100
+	if maxIdleConnsPerHost > maxIdleConns {
+		maxIdleConns = maxIdleConnsPerHost
+	}
+
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: CACHETHQ_RESPONSE_HEADER_TIMEOUT,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachetTransportKeepAlive(t *testing.T) {
+	var connections int32
+	ts := httptest.NewUnstartedServer(NewFakeCachet([]string{"API"}))
+	ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	tlsConfig := ts.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	transport := NewCachetTransport(tlsConfig, 5, time.Minute)
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, CACHETHQ_RESPONSE_HEADER_TIMEOUT, transport.ResponseHeaderTimeout)
+	assert.NotNil(t, transport.TLSClientConfig.ClientSessionCache)
+
+	cachet := NewCachetImpl(ts.URL, "token", &http.Client{Transport: transport})
+	for i := 0; i < 10; i++ {
+		_, err := cachet.ListComponents()
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connections))
+}
+
+func TestCachetTransportSessionCache(t *testing.T) {
+	cache := tls.NewLRUClientSessionCache(1)
+	transport := NewCachetTransport(&tls.Config{ClientSessionCache: cache}, 200, time.Minute)
+	assert.Equal(t, cache, transport.TLSClientConfig.ClientSessionCache)
+	assert.Equal(t, 200, transport.MaxIdleConns)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// cf https://github.com/prometheus/alertmanager/blob/master/api/v2/openapi.yaml
+type alertmanagerGroup struct {
+	Labels   map[string]string `json:"labels"`
+	Receiver struct {
+		Name string `json:"name"`
+	} `json:"receiver"`
+	Alerts []struct {
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+		StartsAt    time.Time         `json:"startsAt"`
+		EndsAt      time.Time         `json:"endsAt"`
+		Fingerprint string            `json:"fingerprint"`
+	} `json:"alerts"`
+}
+
+// AlertGroups reads the alert groups of the Alertmanager API, to complete the payloads truncated by Alertmanager
+// (the max_alerts of the webhook receiver): the truncated alerts may impact other components
+type AlertGroups struct {
+	alertmanager string
+	client       *http.Client
+}
+
+// NewAlertGroups creates a new AlertGroups of the Alertmanager at url
+func NewAlertGroups(alertmanager string) *AlertGroups {
+	return &AlertGroups{
+		alertmanager: strings.TrimSuffix(alertmanager, "/"),
+		client:       &http.Client{Timeout: ALERTMANAGER_TIMEOUT},
+	}
+}
+
+// handleTruncatedAlerts records the truncation of a payload, and completes its firing alerts from the Alertmanager API
+// (if enabled). The resolved alerts are not in the Alertmanager API anymore: they can not be completed
+func handleTruncatedAlerts(config *PrometheusCachetConfig, alerts *PrometheusAlert) {
+	if alerts.TruncatedAlerts <= 0 {
+		return
+	}
+	log.Println("Alertmanager truncated", alerts.TruncatedAlerts, "alerts of the", alerts.Status, "payload of", alerts.Receiver)
+	config.Metrics.TruncatedAlerts(alerts.Receiver, alerts.TruncatedAlerts)
+
+	if config.AlertGroups == nil || alerts.Status != "firing" {
+		return
+	}
+	added, err := config.AlertGroups.complete(alerts)
+	if err != nil {
+		notifyError(config, "prometheus-cachethq: not able to read the truncated alerts of %s from Alertmanager: %v", alerts.Receiver, err)
+		return
+	}
+	if config.debug() {
+		log.Println("read", added, "truncated alerts of", alerts.Receiver, "from Alertmanager")
+	}
+}
+
+// complete adds the alerts of the payload group missing from the payload, and returns how many were added
+func (g *AlertGroups) complete(alerts *PrometheusAlert) (int, error) {
+	group, err := g.group(alerts.Receiver, alerts.GroupLabels)
+	if err != nil || group == nil {
+		return 0, err
+	}
+
+	known := make(map[string]bool)
+	for _, alert := range alerts.Alerts {
+		known[alert.fingerprint()] = true
+	}
+	added := 0
+	for _, alert := range group.Alerts {
+		detail := PrometheusAlertDetail{
+			Labels:      alert.Labels,
+			Annotations: alert.Annotations,
+			StartAt:     alert.StartsAt.Format(time.RFC3339),
+			EndsAt:      alert.EndsAt.Format(time.RFC3339),
+			Fingerprint: alert.Fingerprint,
+		}
+		if known[detail.fingerprint()] {
+			continue
+		}
+		if detail.Annotations == nil {
+			detail.Annotations = make(map[string]string)
+		}
+		alerts.Alerts = append(alerts.Alerts, detail)
+		known[detail.fingerprint()] = true
+		added++
+	}
+	return added, nil
+}
+
+// group returns the active alert group of the receiver with the given labels (nil if gone meanwhile)
+func (g *AlertGroups) group(receiver string, labels map[string]string) (*alertmanagerGroup, error) {
+	query := url.Values{}
+	query.Set("receiver", "^(?:"+regexp.QuoteMeta(receiver)+")$")
+	query.Set("silenced", "false")
+	query.Set("inhibited", "false")
+	resp, err := g.client.Get(g.alertmanager + "/api/v2/alerts/groups?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Alertmanager returned %d: %s", resp.StatusCode, string(b))
+	}
+
+	var groups []*alertmanagerGroup
+	if err := json.NewDecoder(resp.Body).Decode(&groups); err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		if group.Receiver.Name == receiver && sameLabels(group.Labels, labels) {
+			return group, nil
+		}
+	}
+	return nil, nil
+}
+
+func sameLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if b[name] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleTruncatedAlerts(t *testing.T) {
+	am := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/alerts/groups", r.URL.Path)
+		assert.Equal(t, "^(?:cachethq)$", r.URL.Query().Get("receiver"))
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{
+				"labels":   map[string]string{"alertname": "other"},
+				"receiver": map[string]string{"name": "cachethq"},
+				"alerts":   []map[string]interface{}{{"labels": map[string]string{"alertname": "other"}, "fingerprint": "ccc"}},
+			},
+			{
+				"labels":   map[string]string{"team": "edge"},
+				"receiver": map[string]string{"name": "cachethq"},
+				"alerts": []map[string]interface{}{
+					{"labels": map[string]string{"alertname": "API", "team": "edge"}, "fingerprint": "aaa", "startsAt": "2020-01-01T12:00:00Z"},
+					{"labels": map[string]string{"alertname": "CDN", "team": "edge"}, "fingerprint": "bbb", "startsAt": "2020-01-01T12:00:00Z"},
+				},
+			},
+		})
+	}))
+	defer am.Close()
+
+	config := &PrometheusCachetConfig{Metrics: NewMetrics()}
+	alerts := &PrometheusAlert{
+		Version:         "4",
+		Status:          "firing",
+		Receiver:        "cachethq",
+		GroupLabels:     map[string]string{"team": "edge"},
+		Alerts:          []PrometheusAlertDetail{{Labels: map[string]string{"alertname": "API", "team": "edge"}, Fingerprint: "aaa"}},
+		TruncatedAlerts: 1,
+	}
+
+	// counted, but not completed by default
+	handleTruncatedAlerts(config, alerts)
+	assert.Equal(t, 1, len(alerts.Alerts))
+
+	config.AlertGroups = NewAlertGroups(am.URL)
+	handleTruncatedAlerts(config, alerts)
+	assert.Equal(t, 2, len(alerts.Alerts))
+	assert.Equal(t, "CDN", alerts.Alerts[1].Labels["alertname"])
+	assert.Equal(t, "bbb", alerts.Alerts[1].Fingerprint)
+	assert.Equal(t, "2020-01-01T12:00:00Z", alerts.Alerts[1].StartAt)
+	assert.NotNil(t, alerts.Alerts[1].Annotations)
+
+	// the resolved alerts are gone from Alertmanager
+	alerts.Status = "resolved"
+	alerts.Alerts = alerts.Alerts[:1]
+	handleTruncatedAlerts(config, alerts)
+	assert.Equal(t, 1, len(alerts.Alerts))
+
+	w := httptest.NewRecorder()
+	config.Metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Contains(t, string(body), `prometheus_cachethq_truncated_alerts_total{receiver="cachethq"} 3`)
+}
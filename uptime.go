@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// CACHET_TIME_LAYOUT is the format of the CachetHQ incident dates
+const CACHET_TIME_LAYOUT = "2006-01-02 15:04:05"
+
+// ParseCachetTime parses a CachetHQ date, expressed in the timezone of CachetHQ (UTC if loc is nil)
+func ParseCachetTime(value string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return time.ParseInLocation(CACHET_TIME_LAYOUT, value, loc)
+}
+
+// Outage is a period during which a component was down
+type Outage struct {
+	IncidentID int       `json:"incident_id"` // the incident opening the outage
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+}
+
+// ComponentUptime is the availability of a component over a window
+type ComponentUptime struct {
+	ID              int      `json:"id"`
+	Name            string   `json:"name"`
+	Availability    float64  `json:"availability"` // between 0 and 1
+	DowntimeSeconds int64    `json:"downtime_seconds"`
+	Outages         []Outage `json:"outages"`
+}
+
+// UptimeReport is the availability of the components over a window
+type UptimeReport struct {
+	From       time.Time          `json:"from"`
+	To         time.Time          `json:"to"`
+	Components []*ComponentUptime `json:"components"`
+}
+
+// DowntimeRow is one outage of a component, in the downtime report
+type DowntimeRow struct {
+	Component       string    `json:"component"`
+	IncidentID      int       `json:"incident_id"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationMinutes int       `json:"duration_minutes"`
+}
+
+// DowntimeRows flattens the outages of the report, per component
+func (r *UptimeReport) DowntimeRows() []*DowntimeRow {
+	rows := make([]*DowntimeRow, 0)
+	for _, component := range r.Components {
+		for _, outage := range component.Outages {
+			rows = append(rows, &DowntimeRow{
+				Component:       component.Name,
+				IncidentID:      outage.IncidentID,
+				Start:           outage.From,
+				End:             outage.To,
+				DurationMinutes: int(outage.To.Sub(outage.From).Minutes()),
+			})
+		}
+	}
+	return rows
+}
+
+// WriteDowntimeCSV writes the downtime report as CSV (with a header line)
+func WriteDowntimeCSV(w io.Writer, rows []*DowntimeRow) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"component", "incident_id", "start", "end", "duration_minutes"})
+	for _, row := range rows {
+		writer.Write([]string{
+			row.Component,
+			strconv.Itoa(row.IncidentID),
+			row.Start.Format(time.RFC3339),
+			row.End.Format(time.RFC3339),
+			strconv.Itoa(row.DurationMinutes),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ComputeOutages rebuilds the outages of a component from its CachetHQ incidents (dated in loc), as created by the bridge:
+// - in squash mode, an outage is one incident, from its creation to its last update once "Fixed"
+// - otherwise, an outage goes from a "down" incident to the next "Fixed" one
+// An outage still open at the end is considered to last until now. The incidents created by humans are ignored
+func ComputeOutages(incidents []*CachetIncident, loc *time.Location, now time.Time) []Outage {
+	sorted := make([]*CachetIncident, 0, len(incidents))
+	for _, incident := range incidents {
+		if IsBridgeIncident(incident) {
+			sorted = append(sorted, incident)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt < sorted[j].CreatedAt })
+
+	outages := make([]Outage, 0)
+	var downSince time.Time
+	var downIncident int
+	for _, incident := range sorted {
+		createdAt, err := ParseCachetTime(incident.CreatedAt, loc)
+		if err != nil {
+			continue
+		}
+
+		if incident.Status != 4 {
+			if downSince.IsZero() {
+				downSince = createdAt
+				downIncident = incident.Id
+			}
+			continue
+		}
+
+		// "Fixed"
+		if !downSince.IsZero() {
+			outages = append(outages, Outage{IncidentID: downIncident, From: downSince, To: createdAt})
+			downSince = time.Time{}
+		} else if updatedAt, err := ParseCachetTime(incident.UpdatedAt, loc); err == nil && updatedAt.After(createdAt) {
+			outages = append(outages, Outage{IncidentID: incident.Id, From: createdAt, To: updatedAt})
+		}
+	}
+	if !downSince.IsZero() {
+		outages = append(outages, Outage{IncidentID: downIncident, From: downSince, To: now})
+	}
+	return outages
+}
+
+// ComputeUptime returns the availability of a component over the [from, to] window, given its outages
+func ComputeUptime(outages []Outage, from, to time.Time) *ComponentUptime {
+	uptime := &ComponentUptime{Availability: 1, Outages: make([]Outage, 0)}
+
+	var downtime time.Duration
+	for _, outage := range outages {
+		// clip to the window
+		if outage.From.Before(from) {
+			outage.From = from
+		}
+		if outage.To.After(to) {
+			outage.To = to
+		}
+		if !outage.To.After(outage.From) {
+			continue
+		}
+		downtime += outage.To.Sub(outage.From)
+		uptime.Outages = append(uptime.Outages, outage)
+	}
+
+	if window := to.Sub(from); window > 0 {
+		uptime.Availability = 1 - float64(downtime)/float64(window)
+	}
+	uptime.DowntimeSeconds = int64(downtime.Seconds())
+	return uptime
+}
+
+// BuildUptimeReport computes the availability of the CachetHQ components (or only of componentName, if not empty)
+// over the [from, to] window. loc is the timezone of the CachetHQ dates
+func BuildUptimeReport(cachet Cachet, loc *time.Location, from, to time.Time, componentName string) (*UptimeReport, error) {
+	report := &UptimeReport{From: from, To: to, Components: make([]*ComponentUptime, 0)}
+
+	components, err := cachet.ListComponentsDetails()
+	if err != nil {
+		return report, err
+	}
+
+	now := time.Now().UTC()
+	for _, component := range components {
+		if componentName != "" && component.Name != componentName {
+			continue
+		}
+
+		incidents, err := cachet.SearchIncidents(IncidentFilter{ComponentID: component.Id})
+		if err != nil {
+			return report, err
+		}
+
+		uptime := ComputeUptime(ComputeOutages(incidents, loc, now), from, to)
+		uptime.ID = component.Id
+		uptime.Name = component.Name
+		report.Components = append(report.Components, uptime)
+	}
+	return report, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeOutagesSquash(t *testing.T) {
+	now := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	incidents := []*CachetIncident{
+		{Id: 2, Status: 2, Message: "Prometheus flagged service API as down", CreatedAt: "2020-01-01 20:00:00", UpdatedAt: "2020-01-01 20:00:00"},
+		{Id: 1, Status: 4, Message: "Prometheus flagged service API as up" + IncidentMarker("abc"), CreatedAt: "2020-01-01 10:00:00", UpdatedAt: "2020-01-01 10:30:00"},
+	}
+
+	outages := ComputeOutages(incidents, time.UTC, now)
+	assert.Equal(t, []Outage{
+		{IncidentID: 1, From: time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC), To: time.Date(2020, 1, 1, 10, 30, 0, 0, time.UTC)},
+		{IncidentID: 2, From: time.Date(2020, 1, 1, 20, 0, 0, 0, time.UTC), To: now},
+	}, outages)
+}
+
+func TestComputeOutagesNoSquash(t *testing.T) {
+	now := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	incidents := []*CachetIncident{
+		{Id: 1, Status: 2, Message: "Prometheus flagged service API as down", CreatedAt: "2020-01-01 10:00:00", UpdatedAt: "2020-01-01 10:00:00"},
+		{Id: 2, Status: 2, Message: "Prometheus flagged service API as down", CreatedAt: "2020-01-01 10:10:00", UpdatedAt: "2020-01-01 10:10:00"},
+		// created by a human
+		{Id: 3, Status: 4, Message: "API is back", CreatedAt: "2020-01-01 10:30:00", UpdatedAt: "2020-01-01 10:30:00"},
+		{Id: 4, Status: 4, Message: "Prometheus flagged service API as recovered", CreatedAt: "2020-01-01 11:00:00", UpdatedAt: "2020-01-01 11:00:00"},
+	}
+
+	outages := ComputeOutages(incidents, time.UTC, now)
+	assert.Equal(t, []Outage{
+		{IncidentID: 1, From: time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC), To: time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC)},
+	}, outages)
+}
+
+func TestComputeOutagesTimezone(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("no timezone database")
+	}
+	now := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	incidents := []*CachetIncident{
+		{Id: 1, Status: 4, Message: "Prometheus flagged service API as up", CreatedAt: "2020-01-01 10:00:00", UpdatedAt: "2020-01-01 10:30:00"},
+	}
+
+	// 10:00 in Paris is 09:00 UTC in winter
+	outages := ComputeOutages(incidents, paris, now)
+	assert.Equal(t, 1, len(outages))
+	assert.True(t, outages[0].From.Equal(time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)))
+}
+
+func TestComputeUptime(t *testing.T) {
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 1, 11, 0, 0, 0, 0, time.UTC)
+	outages := []Outage{
+		// half inside the window
+		{From: from.Add(-12 * time.Hour), To: from.Add(12 * time.Hour)},
+		{From: from.Add(48 * time.Hour), To: from.Add(60 * time.Hour)},
+		// outside the window
+		{From: to.Add(time.Hour), To: to.Add(2 * time.Hour)},
+	}
+
+	uptime := ComputeUptime(outages, from, to)
+	assert.Equal(t, int64(24*3600), uptime.DowntimeSeconds)
+	assert.InDelta(t, 0.9, uptime.Availability, 0.0001)
+	assert.Equal(t, 2, len(uptime.Outages))
+	assert.Equal(t, from, uptime.Outages[0].From)
+}
+
+func TestUptimeEndpoint(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "Web"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		PrometheusToken: "token",
+		LabelName:       "alertname",
+		Cachet:          NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident:  true,
+	}
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{
+		{Labels: map[string]string{"alertname": "API"}},
+	}})
+	assert.Nil(t, err)
+
+	// the incident started one hour ago
+	now := time.Now().UTC()
+	fake.incidents[0].CreatedAt = now.Add(-time.Hour).Format(CACHET_TIME_LAYOUT)
+
+	router := PrepareGinRouter(config)
+	url := fmt.Sprintf("/uptime?from=%s&to=%s", now.Add(-2*time.Hour).Format(time.RFC3339), now.Add(time.Hour).Format(time.RFC3339))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Authorization", "Bearer token")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	var report UptimeReport
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 2, len(report.Components))
+	assert.Equal(t, "API", report.Components[0].Name)
+	assert.InDelta(t, 0.5, report.Components[0].Availability, 0.01)
+	assert.Equal(t, float64(1), report.Components[1].Availability)
+
+	// wrong window
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/uptime?from=yesterday", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestDowntimeReportCSV(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		LabelName:      "alertname",
+		Cachet:         NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident: true,
+	}
+	alert := PrometheusAlertDetail{Labels: map[string]string{"alertname": "API"}}
+	_, err := ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "firing", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+	_, err = ProcessAlerts(config, &PrometheusAlert{Version: "4", Status: "resolved", Alerts: []PrometheusAlertDetail{alert}})
+	assert.Nil(t, err)
+
+	// the incident lasted 90 minutes, and was resolved 1 hour ago
+	now := time.Now().UTC()
+	fake.incidents[0].CreatedAt = now.Add(-150 * time.Minute).Format(CACHET_TIME_LAYOUT)
+	fake.incidents[0].UpdatedAt = now.Add(-60 * time.Minute).Format(CACHET_TIME_LAYOUT)
+
+	router := PrepareGinRouter(config)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/reports/downtime?format=csv", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, "component,incident_id,start,end,duration_minutes", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "API,1,"))
+	assert.True(t, strings.HasSuffix(lines[1], ",90"))
+
+	// unknown format
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/reports/downtime?format=xml", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// uptimeKumaNotification is the payload posted by the Uptime Kuma webhook notifications (as application/json)
+type uptimeKumaNotification struct {
+	Heartbeat *struct {
+		Status *int   `json:"status"` // 0 down, 1 up, 2 pending, 3 maintenance
+		Msg    string `json:"msg"`
+	} `json:"heartbeat"`
+	Monitor *struct {
+		ID   json.Number `json:"id"`
+		Name string      `json:"name"`
+		Tags []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"tags"`
+	} `json:"monitor"`
+	Msg string `json:"msg"`
+}
+
+const (
+	UPTIME_KUMA_DOWN = 0
+	UPTIME_KUMA_UP   = 1
+)
+
+// UptimeKumaAdapter translates the Uptime Kuma monitor notifications
+type UptimeKumaAdapter struct{}
+
+// Parse translates a monitor going down (or up again): the impacted component is the cachet_component tag, or the monitor name.
+// The test notifications (without monitor), and the pending (or maintenance) heartbeats are ignored
+func (a *UptimeKumaAdapter) Parse(config *PrometheusCachetConfig, body []byte) (*PrometheusAlert, error) {
+	var notification uptimeKumaNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, err
+	}
+	if notification.Monitor == nil && notification.Heartbeat == nil {
+		return nil, nil
+	}
+	if notification.Monitor == nil || notification.Monitor.ID == "" || notification.Heartbeat == nil || notification.Heartbeat.Status == nil {
+		return nil, fmt.Errorf("monitor id and heartbeat status are required")
+	}
+
+	status := *notification.Heartbeat.Status
+	if status != UPTIME_KUMA_DOWN && status != UPTIME_KUMA_UP {
+		return nil, nil
+	}
+
+	component := notification.Monitor.Name
+	for _, tag := range notification.Monitor.Tags {
+		if tag.Name == INPUT_COMPONENT_TAG && tag.Value != "" {
+			component = tag.Value
+		}
+	}
+	if component == "" {
+		return nil, fmt.Errorf("no monitor name nor %s tag", INPUT_COMPONENT_TAG)
+	}
+
+	alerts := newInputAlert(config, "uptimekuma", status == UPTIME_KUMA_DOWN, component, notification.Monitor.ID.String())
+	if notification.Heartbeat.Msg != "" {
+		alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY] = notification.Heartbeat.Msg
+	}
+	return alerts, nil
+}
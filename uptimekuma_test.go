@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUptimeKumaAdapter(t *testing.T) {
+	config := &PrometheusCachetConfig{LabelName: "alertname"}
+	adapter := &UptimeKumaAdapter{}
+
+	alerts, err := adapter.Parse(config, []byte(`{"heartbeat":{"monitorID":12,"status":0,"msg":"timeout of 48000ms exceeded"},"monitor":{"id":12,"name":"Website","tags":[]},"msg":"[Website] [Down] timeout"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "firing", alerts.Status)
+	assert.Equal(t, "Website", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "timeout of 48000ms exceeded", alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY])
+	assert.Equal(t, "uptimekuma:12", alerts.Alerts[0].fingerprint())
+
+	alerts, err = adapter.Parse(config, []byte(`{"heartbeat":{"monitorID":12,"status":1,"msg":"200 - OK"},"monitor":{"id":12,"name":"Website","tags":[{"name":"cachet_component","value":"API"}]}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "resolved", alerts.Status)
+	assert.Equal(t, "API", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "uptimekuma:12", alerts.Alerts[0].fingerprint())
+
+	// pending, and test notification
+	for _, payload := range []string{
+		`{"heartbeat":{"monitorID":12,"status":2},"monitor":{"id":12,"name":"Website"}}`,
+		`{"heartbeat":null,"monitor":null,"msg":"Uptime Kuma Testing"}`,
+	} {
+		alerts, err = adapter.Parse(config, []byte(payload))
+		assert.Nil(t, err)
+		assert.Nil(t, alerts)
+	}
+
+	_, err = adapter.Parse(config, []byte(`{"heartbeat":{"status":0},"monitor":{"name":"Website"}}`))
+	assert.NotNil(t, err)
+}
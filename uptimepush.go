@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// UPTIME_PUSH_INTERVAL is how often the UptimePusher checks if the previous day is pushed
+const UPTIME_PUSH_INTERVAL = 10 * time.Minute
+
+// UptimePusher pushes the daily availability (in percent) of components to CachetHQ metrics, for the uptime graphs
+// of the status page. The availability of a day is pushed once it is over, as a point dated at its start
+type UptimePusher struct {
+	config *PrometheusCachetConfig
+	// the metric id, by component name
+	metrics map[string]int
+
+	// the start of the last day pushed
+	pushed time.Time
+}
+
+// NewUptimePusher creates a new UptimePusher of the components metrics (metric id by component name)
+func NewUptimePusher(config *PrometheusCachetConfig, metrics map[string]int) *UptimePusher {
+	return &UptimePusher{config: config, metrics: metrics}
+}
+
+// Run pushes the availability of the previous day, every day (and right away), until stop is closed
+func (u *UptimePusher) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(UPTIME_PUSH_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		if err := u.push(time.Now()); err != nil {
+			log.Println("not able to push the daily uptime metrics:", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// push pushes the availability of the day before now (in the CachetHQ timezone), if not pushed yet
+func (u *UptimePusher) push(now time.Time) error {
+	loc := u.config.CachetLocation
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	to := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	from := to.AddDate(0, 0, -1)
+	if !from.After(u.pushed) {
+		return nil
+	}
+
+	report, err := BuildUptimeReport(u.config.Cachet, u.config.CachetLocation, from, to, "")
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool)
+	for _, component := range report.Components {
+		metricID, ok := u.metrics[component.Name]
+		if !ok {
+			continue
+		}
+		found[component.Name] = true
+		// 99.999 at most, so the graphs can tell an outage of a few seconds
+		value := math.Floor(component.Availability*100000) / 1000
+		if err := u.config.Cachet.AddMetricPoint(metricID, value, from); err != nil {
+			return fmt.Errorf("metric %d of %s: %v", metricID, component.Name, err)
+		}
+	}
+	for name := range u.metrics {
+		if !found[name] {
+			log.Println("no CachetHQ component", name, "for the daily uptime metrics")
+		}
+	}
+	u.pushed = from
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUptimePusher(t *testing.T) {
+	fake := NewFakeCachet([]string{"API", "Website"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	config := &PrometheusCachetConfig{LabelName: "alertname", Cachet: NewCachetImpl(ts.URL, "undefined", ts.Client())}
+
+	// API down from 06:00 to 12:00 the day before
+	now := time.Date(2020, 1, 2, 0, 30, 0, 0, time.UTC)
+	_, err := config.Cachet.CreateIncident("API", 1, 4, 4, IncidentOptions{Fingerprint: "a"})
+	assert.Nil(t, err)
+	_, err = config.Cachet.CreateIncident("API", 1, 1, 1, IncidentOptions{Fingerprint: "a"})
+	assert.Nil(t, err)
+	fake.incidents[0].CreatedAt = "2020-01-01 06:00:00"
+	fake.incidents[1].CreatedAt = "2020-01-01 12:00:00"
+	fake.incidents[1].Status = 4
+
+	pusher := NewUptimePusher(config, map[string]int{"API": 1, "Website": 2, "unknown": 3})
+	assert.Nil(t, pusher.push(now))
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	assert.Equal(t, []cachetHqMetricPoint{{Value: 75, Timestamp: day}}, fake.MetricPoints(1))
+	assert.Equal(t, []cachetHqMetricPoint{{Value: 100, Timestamp: day}}, fake.MetricPoints(2))
+
+	// once a day
+	assert.Nil(t, pusher.push(now.Add(10*time.Hour)))
+	assert.Equal(t, 1, len(fake.MetricPoints(1)))
+	assert.Nil(t, pusher.push(now.Add(24*time.Hour)))
+	assert.Equal(t, 2, len(fake.MetricPoints(1)))
+	assert.Equal(t, []cachetHqMetricPoint{{Value: 100, Timestamp: day + 86400}}, fake.MetricPoints(1)[1:])
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// FieldError is an invalid field of a payload (the field being its json path, ex: alerts[0].startsAt)
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError lists the invalid fields of a payload
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	fields := make([]string, 0, len(e))
+	for _, field := range e {
+		fields = append(fields, fmt.Sprintf("%s %s", field.Field, field.Reason))
+	}
+	return "invalid payload: " + strings.Join(fields, ", ")
+}
+
+// add records an invalid field
+func (e *ValidationError) add(field, reason string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Reason: fmt.Sprintf(reason, args...)})
+}
+
+// orNil returns the error, or nil if no field is invalid
+func (e ValidationError) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// useJSONFieldNames names the fields of the binding errors by their json name (instead of the go one)
+func useJSONFieldNames() {
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// decodingError translates the error of a json decoding (or of a gin binding) into the invalid fields, when possible
+func decodingError(err error) error {
+	switch err := err.(type) {
+	case *json.UnmarshalTypeError:
+		var fields ValidationError
+		fields.add(err.Field, "can not be a json %s", err.Value)
+		return fields
+	case validator.ValidationErrors:
+		var fields ValidationError
+		for _, field := range err {
+			switch field.Tag() {
+			case "required":
+				fields.add(field.Field(), "is required")
+			default:
+				fields.add(field.Field(), "is not valid (%s)", field.Tag())
+			}
+		}
+		return fields
+	case *json.SyntaxError:
+		return fmt.Errorf("not a json payload: %v", err)
+	}
+	if err == io.EOF {
+		return fmt.Errorf("empty payload")
+	}
+	if err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("not a json payload: truncated")
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CACHETHQ_VERSION_AUTO probes the CachetHQ version on startup
+const CACHETHQ_VERSION_AUTO = "auto"
+
+// CACHETHQ_VERSION_TIMEOUT bounds the probe of the CachetHQ version
+const CACHETHQ_VERSION_TIMEOUT = 10 * time.Second
+
+// CachetVersion is the version of the CachetHQ server
+type CachetVersion struct {
+	Raw   string
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseCachetVersion parses a "2.3.15" (or "v2.4.0-dev") CachetHQ version
+func ParseCachetVersion(raw string) (*CachetVersion, error) {
+	version := &CachetVersion{Raw: raw}
+
+	cleaned := strings.TrimPrefix(strings.TrimSpace(raw), "v")
+	if i := strings.IndexAny(cleaned, "-+ "); i >= 0 {
+		cleaned = cleaned[:i]
+	}
+
+	parts := strings.Split(cleaned, ".")
+	numbers := []*int{&version.Major, &version.Minor, &version.Patch}
+	for i, part := range parts {
+		if i >= len(numbers) {
+			break
+		}
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("unknown CachetHQ version '%s'", raw)
+		}
+		*numbers[i] = number
+	}
+	return version, nil
+}
+
+func (v *CachetVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast returns true if the version is at least major.minor. An unknown (nil) version is considered as the latest one
+func (v *CachetVersion) AtLeast(major, minor int) bool {
+	if v == nil {
+		return true
+	}
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+// Supported returns an error if the bridge can not talk to this CachetHQ version (the 2.x API, from 2.3)
+func (v *CachetVersion) Supported() error {
+	if v.Major != 2 || v.Minor < 3 {
+		return fmt.Errorf("CachetHQ %s is not supported (only the 2.x API, from 2.3, is)", v.Raw)
+	}
+	return nil
+}
+
+// cf https://docs.cachethq.io/reference#version
+type cachetHqVersion struct {
+	Data string `json:"data"`
+}
+
+// DetectVersion probes the version of CachetHQ: /api/v1/version for the 2.x API,
+// and /api/version (without a version in the path) for Cachet 3.x
+func (c *CachetImpl) DetectVersion() (*CachetVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), CACHETHQ_VERSION_TIMEOUT)
+	defer cancel()
+
+	var lastErr error
+	for _, path := range []string{"/api/v1/version", "/api/version"} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		c.prepare(req)
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != 200 {
+			lastErr = &CachetHTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+			continue
+		}
+
+		var message cachetHqVersion
+		if err := json.Unmarshal(body, &message); err != nil {
+			return nil, fmt.Errorf("%s is not a CachetHQ API: %v", c.apiURL, err)
+		}
+		return ParseCachetVersion(message.Data)
+	}
+	if IsTransientError(lastErr) {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("not able to find the CachetHQ version on %s: %v", c.apiURL, lastErr)
+}
+
+// SetVersion adapts the client to the CachetHQ version (nil for the latest one).
+// The bridge only talks the 2.x API: for now, only the stickied incidents (from 2.4) depend on the version
+func (c *CachetImpl) SetVersion(version *CachetVersion) {
+	c.version = version
+}
+
+// setCachetVersion adapts the CachetHQ client to the configured (or probed, with "auto") CachetHQ version,
+// and fails if it is not supported. If CachetHQ is unreachable (ex: restarted during an outage), the latest version is
+// assumed, so that the bridge still accepts (and buffers) the alerts
+func setCachetVersion(cachet *CachetImpl, configured string) error {
+	var version *CachetVersion
+	var err error
+	if configured == CACHETHQ_VERSION_AUTO {
+		version, err = cachet.DetectVersion()
+		if err != nil && IsTransientError(err) {
+			log.Println("warning: not able to probe the CachetHQ version (assuming the latest one):", err)
+			cachet.SetVersion(nil)
+			return nil
+		}
+	} else {
+		version, err = ParseCachetVersion(configured)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := version.Supported(); err != nil {
+		return err
+	}
+	log.Println("using CachetHQ", version)
+	cachet.SetVersion(version)
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCachetVersion(t *testing.T) {
+	version, err := ParseCachetVersion("2.3.15")
+	assert.Nil(t, err)
+	assert.Equal(t, "2.3.15", version.String())
+	assert.Nil(t, version.Supported())
+	assert.False(t, version.AtLeast(2, 4))
+
+	version, err = ParseCachetVersion("v2.4.0-dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "2.4.0", version.String())
+	assert.True(t, version.AtLeast(2, 4))
+
+	version, err = ParseCachetVersion("3.0")
+	assert.Nil(t, err)
+	assert.NotNil(t, version.Supported())
+
+	_, err = ParseCachetVersion("latest")
+	assert.NotNil(t, err)
+
+	// unknown is the latest
+	var unknown *CachetVersion
+	assert.True(t, unknown.AtLeast(2, 4))
+}
+
+func TestDetectVersion(t *testing.T) {
+	var v1 = true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/version" && v1:
+			io.WriteString(w, `{"meta":{"on_latest":true},"data":"2.3.18"}`)
+		case r.URL.Path == "/api/version" && !v1:
+			io.WriteString(w, `{"data":"3.0.0"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	cachet := NewCachetImpl(ts.URL, "token", ts.Client())
+	version, err := cachet.DetectVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, "2.3.18", version.String())
+
+	assert.Nil(t, setCachetVersion(cachet, CACHETHQ_VERSION_AUTO))
+	assert.Equal(t, 2, cachet.version.Major)
+
+	// Cachet 3.x
+	v1 = false
+	assert.NotNil(t, setCachetVersion(cachet, CACHETHQ_VERSION_AUTO))
+
+	// forced
+	assert.Nil(t, setCachetVersion(cachet, "2.4"))
+	assert.Equal(t, 4, cachet.version.Minor)
+	assert.NotNil(t, setCachetVersion(cachet, "1.0"))
+}
+
+func TestDetectVersionNotCachet(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	cachet := NewCachetImpl(ts.URL, "token", ts.Client())
+	assert.NotNil(t, setCachetVersion(cachet, CACHETHQ_VERSION_AUTO))
+
+	// unreachable: the latest version is assumed
+	ts.Close()
+	assert.Nil(t, setCachetVersion(cachet, "2.3"))
+	assert.Nil(t, setCachetVersion(cachet, CACHETHQ_VERSION_AUTO))
+	assert.Nil(t, cachet.version)
+	assert.Nil(t, setCachetVersion(cachet, "2.4"))
+}
+
+func TestStickiedNeedsCachet24(t *testing.T) {
+	var stickied bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var incident cachetHqIncident
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&incident))
+		stickied = incident.Stickied != nil && *incident.Stickied
+		io.WriteString(w, `{"data":{"id":1}}`)
+	}))
+	defer ts.Close()
+
+	cachet := NewCachetImpl(ts.URL, "token", ts.Client())
+	cachet.CreateIncident("API", 1, 4, 4, IncidentOptions{Stickied: true})
+	assert.True(t, stickied)
+
+	version, _ := ParseCachetVersion("2.3.0")
+	cachet.SetVersion(version)
+	cachet.CreateIncident("API", 1, 4, 4, IncidentOptions{Stickied: true})
+	assert.False(t, stickied)
+}
+
+func TestOnlyFiringIncidentsAreStickied(t *testing.T) {
+	var stickied *bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var incident cachetHqIncident
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&incident))
+		stickied = incident.Stickied
+		io.WriteString(w, `{"data":{"id":1}}`)
+	}))
+	defer ts.Close()
+
+	cachet := NewCachetImpl(ts.URL, "token", ts.Client())
+
+	// the "up" incident is not pinned
+	cachet.CreateIncident("API", 1, 1, 1, IncidentOptions{Stickied: true})
+	assert.Nil(t, stickied)
+
+	// resolving unpins the incident
+	assert.Nil(t, cachet.UpdateIncident("API", 1, 1, 1, "", "API is up"))
+	assert.NotNil(t, stickied)
+	assert.False(t, *stickied)
+
+	// but a firing update does not touch the flag
+	assert.Nil(t, cachet.UpdateIncident("API", 1, 1, 4, "", "API is down"))
+	assert.Nil(t, stickied)
+}
+
+func TestIncidentUpdatesNeedCachet24(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	cachet := NewCachetImpl(ts.URL, "token", ts.Client())
+	incidentID, err := cachet.CreateIncident("API", 1, 4, 4, IncidentOptions{Message: "API is down"})
+	assert.Nil(t, err)
+
+	// a timeline entry, the message is left alone
+	assert.Nil(t, cachet.CreateIncidentUpdate(incidentID, 3, "a fix is being deployed"))
+	assert.Equal(t, 1, len(fake.updates))
+	assert.Equal(t, "a fix is being deployed", fake.updates[0].Message)
+	assert.Equal(t, 3, fake.incidents[0].Status)
+	assert.Equal(t, "API is down", fake.incidents[0].Message)
+
+	// appended to the message before CachetHQ 2.4
+	version, _ := ParseCachetVersion("2.3.0")
+	cachet.SetVersion(version)
+	assert.Nil(t, cachet.UpdateIncident("API", 1, incidentID, 1, "", "API is up"))
+	assert.Equal(t, 1, len(fake.updates))
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, "API is down\n\nAPI is up", fake.incidents[0].Message)
+	assert.Equal(t, 1, fake.components[0].Status)
+}
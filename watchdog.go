@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	WATCHDOG_ACTION_LOG       = "log"
+	WATCHDOG_ACTION_NOTIFY    = "notify"
+	WATCHDOG_ACTION_EXEC      = "exec"
+	WATCHDOG_ACTION_READINESS = "readiness"
+)
+
+// CachetWatchdog periodically pings CachetHQ, and triggers the configured actions
+// when CachetHQ has been unreachable for more than a given delay
+type CachetWatchdog struct {
+	cachet    Cachet
+	notifier  Notifier
+	interval  time.Duration
+	threshold time.Duration
+	actions   map[string]bool
+	command   string
+
+	mutex        sync.Mutex
+	firstFailure time.Time
+	triggered    bool
+}
+
+// NewCachetWatchdog creates a new watchdog. actions is a comma separated list of
+// [log|notify|exec|readiness]
+func NewCachetWatchdog(cachet Cachet, notifier Notifier, interval, threshold time.Duration, actions string, command string) *CachetWatchdog {
+	w := &CachetWatchdog{
+		cachet:    cachet,
+		notifier:  notifier,
+		interval:  interval,
+		threshold: threshold,
+		actions:   make(map[string]bool),
+		command:   command,
+	}
+	for _, action := range strings.Split(actions, ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			w.actions[action] = true
+		}
+	}
+	return w
+}
+
+// Run pings CachetHQ every interval, until stop is closed
+func (w *CachetWatchdog) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			w.check(now, w.cachet.Ping())
+		}
+	}
+}
+
+// Ready returns false if CachetHQ is considered down, and the readiness action is enabled
+func (w *CachetWatchdog) Ready() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return !(w.triggered && w.actions[WATCHDOG_ACTION_READINESS])
+}
+
+func (w *CachetWatchdog) check(now time.Time, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err == nil {
+		if w.triggered {
+			w.fire(fmt.Sprintf("prometheus-cachethq: CachetHQ is reachable again (was down for %s)", humanizeDuration(now.Sub(w.firstFailure))), true)
+		}
+		w.firstFailure = time.Time{}
+		w.triggered = false
+		return
+	}
+
+	if w.firstFailure.IsZero() {
+		w.firstFailure = now
+	}
+
+	if !w.triggered && now.Sub(w.firstFailure) >= w.threshold {
+		w.triggered = true
+		w.fire(fmt.Sprintf("prometheus-cachethq: CachetHQ is unreachable for %d minutes: %v", int(now.Sub(w.firstFailure).Minutes()), err), false)
+	}
+}
+
+func (w *CachetWatchdog) fire(message string, recovered bool) {
+	if w.actions[WATCHDOG_ACTION_LOG] {
+		if recovered {
+			log.Println(message)
+		} else {
+			log.Println("ERROR:", message)
+		}
+	}
+
+	if w.actions[WATCHDOG_ACTION_NOTIFY] && w.notifier != nil {
+		go func() {
+			if err := w.notifier.Notify(message); err != nil {
+				log.Println("not able to send notification:", err)
+			}
+		}()
+	}
+
+	// the hook is only called when CachetHQ goes down
+	if w.actions[WATCHDOG_ACTION_EXEC] && w.command != "" && !recovered {
+		go func() {
+			cmd := exec.Command("sh", "-c", w.command)
+			cmd.Env = append(os.Environ(), fmt.Sprintf("WATCHDOG_MESSAGE=%s", message))
+			if out, err := cmd.CombinedOutput(); err != nil {
+				log.Println("watchdog hook failed:", err, string(out))
+			}
+		}()
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachetWatchdog(t *testing.T) {
+	notifier := &mockNotifier{messages: make(chan string, 2)}
+	watchdog := NewCachetWatchdog(nil, notifier, time.Minute, 5*time.Minute, "log, notify,readiness", "")
+
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	down := fmt.Errorf("connection refused")
+
+	// CachetHQ is down, but not long enough
+	watchdog.check(start, down)
+	watchdog.check(start.Add(4*time.Minute), down)
+	assert.True(t, watchdog.Ready())
+
+	// now it is
+	watchdog.check(start.Add(5*time.Minute), down)
+	assert.False(t, watchdog.Ready())
+	assert.Contains(t, <-notifier.messages, "unreachable for 5 minutes")
+
+	// already triggered: no new notification
+	watchdog.check(start.Add(6*time.Minute), down)
+	assert.Equal(t, 0, len(notifier.messages))
+
+	// back online
+	watchdog.check(start.Add(7*time.Minute), nil)
+	assert.True(t, watchdog.Ready())
+	assert.Contains(t, <-notifier.messages, "reachable again")
+}
+
+func TestCachetWatchdogWithoutReadiness(t *testing.T) {
+	watchdog := NewCachetWatchdog(nil, nil, time.Minute, time.Minute, "log", "")
+
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	watchdog.check(start, fmt.Errorf("connection refused"))
+	watchdog.check(start.Add(2*time.Minute), fmt.Errorf("connection refused"))
+	assert.True(t, watchdog.Ready())
+}
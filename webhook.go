@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+)
+
+// WEBHOOK_VERSION is the version of the Alertmanager webhook payload implemented by the bridge
+const WEBHOOK_VERSION = "4"
+
+const (
+	WEBHOOK_ACCEPTED   = "accepted"
+	WEBHOOK_COMPATIBLE = "compatible" // another version, read as a version 4 payload
+	WEBHOOK_REJECTED   = "rejected"
+)
+
+// decodeWebhook reads an Alertmanager webhook payload. The unknown fields are ignored, and a payload of another
+// (numeric) version is read as a version 4 one, as long as it has what the bridge needs: its status and its alerts
+func decodeWebhook(config *PrometheusCachetConfig, body io.Reader) (*PrometheusAlert, error) {
+	var alerts PrometheusAlert
+	if err := json.NewDecoder(body).Decode(&alerts); err != nil {
+		config.Metrics.WebhookPayload("", WEBHOOK_REJECTED)
+		return nil, decodingError(err)
+	}
+
+	if err := validateWebhook(&alerts); err != nil {
+		config.Metrics.WebhookPayload(alerts.Version, WEBHOOK_REJECTED)
+		return nil, err
+	}
+	if alerts.Version != WEBHOOK_VERSION {
+		log.Println("Alertmanager webhook payload version", alerts.Version, "read as a version", WEBHOOK_VERSION, "payload")
+		config.Metrics.WebhookPayload(alerts.Version, WEBHOOK_COMPATIBLE)
+		return &alerts, nil
+	}
+	config.Metrics.WebhookPayload(alerts.Version, WEBHOOK_ACCEPTED)
+	return &alerts, nil
+}
+
+// validateWebhook checks the fields of a webhook payload needed by the bridge (cf ValidationError)
+func validateWebhook(alerts *PrometheusAlert) error {
+	var fields ValidationError
+	if alerts.Version == "" {
+		fields.add("version", "is required (the Alertmanager webhook payload version, %s)", WEBHOOK_VERSION)
+	} else if _, err := strconv.Atoi(alerts.Version); err != nil {
+		fields.add("version", "'%s' is not supported (the bridge implements the version %s)", alerts.Version, WEBHOOK_VERSION)
+	}
+	if alerts.Status == "" {
+		fields.add("status", "is required (firing or resolved)")
+	} else if alerts.Status != "firing" && alerts.Status != "resolved" {
+		fields.add("status", "'%s' is unknown (firing or resolved)", alerts.Status)
+	}
+	if alerts.Alerts == nil {
+		fields.add("alerts", "is required")
+	}
+	for i, alert := range alerts.Alerts {
+		// the endsAt date is not used by the bridge
+		checkDate(&fields, fmt.Sprintf("alerts[%d].startsAt", i), alert.StartAt)
+	}
+	return fields.orNil()
+}
+
+func checkDate(fields *ValidationError, field, date string) {
+	if _, err := time.Parse(time.RFC3339, date); date != "" && err != nil {
+		fields.add(field, "'%s' is not a RFC 3339 date", date)
+	}
+}
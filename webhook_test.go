@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeWebhook(t *testing.T) {
+	config := &PrometheusCachetConfig{Metrics: NewMetrics()}
+
+	// the unknown fields are ignored
+	alerts, err := decodeWebhook(config, strings.NewReader(`{"version":"4","status":"firing","receiver":"cachethq","alerts":[{"labels":{"alertname":"API"},"newField":true}],"truncatedAlerts":0}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "API", alerts.Alerts[0].Labels["alertname"])
+
+	// a future version is read as a version 4 payload
+	alerts, err = decodeWebhook(config, strings.NewReader(`{"version":"5","status":"resolved","alerts":[]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "resolved", alerts.Status)
+
+	for payload, expected := range map[string]string{
+		`{"status":"firing","alerts":[]}`:                                       "version is required",
+		`{"version":"v2","status":"firing","alerts":[]}`:                        "version 'v2' is not supported",
+		`{"version":"4","status":"pending","alerts":[]}`:                        "status 'pending' is unknown",
+		`{"version":"4","status":"firing"}`:                                     "alerts is required",
+		`{"version":"4","status":"firing","alerts":1}`:                          "alerts can not be a json number",
+		`{"version":"4","status":"firing","alerts":[{"startsAt":"yesterday"}]}`: "alerts[0].startsAt 'yesterday' is not a RFC 3339 date",
+		`{"version":"4","status":"firing","alerts":[{"la`:                       "not a json payload",
+		``: "empty payload",
+	} {
+		_, err := decodeWebhook(config, strings.NewReader(payload))
+		if assert.NotNil(t, err, payload) {
+			assert.Contains(t, err.Error(), expected)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	config.Metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Contains(t, string(body), `prometheus_cachethq_webhook_payloads_total{result="accepted",version="4"} 1`)
+	assert.Contains(t, string(body), `prometheus_cachethq_webhook_payloads_total{result="compatible",version="5"} 1`)
+	assert.Contains(t, string(body), `prometheus_cachethq_webhook_payloads_total{result="rejected",version="4"} 3`)
+	assert.Contains(t, string(body), `prometheus_cachethq_webhook_payloads_total{result="rejected",version=""} 4`)
+}
+
+func TestDecodeWebhookFields(t *testing.T) {
+	_, err := decodeWebhook(&PrometheusCachetConfig{}, strings.NewReader(`{"status":"up","alerts":[{"labels":{"alertname":"API"},"startsAt":"2020-01-01T12:00:00Z"},{"startsAt":"12:00"}]}`))
+	assert.Equal(t, ValidationError{
+		{Field: "version", Reason: "is required (the Alertmanager webhook payload version, 4)"},
+		{Field: "status", Reason: "'up' is unknown (firing or resolved)"},
+		{Field: "alerts[1].startsAt", Reason: "'12:00' is not a RFC 3339 date"},
+	}, err)
+}
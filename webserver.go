@@ -1,9 +1,17 @@
 package main
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	httppprof "net/http/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -36,6 +44,42 @@ type PrometheusAlertDetail struct {
 	Annotations map[string]string `json:"annotations"`
 	StartAt     string            `json:"startsAt"`
 	EndsAt      string            `json:"endsAt"`
+	Fingerprint string            `json:"fingerprint"`
+}
+
+// firingSince returns when the alert started to fire (or now, if unknown)
+func (a *PrometheusAlertDetail) firingSince() time.Time {
+	if startsAt, err := time.Parse(time.RFC3339, a.StartAt); err == nil {
+		return startsAt
+	}
+	return time.Now()
+}
+
+// annotation returns the value of an annotation (empty if name is empty, i.e. not configured)
+func (a *PrometheusAlertDetail) annotation(name string) string {
+	if name == "" {
+		return ""
+	}
+	return a.Annotations[name]
+}
+
+// fingerprint identifies the alert: the Alertmanager fingerprint, or (older Alertmanagers) a hash of its labels
+func (a *PrometheusAlertDetail) fingerprint() string {
+	if a.Fingerprint != "" {
+		return a.Fingerprint
+	}
+
+	names := make([]string, 0, len(a.Labels))
+	for name := range a.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha1.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s\xff%s\xff", name, a.Labels[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
 }
 
 type PrometheusAlert struct {
@@ -48,136 +92,747 @@ type PrometheusAlert struct {
 	CommonAnnotations map[string]string       `json:"commonAnnotations"`
 	ExternalURL       string                  `json:"externalURL"`
 	Alerts            []PrometheusAlertDetail `json:"alerts"`
+	// the number of alerts removed from the payload by Alertmanager (cf max_alerts)
+	TruncatedAlerts int `json:"truncatedAlerts"`
 }
 
-// SubmitAlert receive an alert from Prometheus, and try to forward it to CachetHQ
-func SubmitAlert(c *gin.Context, config *PrometheusCachetConfig) {
-	// check the Bearer
+// AUTHENTICATION_REALM is the realm of the authentication challenges (cf WWW-Authenticate)
+const AUTHENTICATION_REALM = "prometheus-cachethq"
+
+// checkAuthorization checks the Bearer sent by Prometheus, and answers with a 401 if it is missing or wrong
+func checkAuthorization(c *gin.Context, config *PrometheusCachetConfig) bool {
 	if config.PrometheusToken != "" {
 		bearer := c.GetHeader("Authorization")
+		if bearer == "" {
+			answerUnauthorized(c, fmt.Sprintf(`Bearer realm="%s"`, AUTHENTICATION_REALM), "missing Authorization header")
+			return false
+		}
 		if bearer != fmt.Sprintf("Bearer %s", config.PrometheusToken) {
-			if config.LogLevel == LOG_DEBUG {
+			if config.debug() {
 				log.Println("wrong Authorization header:", bearer)
 			}
-			c.JSON(http.StatusBadRequest, gin.H{"error": "wrong Authorization header"})
-			return
+			answerUnauthorized(c, fmt.Sprintf(`Bearer realm="%s", error="invalid_token"`, AUTHENTICATION_REALM), "wrong Authorization header")
+			return false
 		}
 	}
+	return true
+}
 
-	// read the payload
-	var alerts PrometheusAlert
-	if err := c.ShouldBindJSON(&alerts); err == nil {
-		// talk to CachetHQ
-		status := 1 // "resolved"
-		componentStatus := 1
-		if alerts.Status == "firing" {
-			status = 4
-			componentStatus = 4
+// checkMetricsAuthorization checks the credentials of /metrics, if any: a basic authentication (metrics_username
+// and metrics_password), or a Bearer (metrics_token)
+func checkMetricsAuthorization(c *gin.Context, config *PrometheusCachetConfig) bool {
+	if config.MetricsUsername == "" && config.MetricsToken == "" {
+		return true
+	}
+	if username, password, ok := c.Request.BasicAuth(); ok && config.MetricsUsername != "" {
+		if username == config.MetricsUsername && password == config.MetricsPassword {
+			return true
 		}
+	}
+	if config.MetricsToken != "" && c.GetHeader("Authorization") == fmt.Sprintf("Bearer %s", config.MetricsToken) {
+		return true
+	}
 
-		list, err := config.Cachet.ListComponents()
-		if err != nil {
-			if config.LogLevel == LOG_DEBUG {
-				log.Println(err)
-			}
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+	if config.MetricsUsername != "" {
+		c.Writer.Header().Add("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, AUTHENTICATION_REALM))
+	}
+	if config.MetricsToken != "" {
+		c.Writer.Header().Add("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s"`, AUTHENTICATION_REALM))
+	}
+	detail := "wrong credentials"
+	if c.GetHeader("Authorization") == "" {
+		detail = "missing Authorization header"
+	}
+	answerProblem(c, http.StatusUnauthorized, PROBLEM_UNAUTHORIZED, detail)
+	return false
+}
+
+// answerUnauthorized answers with a 401, challenging the client to authenticate (cf RFC 7235)
+func answerUnauthorized(c *gin.Context, challenge, detail string) {
+	c.Writer.Header().Add("WWW-Authenticate", challenge)
+	answerProblem(c, http.StatusUnauthorized, PROBLEM_UNAUTHORIZED, detail)
+}
+
+// checkAdminAuthorization checks the token of the /admin endpoints, never served without a prometheus_token
+func checkAdminAuthorization(c *gin.Context, config *PrometheusCachetConfig) bool {
+	if config.PrometheusToken == "" {
+		answerProblem(c, http.StatusForbidden, PROBLEM_FORBIDDEN, "the admin endpoints are only served with a prometheus_token")
+		return false
+	}
+	return checkAuthorization(c, config)
+}
+
+// cachetErrorStatus returns the http status code to answer when CachetHQ failed:
+// config.CachetErrorStatus for transient errors (so Alertmanager can retry), 400 otherwise
+func cachetErrorStatus(config *PrometheusCachetConfig, err error) int {
+	if IsTransientError(err) && config.CachetErrorStatus != 0 {
+		return config.CachetErrorStatus
+	}
+	return http.StatusBadRequest
+}
+
+// SubmitAlert receive an alert from Prometheus, and try to forward it to CachetHQ
+func SubmitAlert(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAuthorization(c, config) {
+		return
+	}
+
+	// read the payload (from its CloudEvent, if wrapped)
+	body, err := webhookBody(c, config)
+	if err != nil {
+		if config.debug() {
+			log.Println(err)
+		}
+		answerInvalidPayload(c, err)
+		return
+	}
+	alerts, err := decodeWebhook(config, body)
+	if err != nil {
+		if config.debug() {
+			log.Println(err)
 		}
+		answerInvalidPayload(c, err)
+		return
+	}
+	handleTruncatedAlerts(config, alerts)
+	forwardAlerts(c, config, alerts)
+}
 
-		// prometheus can send 2 times the same alerts info in one call
-		alreadyFired := make(map[int]int)
-		for _, alert := range alerts.Alerts {
-			// fire something
-			if componentID, ok := list[alert.Labels[config.LabelName]]; ok {
-				if alreadyFired[componentID] == 0 {
-					alreadyFired[componentID] = 1
-
-					if config.SquashIncident {
-						// firing
-						if status != 1 {
-							incidents, err := config.Cachet.SearchIncidents(componentID)
-							if err != nil {
-								c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-								return
-							}
-							// if no open incident currently, let's create a new one
-							if len(incidents) == 0 || incidents[0].Status == 4 {
-								if err := config.Cachet.CreateIncident(alert.Labels[config.LabelName], componentID, status, componentStatus); err != nil {
-									if config.LogLevel == LOG_DEBUG {
-										log.Println(err)
-									}
-									c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-									return
-								}
-							}
-						} else { // resolved
-							// if we want to "squash" event for a given incident
-							if incidents, err := config.Cachet.SearchIncidents(componentID); err == nil {
-								if len(incidents) > 0 {
-									if err == nil {
-										config.Cachet.UpdateIncident(alert.Labels[config.LabelName], componentID, incidents[0].Id, status, fmt.Sprintf("Prometheus flagged service %s as up", alert.Labels[config.LabelName]))
-
-										incidentID := incidents[0].Id
-										componentName := alert.Labels[config.LabelName]
-
-										if incident, err := config.Cachet.ReadIncident(incidentID); err == nil {
-											layout := "2006-01-02 15:04:05"
-											createdAt, err1 := time.Parse(layout, incident.CreatedAt)
-											updatedAt, err2 := time.Parse(layout, incident.UpdatedAt)
-
-											if err1 == nil && err2 == nil {
-												config.Cachet.UpdateIncident(componentName, componentID, incidentID, status, fmt.Sprintf("Prometheus flagged service %s as up (service was down for %d minutes)", componentName, int(updatedAt.Sub(createdAt).Minutes())))
-											}
-										}
-									} else {
-										c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-										return
-									}
-								} else {
-									c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("No incident found for component %d\n", componentID)})
-									return
-								}
-							} else {
-								c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-								return
-							}
-						}
-					} else { // we dont 'squash' so let's create a new incident
-						if err := config.Cachet.CreateIncident(alert.Labels[config.LabelName], componentID, status, componentStatus); err != nil {
-							if config.LogLevel == LOG_DEBUG {
-								log.Println(err)
-							}
-							c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-							return
-						}
-					}
-				}
+// forwardAlerts forwards a payload (received from Alertmanager, or translated by an input adapter) to CachetHQ,
+// and answers with the aggregate result
+func forwardAlerts(c *gin.Context, config *PrometheusCachetConfig, alerts *PrometheusAlert) {
+	if config.Features.Enabled(FEATURE_ASYNC_PIPELINE) {
+		go func() {
+			if _, _, err := dispatchAlerts(config, alerts); err != nil {
+				log.Println("payload of", alerts.Receiver, "not forwarded:", err)
 			}
+		}()
+		c.JSON(http.StatusAccepted, gin.H{"status": "accepted"})
+		return
+	}
+
+	report, queued, err := dispatchAlerts(config, alerts)
+	if queued {
+		c.JSON(http.StatusAccepted, gin.H{"status": "paused"})
+		return
+	}
+	if err != nil {
+		problem := NewProblem(c, cachetErrorStatus(config, err), PROBLEM_CACHETHQ, err.Error())
+		problem.Alerts = report.Alerts
+		problem.Send(c)
+		return
+	}
+
+	// one aggregate result for the whole payload
+	c.JSON(http.StatusOK, gin.H{"status": "OK", "alerts": report.Alerts})
+}
+
+// dispatchAlerts forwards a payload to CachetHQ, or queues it (queued is then true) while the forwarding is paused
+func dispatchAlerts(config *PrometheusCachetConfig, alerts *PrometheusAlert) (*ProcessReport, bool, error) {
+	config.Events.Publish(&Event{Type: EVENT_WEBHOOK, Receiver: alerts.Receiver, Status: alerts.Status, Alerts: len(alerts.Alerts)})
+	if config.debug() {
+		for _, alert := range alerts.Alerts {
+			log.Println("received", alerts.Status, "alert for", alerts.Receiver, ":", alert.Labels)
 		}
+	}
+	config.Relabeling.apply(alerts)
+	if config.Forwarding.enqueue(alerts) {
+		log.Println("forwarding paused: payload of", alerts.Receiver, "queued")
+		return nil, true, nil
+	}
 
-	} else {
-		if config.LogLevel == LOG_DEBUG {
+	report, err := ProcessAlerts(config, alerts)
+	if err != nil && config.debug() {
+		log.Println(err)
+	}
+	// rate limited: replayed once the Retry-After is over, instead of failing the payload
+	if _, limited := RateLimited(err); limited && config.Forwarding.enqueue(alerts) {
+		log.Println("CachetHQ is rate limiting: payload of", alerts.Receiver, "queued")
+		return report, true, nil
+	}
+	return report, false, err
+}
+
+// TestAlert is the payload of the /test endpoint
+type TestAlert struct {
+	Component   string            `json:"component" binding:"required"`
+	Status      string            `json:"status" binding:"required"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	DryRun      bool              `json:"dry_run"`
+}
+
+// TestReport is the answer of the /test endpoint
+type TestReport struct {
+	DryRun     bool     `json:"dry_run"`
+	Components []string `json:"components"`
+	Actions    []string `json:"actions"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// SubmitTestAlert simulates an alert for one component, runs it through the real pipeline, and reports what happened
+func SubmitTestAlert(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAuthorization(c, config) {
+		return
+	}
+
+	var test TestAlert
+	if err := c.ShouldBindJSON(&test); err != nil {
+		answerInvalidPayload(c, decodingError(err))
+		return
+	}
+	if test.Status != "firing" && test.Status != "resolved" {
+		answerProblem(c, http.StatusBadRequest, PROBLEM_INVALID_PAYLOAD, "status must be firing or resolved")
+		return
+	}
+	if !test.DryRun && config.Forwarding.Paused() {
+		answerProblem(c, http.StatusConflict, PROBLEM_PAUSED, "the forwarding to CachetHQ is paused (only dry runs are possible)")
+		return
+	}
+
+	labels := make(map[string]string)
+	for label, value := range test.Labels {
+		labels[label] = value
+	}
+	labels[config.LabelName] = test.Component
+
+	alerts := &PrometheusAlert{
+		Version: "4",
+		Status:  test.Status,
+		Alerts: []PrometheusAlertDetail{
+			{
+				Labels:      labels,
+				Annotations: test.Annotations,
+				StartAt:     time.Now().Format(time.RFC3339),
+			},
+		},
+	}
+
+	recorder := NewRecordingCachet(config.Cachet, test.DryRun)
+	testConfig := *config
+	testConfig.Cachet = recorder
+	// a test alert never pages (and is not counted in the alert volume)
+	testConfig.PagerDuty = nil
+	testConfig.Opsgenie = nil
+	testConfig.AlertVolume = nil
+	if test.DryRun {
+		// no incident to follow, nothing done (and nothing to notify)
+		testConfig.Escalator = nil
+		testConfig.History = nil
+		testConfig.Notifier = nil
+		testConfig.Metrics = nil
+		testConfig.FiringAlerts = nil
+		testConfig.ComponentDetails = nil
+		testConfig.Events = nil
+		// the public snapshot only shows the real alerts
+		testConfig.Snapshot = nil
+		// neither buffered, nor clearing a buffered alert of the same fingerprint
+		testConfig.StoreAndForward = nil
+		// no external command run for a simulated incident
+		testConfig.Hooks = nil
+		// not queued for the end of the quiet hours (it would then be forwarded for real)
+		testConfig.QuietQueue = nil
+		// not watched (the end of the window would fix the incident for real)
+		testConfig.Stability = nil
+		// not held until its probe succeeds (the incident would then be resolved for real)
+		testConfig.Probe = nil
+		// not counted in the flapping notes of the component
+		testConfig.Occurrences = nil
+	}
+
+	result := TestReport{
+		DryRun:     test.DryRun,
+		Components: make([]string, 0),
+	}
+	report, err := ProcessAlerts(&testConfig, alerts)
+	for _, alert := range report.Alerts {
+		result.Components = append(result.Components, alert.Components...)
+	}
+	result.Actions = recorder.Actions()
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// reportWindow reads the from/to query parameters (RFC3339 dates, default: the last 30 days),
+// and answers with an error if they are wrong
+func reportWindow(c *gin.Context) (time.Time, time.Time, bool) {
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		var err error
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			answerProblem(c, http.StatusBadRequest, PROBLEM_INVALID_PARAMETER, fmt.Sprintf("wrong 'to' date: %v", err))
+			return time.Time{}, to, false
+		}
+	}
+	from := to.AddDate(0, 0, -30)
+	if value := c.Query("from"); value != "" {
+		var err error
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			answerProblem(c, http.StatusBadRequest, PROBLEM_INVALID_PARAMETER, fmt.Sprintf("wrong 'from' date: %v", err))
+			return from, to, false
+		}
+	}
+	// the future is not known yet
+	if now := time.Now().UTC(); to.After(now) {
+		to = now
+	}
+	if !from.Before(to) {
+		answerProblem(c, http.StatusBadRequest, PROBLEM_INVALID_PARAMETER, "'from' must be before 'to'")
+		return from, to, false
+	}
+	return from.UTC(), to.UTC(), true
+}
+
+// uptimeReport builds the uptime report of the request, and answers with an error if it fails
+func uptimeReport(c *gin.Context, config *PrometheusCachetConfig) (*UptimeReport, bool) {
+	if !checkAuthorization(c, config) {
+		return nil, false
+	}
+
+	from, to, ok := reportWindow(c)
+	if !ok {
+		return nil, false
+	}
+
+	report, err := BuildUptimeReport(config.Cachet, config.CachetLocation, from, to, c.Query("component"))
+	if err != nil {
+		if config.debug() {
 			log.Println(err)
 		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		answerProblem(c, cachetErrorStatus(config, err), PROBLEM_CACHETHQ, err.Error())
+		return nil, false
+	}
+	return report, true
+}
+
+// GetUptime returns the availability of the components over a window, computed from their CachetHQ incidents
+func GetUptime(c *gin.Context, config *PrometheusCachetConfig) {
+	if report, ok := uptimeReport(c, config); ok {
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// GetRuntimeConfig returns the effective configuration, the secrets redacted (an admin endpoint)
+func GetRuntimeConfig(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAdminAuthorization(c, config) {
+		return
+	}
+	if config.RuntimeConfig == nil {
+		answerProblem(c, http.StatusNotFound, PROBLEM_DISABLED, "the runtime configuration is not known")
+		return
+	}
+	c.JSON(http.StatusOK, config.RuntimeConfig)
+}
+
+// GetComponents returns the last known components and their status, without calling CachetHQ
+func GetComponents(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAuthorization(c, config) {
+		return
+	}
+	if config.Components == nil {
+		answerProblem(c, http.StatusNotFound, PROBLEM_DISABLED, "the components cache is disabled")
+		return
+	}
+
+	components, refreshedAt := config.Components.Components()
+	answer := gin.H{"components": components}
+	if !refreshedAt.IsZero() {
+		answer["refreshed_at"] = refreshedAt.UTC().Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, answer)
+}
+
+// GetRetiredComponents returns the synced components whose targets are gone (cf component_sync_retire_after)
+func GetRetiredComponents(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAuthorization(c, config) {
+		return
+	}
+	if config.ComponentSync == nil || config.ComponentSync.RetireAfter <= 0 {
+		answerProblem(c, http.StatusNotFound, PROBLEM_DISABLED, "the retirement of the components is disabled")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"retired": config.ComponentSync.Retired()})
+}
+
+// GetDowntimeReport returns the outages of the components over a window, in JSON or in CSV (format=csv)
+func GetDowntimeReport(c *gin.Context, config *PrometheusCachetConfig) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		answerProblem(c, http.StatusBadRequest, PROBLEM_INVALID_PARAMETER, "format must be json or csv")
+		return
+	}
+
+	report, ok := uptimeReport(c, config)
+	if !ok {
+		return
+	}
+	rows := report.DowntimeRows()
+
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"from": report.From, "to": report.To, "incidents": rows})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=downtime-%s-%s.csv", report.From.Format("20060102"), report.To.Format("20060102")))
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/csv")
+	if err := WriteDowntimeCSV(c.Writer, rows); err != nil {
+		log.Println("not able to write the downtime report:", err)
+	}
+}
+
+// GetHistory returns the last processed alerts (impacting a component, if requested), most recent first
+func GetHistory(c *gin.Context, config *PrometheusCachetConfig) {
+	// the labels of the alerts are not public
+	if !checkAdminAuthorization(c, config) {
+		return
+	}
+
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			answerProblem(c, http.StatusBadRequest, PROBLEM_INVALID_PARAMETER, "limit must be a positive number")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alerts": config.History.Entries(c.Query("component"), limit)})
+}
+
+// PauseForwarding pauses the writes to CachetHQ (the payloads are queued until ResumeForwarding)
+func PauseForwarding(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAdminAuthorization(c, config) {
+		return
+	}
+
+	config.Forwarding.Pause(time.Now())
+	log.Println("forwarding to CachetHQ paused")
+	c.JSON(http.StatusOK, config.Forwarding.Status())
+}
+
+// ResumeForwarding replays the payloads queued while paused, in order, and resumes the writes to CachetHQ
+func ResumeForwarding(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAdminAuthorization(c, config) {
+		return
+	}
+
+	dropped := config.Forwarding.Status().Dropped
+	replayed, failed := replayForwarding(config)
+	log.Println("forwarding to CachetHQ resumed,", replayed, "payloads replayed")
+	c.JSON(http.StatusOK, gin.H{"status": config.Forwarding.Status(), "replayed": replayed, "failed": failed, "dropped": dropped})
+}
+
+// AddSilence silences a component until a date ({"component": "API", "until": "<rfc3339>"})
+func AddSilence(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAdminAuthorization(c, config) {
+		return
+	}
+	if config.Silences == nil {
+		answerProblem(c, http.StatusNotFound, PROBLEM_DISABLED, "the silences are disabled")
+		return
+	}
+
+	var silence Silence
+	if err := c.ShouldBindJSON(&silence); err != nil {
+		answerInvalidPayload(c, decodingError(err))
+		return
+	}
+	if !silence.Until.After(time.Now()) {
+		answerProblem(c, http.StatusBadRequest, PROBLEM_INVALID_PAYLOAD, "until must be in the future")
+		return
+	}
+	config.Silences.Add(silence)
+	log.Println("component", silence.Component, "silenced until", silence.Until)
+	c.JSON(http.StatusOK, gin.H{"silences": config.Silences.List(time.Now())})
+}
+
+// ListSilences answers the silenced components
+func ListSilences(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAdminAuthorization(c, config) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"silences": config.Silences.List(time.Now())})
+}
+
+// RemoveSilence stops the silence of a component
+func RemoveSilence(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAdminAuthorization(c, config) {
+		return
+	}
+	if config.Silences == nil || !config.Silences.Remove(c.Param("component")) {
+		answerProblem(c, http.StatusNotFound, PROBLEM_NOT_FOUND, "component not silenced")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"silences": config.Silences.List(time.Now())})
+}
+
+// SetLogLevel changes the log level at runtime ({"level": "debug"}), and answers it
+func SetLogLevel(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAdminAuthorization(c, config) {
+		return
+	}
+
+	if c.Request.Method == http.MethodPut {
+		var request struct {
+			Level string `json:"level" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			answerInvalidPayload(c, decodingError(err))
+			return
+		}
+		level, err := ParseLogLevel(request.Level)
+		if err != nil {
+			answerProblem(c, http.StatusBadRequest, PROBLEM_INVALID_PARAMETER, err.Error())
+			return
+		}
+		config.setLogLevel(level)
+		log.Println("log level:", request.Level)
+	}
+	c.JSON(http.StatusOK, gin.H{"level": LogLevelName(atomic.LoadInt32(&config.LogLevel))})
+}
+
+// SetMaintenance enables (or disables) the maintenance mode, and answers its state
+func SetMaintenance(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAdminAuthorization(c, config) {
+		return
+	}
+	if config.Maintenance == nil {
+		answerProblem(c, http.StatusNotFound, PROBLEM_DISABLED, "the maintenance mode is disabled")
+		return
+	}
+
+	if c.Request.Method == http.MethodPut {
+		var status MaintenanceStatus
+		if err := c.ShouldBindJSON(&status); err != nil {
+			answerInvalidPayload(c, decodingError(err))
+			return
+		}
+		config.Maintenance.Set(status.Enabled, status.PrivateIncidents, time.Now())
+		log.Println("maintenance mode:", status.Enabled)
+	}
+	c.JSON(http.StatusOK, config.Maintenance.Status())
+}
+
+// StreamEvents streams the events of the bridge (webhooks received, incidents, errors) as Server-Sent Events
+func StreamEvents(c *gin.Context, config *PrometheusCachetConfig) {
+	if !checkAuthorization(c, config) {
+		return
+	}
+	if config.Events == nil {
+		answerProblem(c, http.StatusNotFound, PROBLEM_DISABLED, "events are disabled")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	events := config.Events.Subscribe()
+	defer config.Events.Unsubscribe(events)
+
+	keepalive := time.NewTicker(EVENTS_KEEPALIVE)
+	defer keepalive.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	// the headers are sent right away, the client knows it is subscribed
+	io.WriteString(c.Writer, ": subscribed\n\n")
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-keepalive.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case event := <-events:
+			c.SSEvent(event.Type, event)
+			return true
+		}
+	})
+}
+
+// WithWriteTimeout bounds the time to answer a request (as the http.Server WriteTimeout), except for the /events streams
+// and the pprof profiles
+func WithWriteTimeout(handler http.Handler, timeout time.Duration) http.Handler {
+	bounded := http.TimeoutHandler(handler, timeout, "")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the streams, and the profiles (30s by default)
+		if r.URL.Path == "/events" || strings.HasPrefix(r.URL.Path, "/debug/pprof/") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		bounded.ServeHTTP(w, r)
+	})
 }
 
+// PrepareGinRouter returns the router of the endpoints. With config.AdminListen, it only serves the ingestion
+// endpoints (and the status snapshot), the other ones being served by PrepareAdminRouter on the admin listener
 func PrepareGinRouter(config *PrometheusCachetConfig) *gin.Engine {
+	router := newRouter(config)
+	addIngestionRoutes(router, config)
+	if config.AdminListen == "" {
+		addAdminRoutes(router, config)
+	}
+	return router
+}
+
+// PrepareAdminRouter returns the router of the admin listener: the health, admin, metrics and pprof endpoints
+func PrepareAdminRouter(config *PrometheusCachetConfig) *gin.Engine {
+	router := newRouter(config)
+	addAdminRoutes(router, config)
+	pprof := router.Group("/debug/pprof", func(c *gin.Context) {
+		if !checkAdminAuthorization(c, config) {
+			c.Abort()
+		}
+	})
+	pprof.GET("/*profile", func(c *gin.Context) {
+		switch profile := strings.TrimPrefix(c.Param("profile"), "/"); profile {
+		case "":
+			httppprof.Index(c.Writer, c.Request)
+		case "cmdline":
+			httppprof.Cmdline(c.Writer, c.Request)
+		case "profile":
+			httppprof.Profile(c.Writer, c.Request)
+		case "symbol":
+			httppprof.Symbol(c.Writer, c.Request)
+		case "trace":
+			httppprof.Trace(c.Writer, c.Request)
+		default:
+			httppprof.Handler(profile).ServeHTTP(c.Writer, c.Request)
+		}
+	})
+	return router
+}
+
+func newRouter(config *PrometheusCachetConfig) *gin.Engine {
+	useJSONFieldNames()
 	router := gin.New()
-	router.Use(gin.LoggerWithWriter(gin.DefaultWriter, "/health"))
+	if config.TrustedProxies != nil {
+		router.Use(TrustedProxiesMiddleware(config.TrustedProxies))
+	}
+	if config.AccessLogger != nil {
+		router.Use(config.AccessLogger)
+	} else {
+		router.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: redactAccessLog(ginAccessLog), Output: gin.DefaultWriter, SkipPaths: splitList(ACCESS_LOG_SKIP_PATHS)}))
+	}
 	router.Use(gin.Recovery())
+	if config.CORS != nil {
+		router.Use(CORSMiddleware(config.CORS))
+	}
+	return router
+}
+
+// addIngestionRoutes adds the endpoints receiving the alerts
+func addIngestionRoutes(router *gin.Engine, config *PrometheusCachetConfig) {
+	alertHandlers := []gin.HandlerFunc{}
+	if config.RateLimiter != nil {
+		alertHandlers = append(alertHandlers, RateLimitMiddleware(config.RateLimiter))
+	}
+
+	router.POST("/alert", append(alertHandlers, func(c *gin.Context) {
+		SubmitAlert(c, config)
+	})...)
 
+	router.POST("/inputs/:adapter", append(alertHandlers, func(c *gin.Context) {
+		SubmitInput(c, config)
+	})...)
+
+	if config.Snapshot != nil {
+		router.GET(config.Snapshot.path, config.Snapshot.Serve)
+	}
+}
+
+// addAdminRoutes adds the other endpoints: health, reports, admin and metrics
+func addAdminRoutes(router *gin.Engine, config *PrometheusCachetConfig) {
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "OK"})
 	})
 
-	router.POST("/alert", func(c *gin.Context) {
-		SubmitAlert(c, config)
+	router.GET("/ready", func(c *gin.Context) {
+		if config.Watchdog != nil && !config.Watchdog.Ready() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "CachetHQ unreachable"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
 	})
 
-	return router
+	router.POST("/test", func(c *gin.Context) {
+		SubmitTestAlert(c, config)
+	})
+
+	router.GET("/uptime", func(c *gin.Context) {
+		GetUptime(c, config)
+	})
+
+	router.GET("/components", func(c *gin.Context) {
+		GetComponents(c, config)
+	})
+
+	router.GET("/reports/downtime", func(c *gin.Context) {
+		GetDowntimeReport(c, config)
+	})
+
+	router.GET("/reports/retired", func(c *gin.Context) {
+		GetRetiredComponents(c, config)
+	})
+
+	router.GET("/events", func(c *gin.Context) {
+		StreamEvents(c, config)
+	})
+
+	router.GET("/config", func(c *gin.Context) {
+		GetRuntimeConfig(c, config)
+	})
+
+	router.GET("/admin/history", func(c *gin.Context) {
+		GetHistory(c, config)
+	})
+
+	router.POST("/admin/silence", func(c *gin.Context) {
+		AddSilence(c, config)
+	})
+
+	router.GET("/admin/silence", func(c *gin.Context) {
+		ListSilences(c, config)
+	})
+
+	router.DELETE("/admin/silence/:component", func(c *gin.Context) {
+		RemoveSilence(c, config)
+	})
+
+	router.GET("/admin/loglevel", func(c *gin.Context) {
+		SetLogLevel(c, config)
+	})
+
+	router.PUT("/admin/loglevel", func(c *gin.Context) {
+		SetLogLevel(c, config)
+	})
+
+	router.GET("/admin/maintenance", func(c *gin.Context) {
+		SetMaintenance(c, config)
+	})
+
+	router.PUT("/admin/maintenance", func(c *gin.Context) {
+		SetMaintenance(c, config)
+	})
+
+	router.POST("/admin/pause", func(c *gin.Context) {
+		PauseForwarding(c, config)
+	})
+
+	router.POST("/admin/resume", func(c *gin.Context) {
+		ResumeForwarding(c, config)
+	})
+
+	if config.Metrics != nil {
+		metrics := config.Metrics.Handler()
+		router.GET("/metrics", func(c *gin.Context) {
+			if checkMetricsAuthorization(c, config) {
+				metrics.ServeHTTP(c.Writer, c.Request)
+			}
+		})
+	}
 }
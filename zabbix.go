@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// zabbixEvent is the payload posted by the Zabbix webhook media type (cf contrib/zabbix)
+type zabbixEvent struct {
+	EventID      string `json:"event_id"`
+	EventValue   string `json:"event_value"` // 1 for a problem, 0 for its recovery
+	UpdateStatus string `json:"event_update_status"`
+	EventName    string `json:"event_name"`
+	Severity     string `json:"event_severity"`
+	Host         string `json:"host"`
+	// {EVENT.TAGSJSON}, as a json array or as a string
+	Tags json.RawMessage `json:"event_tags"`
+}
+
+type zabbixTag struct {
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+func (e *zabbixEvent) tags() []zabbixTag {
+	tags := make([]zabbixTag, 0)
+	raw := []byte(e.Tags)
+	var encoded string
+	if json.Unmarshal(raw, &encoded) == nil {
+		raw = []byte(encoded)
+	}
+	json.Unmarshal(raw, &tags)
+	return tags
+}
+
+// ZabbixAdapter translates the Zabbix problem (and recovery) events
+type ZabbixAdapter struct{}
+
+// Parse translates a problem (or recovery) event, the impacted component being the cachet_component tag, or the host
+func (a *ZabbixAdapter) Parse(config *PrometheusCachetConfig, body []byte) (*PrometheusAlert, error) {
+	var event zabbixEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	if event.EventID == "" || (event.EventValue != "0" && event.EventValue != "1") {
+		return nil, fmt.Errorf("event_id and event_value (0 or 1) are required")
+	}
+	// the acknowledges (and other updates) of a problem
+	if event.UpdateStatus == "1" {
+		return nil, nil
+	}
+
+	component := event.Host
+	for _, tag := range event.tags() {
+		if tag.Tag == INPUT_COMPONENT_TAG {
+			component = tag.Value
+		}
+	}
+	if component == "" {
+		return nil, fmt.Errorf("no host nor %s tag", INPUT_COMPONENT_TAG)
+	}
+
+	// a recovery event has the id of its problem event
+	alerts := newInputAlert(config, "zabbix", event.EventValue == "1", component, event.EventID)
+	alert := &alerts.Alerts[0]
+	if event.Severity != "" {
+		alert.Labels[LABEL_SEVERITY] = strings.ToLower(event.Severity)
+	}
+	if event.EventName != "" {
+		alert.Annotations[ANNOTATION_SUMMARY] = event.EventName
+	}
+	return alerts, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZabbixAdapter(t *testing.T) {
+	config := &PrometheusCachetConfig{LabelName: "alertname"}
+	adapter := &ZabbixAdapter{}
+
+	alerts, err := adapter.Parse(config, []byte(`{"event_id":"42","event_value":"1","event_name":"High CPU","event_severity":"High","host":"web01",
+		"event_tags":"[{\"tag\":\"cachet_component\",\"value\":\"API\"}]"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "firing", alerts.Status)
+	assert.Equal(t, "zabbix", alerts.Receiver)
+	assert.Equal(t, map[string]string{"alertname": "API", "source": "zabbix", "severity": "high"}, alerts.Alerts[0].Labels)
+	assert.Equal(t, "High CPU", alerts.Alerts[0].Annotations[ANNOTATION_SUMMARY])
+	assert.Equal(t, "zabbix:42", alerts.Alerts[0].fingerprint())
+
+	// the recovery resolves the same alert
+	alerts, err = adapter.Parse(config, []byte(`{"event_id":"42","event_value":"0","host":"web01","event_tags":[]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "resolved", alerts.Status)
+	assert.Equal(t, "web01", alerts.Alerts[0].Labels["alertname"])
+	assert.Equal(t, "zabbix:42", alerts.Alerts[0].fingerprint())
+
+	// acknowledges are ignored
+	alerts, err = adapter.Parse(config, []byte(`{"event_id":"42","event_value":"1","event_update_status":"1","host":"web01"}`))
+	assert.Nil(t, err)
+	assert.Nil(t, alerts)
+
+	_, err = adapter.Parse(config, []byte(`{"event_id":"42","event_value":"{EVENT.VALUE}","host":"web01"}`))
+	assert.NotNil(t, err)
+}
+
+func TestInputEndpoint(t *testing.T) {
+	fake := NewFakeCachet([]string{"API"})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	config := &PrometheusCachetConfig{
+		PrometheusToken: "promToken",
+		LabelName:       "alertname",
+		Cachet:          NewCachetImpl(ts.URL, "token", ts.Client()),
+		SquashIncident:  true,
+	}
+	router := PrepareGinRouter(config)
+	send := func(path, payload string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(payload))
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, 401, send("/inputs/zabbix", `{"event_id":"1","event_value":"1","host":"API"}`))
+	assert.Equal(t, 200, send("/inputs/zabbix?token=promToken", `{"event_id":"1","event_value":"1","host":"API"}`))
+	assert.Equal(t, 1, len(fake.incidents))
+	assert.Equal(t, 200, send("/inputs/zabbix?token=promToken", `{"event_id":"1","event_value":"0","host":"API"}`))
+	assert.Equal(t, 4, fake.incidents[0].Status)
+	assert.Equal(t, 404, send("/inputs/unknown?token=promToken", `{}`))
+}